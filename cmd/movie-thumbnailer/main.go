@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -11,8 +13,11 @@ import (
 
 	"github.com/pandino/movie-thumbnailer-go/internal/config"
 	"github.com/pandino/movie-thumbnailer-go/internal/database"
+	"github.com/pandino/movie-thumbnailer-go/internal/ffmpeg"
+	"github.com/pandino/movie-thumbnailer-go/internal/httpclient"
 	"github.com/pandino/movie-thumbnailer-go/internal/scanner"
 	"github.com/pandino/movie-thumbnailer-go/internal/server"
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
 	"github.com/pandino/movie-thumbnailer-go/internal/worker"
 	"github.com/sirupsen/logrus"
 )
@@ -29,6 +34,10 @@ func main() {
 	// Define all command-line flags
 	importFlag := flag.Bool("import-existing", false, "Import existing thumbnails without recreating them")
 	versionFlag := flag.Bool("version", false, "Print version information and exit")
+	migrateFlag := flag.Bool("migrate", false, "Run database migrations at startup before serving")
+	regenerateFlag := flag.Bool("regenerate", false, "Regenerate the thumbnails matching --filter with one scan, then exit")
+	filterFlag := flag.String("filter", "", "Thumbnails to regenerate, such as \"source=imported\" or \"width_below=1280,viewed=false\"")
+	dryRunFlag := flag.Bool("dry-run", false, "With --regenerate, only list the thumbnails that would be regenerated")
 
 	// Parse all flags once
 	flag.Parse()
@@ -48,12 +57,21 @@ func main() {
 
 	// Load configuration
 	cfg := config.New()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	for _, warning := range cfg.Warnings() {
+		log.Warn(warning)
+	}
 
 	// Override config with command-line flags if provided
 	if *importFlag {
 		cfg.ImportExisting = true
 		log.Info("Import existing thumbnails mode enabled")
 	}
+	if *migrateFlag {
+		cfg.RunMigrations = true
+	}
 
 	if cfg.Debug {
 		log.SetLevel(logrus.DebugLevel)
@@ -63,25 +81,90 @@ func main() {
 
 	log.Info("Starting Movie Thumbnailer")
 	log.Infof("Version: %s (commit: %s, built: %s)", version, commit, buildDate)
+	ffmpeg.AppVersion = version
 	log.Debugf("Configuration: Movies=%v, Thumbnails=%s, Data=%s",
 		cfg.MoviesDirs, cfg.ThumbnailsDir, cfg.DataDir)
 
+	// Report the grid size, which drives the size of each thumbnail on disk
+	layout := ffmpeg.ConfiguredLayout(cfg)
+	log.WithFields(logrus.Fields{
+		"grid":   fmt.Sprintf("%dx%d", layout.Cols, layout.Rows),
+		"tile":   fmt.Sprintf("%dx%d", layout.TileWidth, layout.TileHeight),
+		"pixels": layout.Pixels(),
+	}).Infof("Thumbnail grids are %dx%d px", layout.Width(), layout.Height())
+
 	// Create directories
-	createDirIfNotExists(cfg.ThumbnailsDir, log)
+	if cfg.StorageBackend == config.StorageLocal {
+		createDirIfNotExists(cfg.ThumbnailsDir, log)
+	}
 	createDirIfNotExists(cfg.DataDir, log)
 
+	// Fail fast on permission problems instead of failing every movie
+	if err := cfg.CheckMoviesReadable(); err != nil {
+		log.Fatalf("Startup check failed: %v", err)
+	}
+	if err := cfg.CheckThumbnailsWritable(); err != nil {
+		log.Fatalf("Startup check failed: %v", err)
+	}
+
+	// Initialize thumbnail storage
+	store, err := storage.New(cfg, httpclient.New(cfg.HTTPClientTimeout, httpclient.UserAgent(version)))
+	if err != nil {
+		log.Fatalf("Failed to initialize thumbnail storage: %v", err)
+	}
+	log.Infof("Using %s thumbnail storage", cfg.StorageBackend)
+
 	// Initialize database
-	db, err := database.New(cfg.DBPath)
+	db, err := database.NewWithRetry(cfg.DBPath, database.OpenOptions{
+		Retries:     cfg.DBOpenRetries,
+		Delay:       cfg.DBOpenRetryDelay,
+		CreateDir:   true,
+		BusyTimeout: cfg.DBBusyTimeout,
+		OnRetry: func(attempt int, err error, wait time.Duration) {
+			log.WithError(err).WithField("db", cfg.DBPath).Warnf("Failed to open database (attempt %d of %d), retrying in %s",
+				attempt, cfg.DBOpenRetries+1, wait)
+		},
+	})
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
+	db.SetStatsCacheTTL(cfg.StatsCacheTTL)
+
+	// Rows still pending were interrupted by a crash or restart
+	if reset, err := db.ResetPendingToRetry(); err != nil {
+		log.WithError(err).Warn("Failed to reset interrupted thumbnails")
+	} else if reset > 0 {
+		log.Infof("Reset %d thumbnails interrupted by a previous run; the next scan regenerates them", reset)
+	}
+
+	// Run database migrations if requested
+	if cfg.RunMigrations {
+		log.Info("Running database migrations")
+		if err := db.Migrate(); err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		result, err := db.BackfillFileSizes(cfg.MoviesDirs)
+		if err != nil {
+			log.Fatalf("Failed to backfill file sizes: %v", err)
+		}
+		log.Infof("Migration completed: %d records, %d file sizes updated, %d missing, %d errors",
+			result.Total, result.Updated, result.Missing, result.Errors)
+	}
+
+	// Batch regeneration runs instead of the server
+	if *regenerateFlag {
+		if err := runRegenerate(cfg, db, store, log, *filterFlag, *dryRunFlag); err != nil {
+			log.Fatalf("Regeneration failed: %v", err)
+		}
+		return
+	}
 
 	// Setup context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	// Initialize scanner
-	s := scanner.New(cfg, db, log, nil) // Will be updated with metrics later
+	s := scanner.New(cfg, db, store, log, nil) // Will be updated with metrics later
 
 	// Create version info
 	versionInfo := &server.VersionInfo{
@@ -91,10 +174,11 @@ func main() {
 	}
 
 	// Initialize HTTP server
-	srv := server.New(cfg, db, s, log, ctx, versionInfo)
+	srv := server.New(cfg, db, store, s, log, ctx, versionInfo)
+	srv.GetMetrics().RegisterRandomFallbacks(database.RandomFallbacks)
 
 	// Update scanner with metrics - recreate scanner with metrics
-	s = scanner.New(cfg, db, log, srv.GetMetrics())
+	s = scanner.New(cfg, db, store, log, srv.GetMetrics())
 
 	// Update server's scanner reference
 	srv.UpdateScanner(s)
@@ -114,6 +198,13 @@ func main() {
 		}
 	}()
 
+	// Move existing flat thumbnails into the mirrored layout
+	if cfg.MirrorStructure {
+		if _, err := s.RelocateThumbnails(ctx); err != nil {
+			log.Errorf("Failed to relocate thumbnails: %v", err)
+		}
+	}
+
 	// Initialize background worker
 	w := worker.New(cfg, s, log, srv.GetMetrics())
 
@@ -122,7 +213,7 @@ func main() {
 
 	// Start HTTP server
 	go func() {
-		if err := srv.Start(); err != nil {
+		if err := srv.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
@@ -135,7 +226,7 @@ func main() {
 	<-quit
 	log.Info("Shutting down...")
 
-	// Stop the background worker
+	// Stop the background worker and interrupt any running scan
 	cancel()
 
 	// Shutdown HTTP server
@@ -145,6 +236,19 @@ func main() {
 		log.Errorf("Server shutdown failed: %v", err)
 	}
 
+	// Let an interrupted scan save its last records and remove its temporary files
+	stopped := make(chan struct{})
+	go func() {
+		w.Wait()
+		s.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(cfg.ShutdownTimeout):
+		log.Warnf("Background tasks did not stop within %s, exiting anyway", cfg.ShutdownTimeout)
+	}
+
 	log.Info("Shutdown complete")
 }
 
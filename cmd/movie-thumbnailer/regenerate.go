@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/config"
+	"github.com/pandino/movie-thumbnailer-go/internal/database"
+	"github.com/pandino/movie-thumbnailer-go/internal/scanner"
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// runRegenerate regenerates the thumbnails matching filterExpr with a single scan,
+// or with dryRun only lists them
+func runRegenerate(cfg *config.Config, db *database.DB, store storage.Storage, log *logrus.Logger, filterExpr string, dryRun bool) error {
+	if filterExpr == "" {
+		return fmt.Errorf("--regenerate needs a --filter; use --filter status=success to regenerate every thumbnail")
+	}
+	filter, err := database.ParseThumbnailFilter(filterExpr)
+	if err != nil {
+		return fmt.Errorf("invalid --filter: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s := scanner.New(cfg, db, store, log, nil)
+	candidates, err := s.RegenerationCandidates(filter)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		for _, thumbnail := range candidates {
+			fmt.Printf("%s\t%s\t%s\t%dx%d\n", thumbnail.MoviePath, thumbnail.Status, thumbnail.Source, thumbnail.Width, thumbnail.Height)
+		}
+		fmt.Printf("%d thumbnails would be regenerated\n", len(candidates))
+		return nil
+	}
+
+	if len(candidates) == 0 {
+		log.Info("No thumbnails match the filter, nothing to regenerate")
+		return nil
+	}
+	if _, err := s.ResetForRegeneration(ctx, candidates); err != nil {
+		return err
+	}
+
+	// Regeneration must not process the archive and deletion queues
+	result, err := s.ScanMoviesWithOptions(ctx, scanner.ScanOptions{Type: scanner.ScanTypeRegenerate, SkipCleanup: true})
+	if err != nil {
+		return fmt.Errorf("scan failed: %w", err)
+	}
+	log.WithFields(logrus.Fields{
+		"generated": result.Generated,
+		"errors":    result.Errors,
+		"duration":  result.Duration,
+	}).Info("Regeneration completed")
+	return nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AutoDeleteCriteria selects the movies the slideshow stages for deletion once they
+// have been viewed. A movie has to match every criterion that is set.
+type AutoDeleteCriteria struct {
+	SizeBelow     int64 // Movie file size in bytes
+	DurationBelow time.Duration
+	WidthBelow    int
+	HeightBelow   int
+}
+
+// IsEmpty reports whether no criterion is set, which turns automatic deletion off
+func (c AutoDeleteCriteria) IsEmpty() bool {
+	return c == AutoDeleteCriteria{}
+}
+
+// ParseAutoDeleteCriteria parses AUTO_DELETE_CRITERIA, a comma-separated list of
+// size_below, duration_below, width_below and height_below terms such as
+// "size_below=200M,duration_below=2m". An empty expression sets no criteria.
+func ParseAutoDeleteCriteria(expr string) (AutoDeleteCriteria, error) {
+	var criteria AutoDeleteCriteria
+	for _, term := range strings.Split(expr, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		key, value, found := strings.Cut(term, "=")
+		if !found {
+			return criteria, fmt.Errorf("expected key=value, got %q", term)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		var err error
+		switch key {
+		case "size_below":
+			criteria.SizeBelow, err = parseByteSize(value)
+			if err == nil && criteria.SizeBelow == 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "duration_below":
+			criteria.DurationBelow, err = time.ParseDuration(value)
+			if err == nil && criteria.DurationBelow <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "width_below", "height_below":
+			var n int
+			n, err = strconv.Atoi(value)
+			if err == nil && n <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+			if key == "width_below" {
+				criteria.WidthBelow = n
+			} else {
+				criteria.HeightBelow = n
+			}
+		default:
+			return criteria, fmt.Errorf("unknown criterion %q", key)
+		}
+		if err != nil {
+			return criteria, fmt.Errorf("invalid %s %q: %w", key, value, err)
+		}
+	}
+	return criteria, nil
+}
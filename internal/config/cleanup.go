@@ -0,0 +1,36 @@
+package config
+
+import "fmt"
+
+// Cleanup phases selectable and ordered with CLEANUP_PHASES
+const (
+	CleanupArchive = "archive" // Move movies queued for archival to ARCHIVE_DIR
+	CleanupQueue   = "queue"   // Delete movies queued for deletion
+	CleanupMissing = "missing" // Remove the records of movies no longer on disk
+	CleanupOrphans = "orphans" // Remove thumbnails without a record
+)
+
+// DefaultCleanupPhases are the phases a cleanup runs, in order, unless CLEANUP_PHASES
+// is set
+var DefaultCleanupPhases = []string{CleanupArchive, CleanupQueue, CleanupMissing, CleanupOrphans}
+
+// ValidateCleanupPhases checks that phases is a non-empty list of known cleanup
+// phases, each given once
+func ValidateCleanupPhases(phases []string) error {
+	if len(phases) == 0 {
+		return fmt.Errorf("no cleanup phases given")
+	}
+	seen := make(map[string]bool, len(phases))
+	for _, phase := range phases {
+		switch phase {
+		case CleanupArchive, CleanupQueue, CleanupMissing, CleanupOrphans:
+		default:
+			return fmt.Errorf("unknown cleanup phase %q, want %q, %q, %q or %q", phase, CleanupArchive, CleanupQueue, CleanupMissing, CleanupOrphans)
+		}
+		if seen[phase] {
+			return fmt.Errorf("cleanup phase %q given twice", phase)
+		}
+		seen[phase] = true
+	}
+	return nil
+}
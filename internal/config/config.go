@@ -1,43 +1,252 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// Config holds the application configuration
+// Storage backends selectable with STORAGE_BACKEND
+const (
+	StorageLocal = "local"
+	StorageS3    = "s3"
+)
+
+// CleanupInterval is the time between scheduled cleanups
+const CleanupInterval = 6 * time.Hour
+
+// Slideshow orders selectable with SLIDESHOW_ORDER
+const (
+	SlideshowRandom   = "random"
+	SlideshowLRU      = "lru"
+	SlideshowRecorded = "recorded"
+)
+
+// Near-duplicate frame filters selectable with DEDUP_FRAMES
+const (
+	DedupOff        = "off"
+	DedupMpdecimate = "mpdecimate"
+	DedupScene      = "scene"
+)
+
+// Default size of a thumbnail grid tile, in pixels
+const (
+	DefaultThumbCellWidth  = 320
+	DefaultThumbCellHeight = 180
+)
+
+// JPEG quality range of ffmpeg's MJPEG encoder (-q:v), and the default
+const (
+	MinJPEGQuality     = 2
+	MaxJPEGQuality     = 31
+	DefaultJPEGQuality = 3
+)
+
+// Config holds the application configuration. The json tags name the fields in
+// Effective, which redacts those tagged secret.
 type Config struct {
 	// Directory paths
-	MoviesDirs    []string
-	ThumbnailsDir string
-	DataDir       string
-	ArchiveDir    string
-	DBPath        string
-	TemplatesDir  string
-	StaticDir     string
+	MoviesDirs    []string `json:"movies_dirs"`
+	ThumbnailsDir string   `json:"thumbnails_dir"`
+	DataDir       string   `json:"data_dir"`
+	ArchiveDir    string   `json:"archive_dir"`
+	DBPath        string   `json:"db_path"`
+	BackupDir     string   `json:"backup_dir"`
+	TemplatesDir  string   `json:"templates_dir"`
+	StaticDir     string   `json:"static_dir"`
+
+	// Thumbnail layout
+	MirrorStructure bool `json:"mirror_structure"`
+
+	// Thumbnail storage backend
+	StorageBackend    string `json:"storage_backend"`
+	S3Bucket          string `json:"s3_bucket"`
+	S3Region          string `json:"s3_region"`
+	S3Endpoint        string `json:"s3_endpoint"`
+	S3Prefix          string `json:"s3_prefix"`
+	S3AccessKeyID     string `json:"s3_access_key_id" secret:"true"`
+	S3SecretAccessKey string `json:"s3_secret_access_key" secret:"true"`
+	S3PathStyle       bool   `json:"s3_path_style"`
 
 	// Thumbnail generation
-	GridCols       int
-	GridRows       int
-	MaxWorkers     int
-	FileExtensions []string
+	GridCols        int      `json:"grid_cols"`
+	GridRows        int      `json:"grid_rows"`
+	MaxWorkers      int      `json:"max_workers"`
+	FileExtensions  []string `json:"file_extensions"`
+	FFmpegProgress  bool     `json:"ffmpeg_progress"`
+	ProgressiveJPEG bool     `json:"progressive_jpeg"`
+	MaxGridPixels   int      `json:"max_grid_pixels"` // Upper bound on grid width*height; 0 disables the limit
+
+	// Size of each grid tile in pixels; see ThumbCellSize
+	ThumbCellWidth  int `json:"thumb_cell_width"`
+	ThumbCellHeight int `json:"thumb_cell_height"`
+
+	// JPEG quality as an ffmpeg qscale, from 2 (best) to 31 (smallest). GridQuality
+	// and PosterQuality fall back to ThumbnailQuality when 0.
+	ThumbnailQuality int `json:"thumbnail_quality"`
+	GridQuality      int `json:"grid_quality"`
+	PosterQuality    int `json:"poster_quality"`
+
+	// Adjust the number of workers between MinWorkers and MaxWorkers during a scan
+	AdaptiveWorkers bool `json:"adaptive_workers"`
+	MinWorkers      int  `json:"min_workers"`
+
+	// Process the most recently modified movies first during a scan
+	ProcessNewestFirst bool `json:"process_newest_first"`
+
+	// Thumbnail audio files (cover art or waveform) and images (resized still)
+	HandleNonVideo bool `json:"handle_non_video"`
+
+	// Directory names or glob patterns pruned from movie directory walks
+	ScanExcludeDirs []string `json:"scan_exclude_dirs"`
+
+	// Also scan the subdirectories of the movie directories
+	RecursiveScan bool `json:"recursive_scan"`
+
+	// Thumbnail storage each movie directory may use, in bytes; 0 disables the quota
+	ThumbnailQuotaPerRoot int64 `json:"thumbnail_quota_per_root"`
+
+	// Sampling window, as a percentage of the movie duration
+	SampleStartPercent int `json:"sample_start_percent"`
+	SampleEndPercent   int `json:"sample_end_percent"`
+
+	// Intro skip of the default sampling window, as a percentage of the movie duration
+	// capped at IntroSkipMax (0 for no cap); 0 percent samples from the start
+	IntroSkipPercent float64       `json:"intro_skip_percent"`
+	IntroSkipMax     time.Duration `json:"intro_skip_max"`
+
+	// Fixed intro skip in seconds replacing IntroSkipPercent when positive; movies
+	// no longer than it are sampled from the start
+	IntroSkipSeconds float64 `json:"intro_skip_seconds"`
+
+	// Seek after opening the movie, decoding up to the sampling window instead of
+	// jumping to the nearest keyframe before it
+	AccurateSeek bool `json:"accurate_seek"`
+
+	// Filter dropping near-duplicate frames before tiling, and the scene change score
+	// (0 to 1) a frame needs to pass the scene filter
+	DedupFrames    string  `json:"dedup_frames"`
+	SceneThreshold float64 `json:"scene_threshold"`
 
 	// Server settings
-	ServerPort string
-	ServerHost string
+	ServerPort string `json:"server_port"`
+	ServerHost string `json:"server_host"`
+	Headless   bool   `json:"headless"` // Serve only the API, thumbnails and metrics, without HTML pages
+
+	// Separate listener for metrics, profiling and the control routes; disabled
+	// when AdminPort is empty
+	AdminHost string `json:"admin_host"`
+	AdminPort string `json:"admin_port"`
+
+	// Serve net/http/pprof under /debug/pprof/
+	EnablePprof bool `json:"enable_pprof"`
+
+	// Answer mutation requests with 503 while the server is in maintenance mode
+	MaintenanceBlockMutations bool `json:"maintenance_block_mutations"`
+
+	// Proxy addresses or CIDR ranges whose X-Forwarded-For header is trusted
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	// Slideshow settings
+	SlideshowOrder string `json:"slideshow_order"`
+	IncludeViewed  bool   `json:"include_viewed"` // Review mode: include viewed thumbnails in random slideshows
+
+	// Show the thumbnail's position among all unviewed thumbnails
+	ShowLibraryPosition bool `json:"show_library_position"`
+
+	// Slideshow sessions idle for longer than this are replaced; 0 disables expiry
+	SessionIdleExpiry time.Duration `json:"session_idle_expiry"`
+
+	// URL notified when every thumbnail has been viewed
+	CaughtUpWebhook string `json:"caught_up_webhook" secret:"true"`
+
+	// Timeout of outbound HTTP requests such as webhooks and S3 storage
+	HTTPClientTimeout time.Duration `json:"http_client_timeout"`
+
+	// On-the-fly thumbnail resizing
+	ThumbnailWidths    []int `json:"thumbnail_widths"`
+	ThumbnailCacheSize int   `json:"thumbnail_cache_size"`
+
+	// Thumbnail files read at the same time; 0 disables the limit
+	ThumbnailServeConcurrency int `json:"thumbnail_serve_concurrency"`
+
+	// Images served in place of the thumbnail of pending and failed movies; none when empty
+	PendingPlaceholder string `json:"pending_placeholder"`
+	ErrorPlaceholder   string `json:"error_placeholder"`
 
 	// Background task settings
-	ScanInterval time.Duration
-	Debug        bool
+	ScanInterval time.Duration `json:"scan_interval"`
+
+	// Random offset, up to this much either way, added to each scheduled scan and
+	// cleanup; the initial scan waits up to ScanJitter
+	ScanJitter    time.Duration `json:"scan_jitter"`
+	CleanupJitter time.Duration `json:"cleanup_jitter"`
+
+	// How long shutdown waits for a running scan to finish after cancelling it
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
+	Debug           bool          `json:"debug"`
 
 	// Deletion worker settings
-	DisableDeletion bool
+	DisableDeletion         bool          `json:"disable_deletion"`
+	DeletionRequireApproval bool          `json:"deletion_require_approval"` // Only delete items approved with POST /api/deletions/approve
+	DeleteMaxAttempts       int           `json:"delete_max_attempts"`
+	DeleteRetryBackoff      time.Duration `json:"delete_retry_backoff"`
+
+	// Cleanup phases run by scans and cleanups, in order, see DefaultCleanupPhases
+	CleanupPhases []string `json:"cleanup_phases"`
+
+	// Viewed movies the slideshow stages for deletion, see ParseAutoDeleteCriteria;
+	// empty turns automatic deletion off
+	AutoDeleteCriteria string `json:"auto_delete_criteria"`
 
 	// Import settings
-	ImportExisting bool
+	ImportExisting bool `json:"import_existing"`
+
+	// Record existing thumbnail files of movies without a record as imported, without
+	// probing the movie for metadata
+	ReuseExistingThumbnails bool `json:"reuse_existing_thumbnails"`
+
+	// Remove the thumbnail of a successful movie whose regeneration fails
+	ClearThumbnailOnError bool `json:"clear_thumbnail_on_error"`
+
+	// Movies modified this recently that ffprobe can't read are taken to be still
+	// downloading and checked again by the next scan; 0 records them as errors
+	IncompleteFileAge time.Duration `json:"incomplete_file_age"`
+
+	// Error rows of movies no longer on disk are pruned once they are older than
+	// this; 0 keeps them
+	ErrorRetention time.Duration `json:"error_retention"`
+
+	// Abort a scan on the first movie that fails instead of moving on to the rest
+	StrictScan bool `json:"strict_scan"`
+
+	// Initial viewed state of movies with no existing record
+	NewFilesViewed bool `json:"new_files_viewed"`
+
+	// Run database migrations at startup
+	RunMigrations bool `json:"run_migrations"`
+
+	// Database open retries
+	DBOpenRetries    int           `json:"db_open_retries"`
+	DBOpenRetryDelay time.Duration `json:"db_open_retry_delay"`
+
+	// How long SQLite waits for a lock held by another connection or process
+	DBBusyTimeout time.Duration `json:"db_busy_timeout"`
+
+	// How long stats aggregates are reused between writes
+	StatsCacheTTL time.Duration `json:"stats_cache_ttl"`
+
+	// Failure to read a secret from its _FILE variable, reported by Validate
+	secretErr error
+
+	// Values New replaced because they were out of range; see Warnings
+	warnings []string
 }
 
 // New creates a new Config with values from environment variables or defaults
@@ -51,25 +260,137 @@ func New() *Config {
 		TemplatesDir:  getEnv("TEMPLATES_DIR", "./web/templates"),
 		StaticDir:     getEnv("STATIC_DIR", "./web/static"),
 
+		// Thumbnail layout
+		MirrorStructure: getEnvAsBool("MIRROR_STRUCTURE", false),
+
+		// Storage settings
+		StorageBackend: strings.ToLower(getEnv("STORAGE_BACKEND", StorageLocal)),
+		S3Bucket:       getEnv("S3_BUCKET", ""),
+		S3Region:       getEnv("S3_REGION", "us-east-1"),
+		S3Endpoint:     getEnv("S3_ENDPOINT", ""),
+		S3Prefix:       getEnv("S3_PREFIX", ""),
+		S3PathStyle:    getEnvAsBool("S3_PATH_STYLE", false),
+
 		// Default thumbnail generation settings
-		GridCols:       getEnvAsInt("GRID_COLS", 8),
-		GridRows:       getEnvAsInt("GRID_ROWS", 4),
-		MaxWorkers:     getEnvAsInt("MAX_WORKERS", 4),
-		FileExtensions: getEnvAsSlice("FILE_EXTENSIONS", "mp4,mkv,avi,mov,mts,wmv"),
+		GridCols:        getEnvAsInt("GRID_COLS", 8),
+		GridRows:        getEnvAsInt("GRID_ROWS", 4),
+		MaxWorkers:      getEnvAsInt("MAX_WORKERS", 4),
+		FileExtensions:  getEnvAsSlice("FILE_EXTENSIONS", "mp4,mkv,avi,mov,mts,wmv"),
+		FFmpegProgress:  getEnvAsBool("FFMPEG_PROGRESS", false),
+		ProgressiveJPEG: getEnvAsBool("PROGRESSIVE_JPEG", false),
+		MaxGridPixels:   getEnvAsInt("MAX_GRID_PIXELS", 16777216),
+		ThumbCellWidth:  getEnvAsInt("THUMB_CELL_WIDTH", DefaultThumbCellWidth),
+		ThumbCellHeight: getEnvAsInt("THUMB_CELL_HEIGHT", DefaultThumbCellHeight),
+
+		ThumbnailQuality: getEnvAsInt("THUMBNAIL_QUALITY", getEnvAsInt("THUMB_QUALITY", DefaultJPEGQuality)), // THUMB_QUALITY is an alias
+		GridQuality:      getEnvAsInt("GRID_QUALITY", 0),
+		PosterQuality:    getEnvAsInt("POSTER_QUALITY", 0),
+
+		AdaptiveWorkers: getEnvAsBool("ADAPTIVE_WORKERS", false),
+		MinWorkers:      getEnvAsInt("MIN_WORKERS", 1),
+
+		ProcessNewestFirst: getEnvAsBool("PROCESS_NEWEST_FIRST", false),
+		HandleNonVideo:     getEnvAsBool("HANDLE_NON_VIDEO", false),
+		ScanExcludeDirs:    getEnvAsSlice("SCAN_EXCLUDE_DIRS", "@eaDir,#recycle,.recycle,.Trash-*,lost+found"),
+		RecursiveScan:      getEnvAsBool("RECURSIVE_SCAN", false),
+
+		ThumbnailQuotaPerRoot: getEnvAsBytes("THUMBNAIL_QUOTA_PER_ROOT", 0),
+
+		// Default sampling window (whole movie, minus the fixed intro skip)
+		SampleStartPercent: getEnvAsInt("SAMPLE_START_PERCENT", 0),
+		SampleEndPercent:   getEnvAsInt("SAMPLE_END_PERCENT", 100),
+		IntroSkipPercent:   getEnvAsFloat("INTRO_SKIP_PERCENT", 2),
+		IntroSkipMax:       getEnvAsDuration("INTRO_SKIP_MAX", "2m"),
+		IntroSkipSeconds:   getEnvAsFloat("INTRO_SKIP_SECONDS", 0),
+		AccurateSeek:       getEnvAsBool("ACCURATE_SEEK", false),
+		DedupFrames:        strings.ToLower(getEnv("DEDUP_FRAMES", DedupOff)),
+		SceneThreshold:     getEnvAsFloat("SCENE_THRESHOLD", 0.3),
 
 		// Default server settings
 		ServerPort: getEnv("SERVER_PORT", "8080"),
 		ServerHost: getEnv("SERVER_HOST", "0.0.0.0"),
+		Headless:   getEnvAsBool("HEADLESS", false),
+
+		TrustedProxies: getEnvAsSlice("TRUSTED_PROXIES", ""),
+
+		AdminHost:   getEnv("ADMIN_HOST", "127.0.0.1"),
+		AdminPort:   getEnv("ADMIN_PORT", ""),
+		EnablePprof: getEnvAsBool("ENABLE_PPROF", false),
+
+		MaintenanceBlockMutations: getEnvAsBool("MAINTENANCE_BLOCK_MUTATIONS", false),
+
+		// Default slideshow settings
+		SlideshowOrder: strings.ToLower(getEnv("SLIDESHOW_ORDER", SlideshowRandom)),
+		IncludeViewed:  getEnvAsBool("INCLUDE_VIEWED", false),
+
+		ShowLibraryPosition: getEnvAsBool("SLIDESHOW_LIBRARY_POSITION", false),
+		SessionIdleExpiry:   getEnvAsDuration("SESSION_IDLE_EXPIRY", "0"),
+
+		// Notification settings
+		HTTPClientTimeout: getEnvAsDuration("HTTP_CLIENT_TIMEOUT", "10s"),
+
+		// Default resizing settings
+		ThumbnailWidths:    getEnvAsIntSlice("THUMBNAIL_RESIZE_WIDTHS", "320,640,960,1280"),
+		ThumbnailCacheSize: getEnvAsInt("THUMBNAIL_RESIZE_CACHE", 128),
+
+		ThumbnailServeConcurrency: getEnvAsInt("THUMBNAIL_SERVE_CONCURRENCY", 0),
+		PendingPlaceholder:        getEnv("PENDING_PLACEHOLDER", ""),
+		ErrorPlaceholder:          getEnv("ERROR_PLACEHOLDER", ""),
 
 		// Default background task settings
-		ScanInterval: getEnvAsDuration("SCAN_INTERVAL", "1h"),
-		Debug:        getEnvAsBool("DEBUG", false),
+		ScanInterval:    getEnvAsDuration("SCAN_INTERVAL", "1h"),
+		ScanJitter:      getEnvAsDuration("SCAN_JITTER", "0"),
+		CleanupJitter:   getEnvAsDuration("CLEANUP_JITTER", "0"),
+		ShutdownTimeout: getEnvAsDuration("SHUTDOWN_TIMEOUT", "30s"),
+		Debug:           getEnvAsBool("DEBUG", false),
 
 		// Default deletion worker settings
-		DisableDeletion: getEnvAsBool("DISABLE_DELETION", false),
+		DisableDeletion:         getEnvAsBool("DISABLE_DELETION", false),
+		DeletionRequireApproval: getEnvAsBool("DELETION_REQUIRE_APPROVAL", false),
+		AutoDeleteCriteria:      getEnv("AUTO_DELETE_CRITERIA", ""),
+		DeleteMaxAttempts:       getEnvAsInt("DELETE_MAX_ATTEMPTS", 5),
+		DeleteRetryBackoff:      getEnvAsDuration("DELETE_RETRY_BACKOFF", "1h"),
+		CleanupPhases:           getEnvAsSlice("CLEANUP_PHASES", strings.Join(DefaultCleanupPhases, ",")),
 
 		// Import settings
 		ImportExisting: getEnvAsBool("IMPORT_EXISTING", false),
+
+		ReuseExistingThumbnails: getEnvAsBool("REUSE_EXISTING_THUMBNAILS", false),
+
+		ClearThumbnailOnError: getEnvAsBool("CLEAR_THUMBNAIL_ON_ERROR", false),
+		IncompleteFileAge:     getEnvAsDuration("INCOMPLETE_FILE_AGE", "10m"),
+		ErrorRetention:        getEnvAsDuration("ERROR_RETENTION", "0"),
+
+		StrictScan: getEnvAsBool("STRICT_SCAN", false),
+
+		// New file settings
+		NewFilesViewed: getEnvAsBool("NEW_FILES_VIEWED", false),
+
+		// Migration settings
+		RunMigrations: getEnvAsBool("RUN_MIGRATIONS", false),
+
+		// Database open settings
+		DBOpenRetries:    getEnvAsInt("DB_OPEN_RETRIES", 5),
+		DBOpenRetryDelay: getEnvAsDuration("DB_OPEN_RETRY_DELAY", "1s"),
+		DBBusyTimeout:    getEnvAsDuration("DB_BUSY_TIMEOUT", "5s"),
+		StatsCacheTTL:    getEnvAsDuration("STATS_CACHE_TTL", "5s"),
+	}
+
+	// Secrets may also be read from files, such as Docker or Kubernetes secrets
+	for _, secret := range []struct {
+		key   string
+		value *string
+	}{
+		{"S3_ACCESS_KEY_ID", &config.S3AccessKeyID},
+		{"S3_SECRET_ACCESS_KEY", &config.S3SecretAccessKey},
+		{"CAUGHT_UP_WEBHOOK", &config.CaughtUpWebhook},
+	} {
+		value, err := getEnvOrFile(secret.key)
+		if err != nil {
+			config.secretErr = errors.Join(config.secretErr, err)
+			continue
+		}
+		*secret.value = value
 	}
 
 	// Derive DB path - check DATABASE_PATH first, then default
@@ -79,9 +400,183 @@ func New() *Config {
 		config.DBPath = filepath.Join(config.DataDir, "thumbnailer.db")
 	}
 
+	// Derive backup directory - check BACKUP_DIR first, then default
+	config.BackupDir = getEnv("BACKUP_DIR", filepath.Join(config.DataDir, "backups"))
+
+	for _, cell := range []struct {
+		name     string
+		value    *int
+		fallback int
+	}{
+		{"THUMB_CELL_WIDTH", &config.ThumbCellWidth, DefaultThumbCellWidth},
+		{"THUMB_CELL_HEIGHT", &config.ThumbCellHeight, DefaultThumbCellHeight},
+	} {
+		if *cell.value <= 0 {
+			config.warnings = append(config.warnings, fmt.Sprintf("%s must be positive, got %d; using %d", cell.name, *cell.value, cell.fallback))
+			*cell.value = cell.fallback
+		}
+	}
+	if q := config.ThumbnailQuality; q < MinJPEGQuality || q > MaxJPEGQuality {
+		config.ThumbnailQuality = min(max(q, MinJPEGQuality), MaxJPEGQuality)
+		config.warnings = append(config.warnings, fmt.Sprintf("THUMBNAIL_QUALITY must be between %d and %d, got %d; using %d",
+			MinJPEGQuality, MaxJPEGQuality, q, config.ThumbnailQuality))
+	}
+
 	return config
 }
 
+// Warnings describes the values New replaced because they were out of range
+func (c *Config) Warnings() []string {
+	return c.warnings
+}
+
+// Validate checks the configuration for values that cannot work together
+func (c *Config) Validate() error {
+	if c.secretErr != nil {
+		return c.secretErr
+	}
+	if _, err := ParseAutoDeleteCriteria(c.AutoDeleteCriteria); err != nil {
+		return fmt.Errorf("AUTO_DELETE_CRITERIA: %w", err)
+	}
+	if c.ScanJitter < 0 || (c.ScanInterval > 0 && c.ScanJitter >= c.ScanInterval) {
+		return fmt.Errorf("SCAN_JITTER must be at least 0 and below SCAN_INTERVAL (%s), got %s", c.ScanInterval, c.ScanJitter)
+	}
+	if c.CleanupJitter < 0 || c.CleanupJitter >= CleanupInterval {
+		return fmt.Errorf("CLEANUP_JITTER must be at least 0 and below the cleanup interval (%s), got %s", CleanupInterval, c.CleanupJitter)
+	}
+	if c.CleanupPhases != nil {
+		if err := ValidateCleanupPhases(c.CleanupPhases); err != nil {
+			return fmt.Errorf("CLEANUP_PHASES: %w", err)
+		}
+	}
+	if c.SampleStartPercent < 0 || c.SampleStartPercent > 100 {
+		return fmt.Errorf("SAMPLE_START_PERCENT must be between 0 and 100, got %d", c.SampleStartPercent)
+	}
+	if c.SampleEndPercent < 0 || c.SampleEndPercent > 100 {
+		return fmt.Errorf("SAMPLE_END_PERCENT must be between 0 and 100, got %d", c.SampleEndPercent)
+	}
+	if c.SampleStartPercent >= c.SampleEndPercent {
+		return fmt.Errorf("SAMPLE_START_PERCENT (%d) must be less than SAMPLE_END_PERCENT (%d)",
+			c.SampleStartPercent, c.SampleEndPercent)
+	}
+	for _, placeholder := range []struct{ name, path string }{
+		{"PENDING_PLACEHOLDER", c.PendingPlaceholder},
+		{"ERROR_PLACEHOLDER", c.ErrorPlaceholder},
+	} {
+		if placeholder.path == "" {
+			continue
+		}
+		if info, err := os.Stat(placeholder.path); err != nil || !info.Mode().IsRegular() {
+			return fmt.Errorf("%s must name an image file, got %q", placeholder.name, placeholder.path)
+		}
+	}
+	if c.IncompleteFileAge < 0 {
+		return fmt.Errorf("INCOMPLETE_FILE_AGE must not be negative, got %s", c.IncompleteFileAge)
+	}
+	if c.RecursiveScan && !c.MirrorStructure {
+		return fmt.Errorf("RECURSIVE_SCAN requires MIRROR_STRUCTURE, so movies with the same name in different directories get their own thumbnails")
+	}
+	if c.ErrorRetention < 0 {
+		return fmt.Errorf("ERROR_RETENTION must not be negative, got %s", c.ErrorRetention)
+	}
+	if c.DBBusyTimeout < 0 {
+		return fmt.Errorf("DB_BUSY_TIMEOUT must not be negative, got %s", c.DBBusyTimeout)
+	}
+	if c.HTTPClientTimeout <= 0 {
+		return fmt.Errorf("HTTP_CLIENT_TIMEOUT must be positive, so outbound requests cannot hang, got %s", c.HTTPClientTimeout)
+	}
+	if c.ThumbnailServeConcurrency < 0 {
+		return fmt.Errorf("THUMBNAIL_SERVE_CONCURRENCY must not be negative, got %d", c.ThumbnailServeConcurrency)
+	}
+	if c.IntroSkipSeconds < 0 {
+		return fmt.Errorf("INTRO_SKIP_SECONDS must not be negative, got %g", c.IntroSkipSeconds)
+	}
+	if c.IntroSkipPercent < 0 || c.IntroSkipPercent >= 50 {
+		return fmt.Errorf("INTRO_SKIP_PERCENT must be at least 0 and below 50, got %g", c.IntroSkipPercent)
+	}
+	switch c.StorageBackend {
+	case "", StorageLocal:
+	case StorageS3:
+		if c.S3Bucket == "" {
+			return fmt.Errorf("S3_BUCKET is required when STORAGE_BACKEND is s3")
+		}
+	default:
+		return fmt.Errorf("STORAGE_BACKEND must be %q or %q, got %q", StorageLocal, StorageS3, c.StorageBackend)
+	}
+	for _, proxy := range c.TrustedProxies {
+		if proxy = strings.TrimSpace(proxy); proxy == "" {
+			continue
+		}
+		if _, err := netip.ParsePrefix(proxy); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(proxy); err != nil {
+			return fmt.Errorf("TRUSTED_PROXIES entries must be IP addresses or CIDR ranges, got %q", proxy)
+		}
+	}
+	if c.AdaptiveWorkers && (c.MinWorkers < 1 || c.MinWorkers > c.MaxWorkers) {
+		return fmt.Errorf("MIN_WORKERS must be between 1 and MAX_WORKERS (%d), got %d", c.MaxWorkers, c.MinWorkers)
+	}
+	switch c.DedupFrames {
+	case "", DedupOff, DedupMpdecimate, DedupScene:
+	default:
+		return fmt.Errorf("DEDUP_FRAMES must be %q, %q or %q, got %q", DedupOff, DedupMpdecimate, DedupScene, c.DedupFrames)
+	}
+	if c.DedupFrames == DedupScene && (c.SceneThreshold <= 0 || c.SceneThreshold >= 1) {
+		return fmt.Errorf("SCENE_THRESHOLD must be between 0 and 1, got %g", c.SceneThreshold)
+	}
+	switch c.SlideshowOrder {
+	case "", SlideshowRandom, SlideshowLRU, SlideshowRecorded:
+	default:
+		return fmt.Errorf("SLIDESHOW_ORDER must be %q, %q or %q, got %q", SlideshowRandom, SlideshowLRU, SlideshowRecorded, c.SlideshowOrder)
+	}
+	for _, q := range []struct {
+		name  string
+		value int
+	}{{"GRID_QUALITY", c.GridQuality}, {"POSTER_QUALITY", c.PosterQuality}} {
+		if q.value != 0 && (q.value < MinJPEGQuality || q.value > MaxJPEGQuality) {
+			return fmt.Errorf("%s must be between %d and %d for JPEG thumbnails, got %d", q.name, MinJPEGQuality, MaxJPEGQuality, q.value)
+		}
+	}
+	return nil
+}
+
+// ThumbCellSize returns the size of a grid tile. A width or height that is not
+// positive falls back to its default.
+func (c *Config) ThumbCellSize() (width, height int) {
+	width, height = c.ThumbCellWidth, c.ThumbCellHeight
+	if width <= 0 {
+		width = DefaultThumbCellWidth
+	}
+	if height <= 0 {
+		height = DefaultThumbCellHeight
+	}
+	return width, height
+}
+
+// GridJPEGQuality returns the ffmpeg qscale used for thumbnail grids
+func (c *Config) GridJPEGQuality() int {
+	return jpegQuality(c.GridQuality, c.ThumbnailQuality)
+}
+
+// PosterJPEGQuality returns the ffmpeg qscale used for the single still thumbnails
+// of audio and image files
+func (c *Config) PosterJPEGQuality() int {
+	return jpegQuality(c.PosterQuality, c.ThumbnailQuality)
+}
+
+// jpegQuality returns quality, or the global quality when it is unset
+func jpegQuality(quality, global int) int {
+	switch {
+	case quality > 0:
+		return quality
+	case global > 0:
+		return global
+	default:
+		return DefaultJPEGQuality
+	}
+}
+
 // Helper functions to get environment variables with defaults
 
 func getEnv(key, defaultValue string) string {
@@ -91,6 +586,23 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvOrFile returns the value of key or, when key is not set, the contents of the
+// file named by key_FILE with trailing newlines trimmed. It returns "" when neither is set.
+func getEnvOrFile(key string) (string, error) {
+	if value, exists := os.LookupEnv(key); exists {
+		return value, nil
+	}
+	path, exists := os.LookupEnv(key + "_FILE")
+	if !exists || path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s_FILE: %w", key, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
 func getEnvAsInt(key string, defaultValue int) int {
 	if value, exists := os.LookupEnv(key); exists {
 		if intValue, err := strconv.Atoi(value); err == nil {
@@ -100,6 +612,15 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 func getEnvAsSlice(key, defaultValue string) []string {
 	if value, exists := os.LookupEnv(key); exists {
 		return strings.Split(value, ",")
@@ -107,6 +628,21 @@ func getEnvAsSlice(key, defaultValue string) []string {
 	return strings.Split(defaultValue, ",")
 }
 
+// getEnvAsIntSlice parses a comma-separated list of positive integers, sorted ascending.
+// Invalid entries are skipped; an empty value yields an empty slice.
+func getEnvAsIntSlice(key, defaultValue string) []int {
+	raw := getEnv(key, defaultValue)
+
+	var values []int
+	for _, part := range strings.Split(raw, ",") {
+		if v, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && v > 0 {
+			values = append(values, v)
+		}
+	}
+	sort.Ints(values)
+	return values
+}
+
 func getEnvAsBool(key string, defaultValue bool) bool {
 	if value, exists := os.LookupEnv(key); exists {
 		if boolValue, err := strconv.ParseBool(value); err == nil {
@@ -126,6 +662,37 @@ func getEnvAsDuration(key string, defaultValue string) time.Duration {
 	return duration
 }
 
+// getEnvAsBytes parses a byte size such as 500M or 2G (binary multiples)
+func getEnvAsBytes(key string, defaultValue int64) int64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if size, err := parseByteSize(value); err == nil {
+			return size
+		}
+	}
+	return defaultValue
+}
+
+// parseByteSize parses a non-negative number of bytes with an optional K, M, G or T
+// suffix, optionally followed by B or iB
+func parseByteSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+
+	multiplier := int64(1)
+	if n := len(s); n > 0 {
+		if i := strings.IndexByte("KMGT", s[n-1]); i >= 0 {
+			multiplier = int64(1) << (10 * (i + 1))
+			s = s[:n-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid byte size %q", value)
+	}
+	return n * multiplier, nil
+}
+
 // getEnvAsMovieDirs parses a comma-separated list of directories, trimming whitespace
 // and dropping empty entries. Falls back to a single-element slice of defaultValue.
 func getEnvAsMovieDirs(key, defaultValue string) []string {
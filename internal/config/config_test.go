@@ -2,7 +2,10 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetEnvAsMovieDirs(t *testing.T) {
@@ -78,3 +81,276 @@ func TestNewMoviesDirs_DefaultSingleElement(t *testing.T) {
 		t.Errorf("default MoviesDirs = %v, want [/movies]", cfg.MoviesDirs)
 	}
 }
+
+func TestValidateSampleWindow(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end int
+		wantErr    bool
+	}{
+		{"defaults", 0, 100, false},
+		{"middle", 20, 80, false},
+		{"start after end", 80, 20, true},
+		{"equal", 50, 50, true},
+		{"negative start", -1, 100, true},
+		{"end above 100", 0, 101, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{HTTPClientTimeout: time.Second, SampleStartPercent: tt.start, SampleEndPercent: tt.end}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTrustedProxies(t *testing.T) {
+	tests := []struct {
+		proxies []string
+		wantErr bool
+	}{
+		{[]string{""}, false},
+		{[]string{"10.0.0.0/8", " 192.168.1.1 ", "::1"}, false},
+		{[]string{"10.0.0.0/8", "proxy.local"}, true},
+		{[]string{"10.0.0.0/33"}, true},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{HTTPClientTimeout: time.Second, SampleEndPercent: 100, TrustedProxies: tt.proxies}
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%q) error = %v, wantErr %v", tt.proxies, err, tt.wantErr)
+		}
+	}
+}
+
+func TestJPEGQuality(t *testing.T) {
+	tests := []struct {
+		name                 string
+		global, grid, poster int
+		wantGrid, wantPoster int
+		wantErr              bool
+	}{
+		{"unset", 0, 0, 0, DefaultJPEGQuality, DefaultJPEGQuality, false},
+		{"global only", 5, 0, 0, 5, 5, false},
+		{"per artifact", 5, 8, 2, 8, 2, false},
+		{"grid out of range", 3, 32, 0, 32, 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{HTTPClientTimeout: time.Second, SampleEndPercent: 100, ThumbnailQuality: tt.global, GridQuality: tt.grid, PosterQuality: tt.poster}
+			if got := cfg.GridJPEGQuality(); got != tt.wantGrid {
+				t.Errorf("GridJPEGQuality() = %d, want %d", got, tt.wantGrid)
+			}
+			if got := cfg.PosterJPEGQuality(); got != tt.wantPoster {
+				t.Errorf("PosterJPEGQuality() = %d, want %d", got, tt.wantPoster)
+			}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestThumbCellSize(t *testing.T) {
+	t.Setenv("THUMB_CELL_WIDTH", "640")
+	t.Setenv("THUMB_CELL_HEIGHT", "-1")
+	cfg := New()
+	if cfg.ThumbCellWidth != 640 || cfg.ThumbCellHeight != DefaultThumbCellHeight {
+		t.Errorf("New() cell = %dx%d, want 640x%d", cfg.ThumbCellWidth, cfg.ThumbCellHeight, DefaultThumbCellHeight)
+	}
+	if len(cfg.Warnings()) != 1 {
+		t.Errorf("Warnings() = %q, want one for THUMB_CELL_HEIGHT", cfg.Warnings())
+	}
+
+	if w, h := (&Config{}).ThumbCellSize(); w != DefaultThumbCellWidth || h != DefaultThumbCellHeight {
+		t.Errorf("ThumbCellSize() unset = %dx%d, want the defaults", w, h)
+	}
+}
+
+func TestThumbQualityAlias(t *testing.T) {
+	t.Setenv("THUMB_QUALITY", "10")
+	if got := New().ThumbnailQuality; got != 10 {
+		t.Errorf("ThumbnailQuality with THUMB_QUALITY = %d, want 10", got)
+	}
+
+	t.Setenv("THUMBNAIL_QUALITY", "5")
+	if got := New().ThumbnailQuality; got != 5 {
+		t.Errorf("ThumbnailQuality with both set = %d, want THUMBNAIL_QUALITY's 5", got)
+	}
+}
+
+func TestThumbnailQualityClamp(t *testing.T) {
+	for value, want := range map[string]int{"1": MinJPEGQuality, "32": MaxJPEGQuality, "10": 10} {
+		t.Setenv("THUMBNAIL_QUALITY", value)
+		cfg := New()
+		if cfg.ThumbnailQuality != want {
+			t.Errorf("ThumbnailQuality with THUMBNAIL_QUALITY=%s = %d, want %d", value, cfg.ThumbnailQuality, want)
+		}
+		if clamped := want != 10; (len(cfg.Warnings()) > 0) != clamped {
+			t.Errorf("Warnings() with THUMBNAIL_QUALITY=%s = %q, want a warning %v", value, cfg.Warnings(), clamped)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate() with THUMBNAIL_QUALITY=%s error = %v", value, err)
+		}
+	}
+}
+
+func TestHTTPClientTimeout(t *testing.T) {
+	for timeout, wantErr := range map[time.Duration]bool{time.Second: false, 0: true, -time.Second: true} {
+		cfg := &Config{SampleEndPercent: 100, HTTPClientTimeout: timeout}
+		if err := cfg.Validate(); (err != nil) != wantErr {
+			t.Errorf("Validate() with HTTPClientTimeout %s error = %v, wantErr %v", timeout, err, wantErr)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"1024", 1024, false},
+		{"512B", 512, false},
+		{"2k", 2 << 10, false},
+		{"500M", 500 << 20, false},
+		{"2GiB", 2 << 30, false},
+		{" 1T ", 1 << 40, false},
+		{"", 0, true},
+		{"-1G", 0, true},
+		{"lots", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseByteSize(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseByteSize(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseByteSize(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseAutoDeleteCriteria(t *testing.T) {
+	got, err := ParseAutoDeleteCriteria(" size_below=200M, duration_below=2m,width_below=640 ,height_below=480")
+	want := AutoDeleteCriteria{SizeBelow: 200 << 20, DurationBelow: 2 * time.Minute, WidthBelow: 640, HeightBelow: 480}
+	if err != nil || got != want {
+		t.Errorf("ParseAutoDeleteCriteria = %+v, %v; want %+v", got, err, want)
+	}
+
+	if got, err := ParseAutoDeleteCriteria(""); err != nil || !got.IsEmpty() {
+		t.Errorf("empty expression = %+v, %v; want no criteria", got, err)
+	}
+
+	for _, expr := range []string{
+		"size_below",
+		"size_below=0",
+		"size_below=lots",
+		"duration_below=-1m",
+		"width_below=0",
+		"height_below=tall",
+		"tag=trash",
+	} {
+		if _, err := ParseAutoDeleteCriteria(expr); err == nil {
+			t.Errorf("ParseAutoDeleteCriteria(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestGetEnvOrFile(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secretFile, []byte("s3cr3t value\r\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("file with trailing newlines trimmed", func(t *testing.T) {
+		t.Setenv("S3_SECRET_ACCESS_KEY_FILE", secretFile)
+		got, err := getEnvOrFile("S3_SECRET_ACCESS_KEY")
+		if err != nil || got != "s3cr3t value" {
+			t.Errorf("getEnvOrFile = %q, %v, want %q", got, err, "s3cr3t value")
+		}
+	})
+
+	t.Run("variable wins over file", func(t *testing.T) {
+		t.Setenv("S3_SECRET_ACCESS_KEY", "from-env")
+		t.Setenv("S3_SECRET_ACCESS_KEY_FILE", secretFile)
+		if got, err := getEnvOrFile("S3_SECRET_ACCESS_KEY"); err != nil || got != "from-env" {
+			t.Errorf("getEnvOrFile = %q, %v, want from-env", got, err)
+		}
+	})
+
+	t.Run("neither set", func(t *testing.T) {
+		if got, err := getEnvOrFile("S3_SECRET_ACCESS_KEY"); err != nil || got != "" {
+			t.Errorf("getEnvOrFile = %q, %v, want empty", got, err)
+		}
+	})
+
+	t.Run("unreadable file fails validation", func(t *testing.T) {
+		t.Setenv("S3_ACCESS_KEY_ID_FILE", filepath.Join(dir, "missing"))
+		cfg := New()
+		if cfg.S3AccessKeyID != "" {
+			t.Errorf("S3AccessKeyID = %q, want empty", cfg.S3AccessKeyID)
+		}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "S3_ACCESS_KEY_ID_FILE") {
+			t.Errorf("Validate() = %v, want an error naming S3_ACCESS_KEY_ID_FILE", err)
+		}
+	})
+
+	t.Run("loaded by New", func(t *testing.T) {
+		t.Setenv("CAUGHT_UP_WEBHOOK_FILE", secretFile)
+		if got := New().CaughtUpWebhook; got != "s3cr3t value" {
+			t.Errorf("CaughtUpWebhook = %q, want the file contents", got)
+		}
+	})
+}
+
+func TestValidateCleanupPhases(t *testing.T) {
+	tests := []struct {
+		phases  []string
+		wantErr bool
+	}{
+		{nil, false},
+		{DefaultCleanupPhases, false},
+		{[]string{CleanupOrphans, CleanupMissing}, false},
+		{[]string{""}, true}, // CLEANUP_PHASES set but empty
+		{[]string{CleanupOrphans, "vacuum"}, true},
+		{[]string{CleanupQueue, CleanupQueue}, true},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{HTTPClientTimeout: time.Second, SampleEndPercent: 100, CleanupPhases: tt.phases}
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%q: Validate() error = %v, wantErr %v", tt.phases, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateJitter(t *testing.T) {
+	tests := []struct {
+		name          string
+		scan, cleanup time.Duration
+		wantErr       bool
+	}{
+		{"none", 0, 0, false},
+		{"within intervals", 5 * time.Minute, time.Hour, false},
+		{"scan jitter of a whole interval", time.Hour, 0, true},
+		{"cleanup jitter of a whole interval", 0, CleanupInterval, true},
+		{"negative", -time.Second, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{HTTPClientTimeout: time.Second, SampleEndPercent: 100, ScanInterval: time.Hour, ScanJitter: tt.scan, CleanupJitter: tt.cleanup}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Redacted replaces the value of set secrets in Effective
+const Redacted = "***"
+
+// Effective returns the resolved configuration keyed by the fields' json names, for
+// GET /api/config. Durations are formatted like their environment variables, and
+// fields tagged secret are Redacted when set.
+func (c *Config) Effective() map[string]interface{} {
+	v := reflect.ValueOf(c).Elem()
+	effective := make(map[string]interface{}, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+
+		value := v.Field(i).Interface()
+		switch {
+		case field.Tag.Get("secret") == "true":
+			if !v.Field(i).IsZero() {
+				value = Redacted
+			}
+		case field.Type == reflect.TypeOf(time.Duration(0)):
+			value = value.(time.Duration).String()
+		}
+		effective[name] = value
+	}
+	return effective
+}
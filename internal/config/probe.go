@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// CheckMoviesReadable verifies that every existing movie directory can be listed.
+// Missing directories are left to the scanner, which skips offline volumes.
+func (c *Config) CheckMoviesReadable() error {
+	for _, dir := range c.MoviesDirs {
+		if err := probeReadable(dir); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("movie directory %s is not readable (check MOVIE_INPUT_DIR and the directory permissions for this user): %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// CheckThumbnailsWritable verifies that thumbnails can be written to ThumbnailsDir
+// by creating and removing a temporary file. Only local storage is checked.
+func (c *Config) CheckThumbnailsWritable() error {
+	if c.StorageBackend != "" && c.StorageBackend != StorageLocal {
+		return nil
+	}
+	if err := probeWritable(c.ThumbnailsDir); err != nil {
+		return fmt.Errorf("thumbnail directory %s is not writable (check THUMBNAIL_OUTPUT_DIR and the directory permissions for this user): %w", c.ThumbnailsDir, err)
+	}
+	return nil
+}
+
+// probeReadable opens dir and reads one entry
+func probeReadable(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// probeWritable creates and removes a temporary file in dir
+func probeWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".write-probe-*.tmp")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckAccess(t *testing.T) {
+	movies := t.TempDir()
+	thumbs := t.TempDir()
+
+	cfg := &Config{
+		MoviesDirs:    []string{movies, filepath.Join(movies, "offline")},
+		ThumbnailsDir: thumbs,
+	}
+	if err := cfg.CheckMoviesReadable(); err != nil {
+		t.Errorf("expected readable movie directories, got %v", err)
+	}
+	if err := cfg.CheckThumbnailsWritable(); err != nil {
+		t.Errorf("expected a writable thumbnail directory, got %v", err)
+	}
+	if entries, _ := os.ReadDir(thumbs); len(entries) != 0 {
+		t.Errorf("probe left files behind: %v", entries)
+	}
+
+	cfg.ThumbnailsDir = filepath.Join(thumbs, "missing")
+	if err := cfg.CheckThumbnailsWritable(); err == nil {
+		t.Error("expected an error for a missing thumbnail directory")
+	}
+
+	// Remote storage does not need a local thumbnail directory
+	cfg.StorageBackend = StorageS3
+	if err := cfg.CheckThumbnailsWritable(); err != nil {
+		t.Errorf("expected no check for S3 storage, got %v", err)
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("permission checks are bypassed when running as root")
+	}
+	locked := t.TempDir()
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(locked, 0755) })
+
+	cfg.MoviesDirs = []string{locked}
+	if err := cfg.CheckMoviesReadable(); err == nil {
+		t.Error("expected an error for an unreadable movie directory")
+	}
+	cfg.StorageBackend = StorageLocal
+	cfg.ThumbnailsDir = locked
+	if err := cfg.CheckThumbnailsWritable(); err == nil {
+		t.Error("expected an error for an unwritable thumbnail directory")
+	}
+}
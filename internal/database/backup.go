@@ -0,0 +1,31 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Backup writes a consistent copy of the database to destPath using VACUUM INTO.
+// It runs on the pool's single connection, so it is serialized with other queries
+// rather than competing with them for a lock. destPath must not already exist.
+func (d *DB) Backup(destPath string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	if _, err := os.Stat(destPath); err == nil {
+		return 0, fmt.Errorf("backup file already exists: %s", destPath)
+	}
+
+	if _, err := d.db.Exec("VACUUM INTO ?", destPath); err != nil {
+		return 0, fmt.Errorf("failed to back up database: %w", err)
+	}
+
+	info, err := os.Stat(destPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat backup: %w", err)
+	}
+
+	return info.Size(), nil
+}
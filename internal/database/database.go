@@ -1,25 +1,57 @@
 package database
 
 import (
-	"crypto/rand"
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"math/big"
-	mathrand "math/rand"
+	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 	"github.com/pandino/movie-thumbnailer-go/internal/models"
 )
 
+// ErrNotFound is returned by updates by ID when there is no thumbnail with that ID
+var ErrNotFound = errors.New("thumbnail not found")
+
+// ErrAmbiguous is returned by GetByMovieFilename when movies in different
+// subdirectories share the file name
+var ErrAmbiguous = errors.New("more than one movie has this file name")
+
 // DB represents the database connection and operations
 type DB struct {
-	db *sql.DB
+	db    *sql.DB
+	stats *statsCache
 }
 
+// DefaultBusyTimeout is how long SQLite waits for a lock unless OpenOptions sets one
+const DefaultBusyTimeout = 5 * time.Second
+
+// Writes failing with SQLITE_BUSY or SQLITE_LOCKED once the busy timeout has
+// passed are retried this many times, waiting busyRetryDelay, doubled each time
+const (
+	busyRetries    = 3
+	busyRetryDelay = 50 * time.Millisecond
+)
+
 // New creates a new database connection and initializes the schema
 func New(dbPath string) (*DB, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	return open(dbPath, DefaultBusyTimeout)
+}
+
+// open connects to the database with the given busy timeout and initializes the schema
+func open(dbPath string, busyTimeout time.Duration) (*DB, error) {
+	if busyTimeout <= 0 {
+		busyTimeout = DefaultBusyTimeout
+	}
+	// The driver runs PRAGMA busy_timeout on every new connection
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s%s_busy_timeout=%d", dbPath, sep, busyTimeout.Milliseconds()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -35,7 +67,40 @@ func New(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
-	return &DB{db: db}, nil
+	d := &DB{db: db, stats: &statsCache{}}
+
+	// Add columns introduced after the initial schema
+	if err := d.migrateColumns(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
+	return d, nil
+}
+
+// exec runs a statement that modifies thumbnails, invalidating cached stats. A
+// statement that fails because another process holds the lock is retried.
+func (d *DB) exec(query string, args ...interface{}) (sql.Result, error) {
+	defer d.stats.invalidate()
+
+	wait := busyRetryDelay
+	for attempt := 0; ; attempt++ {
+		result, err := d.db.Exec(query, args...)
+		if err == nil || attempt == busyRetries || !isBusy(err) {
+			return result, err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// isBusy reports whether err means the database was locked by another connection
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
 }
 
 // Close closes the database connection
@@ -61,7 +126,26 @@ func initSchema(db *sql.DB) error {
 			duration REAL DEFAULT 0,
 			file_size INTEGER DEFAULT 0,
 			error_message TEXT NOT NULL DEFAULT '',
-			source TEXT DEFAULT 'generated'
+			source TEXT DEFAULT 'generated',
+			delete_attempts INTEGER DEFAULT 0,
+			last_delete_attempt INTEGER DEFAULT 0,
+			view_count INTEGER DEFAULT 0,
+			last_viewed_at TIMESTAMP,
+			content_hash TEXT,
+			root_dir TEXT NOT NULL DEFAULT '',
+			thumbnail_size INTEGER DEFAULT 0,
+			movie_mtime INTEGER DEFAULT 0,
+			grid_cols INTEGER DEFAULT 0,
+			grid_rows INTEGER DEFAULT 0,
+			recorded_at TIMESTAMP,
+			video_stream INTEGER DEFAULT 0,
+			sidecar_mtime INTEGER DEFAULT 0,
+			deletion_approved INTEGER DEFAULT 0,
+			generated_at TIMESTAMP,
+			generator_version TEXT NOT NULL DEFAULT '',
+			poster_path TEXT NOT NULL DEFAULT '',
+			preview_path TEXT NOT NULL DEFAULT '',
+			queued_at TIMESTAMP
 		);
 		
 		-- Index for faster queries by status
@@ -96,7 +180,7 @@ func (d *DB) Add(thumbnail *models.Thumbnail) error {
 		thumbnail.Source = models.SourceGenerated
 	}
 
-	_, err := d.db.Exec(`
+	_, err := d.exec(`
 		INSERT OR REPLACE INTO thumbnails 
 		(movie_path, movie_filename, thumbnail_path, status, viewed, width, height, duration, file_size, error_message, source) 
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
@@ -122,22 +206,33 @@ func (d *DB) UpsertThumbnail(thumbnail *models.Thumbnail) error {
 		thumbnail.Source = models.SourceGenerated
 	}
 
-	// SQLite supports "INSERT OR REPLACE" syntax for upsert operations
-	// For this to work correctly, we need to make sure movie_path is set as UNIQUE in the schema
-	_, err := d.db.Exec(`
-        INSERT OR REPLACE INTO thumbnails 
-        (id, movie_path, movie_filename, thumbnail_path, status, viewed, 
+	// Update the existing row in place, so the columns recorded separately (content
+	// hash, modification times, grid size, video stream, root usage, approvals and
+	// view history) survive; movie_path is UNIQUE in the schema
+	_, err := d.exec(`
+        INSERT INTO thumbnails 
+        (movie_path, movie_filename, thumbnail_path, status, viewed, 
          width, height, duration, file_size, error_message, source,
-         created_at, updated_at) 
+         recorded_at, generated_at, generator_version, poster_path, preview_path) 
         VALUES 
-        (
-            (SELECT id FROM thumbnails WHERE movie_path = ?), 
-            ?, ?, ?, ?, ?, 
-            ?, ?, ?, ?, ?, ?,
-            COALESCE((SELECT created_at FROM thumbnails WHERE movie_path = ?), CURRENT_TIMESTAMP),
-            CURRENT_TIMESTAMP
-        )`,
-		thumbnail.MoviePath, // For the subquery to find existing ID
+        (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT(movie_path) DO UPDATE SET
+            movie_filename = excluded.movie_filename,
+            thumbnail_path = excluded.thumbnail_path,
+            status = excluded.status,
+            viewed = excluded.viewed,
+            width = excluded.width,
+            height = excluded.height,
+            duration = excluded.duration,
+            file_size = excluded.file_size,
+            error_message = excluded.error_message,
+            source = excluded.source,
+            recorded_at = COALESCE(excluded.recorded_at, thumbnails.recorded_at),
+            generated_at = COALESCE(excluded.generated_at, thumbnails.generated_at),
+            generator_version = COALESCE(NULLIF(excluded.generator_version, ''), thumbnails.generator_version),
+            poster_path = COALESCE(NULLIF(excluded.poster_path, ''), thumbnails.poster_path),
+            preview_path = COALESCE(NULLIF(excluded.preview_path, ''), thumbnails.preview_path),
+            updated_at = CURRENT_TIMESTAMP`,
 		thumbnail.MoviePath,
 		thumbnail.MovieFilename,
 		thumbnail.ThumbnailPath,
@@ -149,7 +244,11 @@ func (d *DB) UpsertThumbnail(thumbnail *models.Thumbnail) error {
 		thumbnail.FileSize,
 		thumbnail.ErrorMessage,
 		thumbnail.Source,
-		thumbnail.MoviePath, // For the created_at preservation
+		timestampArg(thumbnail.RecordedAt),  // Kept when the movie has no recording date this time
+		timestampArg(thumbnail.GeneratedAt), // Kept until the thumbnail is generated again
+		thumbnail.GeneratorVersion,
+		thumbnail.PosterPath, // Artifacts are kept until they are generated again
+		thumbnail.PreviewPath,
 	)
 
 	if err != nil {
@@ -170,7 +269,7 @@ func (d *DB) UpsertThumbnail(thumbnail *models.Thumbnail) error {
 
 // UpdateStatus updates the status of a thumbnail
 func (d *DB) UpdateStatus(moviePath string, status string, errorMsg string) error {
-	_, err := d.db.Exec(`
+	_, err := d.exec(`
 		UPDATE thumbnails 
 		SET status = ?, error_message = ?
 		WHERE movie_path = ?`,
@@ -179,36 +278,188 @@ func (d *DB) UpdateStatus(moviePath string, status string, errorMsg string) erro
 	return err
 }
 
-// MarkAsViewedByID marks a thumbnail as viewed by ID
+// UpdateThumbnailPath changes the stored thumbnail path for a thumbnail by ID
+func (d *DB) UpdateThumbnailPath(id int64, thumbnailPath string) error {
+	_, err := d.exec(`
+		UPDATE thumbnails 
+		SET thumbnail_path = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		thumbnailPath, id,
+	)
+	return err
+}
+
+// SetContentHash records the partial content hash of a movie
+func (d *DB) SetContentHash(moviePath, hash string) error {
+	_, err := d.exec(`
+		UPDATE thumbnails 
+		SET content_hash = ?
+		WHERE movie_path = ?`,
+		hash, moviePath,
+	)
+	return err
+}
+
+// SetMovieMtime records the modification time of a movie file, in Unix seconds
+func (d *DB) SetMovieMtime(moviePath string, mtime int64) error {
+	_, err := d.exec(`
+		UPDATE thumbnails 
+		SET movie_mtime = ?
+		WHERE movie_path = ?`,
+		mtime, moviePath,
+	)
+	return err
+}
+
+// SetSidecarMtime records the modification time, in Unix seconds, of the override
+// sidecar a movie's thumbnail was generated with, or 0 when it had none
+func (d *DB) SetSidecarMtime(moviePath string, mtime int64) error {
+	_, err := d.exec(`
+		UPDATE thumbnails 
+		SET sidecar_mtime = ?
+		WHERE movie_path = ?`,
+		mtime, moviePath,
+	)
+	return err
+}
+
+// GetSidecarMtime returns the recorded modification time of a movie's override
+// sidecar, in Unix seconds, or 0 if it had none
+func (d *DB) GetSidecarMtime(moviePath string) (int64, error) {
+	var mtime sql.NullInt64
+	err := d.db.QueryRow("SELECT sidecar_mtime FROM thumbnails WHERE movie_path = ?", moviePath).Scan(&mtime)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error fetching sidecar modification time of %s: %w", moviePath, err)
+	}
+	return mtime.Int64, nil
+}
+
+// SetGridSize records the columns and rows of a generated thumbnail grid
+func (d *DB) SetGridSize(moviePath string, cols, rows int) error {
+	_, err := d.exec(`
+		UPDATE thumbnails 
+		SET grid_cols = ?, grid_rows = ?
+		WHERE movie_path = ?`,
+		cols, rows, moviePath,
+	)
+	return err
+}
+
+// SetVideoStream records which video stream, counted among the movie's video streams,
+// its thumbnail grid was generated from
+func (d *DB) SetVideoStream(moviePath string, stream int) error {
+	_, err := d.exec(`
+		UPDATE thumbnails 
+		SET video_stream = ?
+		WHERE movie_path = ?`,
+		stream, moviePath,
+	)
+	return err
+}
+
+// GetMovieMtime returns the recorded modification time of a movie file, in Unix
+// seconds, or 0 if none was recorded
+func (d *DB) GetMovieMtime(moviePath string) (int64, error) {
+	var mtime sql.NullInt64
+	err := d.db.QueryRow("SELECT movie_mtime FROM thumbnails WHERE movie_path = ?", moviePath).Scan(&mtime)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error fetching modification time of %s: %w", moviePath, err)
+	}
+	return mtime.Int64, nil
+}
+
+// SetRootUsage records the movie directory a movie was found in and the size of its
+// stored thumbnail
+func (d *DB) SetRootUsage(moviePath, rootDir string, thumbnailSize int64) error {
+	_, err := d.exec(`
+		UPDATE thumbnails 
+		SET root_dir = ?, thumbnail_size = ?
+		WHERE movie_path = ?`,
+		rootDir, thumbnailSize, moviePath,
+	)
+	return err
+}
+
+// RenameMovie moves a thumbnail record to a new movie file name and thumbnail path,
+// keeping its status and view history
+func (d *DB) RenameMovie(id int64, moviePath, movieFilename, thumbnailPath string) error {
+	_, err := d.exec(`
+		UPDATE thumbnails 
+		SET movie_path = ?, movie_filename = ?, thumbnail_path = ?
+		WHERE id = ?`,
+		moviePath, movieFilename, thumbnailPath, id,
+	)
+	return err
+}
+
+// MarkAsViewedByID marks a thumbnail as viewed by ID. It returns ErrNotFound if there
+// is no thumbnail with that ID.
 func (d *DB) MarkAsViewedByID(id int64) error {
-	_, err := d.db.Exec(`
+	return d.execByID(`
 		UPDATE thumbnails 
-		SET viewed = 1
+		SET viewed = 1, view_count = view_count + 1, last_viewed_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
 		id,
 	)
-	return err
 }
 
-// MarkForDeletionByID marks a thumbnail for deletion by ID without actually deleting it
+// MarkAsUnviewedByID clears the viewed flag of a thumbnail, keeping its view history.
+// It returns ErrNotFound if there is no thumbnail with that ID.
+func (d *DB) MarkAsUnviewedByID(id int64) error {
+	return d.execByID(`
+		UPDATE thumbnails 
+		SET viewed = 0
+		WHERE id = ?`,
+		id,
+	)
+}
+
+// MarkForDeletionByID marks a thumbnail for deletion by ID without actually deleting it.
+// It returns ErrNotFound if there is no thumbnail with that ID.
 func (d *DB) MarkForDeletionByID(id int64) error {
-	_, err := d.db.Exec(`
+	return d.execByID(`
 		UPDATE thumbnails 
-		SET status = 'deleted'
+		SET status = 'deleted', delete_attempts = 0, last_delete_attempt = 0, deletion_approved = 0,
+			queued_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
 		id,
 	)
-	return err
 }
 
-// MarkForArchivalByID marks a thumbnail for archival by ID
+// MarkForArchivalByID marks a thumbnail for archival by ID. It returns ErrNotFound if
+// there is no thumbnail with that ID.
 func (d *DB) MarkForArchivalByID(id int64) error {
-	_, err := d.db.Exec(`
+	return d.execByID(`
 		UPDATE thumbnails 
-		SET status = 'archived'
+		SET status = 'archived', queued_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
 		id,
 	)
+}
+
+// execByID runs an update of the thumbnail with the given ID, the statement's last
+// argument. When no row changes it returns ErrNotFound if the ID does not exist, and
+// nil if the row exists but did not match the statement's other conditions.
+func (d *DB) execByID(query string, id int64) error {
+	result, err := d.exec(query, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	var exists int
+	err = d.db.QueryRow("SELECT 1 FROM thumbnails WHERE id = ?", id).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("thumbnail %d: %w", id, ErrNotFound)
+	}
 	return err
 }
 
@@ -219,7 +470,9 @@ func (d *DB) GetByID(id int64) (*models.Thumbnail, error) {
 		SELECT 
 			id, movie_path, movie_filename, thumbnail_path, 
 			created_at, updated_at, status, viewed, 
-			width, height, duration, file_size, error_message, source
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
 		FROM thumbnails 
 		WHERE id = ?`,
 		id,
@@ -227,6 +480,8 @@ func (d *DB) GetByID(id int64) (*models.Thumbnail, error) {
 		&thumbnail.ID, &thumbnail.MoviePath, &thumbnail.MovieFilename, &thumbnail.ThumbnailPath,
 		&thumbnail.CreatedAt, &thumbnail.UpdatedAt, &thumbnail.Status, &thumbnail.Viewed,
 		&thumbnail.Width, &thumbnail.Height, &thumbnail.Duration, &thumbnail.FileSize, &thumbnail.ErrorMessage, &thumbnail.Source,
+		&thumbnail.ViewCount, &thumbnail.LastViewedAt, &thumbnail.RecordedAt, &thumbnail.GeneratedAt, &thumbnail.GeneratorVersion,
+		&thumbnail.PosterPath, &thumbnail.PreviewPath, &thumbnail.QueuedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -244,7 +499,9 @@ func (d *DB) GetByMoviePath(moviePath string) (*models.Thumbnail, error) {
 		SELECT 
 			id, movie_path, movie_filename, thumbnail_path, 
 			created_at, updated_at, status, viewed, 
-			width, height, duration, file_size, error_message, source
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
 		FROM thumbnails 
 		WHERE movie_path = ?`,
 		moviePath,
@@ -252,6 +509,8 @@ func (d *DB) GetByMoviePath(moviePath string) (*models.Thumbnail, error) {
 		&thumbnail.ID, &thumbnail.MoviePath, &thumbnail.MovieFilename, &thumbnail.ThumbnailPath,
 		&thumbnail.CreatedAt, &thumbnail.UpdatedAt, &thumbnail.Status, &thumbnail.Viewed,
 		&thumbnail.Width, &thumbnail.Height, &thumbnail.Duration, &thumbnail.FileSize, &thumbnail.ErrorMessage, &thumbnail.Source,
+		&thumbnail.ViewCount, &thumbnail.LastViewedAt, &thumbnail.RecordedAt, &thumbnail.GeneratedAt, &thumbnail.GeneratorVersion,
+		&thumbnail.PosterPath, &thumbnail.PreviewPath, &thumbnail.QueuedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -259,26 +518,60 @@ func (d *DB) GetByMoviePath(moviePath string) (*models.Thumbnail, error) {
 	return thumbnail, err
 }
 
-// GetByMovieFilename retrieves a thumbnail by its movie filename (for API endpoints)
+// GetByMovieFilename retrieves a thumbnail by its movie filename (for API endpoints).
+// With RECURSIVE_SCAN file names need not be unique; it returns ErrAmbiguous when
+// several movies have this one.
 func (d *DB) GetByMovieFilename(movieFilename string) (*models.Thumbnail, error) {
-	thumbnail := &models.Thumbnail{}
-	err := d.db.QueryRow(`
+	rows, err := d.db.Query(`
 		SELECT 
 			id, movie_path, movie_filename, thumbnail_path, 
 			created_at, updated_at, status, viewed, 
-			width, height, duration, file_size, error_message, source
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
 		FROM thumbnails 
-		WHERE movie_filename = ?`,
+		WHERE movie_filename = ?
+		LIMIT 2`,
 		movieFilename,
-	).Scan(
-		&thumbnail.ID, &thumbnail.MoviePath, &thumbnail.MovieFilename, &thumbnail.ThumbnailPath,
-		&thumbnail.CreatedAt, &thumbnail.UpdatedAt, &thumbnail.Status, &thumbnail.Viewed,
-		&thumbnail.Width, &thumbnail.Height, &thumbnail.Duration, &thumbnail.FileSize, &thumbnail.ErrorMessage, &thumbnail.Source,
 	)
-	if err == sql.ErrNoRows {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	thumbnails, err := scanThumbnails(rows)
+	switch {
+	case err != nil:
+		return nil, err
+	case len(thumbnails) == 0:
 		return nil, nil
+	case len(thumbnails) > 1:
+		return nil, fmt.Errorf("%s: %w", movieFilename, ErrAmbiguous)
 	}
-	return thumbnail, err
+	return thumbnails[0], nil
+}
+
+// GetByContentHash returns the successful thumbnails whose movie has the given
+// partial content hash, oldest first
+func (d *DB) GetByContentHash(hash string) ([]*models.Thumbnail, error) {
+	rows, err := d.db.Query(`
+		SELECT 
+			id, movie_path, movie_filename, thumbnail_path, 
+			created_at, updated_at, status, viewed, 
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
+		FROM thumbnails 
+		WHERE content_hash = ? AND status = 'success'
+		ORDER BY id`,
+		hash,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying thumbnails by content hash: %w", err)
+	}
+	defer rows.Close()
+
+	return scanThumbnails(rows)
 }
 
 // GetByThumbnailPath retrieves a thumbnail by its thumbnail path
@@ -288,7 +581,9 @@ func (d *DB) GetByThumbnailPath(thumbnailPath string) (*models.Thumbnail, error)
 		SELECT 
 			id, movie_path, movie_filename, thumbnail_path, 
 			created_at, updated_at, status, viewed,
-			width, height, duration, file_size, error_message, source
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
 		FROM thumbnails 
 		WHERE thumbnail_path = ?`,
 		thumbnailPath,
@@ -296,6 +591,8 @@ func (d *DB) GetByThumbnailPath(thumbnailPath string) (*models.Thumbnail, error)
 		&thumbnail.ID, &thumbnail.MoviePath, &thumbnail.MovieFilename, &thumbnail.ThumbnailPath,
 		&thumbnail.CreatedAt, &thumbnail.UpdatedAt, &thumbnail.Status, &thumbnail.Viewed,
 		&thumbnail.Width, &thumbnail.Height, &thumbnail.Duration, &thumbnail.FileSize, &thumbnail.ErrorMessage, &thumbnail.Source,
+		&thumbnail.ViewCount, &thumbnail.LastViewedAt, &thumbnail.RecordedAt, &thumbnail.GeneratedAt, &thumbnail.GeneratorVersion,
+		&thumbnail.PosterPath, &thumbnail.PreviewPath, &thumbnail.QueuedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -303,48 +600,179 @@ func (d *DB) GetByThumbnailPath(thumbnailPath string) (*models.Thumbnail, error)
 	return thumbnail, err
 }
 
+// Selection conditions for the random slideshow queries
+const (
+	unviewedCondition   = "status = 'success' AND viewed = 0 AND status != 'deleted' AND status != 'archived'"
+	successfulCondition = "status = 'success'"
+)
+
 // GetRandomUnviewedThumbnail gets a random unviewed thumbnail
 func (d *DB) GetRandomUnviewedThumbnail() (*models.Thumbnail, error) {
-	// First, count the total number of unviewed thumbnails
-	var count int
-	err := d.db.QueryRow(`
-		SELECT COUNT(*) 
-		FROM thumbnails 
-		WHERE status = 'success' AND viewed = 0 AND status != 'deleted' AND status != 'archived'
-	`).Scan(&count)
+	return d.getRandomThumbnail(unviewedCondition, nil, "unviewed")
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to count unviewed thumbnails: %w", err)
+// GetRandomUnviewedThumbnailExcluding gets a random unviewed thumbnail excluding specific IDs
+func (d *DB) GetRandomUnviewedThumbnailExcluding(excludeIDs ...int64) (*models.Thumbnail, error) {
+	return d.getRandomThumbnail(unviewedCondition, nil, "unviewed", excludeIDs...)
+}
+
+// GetRandomThumbnailNotViewedSince gets a random successful thumbnail, viewed or not,
+// that was not viewed at or after since (any when since is zero), excluding specific IDs
+func (d *DB) GetRandomThumbnailNotViewedSince(since time.Time, excludeIDs ...int64) (*models.Thumbnail, error) {
+	condition, args := lruCondition(since, nil)
+	return d.getRandomThumbnail(condition, args, "successful", excludeIDs...)
+}
+
+// GetRandomThumbnailBySource gets a random successful thumbnail of the given source
+// from the slideshow pool described by poolCondition, excluding specific IDs
+func (d *DB) GetRandomThumbnailBySource(source string, includeViewed bool, since time.Time, excludeIDs ...int64) (*models.Thumbnail, error) {
+	condition, args := poolCondition(includeViewed, since)
+	return d.getRandomThumbnail(condition+" AND source = ?", append(args, source), source, excludeIDs...)
+}
+
+// poolCondition restricts a query to the thumbnails a slideshow draws from: the
+// unviewed ones, or with includeViewed the successful ones not viewed since the given
+// time, so a review session shows each thumbnail once
+func poolCondition(includeViewed bool, since time.Time) (string, []interface{}) {
+	if includeViewed {
+		return lruCondition(since, nil)
 	}
+	return unviewedCondition, nil
+}
 
-	// If no unviewed thumbnails, return nil
-	if count == 0 {
-		return nil, nil
+// maxExcludePlaceholders is the largest exclude list bound as one parameter per ID;
+// longer lists are passed as a single JSON array to stay under SQLite's variable limit
+const maxExcludePlaceholders = 500
+
+// excludeCondition returns an "AND id NOT IN (...)" clause and its arguments for the
+// given IDs, or an empty clause when there is nothing to exclude
+func excludeCondition(excludeIDs []int64) (string, []interface{}) {
+	seen := make(map[int64]bool, len(excludeIDs))
+	ids := make([]int64, 0, len(excludeIDs))
+	for _, id := range excludeIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
 	}
 
-	// Generate a random offset
-	// We're using crypto/rand for better randomness
-	randomNum, err := rand.Int(rand.Reader, big.NewInt(int64(count)))
-	if err != nil {
-		// Fall back to math/rand if crypto/rand fails
-		offset := mathrand.Intn(count)
-		randomNum = big.NewInt(int64(offset))
+	switch {
+	case len(ids) == 0:
+		return "", nil
+	case len(ids) > maxExcludePlaceholders:
+		encoded, _ := json.Marshal(ids)
+		return " AND id NOT IN (SELECT value FROM json_each(?))", []interface{}{string(encoded)}
+	}
+
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
 	}
+	return " AND id NOT IN (" + strings.Join(placeholders, ", ") + ")", args
+}
+
+// getRandomThumbnail picks a random thumbnail matching condition, bound to
+// conditionArgs, excluding specific IDs
+func (d *DB) getRandomThumbnail(condition string, conditionArgs []interface{}, kind string, excludeIDs ...int64) (*models.Thumbnail, error) {
+	exclude, excludeArgs := excludeCondition(excludeIDs)
+	excludeArgs = append(append([]interface{}{}, conditionArgs...), excludeArgs...)
+
+	// The offset is picked from a separate count, so a row removed in between can
+	// leave it past the end; pick again rather than reporting no thumbnails
+	for attempt := 0; attempt < 3; attempt++ {
+		var count int
+		countQuery := `
+			SELECT COUNT(*) 
+			FROM thumbnails 
+			WHERE ` + condition + exclude
+
+		err := d.db.QueryRow(countQuery, excludeArgs...).Scan(&count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count %s thumbnails: %w", kind, err)
+		}
+
+		// If no thumbnails available, return nil
+		if count == 0 {
+			return nil, nil
+		}
+
+		offset := randomOffset(count)
+
+		// Get a random thumbnail using LIMIT and OFFSET
+		thumbnail := &models.Thumbnail{}
+		selectQuery := `
+			SELECT 
+				id, movie_path, movie_filename, thumbnail_path, 
+				created_at, updated_at, status, viewed,
+				width, height, duration, file_size, error_message, source,
+				view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+				poster_path, preview_path, queued_at
+			FROM thumbnails 
+			WHERE ` + condition + exclude + `
+			LIMIT 1 OFFSET ?`
+
+		selectArgs := append(append([]interface{}{}, excludeArgs...), offset)
+		err = d.db.QueryRow(selectQuery, selectArgs...).Scan(
+			&thumbnail.ID, &thumbnail.MoviePath, &thumbnail.MovieFilename, &thumbnail.ThumbnailPath,
+			&thumbnail.CreatedAt, &thumbnail.UpdatedAt, &thumbnail.Status, &thumbnail.Viewed,
+			&thumbnail.Width, &thumbnail.Height, &thumbnail.Duration, &thumbnail.FileSize, &thumbnail.ErrorMessage, &thumbnail.Source,
+			&thumbnail.ViewCount, &thumbnail.LastViewedAt, &thumbnail.RecordedAt, &thumbnail.GeneratedAt, &thumbnail.GeneratorVersion,
+			&thumbnail.PosterPath, &thumbnail.PreviewPath, &thumbnail.QueuedAt,
+		)
+
+		if err == sql.ErrNoRows {
+			continue
+		}
+
+		return thumbnail, err
+	}
+
+	return nil, nil
+}
+
+// lruCondition restricts a query to successful thumbnails not viewed since the given
+// time (all of them when since is zero), excluding specific IDs
+func lruCondition(since time.Time, excludeIDs []int64) (string, []interface{}) {
+	condition := successfulCondition
+	args := []interface{}{}
+
+	if !since.IsZero() {
+		condition += " AND (last_viewed_at IS NULL OR last_viewed_at < ?)"
+		args = append(args, since.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	exclude, excludeArgs := excludeCondition(excludeIDs)
+	condition += exclude
+	args = append(args, excludeArgs...)
+
+	return condition, args
+}
+
+// GetLeastRecentlyViewedThumbnail gets the successful thumbnail that was viewed longest
+// ago, preferring ones that were never viewed. Thumbnails viewed at or after since are
+// skipped, so a slideshow session shows each thumbnail once.
+func (d *DB) GetLeastRecentlyViewedThumbnail(since time.Time, excludeIDs ...int64) (*models.Thumbnail, error) {
+	condition, args := lruCondition(since, excludeIDs)
 
-	// Get a random thumbnail using LIMIT and OFFSET
 	thumbnail := &models.Thumbnail{}
-	err = d.db.QueryRow(`
+	err := d.db.QueryRow(`
 		SELECT 
 			id, movie_path, movie_filename, thumbnail_path, 
 			created_at, updated_at, status, viewed,
-			width, height, duration, file_size, error_message, source
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
 		FROM thumbnails 
-		WHERE status = 'success' AND viewed = 0 AND status != 'deleted' AND status != 'archived'
-		LIMIT 1 OFFSET ?
-	`, randomNum.Int64()).Scan(
+		WHERE `+condition+`
+		ORDER BY last_viewed_at ASC NULLS FIRST, id ASC
+		LIMIT 1`, args...).Scan(
 		&thumbnail.ID, &thumbnail.MoviePath, &thumbnail.MovieFilename, &thumbnail.ThumbnailPath,
 		&thumbnail.CreatedAt, &thumbnail.UpdatedAt, &thumbnail.Status, &thumbnail.Viewed,
 		&thumbnail.Width, &thumbnail.Height, &thumbnail.Duration, &thumbnail.FileSize, &thumbnail.ErrorMessage, &thumbnail.Source,
+		&thumbnail.ViewCount, &thumbnail.LastViewedAt, &thumbnail.RecordedAt, &thumbnail.GeneratedAt, &thumbnail.GeneratorVersion,
+		&thumbnail.PosterPath, &thumbnail.PreviewPath, &thumbnail.QueuedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -354,71 +782,34 @@ func (d *DB) GetRandomUnviewedThumbnail() (*models.Thumbnail, error) {
 	return thumbnail, err
 }
 
-// GetRandomUnviewedThumbnailExcluding gets a random unviewed thumbnail excluding specific IDs
-func (d *DB) GetRandomUnviewedThumbnailExcluding(excludeIDs ...int64) (*models.Thumbnail, error) {
-	// Build the exclude condition
-	excludeCondition := ""
-	excludeArgs := []interface{}{}
-
-	if len(excludeIDs) > 0 {
-		excludeCondition = " AND id NOT IN ("
-		for i, id := range excludeIDs {
-			if i > 0 {
-				excludeCondition += ", "
-			}
-			excludeCondition += "?"
-			excludeArgs = append(excludeArgs, id)
-		}
-		excludeCondition += ")"
-	}
-
-	// First, count the total number of unviewed thumbnails excluding the specified IDs
-	var count int
-	countQuery := `
-		SELECT COUNT(*) 
-		FROM thumbnails 
-		WHERE status = 'success' AND viewed = 0 AND status != 'deleted' AND status != 'archived'` + excludeCondition
-
-	err := d.db.QueryRow(countQuery, excludeArgs...).Scan(&count)
-	if err != nil {
-		return nil, fmt.Errorf("failed to count unviewed thumbnails: %w", err)
-	}
-
-	// If no unviewed thumbnails available, return nil
-	if count == 0 {
-		return nil, nil
-	}
+// GetEarliestRecordedThumbnail gets the thumbnail recorded first in the slideshow pool
+// described by poolCondition, excluding specific IDs. Undated thumbnails come last.
+func (d *DB) GetEarliestRecordedThumbnail(includeViewed bool, since time.Time, excludeIDs ...int64) (*models.Thumbnail, error) {
+	condition, args := poolCondition(includeViewed, since)
+	exclude, excludeArgs := excludeCondition(excludeIDs)
+	args = append(args, excludeArgs...)
 
-	// Generate a random offset
-	randomNum, err := rand.Int(rand.Reader, big.NewInt(int64(count)))
-	if err != nil {
-		// Fall back to math/rand if crypto/rand fails
-		offset := mathrand.Intn(count)
-		randomNum = big.NewInt(int64(offset))
-	}
-
-	// Get a random thumbnail using LIMIT and OFFSET
 	thumbnail := &models.Thumbnail{}
-	selectQuery := `
+	err := d.db.QueryRow(`
 		SELECT 
 			id, movie_path, movie_filename, thumbnail_path, 
 			created_at, updated_at, status, viewed,
-			width, height, duration, file_size, error_message, source
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
 		FROM thumbnails 
-		WHERE status = 'success' AND viewed = 0 AND status != 'deleted' AND status != 'archived'` + excludeCondition + `
-		LIMIT 1 OFFSET ?`
-
-	selectArgs := append(excludeArgs, randomNum.Int64())
-	err = d.db.QueryRow(selectQuery, selectArgs...).Scan(
+		WHERE `+condition+exclude+`
+		ORDER BY recorded_at IS NULL, recorded_at ASC, id ASC
+		LIMIT 1`, args...).Scan(
 		&thumbnail.ID, &thumbnail.MoviePath, &thumbnail.MovieFilename, &thumbnail.ThumbnailPath,
 		&thumbnail.CreatedAt, &thumbnail.UpdatedAt, &thumbnail.Status, &thumbnail.Viewed,
 		&thumbnail.Width, &thumbnail.Height, &thumbnail.Duration, &thumbnail.FileSize, &thumbnail.ErrorMessage, &thumbnail.Source,
+		&thumbnail.ViewCount, &thumbnail.LastViewedAt, &thumbnail.RecordedAt, &thumbnail.GeneratedAt, &thumbnail.GeneratorVersion,
+		&thumbnail.PosterPath, &thumbnail.PreviewPath, &thumbnail.QueuedAt,
 	)
-
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
-
 	return thumbnail, err
 }
 
@@ -434,10 +825,12 @@ func (d *DB) GetDeletedThumbnails(limit int) ([]*models.Thumbnail, error) {
         SELECT 
             id, movie_path, movie_filename, thumbnail_path, 
             created_at, updated_at, status, viewed,
-            width, height, duration, file_size, error_message, source
+            width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
         FROM thumbnails 
         WHERE status = 'deleted'
-        ORDER BY updated_at DESC`
+        ORDER BY queued_at DESC`
 
 	// Add limit clause if a limit is specified
 	if limit > 0 {
@@ -454,6 +847,276 @@ func (d *DB) GetDeletedThumbnails(limit int) ([]*models.Thumbnail, error) {
 	return scanThumbnails(rows)
 }
 
+// GetDeletedThumbnailsPaged retrieves a page of the deletion queue, oldest queued first,
+// along with the total number of queued items
+func (d *DB) GetDeletedThumbnailsPaged(limit, offset int) ([]*models.Thumbnail, int, error) {
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM thumbnails WHERE status = 'deleted'`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if limit <= 0 {
+		limit = -1 // SQLite treats a negative limit as no limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := d.db.Query(`
+        SELECT 
+            id, movie_path, movie_filename, thumbnail_path, 
+            created_at, updated_at, status, viewed,
+            width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
+        FROM thumbnails 
+        WHERE status = 'deleted'
+        ORDER BY queued_at ASC, id ASC
+        LIMIT ? OFFSET ?`,
+		limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	thumbnails, err := scanThumbnails(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return thumbnails, total, nil
+}
+
+// GetDeletionCandidates retrieves thumbnails queued for deletion whose retry backoff has
+// elapsed. After n failed attempts a row waits baseBackoff * 2^(n-1) before the next try.
+// With approvedOnly, rows not yet approved by ApproveDeletions are left out.
+func (d *DB) GetDeletionCandidates(now time.Time, baseBackoff time.Duration, approvedOnly bool) ([]*models.Thumbnail, error) {
+	rows, err := d.db.Query(`
+        SELECT 
+            id, movie_path, movie_filename, thumbnail_path, 
+            created_at, updated_at, status, viewed,
+            width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
+        FROM thumbnails 
+        WHERE status = 'deleted'
+          AND (delete_attempts <= 0
+               OR last_delete_attempt + ? * (1 << (MIN(delete_attempts, 31) - 1)) <= ?)
+          AND (? = 0 OR deletion_approved = 1)
+        ORDER BY queued_at DESC`,
+		int64(baseBackoff/time.Second), now.Unix(), approvedOnly,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanThumbnails(rows)
+}
+
+// GetThumbnailsGeneratedBefore retrieves the thumbnails last generated before t, oldest
+// first. Imported thumbnails and those generated before generated_at was recorded have
+// no generation time and are left out.
+func (d *DB) GetThumbnailsGeneratedBefore(t time.Time) ([]*models.Thumbnail, error) {
+	rows, err := d.db.Query(`
+		SELECT 
+			id, movie_path, movie_filename, thumbnail_path, 
+			created_at, updated_at, status, viewed,
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
+		FROM thumbnails 
+		WHERE generated_at < ?
+		ORDER BY generated_at ASC, id ASC`,
+		timestampArg(&t),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanThumbnails(rows)
+}
+
+// GetThumbnailsGeneratedWithVersion retrieves the thumbnails last generated by the
+// given generator version, see ffmpeg.Thumbnailer.GeneratorVersion. An empty version
+// matches the thumbnails without one.
+func (d *DB) GetThumbnailsGeneratedWithVersion(version string) ([]*models.Thumbnail, error) {
+	rows, err := d.db.Query(`
+		SELECT 
+			id, movie_path, movie_filename, thumbnail_path, 
+			created_at, updated_at, status, viewed,
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
+		FROM thumbnails 
+		WHERE generator_version = ?
+		ORDER BY id`,
+		version,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanThumbnails(rows)
+}
+
+// ApproveDeletions approves queued deletions for DELETION_REQUIRE_APPROVAL, the given
+// IDs or, without IDs, the whole queue. It returns the number of rows approved.
+func (d *DB) ApproveDeletions(ids ...int64) (int64, error) {
+	query := `UPDATE thumbnails SET deletion_approved = 1 WHERE status = 'deleted'`
+	var args []interface{}
+	if len(ids) > 0 {
+		query += ` AND id IN (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+
+	result, err := d.exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RecordDeleteFailure counts a failed deletion attempt for a thumbnail by ID. Once
+// maxAttempts is reached the row is moved to the delete_failed status. It returns
+// the new attempt count.
+func (d *DB) RecordDeleteFailure(id int64, now time.Time, maxAttempts int) (int, error) {
+	_, err := d.exec(`
+		UPDATE thumbnails 
+		SET delete_attempts = delete_attempts + 1,
+		    last_delete_attempt = ?,
+		    status = CASE WHEN ? > 0 AND delete_attempts + 1 >= ? THEN 'delete_failed' ELSE status END
+		WHERE id = ?`,
+		now.Unix(), maxAttempts, maxAttempts, id,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var attempts int
+	err = d.db.QueryRow("SELECT delete_attempts FROM thumbnails WHERE id = ?", id).Scan(&attempts)
+	return attempts, err
+}
+
+// ThumbnailFilter selects thumbnails for GetThumbnailsFiltered. Zero-valued fields
+// are ignored.
+type ThumbnailFilter struct {
+	Status string // Only thumbnails with this status
+	Viewed *bool  // Only viewed (true) or unviewed (false) thumbnails
+	Source string // Only generated or imported thumbnails
+
+	// Only movies narrower or lower than this many pixels
+	WidthBelow  int
+	HeightBelow int
+
+	// Only movies recorded at or after, and before, these times
+	RecordedAfter  time.Time
+	RecordedBefore time.Time
+
+	Sort string // SortRecordedAt, or "" for the default order
+}
+
+// SortRecordedAt orders thumbnails chronologically by recording date, undated ones last
+const SortRecordedAt = "recorded_at"
+
+// GetThumbnailsFiltered retrieves a page of the thumbnails matching filter, along with
+// the total number of matches. Unless filter.Sort asks for the recording date, deleted
+// and archived thumbnails are ordered by when they were queued, all others by
+// creation, newest first.
+func (d *DB) GetThumbnailsFiltered(filter ThumbnailFilter, limit, offset int) ([]*models.Thumbnail, int, error) {
+	where := ` WHERE 1 = 1`
+	var args []interface{}
+	if filter.Status != "" {
+		where += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	if filter.Viewed != nil {
+		where += ` AND viewed = ?`
+		if *filter.Viewed {
+			args = append(args, 1)
+		} else {
+			args = append(args, 0)
+		}
+	}
+	if filter.Source != "" {
+		where += ` AND source = ?`
+		args = append(args, filter.Source)
+	}
+	if filter.WidthBelow > 0 {
+		where += ` AND width < ?`
+		args = append(args, filter.WidthBelow)
+	}
+	if filter.HeightBelow > 0 {
+		where += ` AND height < ?`
+		args = append(args, filter.HeightBelow)
+	}
+	if !filter.RecordedAfter.IsZero() {
+		where += ` AND recorded_at >= ?`
+		args = append(args, timestampArg(&filter.RecordedAfter))
+	}
+	if !filter.RecordedBefore.IsZero() {
+		where += ` AND recorded_at < ?`
+		args = append(args, timestampArg(&filter.RecordedBefore))
+	}
+
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM thumbnails`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order := ` ORDER BY created_at DESC, id DESC`
+	switch {
+	case filter.Sort == SortRecordedAt:
+		order = ` ORDER BY recorded_at IS NULL, recorded_at ASC, id ASC`
+	case filter.Status == models.StatusDeleted || filter.Status == models.StatusArchived:
+		order = ` ORDER BY queued_at DESC, id DESC`
+	}
+	if limit <= 0 {
+		limit = -1 // SQLite treats a negative limit as no limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := d.db.Query(`
+        SELECT 
+            id, movie_path, movie_filename, thumbnail_path, 
+            created_at, updated_at, status, viewed,
+            width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
+        FROM thumbnails`+where+order+`
+        LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	thumbnails, err := scanThumbnails(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return thumbnails, total, nil
+}
+
+// ThumbnailsVersion returns a value that changes whenever the thumbnails table does:
+// the row count, the latest updated_at and the number of writes through this DB
+func (d *DB) ThumbnailsVersion() (string, error) {
+	var count int
+	var latest sql.NullString
+	if err := d.db.QueryRow(`SELECT COUNT(*), MAX(updated_at) FROM thumbnails`).Scan(&count, &latest); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%s-%d", count, latest.String, d.stats.generation()), nil
+}
+
 // GetArchivedThumbnails retrieves thumbnails marked for archival
 // If limit > 0, only that many items will be returned
 // If limit = 0, all matching thumbnails will be returned
@@ -466,10 +1129,12 @@ func (d *DB) GetArchivedThumbnails(limit int) ([]*models.Thumbnail, error) {
         SELECT 
             id, movie_path, movie_filename, thumbnail_path, 
             created_at, updated_at, status, viewed,
-            width, height, duration, file_size, error_message, source
+            width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
         FROM thumbnails 
         WHERE status = 'archived'
-        ORDER BY updated_at DESC`
+        ORDER BY queued_at DESC`
 
 	// Add limit clause if a limit is specified
 	if limit > 0 {
@@ -493,7 +1158,9 @@ func (d *DB) GetFirstUnviewedThumbnail() (*models.Thumbnail, error) {
         SELECT 
             id, movie_path, movie_filename, thumbnail_path, 
             created_at, updated_at, status, viewed,
-            width, height, duration, file_size, error_message, source
+            width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
         FROM thumbnails 
         WHERE status = 'success' AND viewed = 0 AND status != 'deleted' AND status != 'archived'
         ORDER BY id ASC
@@ -502,6 +1169,8 @@ func (d *DB) GetFirstUnviewedThumbnail() (*models.Thumbnail, error) {
 		&thumbnail.ID, &thumbnail.MoviePath, &thumbnail.MovieFilename, &thumbnail.ThumbnailPath,
 		&thumbnail.CreatedAt, &thumbnail.UpdatedAt, &thumbnail.Status, &thumbnail.Viewed,
 		&thumbnail.Width, &thumbnail.Height, &thumbnail.Duration, &thumbnail.FileSize, &thumbnail.ErrorMessage, &thumbnail.Source,
+		&thumbnail.ViewCount, &thumbnail.LastViewedAt, &thumbnail.RecordedAt, &thumbnail.GeneratedAt, &thumbnail.GeneratorVersion,
+		&thumbnail.PosterPath, &thumbnail.PreviewPath, &thumbnail.QueuedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -518,7 +1187,9 @@ func (d *DB) GetNextUnviewedThumbnail(currentID int64) (*models.Thumbnail, error
         SELECT 
             id, movie_path, movie_filename, thumbnail_path, 
             created_at, updated_at, status, viewed,
-            width, height, duration, file_size, error_message, source
+            width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
         FROM thumbnails 
         WHERE status = 'success' AND viewed = 0 AND status != 'deleted' AND status != 'archived' AND id > ?
         ORDER BY id ASC
@@ -527,6 +1198,8 @@ func (d *DB) GetNextUnviewedThumbnail(currentID int64) (*models.Thumbnail, error
 		&thumbnail.ID, &thumbnail.MoviePath, &thumbnail.MovieFilename, &thumbnail.ThumbnailPath,
 		&thumbnail.CreatedAt, &thumbnail.UpdatedAt, &thumbnail.Status, &thumbnail.Viewed,
 		&thumbnail.Width, &thumbnail.Height, &thumbnail.Duration, &thumbnail.FileSize, &thumbnail.ErrorMessage, &thumbnail.Source,
+		&thumbnail.ViewCount, &thumbnail.LastViewedAt, &thumbnail.RecordedAt, &thumbnail.GeneratedAt, &thumbnail.GeneratorVersion,
+		&thumbnail.PosterPath, &thumbnail.PreviewPath, &thumbnail.QueuedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -548,7 +1221,9 @@ func (d *DB) GetPreviousThumbnail(currentID int64) (*models.Thumbnail, error) {
         SELECT 
             id, movie_path, movie_filename, thumbnail_path, 
             created_at, updated_at, status, viewed,
-            width, height, duration, file_size, error_message, source
+            width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
         FROM thumbnails 
         WHERE status = 'success' AND status != 'deleted' AND id < ?
         ORDER BY id DESC
@@ -557,6 +1232,8 @@ func (d *DB) GetPreviousThumbnail(currentID int64) (*models.Thumbnail, error) {
 		&thumbnail.ID, &thumbnail.MoviePath, &thumbnail.MovieFilename, &thumbnail.ThumbnailPath,
 		&thumbnail.CreatedAt, &thumbnail.UpdatedAt, &thumbnail.Status, &thumbnail.Viewed,
 		&thumbnail.Width, &thumbnail.Height, &thumbnail.Duration, &thumbnail.FileSize, &thumbnail.ErrorMessage, &thumbnail.Source,
+		&thumbnail.ViewCount, &thumbnail.LastViewedAt, &thumbnail.RecordedAt, &thumbnail.GeneratedAt, &thumbnail.GeneratorVersion,
+		&thumbnail.PosterPath, &thumbnail.PreviewPath, &thumbnail.QueuedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -578,6 +1255,14 @@ func (d *DB) GetUnviewedThumbnailCount() (int, error) {
 	return count, err
 }
 
+// GetSuccessfulThumbnailCount returns the total count of successful thumbnails, viewed or not
+func (d *DB) GetSuccessfulThumbnailCount() (int, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM thumbnails WHERE ` + successfulCondition).Scan(&count)
+
+	return count, err
+}
+
 // GetThumbnailPosition gets the position of a thumbnail in the unviewed sequence
 func (d *DB) GetThumbnailPosition(id int64) (int, error) {
 	var position int
@@ -596,7 +1281,9 @@ func (d *DB) GetUnviewedThumbnails() ([]*models.Thumbnail, error) {
         SELECT 
             id, movie_path, movie_filename, thumbnail_path, 
             created_at, updated_at, status, viewed,
-            width, height, duration, file_size, error_message, source
+            width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
         FROM thumbnails 
         WHERE status = 'success' AND viewed = 0
         ORDER BY updated_at DESC
@@ -616,7 +1303,9 @@ func (d *DB) GetViewedThumbnails() ([]*models.Thumbnail, error) {
 		SELECT 
 			id, movie_path, movie_filename, thumbnail_path, 
 			created_at, updated_at, status, viewed,
-			width, height, duration, file_size, error_message, source
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
 		FROM thumbnails 
 		WHERE status = 'success' AND viewed = 1
 		ORDER BY created_at DESC`,
@@ -629,13 +1318,31 @@ func (d *DB) GetViewedThumbnails() ([]*models.Thumbnail, error) {
 	return scanThumbnails(rows)
 }
 
+// ResetPendingToRetry prepares rows left pending by a previous run for regeneration.
+// Nothing is being generated while the application starts, so every pending row is
+// stale. The rows stay pending, which makes the next scan regenerate them, and lose
+// the metadata of the interrupted attempt. It returns the number of rows reset.
+func (d *DB) ResetPendingToRetry() (int64, error) {
+	result, err := d.exec(`
+		UPDATE thumbnails 
+		SET error_message = '', width = 0, height = 0, duration = 0
+		WHERE status = 'pending'`,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reset pending thumbnails: %w", err)
+	}
+	return result.RowsAffected()
+}
+
 // GetPendingThumbnails retrieves all pending thumbnails
 func (d *DB) GetPendingThumbnails() ([]*models.Thumbnail, error) {
 	rows, err := d.db.Query(`
 		SELECT 
 			id, movie_path, movie_filename, thumbnail_path, 
 			created_at, updated_at, status, viewed,
-			width, height, duration, file_size, error_message, source
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
 		FROM thumbnails 
 		WHERE status = 'pending'
 		ORDER BY created_at DESC`,
@@ -648,13 +1355,89 @@ func (d *DB) GetPendingThumbnails() ([]*models.Thumbnail, error) {
 	return scanThumbnails(rows)
 }
 
+// GetRowsNeedingFileSize retrieves successful thumbnails whose movie file size was never recorded
+func (d *DB) GetRowsNeedingFileSize() ([]*models.Thumbnail, error) {
+	rows, err := d.db.Query(`
+		SELECT 
+			id, movie_path, movie_filename, thumbnail_path, 
+			created_at, updated_at, status, viewed,
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
+		FROM thumbnails 
+		WHERE status = 'success' AND file_size = 0
+		ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanThumbnails(rows)
+}
+
+// GetRowsNeedingRootDir retrieves successful thumbnails whose movie directory was never recorded
+func (d *DB) GetRowsNeedingRootDir() ([]*models.Thumbnail, error) {
+	rows, err := d.db.Query(`
+		SELECT 
+			id, movie_path, movie_filename, thumbnail_path, 
+			created_at, updated_at, status, viewed,
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
+		FROM thumbnails 
+		WHERE status = 'success' AND root_dir = ''
+		ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanThumbnails(rows)
+}
+
+// GetRowsNeedingContentHash retrieves successful thumbnails whose movie content hash was never recorded
+func (d *DB) GetRowsNeedingContentHash() ([]*models.Thumbnail, error) {
+	rows, err := d.db.Query(`
+		SELECT 
+			id, movie_path, movie_filename, thumbnail_path, 
+			created_at, updated_at, status, viewed,
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
+		FROM thumbnails 
+		WHERE status = 'success' AND content_hash IS NULL
+		ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanThumbnails(rows)
+}
+
+// UpdateFileSize sets only the movie file size for a thumbnail by ID
+func (d *DB) UpdateFileSize(id int64, fileSize int64) error {
+	_, err := d.exec(`
+		UPDATE thumbnails 
+		SET file_size = ?
+		WHERE id = ?`,
+		fileSize, id,
+	)
+	return err
+}
+
 // GetErrorThumbnails retrieves all thumbnails with errors
 func (d *DB) GetErrorThumbnails() ([]*models.Thumbnail, error) {
 	rows, err := d.db.Query(`
 		SELECT 
 			id, movie_path, movie_filename, thumbnail_path, 
 			created_at, updated_at, status, viewed,
-			width, height, duration, file_size, error_message, source
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
 		FROM thumbnails 
 		WHERE status = 'error'
 		ORDER BY created_at DESC`,
@@ -667,13 +1450,65 @@ func (d *DB) GetErrorThumbnails() ([]*models.Thumbnail, error) {
 	return scanThumbnails(rows)
 }
 
-// GetAllThumbnails retrieves all thumbnails
+// PruneStaleErrors deletes the error rows last updated before cutoff whose movie is
+// gone according to exists, returning the number deleted. Failing movies that are
+// still on disk are kept.
+func (d *DB) PruneStaleErrors(cutoff time.Time, exists func(moviePath string) bool) (int64, error) {
+	rows, err := d.db.Query(`
+		SELECT id, movie_path
+		FROM thumbnails
+		WHERE status = 'error' AND updated_at < ?
+		ORDER BY id`,
+		timestampArg(&cutoff),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var stale []int64
+	for rows.Next() {
+		var id int64
+		var moviePath string
+		if err := rows.Scan(&id, &moviePath); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !exists(moviePath) {
+			stale = append(stale, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var pruned int64
+	for _, id := range stale {
+		// The row may have been retried since it was read
+		result, err := d.exec(`
+			DELETE FROM thumbnails
+			WHERE id = ? AND status = 'error'`,
+			id,
+		)
+		if err != nil {
+			return pruned, err
+		}
+		n, _ := result.RowsAffected()
+		pruned += n
+	}
+	return pruned, nil
+}
+
+// GetAllThumbnails retrieves all thumbnails. The whole table is loaded into memory,
+// so code walking a large library should use ForEachThumbnail instead.
 func (d *DB) GetAllThumbnails() ([]*models.Thumbnail, error) {
 	rows, err := d.db.Query(`
 		SELECT 
 			id, movie_path, movie_filename, thumbnail_path, 
 			created_at, updated_at, status, viewed,
-			width, height, duration, file_size, error_message, source
+			width, height, duration, file_size, error_message, source,
+			view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+			poster_path, preview_path, queued_at
 		FROM thumbnails
 		ORDER BY created_at DESC`,
 	)
@@ -685,9 +1520,52 @@ func (d *DB) GetAllThumbnails() ([]*models.Thumbnail, error) {
 	return scanThumbnails(rows)
 }
 
+// forEachBatchSize is how many rows ForEachThumbnail reads at a time
+const forEachBatchSize = 500
+
+// ForEachThumbnail calls fn for every thumbnail in ID order, stopping at the first error.
+// Rows are read in batches, so memory use doesn't grow with the library and the
+// connection is free between batches, letting fn use the database itself.
+func (d *DB) ForEachThumbnail(ctx context.Context, fn func(*models.Thumbnail) error) error {
+	var lastID int64
+	for {
+		rows, err := d.db.QueryContext(ctx, `
+			SELECT 
+				id, movie_path, movie_filename, thumbnail_path, 
+				created_at, updated_at, status, viewed,
+				width, height, duration, file_size, error_message, source,
+				view_count, last_viewed_at, recorded_at, generated_at, generator_version,
+				poster_path, preview_path, queued_at
+			FROM thumbnails
+			WHERE id > ?
+			ORDER BY id
+			LIMIT ?`,
+			lastID, forEachBatchSize,
+		)
+		if err != nil {
+			return err
+		}
+		batch, err := scanThumbnails(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, thumbnail := range batch {
+			if err := fn(thumbnail); err != nil {
+				return err
+			}
+		}
+		if len(batch) < forEachBatchSize {
+			return nil
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
+
 // ResetViewedStatus resets the viewed status of all thumbnails
 func (d *DB) ResetViewedStatus() (int64, error) {
-	result, err := d.db.Exec(`
+	result, err := d.exec(`
 		UPDATE thumbnails 
 		SET viewed = 0 
 		WHERE viewed = 1`,
@@ -698,9 +1576,71 @@ func (d *DB) ResetViewedStatus() (int64, error) {
 	return result.RowsAffected()
 }
 
+// ResetFilter restricts which thumbnails have their viewed status reset.
+// Zero-valued fields are ignored.
+type ResetFilter struct {
+	MinSize       int64     // Only movies at least this many bytes
+	MaxSize       int64     // Only movies at most this many bytes
+	CreatedAfter  time.Time // Only thumbnails created at or after this time
+	CreatedBefore time.Time // Only thumbnails created before this time
+	Source        string    // Only thumbnails with this source
+	PathPrefix    string    // Only movies whose path starts with this prefix
+
+	ClearHistory bool // Also zero view_count and last_viewed_at instead of preserving them
+}
+
+// IsEmpty reports whether the filter matches every thumbnail
+func (f ResetFilter) IsEmpty() bool {
+	f.ClearHistory = false
+	return f == ResetFilter{}
+}
+
+// ResetViewedStatusFiltered resets the viewed status of the thumbnails matching filter
+func (d *DB) ResetViewedStatusFiltered(filter ResetFilter) (int64, error) {
+	const timeFormat = "2006-01-02 15:04:05" // Matches CURRENT_TIMESTAMP
+
+	query := `UPDATE thumbnails SET viewed = 0 WHERE viewed = 1`
+	if filter.ClearHistory {
+		query = `UPDATE thumbnails SET viewed = 0, view_count = 0, last_viewed_at = NULL
+			WHERE (viewed = 1 OR view_count > 0 OR last_viewed_at IS NOT NULL)`
+	}
+	var args []interface{}
+
+	if filter.MinSize > 0 {
+		query += ` AND file_size >= ?`
+		args = append(args, filter.MinSize)
+	}
+	if filter.MaxSize > 0 {
+		query += ` AND file_size <= ?`
+		args = append(args, filter.MaxSize)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, filter.CreatedAfter.UTC().Format(timeFormat))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query += ` AND created_at < ?`
+		args = append(args, filter.CreatedBefore.UTC().Format(timeFormat))
+	}
+	if filter.Source != "" {
+		query += ` AND source = ?`
+		args = append(args, filter.Source)
+	}
+	if filter.PathPrefix != "" {
+		query += ` AND substr(movie_path, 1, length(?)) = ?`
+		args = append(args, filter.PathPrefix, filter.PathPrefix)
+	}
+
+	result, err := d.exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // DeleteThumbnail deletes a thumbnail record
 func (d *DB) DeleteThumbnail(moviePath string) error {
-	_, err := d.db.Exec(`
+	_, err := d.exec(`
 		DELETE FROM thumbnails 
 		WHERE movie_path = ?`,
 		moviePath,
@@ -708,43 +1648,54 @@ func (d *DB) DeleteThumbnail(moviePath string) error {
 	return err
 }
 
-// RestoreFromDeletion restores a thumbnail from deletion status back to success
+// RestoreFromDeletion restores a thumbnail from deletion status back to success, looked
+// up by movie path.
+//
+// Deprecated: the server identifies thumbnails by ID; use RestoreFromDeletionByID.
 func (d *DB) RestoreFromDeletion(moviePath string) error {
-	_, err := d.db.Exec(`
-        UPDATE thumbnails 
-        SET status = 'success', viewed = 0
-        WHERE movie_path = ? AND status = 'deleted'`,
-		moviePath,
-	)
-	return err
+	thumbnail, err := d.GetByMoviePath(moviePath)
+	if err != nil || thumbnail == nil {
+		return err
+	}
+	return d.RestoreFromDeletionByID(thumbnail.ID)
 }
 
-// RestoreFromDeletionByID restores a thumbnail from deletion status back to success by ID
+// RestoreFromDeletionByID restores a thumbnail from deletion status back to success by
+// ID. Thumbnails that are not queued for deletion are left alone; it returns
+// ErrNotFound if there is no thumbnail with that ID.
 func (d *DB) RestoreFromDeletionByID(id int64) error {
-	_, err := d.db.Exec(`
+	return d.execByID(`
         UPDATE thumbnails 
-        SET status = 'success', viewed = 0
-        WHERE id = ? AND status = 'deleted'`,
+        SET status = 'success', viewed = 0, delete_attempts = 0, last_delete_attempt = 0, deletion_approved = 0,
+            queued_at = NULL
+        WHERE status IN ('deleted', 'delete_failed') AND id = ?`,
 		id,
 	)
-	return err
 }
 
+// GetStats returns thumbnail counts and sizes, served from the stats cache when fresh
 func (d *DB) GetStats() (*models.Stats, error) {
+	return d.stats.get(d.queryStats)
+}
+
+// queryStats aggregates thumbnail counts and sizes over the whole table
+func (d *DB) queryStats() (*models.Stats, error) {
 	stats := &models.Stats{}
 
 	err := d.db.QueryRow(`
 		SELECT
 			COUNT(*) as total,
-			SUM(CASE WHEN status = 'success' THEN 1 ELSE 0 END) as success,
-			SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END) as error,
-			SUM(CASE WHEN status = 'pending' THEN 1 ELSE 0 END) as pending,
-			SUM(CASE WHEN status = 'success' AND viewed = 1 THEN 1 ELSE 0 END) as viewed,
-			SUM(CASE WHEN status = 'success' AND viewed = 0 THEN 1 ELSE 0 END) as unviewed,
-			SUM(CASE WHEN status = 'deleted' THEN 1 ELSE 0 END) as deleted,
-			SUM(CASE WHEN status = 'archived' THEN 1 ELSE 0 END) as archived,
-			SUM(CASE WHEN source = 'generated' THEN 1 ELSE 0 END) as generated,
-			SUM(CASE WHEN source = 'imported' THEN 1 ELSE 0 END) as imported,
+			COALESCE(SUM(CASE WHEN status = 'success' THEN 1 ELSE 0 END), 0) as success,
+			COALESCE(SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END), 0) as error,
+			COALESCE(SUM(CASE WHEN status = 'pending' THEN 1 ELSE 0 END), 0) as pending,
+			COALESCE(SUM(CASE WHEN status = 'success' AND viewed = 1 THEN 1 ELSE 0 END), 0) as viewed,
+			COALESCE(SUM(CASE WHEN status = 'success' AND viewed = 0 THEN 1 ELSE 0 END), 0) as unviewed,
+			COALESCE(SUM(CASE WHEN status = 'deleted' THEN 1 ELSE 0 END), 0) as deleted,
+			COALESCE(SUM(CASE WHEN status = 'archived' THEN 1 ELSE 0 END), 0) as archived,
+			COALESCE(SUM(CASE WHEN status = 'delete_failed' THEN 1 ELSE 0 END), 0) as delete_failed,
+			COALESCE(SUM(CASE WHEN status = 'incomplete' THEN 1 ELSE 0 END), 0) as incomplete,
+			COALESCE(SUM(CASE WHEN source = 'generated' THEN 1 ELSE 0 END), 0) as generated,
+			COALESCE(SUM(CASE WHEN source = 'imported' THEN 1 ELSE 0 END), 0) as imported,
 			COALESCE(SUM(CASE WHEN status = 'success' AND viewed = 1 THEN file_size ELSE 0 END), 0) as viewed_size,
 			COALESCE(SUM(CASE WHEN status = 'success' AND viewed = 0 THEN file_size ELSE 0 END), 0) as unviewed_size
 		FROM thumbnails
@@ -757,15 +1708,85 @@ func (d *DB) GetStats() (*models.Stats, error) {
 		&stats.Unviewed,
 		&stats.Deleted,
 		&stats.Archived,
+		&stats.DeleteFailed,
+		&stats.Incomplete,
 		&stats.Generated,
 		&stats.Imported,
 		&stats.ViewedSize,
 		&stats.UnviewedSize,
 	)
+	stats.CaughtUp = stats.Success > 0 && stats.Unviewed == 0
 
 	return stats, err
 }
 
+// GetStatsByRoot aggregates thumbnail counts and sizes per movie directory, ordered
+// by directory. Rows recorded before the directory was tracked have an empty RootDir.
+func (d *DB) GetStatsByRoot() ([]*models.RootStats, error) {
+	rows, err := d.db.Query(`
+		SELECT
+			root_dir,
+			COUNT(*) as total,
+			COALESCE(SUM(CASE WHEN status = 'success' THEN 1 ELSE 0 END), 0) as success,
+			COALESCE(SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END), 0) as error,
+			COALESCE(SUM(CASE WHEN status = 'pending' THEN 1 ELSE 0 END), 0) as pending,
+			COALESCE(SUM(CASE WHEN status = 'success' AND viewed = 1 THEN 1 ELSE 0 END), 0) as viewed,
+			COALESCE(SUM(CASE WHEN status = 'success' AND viewed = 0 THEN 1 ELSE 0 END), 0) as unviewed,
+			COALESCE(SUM(CASE WHEN status = 'success' THEN file_size ELSE 0 END), 0) as movie_size,
+			COALESCE(SUM(CASE WHEN status = 'success' THEN thumbnail_size ELSE 0 END), 0) as thumbnail_size
+		FROM thumbnails
+		WHERE status NOT IN ('deleted', 'archived')
+		GROUP BY root_dir
+		ORDER BY root_dir
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stats by root: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []*models.RootStats
+	for rows.Next() {
+		root := &models.RootStats{}
+		if err := rows.Scan(
+			&root.RootDir,
+			&root.Total,
+			&root.Success,
+			&root.Error,
+			&root.Pending,
+			&root.Viewed,
+			&root.Unviewed,
+			&root.MovieSize,
+			&root.ThumbnailSize,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan stats by root: %w", err)
+		}
+		stats = append(stats, root)
+	}
+	return stats, rows.Err()
+}
+
+// GetRootThumbnailUsage returns the total size and the number of the stored thumbnails
+// of a movie directory
+func (d *DB) GetRootThumbnailUsage(rootDir string) (int64, int, error) {
+	var size int64
+	var count int
+	err := d.db.QueryRow(`
+		SELECT COALESCE(SUM(thumbnail_size), 0), COUNT(*)
+		FROM thumbnails
+		WHERE root_dir = ? AND status = 'success'`,
+		rootDir,
+	).Scan(&size, &count)
+	return size, count, err
+}
+
+// timestampArg binds an optional time in the CURRENT_TIMESTAMP format, or NULL
+func timestampArg(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
 // Helper function to scan rows into thumbnail structs
 func scanThumbnails(rows *sql.Rows) ([]*models.Thumbnail, error) {
 	var thumbnails []*models.Thumbnail
@@ -775,6 +1796,8 @@ func scanThumbnails(rows *sql.Rows) ([]*models.Thumbnail, error) {
 			&thumbnail.ID, &thumbnail.MoviePath, &thumbnail.MovieFilename, &thumbnail.ThumbnailPath,
 			&thumbnail.CreatedAt, &thumbnail.UpdatedAt, &thumbnail.Status, &thumbnail.Viewed,
 			&thumbnail.Width, &thumbnail.Height, &thumbnail.Duration, &thumbnail.FileSize, &thumbnail.ErrorMessage, &thumbnail.Source,
+			&thumbnail.ViewCount, &thumbnail.LastViewedAt, &thumbnail.RecordedAt, &thumbnail.GeneratedAt, &thumbnail.GeneratorVersion,
+			&thumbnail.PosterPath, &thumbnail.PreviewPath, &thumbnail.QueuedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -789,7 +1812,7 @@ func scanThumbnails(rows *sql.Rows) ([]*models.Thumbnail, error) {
 
 // CleanupOrphans removes database entries for missing movies
 func (d *DB) CleanupOrphans() (int64, error) {
-	result, err := d.db.Exec(`
+	result, err := d.exec(`
 		DELETE FROM thumbnails
 		WHERE status = 'deleted'
 	`)
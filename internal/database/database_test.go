@@ -0,0 +1,1708 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+func newTestDB(t testing.TB) *DB {
+	t.Helper()
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func addThumbnail(t *testing.T, db *DB, name string, status string) *models.Thumbnail {
+	t.Helper()
+	thumbnail := &models.Thumbnail{
+		MoviePath:     name,
+		MovieFilename: name,
+		ThumbnailPath: name + ".jpg",
+		Status:        status,
+		Source:        models.SourceGenerated,
+		FileSize:      1024,
+	}
+	if err := db.UpsertThumbnail(thumbnail); err != nil {
+		t.Fatalf("failed to add thumbnail %s: %v", name, err)
+	}
+	stored, err := db.GetByMoviePath(name)
+	if err != nil || stored == nil {
+		t.Fatalf("failed to read back thumbnail %s: %v", name, err)
+	}
+	return stored
+}
+
+func TestGetDeletedThumbnailsPaged(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, name := range []string{"a.mp4", "b.mp4", "c.mp4"} {
+		addThumbnail(t, db, name, models.StatusDeleted)
+	}
+	addThumbnail(t, db, "kept.mp4", models.StatusSuccess)
+
+	page, total, err := db.GetDeletedThumbnailsPaged(2, 0)
+	if err != nil {
+		t.Fatalf("GetDeletedThumbnailsPaged failed: %v", err)
+	}
+	if total != 3 {
+		t.Errorf("expected total 3, got %d", total)
+	}
+	if len(page) != 2 || page[0].MoviePath != "a.mp4" || page[1].MoviePath != "b.mp4" {
+		t.Errorf("unexpected first page: %v", page)
+	}
+
+	page, _, err = db.GetDeletedThumbnailsPaged(2, 2)
+	if err != nil {
+		t.Fatalf("GetDeletedThumbnailsPaged failed: %v", err)
+	}
+	if len(page) != 1 || page[0].MoviePath != "c.mp4" {
+		t.Errorf("unexpected second page: %v", page)
+	}
+
+	page, _, err = db.GetDeletedThumbnailsPaged(0, 0)
+	if err != nil {
+		t.Fatalf("GetDeletedThumbnailsPaged failed: %v", err)
+	}
+	if len(page) != 3 {
+		t.Errorf("expected all 3 items without a limit, got %d", len(page))
+	}
+}
+
+func TestBackfillFileSizes(t *testing.T) {
+	db := newTestDB(t)
+	movieDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(movieDir, "present.mp4"), make([]byte, 42), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	present := addThumbnail(t, db, "present.mp4", models.StatusSuccess)
+	missing := addThumbnail(t, db, "missing.mp4", models.StatusSuccess)
+	sized := addThumbnail(t, db, "sized.mp4", models.StatusSuccess)
+	for _, id := range []int64{present.ID, missing.ID} {
+		if _, err := db.db.Exec("UPDATE thumbnails SET file_size = 0 WHERE id = ?", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	result, err := db.BackfillFileSizes([]string{movieDir})
+	if err != nil {
+		t.Fatalf("BackfillFileSizes failed: %v", err)
+	}
+	if result.Total != 3 || result.Updated != 1 || result.Missing != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	got, _ := db.GetByID(present.ID)
+	if got.FileSize != 42 {
+		t.Errorf("expected file_size 42, got %d", got.FileSize)
+	}
+	got, _ = db.GetByID(missing.ID)
+	if got.Status != models.StatusDeleted {
+		t.Errorf("expected missing movie to be marked deleted, got %s", got.Status)
+	}
+	got, _ = db.GetByID(sized.ID)
+	if got.FileSize != 1024 || got.Status != models.StatusSuccess {
+		t.Errorf("expected sized row to be untouched, got %+v", got)
+	}
+}
+
+func TestGetRowsNeedingFileSize(t *testing.T) {
+	db := newTestDB(t)
+
+	needs := addThumbnail(t, db, "needs.mp4", models.StatusSuccess)
+	addThumbnail(t, db, "sized.mp4", models.StatusSuccess)
+	pending := addThumbnail(t, db, "pending.mp4", models.StatusPending)
+	for _, id := range []int64{needs.ID, pending.ID} {
+		if _, err := db.db.Exec("UPDATE thumbnails SET file_size = 0 WHERE id = ?", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, err := db.GetRowsNeedingFileSize()
+	if err != nil {
+		t.Fatalf("GetRowsNeedingFileSize failed: %v", err)
+	}
+	if len(rows) != 1 || rows[0].ID != needs.ID {
+		t.Fatalf("expected only %d, got %v", needs.ID, rows)
+	}
+
+	if err := db.UpdateFileSize(needs.ID, 2048); err != nil {
+		t.Fatalf("UpdateFileSize failed: %v", err)
+	}
+	rows, err = db.GetRowsNeedingFileSize()
+	if err != nil {
+		t.Fatalf("GetRowsNeedingFileSize failed: %v", err)
+	}
+	if len(rows) != 0 {
+		t.Errorf("expected no rows after update, got %v", rows)
+	}
+
+	got, _ := db.GetByID(needs.ID)
+	if got.FileSize != 2048 || got.Status != models.StatusSuccess {
+		t.Errorf("expected only file_size to change, got %+v", got)
+	}
+}
+
+func TestGetStatsEmpty(t *testing.T) {
+	db := newTestDB(t)
+
+	stats, err := db.GetStats()
+	if err != nil {
+		t.Fatalf("GetStats on an empty database failed: %v", err)
+	}
+	if stats.Total != 0 || stats.Success != 0 || stats.UnviewedSize != 0 {
+		t.Errorf("expected zeroed stats, got %+v", stats)
+	}
+	if stats.CaughtUp {
+		t.Error("an empty library should not be caught up")
+	}
+
+	thumb := addThumbnail(t, db, "a.mp4", models.StatusSuccess)
+	if stats, _ = db.GetStats(); stats.CaughtUp {
+		t.Error("expected not caught up with an unviewed thumbnail")
+	}
+	if err := db.MarkAsViewedByID(thumb.ID); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ = db.GetStats(); !stats.CaughtUp {
+		t.Error("expected caught up once every thumbnail is viewed")
+	}
+}
+
+func TestGetDeletionCandidatesBackoff(t *testing.T) {
+	db := newTestDB(t)
+	base := time.Hour
+	now := time.Unix(1_700_000_000, 0)
+
+	fresh := addThumbnail(t, db, "fresh.mp4", models.StatusDeleted)
+	once := addThumbnail(t, db, "once.mp4", models.StatusDeleted)
+	twice := addThumbnail(t, db, "twice.mp4", models.StatusDeleted)
+	addThumbnail(t, db, "kept.mp4", models.StatusSuccess)
+
+	// once.mp4 failed 30 minutes ago: within its 1h window
+	if _, err := db.RecordDeleteFailure(once.ID, now.Add(-30*time.Minute), 5); err != nil {
+		t.Fatal(err)
+	}
+	// twice.mp4 failed twice, last 30 minutes ago: within its 2h window
+	if _, err := db.RecordDeleteFailure(twice.ID, now.Add(-3*time.Hour), 5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.RecordDeleteFailure(twice.ID, now.Add(-30*time.Minute), 5); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(at time.Time) map[int64]bool {
+		t.Helper()
+		candidates, err := db.GetDeletionCandidates(at, base, false)
+		if err != nil {
+			t.Fatalf("GetDeletionCandidates failed: %v", err)
+		}
+		set := make(map[int64]bool)
+		for _, c := range candidates {
+			set[c.ID] = true
+		}
+		return set
+	}
+
+	got := ids(now)
+	if !got[fresh.ID] || got[once.ID] || got[twice.ID] || len(got) != 1 {
+		t.Errorf("at now: expected only fresh, got %v", got)
+	}
+
+	got = ids(now.Add(31 * time.Minute))
+	if !got[fresh.ID] || !got[once.ID] || got[twice.ID] {
+		t.Errorf("after 1h window: expected fresh and once, got %v", got)
+	}
+
+	got = ids(now.Add(91 * time.Minute))
+	if !got[twice.ID] {
+		t.Errorf("after 2h window: expected twice to be retried, got %v", got)
+	}
+}
+
+func TestApproveDeletions(t *testing.T) {
+	db := newTestDB(t)
+	first := addThumbnail(t, db, "first.mp4", models.StatusDeleted)
+	second := addThumbnail(t, db, "second.mp4", models.StatusDeleted)
+	kept := addThumbnail(t, db, "kept.mp4", models.StatusSuccess)
+
+	approved := func() []string {
+		t.Helper()
+		candidates, err := db.GetDeletionCandidates(time.Now(), time.Hour, true)
+		if err != nil {
+			t.Fatalf("GetDeletionCandidates failed: %v", err)
+		}
+		var paths []string
+		for _, c := range candidates {
+			paths = append(paths, c.MoviePath)
+		}
+		return paths
+	}
+
+	if got := approved(); len(got) != 0 {
+		t.Errorf("approved candidates before approval = %v, want none", got)
+	}
+
+	// Only queued items can be approved
+	if n, err := db.ApproveDeletions(first.ID, kept.ID); err != nil || n != 1 {
+		t.Fatalf("ApproveDeletions(first, kept) = %d, %v; want 1", n, err)
+	}
+	if got := fmt.Sprint(approved()); got != "[first.mp4]" {
+		t.Errorf("approved candidates = %s, want [first.mp4]", got)
+	}
+
+	if n, err := db.ApproveDeletions(); err != nil || n != 2 {
+		t.Fatalf("ApproveDeletions() = %d, %v; want 2", n, err)
+	}
+
+	// Queueing an item again needs a new approval
+	if err := db.RestoreFromDeletionByID(second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.MarkForDeletionByID(second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(approved()); got != "[first.mp4]" {
+		t.Errorf("approved candidates after requeueing second = %s, want [first.mp4]", got)
+	}
+}
+func TestQueuedAtSurvivesQueueUpdates(t *testing.T) {
+	db := newTestDB(t)
+	a := addThumbnail(t, db, "a.mp4", models.StatusSuccess)
+	b := addThumbnail(t, db, "b.mp4", models.StatusSuccess)
+	if err := db.MarkForDeletionByID(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.MarkForDeletionByID(b.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Queue a.mp4 well before b.mp4, since CURRENT_TIMESTAMP only has second precision
+	queued := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	if _, err := db.db.Exec("UPDATE thumbnails SET queued_at = ? WHERE id = ?", queued, a.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.RecordDeleteFailure(a.ID, time.Now(), 5); err != nil {
+		t.Fatalf("RecordDeleteFailure failed: %v", err)
+	}
+	if _, err := db.ApproveDeletions(a.ID); err != nil {
+		t.Fatalf("ApproveDeletions failed: %v", err)
+	}
+
+	stored, err := db.GetByID(a.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if stored.QueuedAt == nil || !stored.QueuedAt.Equal(queued) {
+		t.Errorf("queued_at = %v, want %v", stored.QueuedAt, queued)
+	}
+
+	page, _, err := db.GetDeletedThumbnailsPaged(0, 0)
+	if err != nil {
+		t.Fatalf("GetDeletedThumbnailsPaged failed: %v", err)
+	}
+	if len(page) != 2 || page[0].MoviePath != "a.mp4" || page[1].MoviePath != "b.mp4" {
+		t.Errorf("deletion queue order = %v, want [a.mp4 b.mp4]", page)
+	}
+
+	if err := db.RestoreFromDeletionByID(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	stored, err = db.GetByID(a.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if stored.QueuedAt != nil {
+		t.Errorf("queued_at after restore = %v, want nil", stored.QueuedAt)
+	}
+}
+
+func TestRecordDeleteFailureMaxAttempts(t *testing.T) {
+	db := newTestDB(t)
+	thumbnail := addThumbnail(t, db, "stuck.mp4", models.StatusDeleted)
+	now := time.Now()
+
+	for i := 1; i <= 3; i++ {
+		attempts, err := db.RecordDeleteFailure(thumbnail.ID, now, 3)
+		if err != nil {
+			t.Fatalf("RecordDeleteFailure failed: %v", err)
+		}
+		if attempts != i {
+			t.Errorf("expected %d attempts, got %d", i, attempts)
+		}
+	}
+
+	got, _ := db.GetByID(thumbnail.ID)
+	if got.Status != models.StatusDeleteFailed {
+		t.Errorf("expected status %s after max attempts, got %s", models.StatusDeleteFailed, got.Status)
+	}
+
+	stats, err := db.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.DeleteFailed != 1 || stats.Deleted != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// Restoring clears the failure state
+	if err := db.RestoreFromDeletionByID(thumbnail.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = db.GetByID(thumbnail.ID)
+	if got.Status != models.StatusSuccess {
+		t.Errorf("expected restored status success, got %s", got.Status)
+	}
+}
+
+func TestResetViewedStatusFiltered(t *testing.T) {
+	setup := func(t *testing.T) *DB {
+		t.Helper()
+		db := newTestDB(t)
+		rows := []struct {
+			name    string
+			size    int64
+			source  string
+			created string
+		}{
+			{"small.mp4", 100, models.SourceGenerated, "2024-01-10 12:00:00"},
+			{"large.mp4", 5000, models.SourceGenerated, "2024-03-10 12:00:00"},
+			{"shows/episode.mp4", 3000, models.SourceImported, "2024-02-10 12:00:00"},
+		}
+		for _, r := range rows {
+			if err := db.UpsertThumbnail(&models.Thumbnail{
+				MoviePath:     r.name,
+				MovieFilename: filepath.Base(r.name),
+				Status:        models.StatusSuccess,
+				Viewed:        1,
+				FileSize:      r.size,
+				Source:        r.source,
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.db.Exec("UPDATE thumbnails SET created_at = ? WHERE movie_path = ?", r.created, r.name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return db
+	}
+
+	tests := []struct {
+		name   string
+		filter ResetFilter
+		want   []string
+	}{
+		{"min size", ResetFilter{MinSize: 3000}, []string{"large.mp4", "shows/episode.mp4"}},
+		{"size range", ResetFilter{MinSize: 200, MaxSize: 4000}, []string{"shows/episode.mp4"}},
+		{"date range", ResetFilter{
+			CreatedAfter:  time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			CreatedBefore: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		}, []string{"shows/episode.mp4"}},
+		{"source", ResetFilter{Source: models.SourceGenerated}, []string{"small.mp4", "large.mp4"}},
+		{"path prefix", ResetFilter{PathPrefix: "shows/"}, []string{"shows/episode.mp4"}},
+		{"no match", ResetFilter{MinSize: 1 << 40}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setup(t)
+			count, err := db.ResetViewedStatusFiltered(tt.filter)
+			if err != nil {
+				t.Fatalf("ResetViewedStatusFiltered failed: %v", err)
+			}
+			if int(count) != len(tt.want) {
+				t.Errorf("expected %d rows reset, got %d", len(tt.want), count)
+			}
+
+			reset := make(map[string]bool)
+			for _, name := range tt.want {
+				reset[name] = true
+			}
+			all, err := db.GetAllThumbnails()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, th := range all {
+				if wantViewed := !reset[th.MoviePath]; th.IsViewed() != wantViewed {
+					t.Errorf("%s: viewed = %v, want %v", th.MoviePath, th.IsViewed(), wantViewed)
+				}
+			}
+		})
+	}
+}
+
+func TestBackup(t *testing.T) {
+	db := newTestDB(t)
+	addThumbnail(t, db, "a.mp4", "success")
+	addThumbnail(t, db, "b.mp4", "deleted")
+
+	dest := filepath.Join(t.TempDir(), "backups", "backup.db")
+	size, err := db.Backup(dest)
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if size <= 0 {
+		t.Errorf("expected a non-empty backup, got %d bytes", size)
+	}
+
+	// The copy must open and contain the same rows
+	backup, err := New(dest)
+	if err != nil {
+		t.Fatalf("failed to open backup: %v", err)
+	}
+	defer backup.Close()
+
+	for _, name := range []string{"a.mp4", "b.mp4"} {
+		thumb, err := backup.GetByMoviePath(name)
+		if err != nil || thumb == nil {
+			t.Errorf("backup is missing %s: %v", name, err)
+		}
+	}
+
+	// Refuse to overwrite an existing backup
+	if _, err := db.Backup(dest); err == nil {
+		t.Error("expected an error backing up onto an existing file")
+	}
+}
+
+func TestNewWithRetry(t *testing.T) {
+	t.Run("creates missing directory", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "nested", "dir", "test.db")
+		db, err := NewWithRetry(dbPath, OpenOptions{CreateDir: true})
+		if err != nil {
+			t.Fatalf("NewWithRetry failed: %v", err)
+		}
+		db.Close()
+	})
+
+	t.Run("recovers once the path becomes available", func(t *testing.T) {
+		// A regular file where the directory should be makes every open fail
+		parent := filepath.Join(t.TempDir(), "data")
+		if err := os.WriteFile(parent, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var attempts []int
+		db, err := NewWithRetry(filepath.Join(parent, "test.db"), OpenOptions{
+			Retries: 3,
+			Delay:   time.Millisecond,
+			OnRetry: func(attempt int, err error, wait time.Duration) {
+				attempts = append(attempts, attempt)
+				if attempt == 2 {
+					os.Remove(parent)
+					os.Mkdir(parent, 0755)
+				}
+			},
+		})
+		if err != nil {
+			t.Fatalf("expected the third attempt to succeed: %v", err)
+		}
+		db.Close()
+
+		if len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 2 {
+			t.Errorf("expected retries after attempts 1 and 2, got %v", attempts)
+		}
+	})
+
+	t.Run("gives up after the configured retries", func(t *testing.T) {
+		parent := filepath.Join(t.TempDir(), "data")
+		if err := os.WriteFile(parent, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var waits []time.Duration
+		_, err := NewWithRetry(filepath.Join(parent, "test.db"), OpenOptions{
+			Retries: 2,
+			Delay:   time.Millisecond,
+			OnRetry: func(attempt int, err error, wait time.Duration) {
+				waits = append(waits, wait)
+			},
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if len(waits) != 2 || waits[1] != 2*waits[0] {
+			t.Errorf("expected two retries with doubling delay, got %v", waits)
+		}
+	})
+}
+
+func TestMarkAsViewedByIDCountsViews(t *testing.T) {
+	db := newTestDB(t)
+	thumb := addThumbnail(t, db, "a.mp4", models.StatusSuccess)
+
+	if thumb.ViewCount != 0 || thumb.LastViewedAt != nil {
+		t.Fatalf("new thumbnail has view history: count=%d last=%v", thumb.ViewCount, thumb.LastViewedAt)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := db.MarkAsViewedByID(thumb.ID); err != nil {
+			t.Fatalf("MarkAsViewedByID failed: %v", err)
+		}
+	}
+
+	got, err := db.GetByID(thumb.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Viewed != 1 || got.ViewCount != 3 || got.LastViewedAt == nil {
+		t.Fatalf("expected viewed=1, view_count=3 and a last_viewed_at, got viewed=%d count=%d last=%v",
+			got.Viewed, got.ViewCount, got.LastViewedAt)
+	}
+
+	// Upserting the record (as a rescan does) keeps the history
+	got.Status = models.StatusSuccess
+	if err := db.UpsertThumbnail(got); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = db.GetByID(thumb.ID); got.ViewCount != 3 || got.LastViewedAt == nil {
+		t.Errorf("upsert lost the view history: count=%d last=%v", got.ViewCount, got.LastViewedAt)
+	}
+
+	// A plain reset keeps the history
+	if _, err := db.ResetViewedStatus(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = db.GetByID(thumb.ID); got.Viewed != 0 || got.ViewCount != 3 {
+		t.Errorf("expected viewed=0 with view_count kept, got viewed=%d count=%d", got.Viewed, got.ViewCount)
+	}
+
+	// Clearing the history zeroes it, even for rows that are already unviewed
+	if _, err := db.ResetViewedStatusFiltered(ResetFilter{ClearHistory: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = db.GetByID(thumb.ID); got.ViewCount != 0 || got.LastViewedAt != nil {
+		t.Errorf("expected cleared history, got count=%d last=%v", got.ViewCount, got.LastViewedAt)
+	}
+}
+
+func TestGetLeastRecentlyViewedThumbnail(t *testing.T) {
+	db := newTestDB(t)
+	old := addThumbnail(t, db, "old.mp4", models.StatusSuccess)
+	recent := addThumbnail(t, db, "recent.mp4", models.StatusSuccess)
+	never := addThumbnail(t, db, "never.mp4", models.StatusSuccess)
+	addThumbnail(t, db, "broken.mp4", models.StatusError)
+
+	lastViewed := map[int64]string{old.ID: "2024-01-01 10:00:00", recent.ID: "2024-06-01 10:00:00"}
+	for id, at := range lastViewed {
+		if _, err := db.db.Exec(`UPDATE thumbnails SET viewed = 1, last_viewed_at = ? WHERE id = ?`, at, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Never viewed first, then oldest view first; viewed rows are not filtered out
+	var order []string
+	var exclude []int64
+	for {
+		got, err := db.GetLeastRecentlyViewedThumbnail(time.Time{}, exclude...)
+		if err != nil {
+			t.Fatalf("GetLeastRecentlyViewedThumbnail failed: %v", err)
+		}
+		if got == nil {
+			break
+		}
+		order = append(order, got.MoviePath)
+		exclude = append(exclude, got.ID)
+	}
+	want := []string{never.MoviePath, old.MoviePath, recent.MoviePath}
+	if len(order) != len(want) {
+		t.Fatalf("expected order %v, got %v", want, order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("expected order %v, got %v", want, order)
+		}
+	}
+
+	// Thumbnails viewed since the cutoff drop out of the pool
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	got, err := db.GetLeastRecentlyViewedThumbnail(since, never.ID, old.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("expected no thumbnail left, got %s", got.MoviePath)
+	}
+
+	// The random unviewed selection only ever returns the never-viewed thumbnail
+	for i := 0; i < 10; i++ {
+		got, err := db.GetRandomUnviewedThumbnailExcluding()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == nil || got.ID != never.ID {
+			t.Fatalf("expected random selection to return %s, got %+v", never.MoviePath, got)
+		}
+	}
+}
+
+func TestGetRandomUnviewedThumbnailExcludingNeverReturnsExcluded(t *testing.T) {
+	db := newTestDB(t)
+	var ids []int64
+	for i := 0; i < 10; i++ {
+		ids = append(ids, addThumbnail(t, db, fmt.Sprintf("movie%d.mp4", i), models.StatusSuccess).ID)
+	}
+	excluded := map[int64]bool{}
+	for _, id := range ids[:5] {
+		excluded[id] = true
+	}
+
+	// Small lists are bound per ID; a long list with unknown IDs and duplicates goes
+	// through a single JSON parameter
+	long := append([]int64{}, ids[:5]...)
+	for i := int64(0); i < 5000; i++ {
+		long = append(long, 100000+i, ids[0])
+	}
+
+	for name, exclude := range map[string][]int64{"short": ids[:5], "long": long} {
+		for i := 0; i < 50; i++ {
+			got, err := db.GetRandomUnviewedThumbnailExcluding(exclude...)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if got == nil {
+				t.Fatalf("%s: expected a thumbnail", name)
+			}
+			if excluded[got.ID] {
+				t.Fatalf("%s: got excluded thumbnail %d", name, got.ID)
+			}
+		}
+	}
+
+	if got, err := db.GetRandomUnviewedThumbnailExcluding(); err != nil || got == nil {
+		t.Errorf("expected a thumbnail without exclusions, got %+v (%v)", got, err)
+	}
+	if got, err := db.GetRandomUnviewedThumbnailExcluding(ids...); err != nil || got != nil {
+		t.Errorf("expected nothing when every thumbnail is excluded, got %+v (%v)", got, err)
+	}
+}
+
+func TestGetRandomThumbnailIncludesViewed(t *testing.T) {
+	db := newTestDB(t)
+	viewed := addThumbnail(t, db, "viewed.mp4", models.StatusSuccess)
+	unviewed := addThumbnail(t, db, "unviewed.mp4", models.StatusSuccess)
+	addThumbnail(t, db, "broken.mp4", models.StatusError)
+	deleted := addThumbnail(t, db, "deleted.mp4", models.StatusSuccess)
+
+	if err := db.MarkAsViewedByID(viewed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.MarkForDeletionByID(deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	unviewedCount, err := db.GetUnviewedThumbnailCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	allCount, err := db.GetSuccessfulThumbnailCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unviewedCount != 1 || allCount != 2 {
+		t.Errorf("expected unviewed=1 and successful=2, got unviewed=%d successful=%d", unviewedCount, allCount)
+	}
+
+	got, err := db.GetRandomThumbnailNotViewedSince(time.Time{}, unviewed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.ID != viewed.ID {
+		t.Fatalf("expected the viewed thumbnail, got %+v", got)
+	}
+	if got, _ := db.GetRandomUnviewedThumbnailExcluding(unviewed.ID); got != nil {
+		t.Errorf("expected no unviewed thumbnail, got %s", got.MoviePath)
+	}
+	if got, _ := db.GetRandomThumbnailNotViewedSince(time.Time{}, viewed.ID, unviewed.ID); got != nil {
+		t.Errorf("expected deleted and failed thumbnails to be skipped, got %s", got.MoviePath)
+	}
+
+	// A thumbnail viewed since the cutoff is no longer drawn
+	if got, _ := db.GetRandomThumbnailNotViewedSince(time.Now().Add(-time.Minute), unviewed.ID); got != nil {
+		t.Errorf("expected the thumbnail viewed since the cutoff to be skipped, got %s", got.MoviePath)
+	}
+}
+
+func TestGetStatsCache(t *testing.T) {
+	db := newTestDB(t)
+	db.SetStatsCacheTTL(time.Hour)
+	addThumbnail(t, db, "a.mp4", models.StatusSuccess)
+
+	stats, err := db.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 1 {
+		t.Fatalf("expected 1 thumbnail, got %d", stats.Total)
+	}
+	stats.Total = 99 // Callers get a copy
+
+	// A change behind the DB's back is not seen while the cache is fresh
+	if _, err := db.db.Exec(`INSERT INTO thumbnails (movie_path, movie_filename, status) VALUES ('b.mp4', 'b.mp4', 'success')`); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ = db.GetStats(); stats.Total != 1 {
+		t.Errorf("expected the cached total of 1, got %d", stats.Total)
+	}
+
+	// Writes through the DB invalidate the cache
+	addThumbnail(t, db, "c.mp4", models.StatusSuccess)
+	if stats, _ = db.GetStats(); stats.Total != 3 {
+		t.Errorf("expected a refreshed total of 3 after a write, got %d", stats.Total)
+	}
+
+	// Expired entries are reloaded
+	db.SetStatsCacheTTL(time.Millisecond)
+	db.GetStats()
+	if _, err := db.db.Exec(`DELETE FROM thumbnails WHERE movie_path = 'b.mp4'`); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if stats, _ = db.GetStats(); stats.Total != 2 {
+		t.Errorf("expected a refreshed total of 2 after the TTL, got %d", stats.Total)
+	}
+}
+
+func TestGetByContentHashAndRename(t *testing.T) {
+	db := newTestDB(t)
+	old := addThumbnail(t, db, "old.mp4", models.StatusSuccess)
+	broken := addThumbnail(t, db, "broken.mp4", models.StatusError)
+	if err := db.MarkAsViewedByID(old.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{old.MoviePath, broken.MoviePath} {
+		if err := db.SetContentHash(name, "abc"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	matches, err := db.GetByContentHash("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].ID != old.ID {
+		t.Fatalf("expected only the successful thumbnail, got %+v", matches)
+	}
+	if matches, _ := db.GetByContentHash("other"); len(matches) != 0 {
+		t.Errorf("expected no match for another hash, got %d", len(matches))
+	}
+
+	if err := db.RenameMovie(old.ID, "new.mp4", "new.mp4", "new.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.GetByMoviePath("old.mp4"); got != nil {
+		t.Error("old movie path still present after rename")
+	}
+	renamed, err := db.GetByMoviePath("new.mp4")
+	if err != nil || renamed == nil {
+		t.Fatalf("renamed record not found: %v", err)
+	}
+	if renamed.ID != old.ID || renamed.MovieFilename != "new.mp4" || renamed.ThumbnailPath != "new.jpg" || renamed.ViewCount != 1 {
+		t.Errorf("unexpected renamed record %+v", renamed)
+	}
+	if matches, _ := db.GetByContentHash("abc"); len(matches) != 1 || matches[0].MoviePath != "new.mp4" {
+		t.Errorf("content hash not kept across rename: %+v", matches)
+	}
+}
+
+func TestResetPendingToRetry(t *testing.T) {
+	db := newTestDB(t)
+	stuck := addThumbnail(t, db, "stuck.mp4", models.StatusPending)
+	done := addThumbnail(t, db, "done.mp4", models.StatusSuccess)
+	if _, err := db.db.Exec("UPDATE thumbnails SET width = 640, height = 480, duration = 60"); err != nil {
+		t.Fatal(err)
+	}
+
+	reset, err := db.ResetPendingToRetry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset != 1 {
+		t.Errorf("reset %d rows, want 1", reset)
+	}
+
+	got, _ := db.GetByID(stuck.ID)
+	if got.Status != models.StatusPending || got.ErrorMessage != "" || got.Width != 0 || got.Duration != 0 {
+		t.Errorf("stuck row = %s %q %dx%d %.0fs, want pending without an error or metadata",
+			got.Status, got.ErrorMessage, got.Width, got.Height, got.Duration)
+	}
+	if got, _ := db.GetByID(done.ID); got.Status != models.StatusSuccess || got.Width != 640 {
+		t.Errorf("successful row changed: %+v", got)
+	}
+}
+
+func TestGetStatsByRoot(t *testing.T) {
+	db := newTestDB(t)
+
+	rows := []struct {
+		name, status, root string
+		thumbnailSize      int64
+	}{
+		{"a1.mp4", models.StatusSuccess, "/movies/a", 100},
+		{"a2.mp4", models.StatusSuccess, "/movies/a", 200},
+		{"a3.mp4", models.StatusError, "/movies/a", 0},
+		{"b1.mp4", models.StatusSuccess, "/movies/b", 50},
+		{"b2.mp4", models.StatusDeleted, "/movies/b", 70},
+		{"legacy.mp4", models.StatusPending, "", 0},
+	}
+	for _, r := range rows {
+		addThumbnail(t, db, r.name, r.status)
+		if r.root == "" {
+			continue
+		}
+		if err := db.SetRootUsage(r.name, r.root, r.thumbnailSize); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a2, _ := db.GetByMoviePath("a2.mp4")
+	if err := db.MarkAsViewedByID(a2.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := db.GetStatsByRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.RootStats{
+		{RootDir: "", Total: 1, Pending: 1},
+		{RootDir: "/movies/a", Total: 3, Success: 2, Error: 1, Viewed: 1, Unviewed: 1, MovieSize: 2048, ThumbnailSize: 300},
+		{RootDir: "/movies/b", Total: 1, Success: 1, Unviewed: 1, MovieSize: 1024, ThumbnailSize: 50},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("expected %d roots, got %d: %+v", len(want), len(stats), stats)
+	}
+	for i := range want {
+		if *stats[i] != want[i] {
+			t.Errorf("root %d = %+v, want %+v", i, *stats[i], want[i])
+		}
+	}
+
+	size, count, err := db.GetRootThumbnailUsage("/movies/a")
+	if err != nil || size != 300 || count != 2 {
+		t.Errorf("GetRootThumbnailUsage = %d, %d, %v; want 300, 2", size, count, err)
+	}
+	if size, count, _ := db.GetRootThumbnailUsage("/movies/none"); size != 0 || count != 0 {
+		t.Errorf("expected no usage for an unknown root, got %d in %d thumbnails", size, count)
+	}
+}
+
+func TestGetRowsNeedingRootDir(t *testing.T) {
+	db := newTestDB(t)
+	addThumbnail(t, db, "legacy.mp4", models.StatusSuccess)
+	addThumbnail(t, db, "failed.mp4", models.StatusError)
+	addThumbnail(t, db, "tracked.mp4", models.StatusSuccess)
+	if err := db.SetRootUsage("tracked.mp4", "/movies", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.GetRowsNeedingRootDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].MoviePath != "legacy.mp4" {
+		t.Errorf("expected only legacy.mp4, got %+v", rows)
+	}
+}
+
+func TestGetRowsNeedingContentHash(t *testing.T) {
+	db := newTestDB(t)
+	addThumbnail(t, db, "legacy.mp4", models.StatusSuccess)
+	addThumbnail(t, db, "failed.mp4", models.StatusError)
+	addThumbnail(t, db, "hashed.mp4", models.StatusSuccess)
+	if err := db.SetContentHash("hashed.mp4", "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.GetRowsNeedingContentHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].MoviePath != "legacy.mp4" {
+		t.Errorf("expected only legacy.mp4, got %+v", rows)
+	}
+}
+
+func TestGetThumbnailsFiltered(t *testing.T) {
+	db := newTestDB(t)
+	seen := addThumbnail(t, db, "seen.mp4", models.StatusSuccess)
+	addThumbnail(t, db, "unseen.mp4", models.StatusSuccess)
+	addThumbnail(t, db, "broken.mp4", models.StatusError)
+	if err := db.MarkAsViewedByID(seen.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	viewed, unviewed := true, false
+	tests := []struct {
+		name   string
+		filter ThumbnailFilter
+		want   []string
+	}{
+		{"all", ThumbnailFilter{}, []string{"broken.mp4", "unseen.mp4", "seen.mp4"}},
+		{"status", ThumbnailFilter{Status: models.StatusSuccess}, []string{"unseen.mp4", "seen.mp4"}},
+		{"viewed", ThumbnailFilter{Status: models.StatusSuccess, Viewed: &viewed}, []string{"seen.mp4"}},
+		{"unviewed", ThumbnailFilter{Status: models.StatusSuccess, Viewed: &unviewed}, []string{"unseen.mp4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumbnails, total, err := db.GetThumbnailsFiltered(tt.filter, 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != len(tt.want) || len(thumbnails) != len(tt.want) {
+				t.Fatalf("got %d of %d thumbnails, want %d", len(thumbnails), total, len(tt.want))
+			}
+			for i, name := range tt.want {
+				if thumbnails[i].MoviePath != name {
+					t.Errorf("thumbnail %d = %s, want %s", i, thumbnails[i].MoviePath, name)
+				}
+			}
+		})
+	}
+
+	page, total, err := db.GetThumbnailsFiltered(ThumbnailFilter{}, 2, 2)
+	if err != nil || total != 3 || len(page) != 1 || page[0].MoviePath != "seen.mp4" {
+		t.Errorf("last page = %d of %d (%v), want only seen.mp4", len(page), total, err)
+	}
+}
+
+func TestMarkByIDLifecycle(t *testing.T) {
+	db := newTestDB(t)
+	thumb := addThumbnail(t, db, "a.mp4", models.StatusSuccess)
+	other := addThumbnail(t, db, "b.mp4", models.StatusSuccess)
+
+	if err := db.MarkAsViewedByID(thumb.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.MarkForDeletionByID(thumb.ID); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := db.GetDeletedThumbnails(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != thumb.ID {
+		t.Fatalf("expected only a.mp4 in the deletion queue, got %+v", deleted)
+	}
+	if got, _ := db.GetByID(other.ID); got.Status != models.StatusSuccess || got.IsViewed() {
+		t.Errorf("another thumbnail was changed: %+v", got)
+	}
+
+	if err := db.RestoreFromDeletionByID(thumb.ID); err != nil {
+		t.Fatal(err)
+	}
+	restored, _ := db.GetByID(thumb.ID)
+	if restored.Status != models.StatusSuccess || restored.IsViewed() || restored.ViewCount != 1 {
+		t.Errorf("unexpected restored thumbnail %+v", restored)
+	}
+
+	// Restoring only applies to queued deletions
+	if err := db.MarkForArchivalByID(thumb.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RestoreFromDeletionByID(thumb.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.GetByID(thumb.ID); got.Status != models.StatusArchived {
+		t.Errorf("archived thumbnail was restored: status %q", got.Status)
+	}
+
+	// The path-based variant resolves to the same record
+	if err := db.MarkForDeletionByID(other.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RestoreFromDeletion("b.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.GetByID(other.ID); got.Status != models.StatusSuccess {
+		t.Errorf("RestoreFromDeletion left status %q", got.Status)
+	}
+	if err := db.RestoreFromDeletion("missing.mp4"); err != nil {
+		t.Errorf("RestoreFromDeletion of an unknown movie: %v", err)
+	}
+}
+
+func TestUpdateByIDNotFound(t *testing.T) {
+	db := newTestDB(t)
+	thumb := addThumbnail(t, db, "a.mp4", models.StatusSuccess)
+	const missing = 9999
+
+	updates := map[string]func(int64) error{
+		"MarkAsViewedByID":        db.MarkAsViewedByID,
+		"MarkForDeletionByID":     db.MarkForDeletionByID,
+		"MarkForArchivalByID":     db.MarkForArchivalByID,
+		"RestoreFromDeletionByID": db.RestoreFromDeletionByID,
+	}
+	for name, update := range updates {
+		if err := update(missing); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s(missing) = %v, want ErrNotFound", name, err)
+		}
+		if err := update(thumb.ID); err != nil {
+			t.Errorf("%s(existing) = %v", name, err)
+		}
+	}
+
+	// A thumbnail that exists but is not queued for deletion is not an error
+	fresh := addThumbnail(t, db, "b.mp4", models.StatusSuccess)
+	if err := db.RestoreFromDeletionByID(fresh.ID); err != nil {
+		t.Errorf("RestoreFromDeletionByID(not deleted) = %v, want nil", err)
+	}
+}
+
+func TestExecRetriesWhileLocked(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewWithRetry(dbPath, OpenOptions{BusyTimeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// A second process holding the write lock
+	other, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	lock := func(t *testing.T) *sql.Conn {
+		t.Helper()
+		conn, err := other.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.ExecContext(context.Background(), "BEGIN IMMEDIATE"); err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	unlock := func(conn *sql.Conn) {
+		conn.ExecContext(context.Background(), "ROLLBACK")
+		conn.Close()
+	}
+
+	t.Run("succeeds once the lock is released", func(t *testing.T) {
+		conn := lock(t)
+		time.AfterFunc(100*time.Millisecond, func() { unlock(conn) })
+
+		if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: "a.mp4", MovieFilename: "a.mp4"}); err != nil {
+			t.Fatalf("write while locked failed: %v", err)
+		}
+	})
+
+	t.Run("gives up while the lock is held", func(t *testing.T) {
+		conn := lock(t)
+		defer unlock(conn)
+
+		err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: "b.mp4", MovieFilename: "b.mp4"})
+		if !isBusy(err) {
+			t.Fatalf("expected a busy error, got %v", err)
+		}
+	})
+}
+
+// insertSyntheticThumbnails adds n successful thumbnails in a single transaction
+func insertSyntheticThumbnails(tb testing.TB, db *DB, n int) {
+	tb.Helper()
+	tx, err := db.db.Begin()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("movie-%06d.mp4", i)
+		if _, err := tx.Exec(`
+			INSERT INTO thumbnails (movie_path, movie_filename, thumbnail_path, status, error_message)
+			VALUES (?, ?, ?, 'success', '')`,
+			name, name, name+".jpg",
+		); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestForEachThumbnail(t *testing.T) {
+	db := newTestDB(t)
+	n := 2*forEachBatchSize + 3
+	insertSyntheticThumbnails(t, db, n)
+
+	// The callback may write to the database while it walks the table
+	var seen int
+	var lastID int64
+	err := db.ForEachThumbnail(context.Background(), func(thumbnail *models.Thumbnail) error {
+		if thumbnail.ID <= lastID {
+			t.Fatalf("thumbnail %d visited after %d", thumbnail.ID, lastID)
+		}
+		lastID = thumbnail.ID
+		seen++
+		if seen%2 == 0 {
+			return db.DeleteThumbnail(thumbnail.MoviePath)
+		}
+		return nil
+	})
+	if err != nil || seen != n {
+		t.Fatalf("visited %d of %d thumbnails: %v", seen, n, err)
+	}
+	if remaining, _ := db.GetAllThumbnails(); len(remaining) != n-n/2 {
+		t.Errorf("%d thumbnails left, want %d", len(remaining), n-n/2)
+	}
+
+	stop := errors.New("stop")
+	seen = 0
+	err = db.ForEachThumbnail(context.Background(), func(*models.Thumbnail) error {
+		seen++
+		return stop
+	})
+	if !errors.Is(err, stop) || seen != 1 {
+		t.Errorf("expected the walk to stop after the first error, visited %d: %v", seen, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := db.ForEachThumbnail(ctx, func(*models.Thumbnail) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+// peakHeap samples the live heap so the benchmarks can report the largest one seen
+type peakHeap struct {
+	peak uint64
+}
+
+func (p *peakHeap) sample() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	if stats.HeapAlloc > p.peak {
+		p.peak = stats.HeapAlloc
+	}
+}
+
+func (p *peakHeap) report(b *testing.B) {
+	b.ReportMetric(float64(p.peak)/(1<<20), "peak-heap-MB")
+}
+
+// BenchmarkGetAllThumbnails and BenchmarkForEachThumbnail compare the peak memory of
+// loading a 20,000-row library at once with walking it in batches
+func BenchmarkGetAllThumbnails(b *testing.B) {
+	db := newTestDB(b)
+	insertSyntheticThumbnails(b, db, 20000)
+	var heap peakHeap
+	runtime.GC()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		thumbnails, err := db.GetAllThumbnails()
+		if err != nil || len(thumbnails) != 20000 {
+			b.Fatalf("got %d thumbnails: %v", len(thumbnails), err)
+		}
+		heap.sample()
+	}
+	heap.report(b)
+}
+
+func BenchmarkForEachThumbnail(b *testing.B) {
+	db := newTestDB(b)
+	insertSyntheticThumbnails(b, db, 20000)
+	var heap peakHeap
+	runtime.GC()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var n int
+		err := db.ForEachThumbnail(context.Background(), func(*models.Thumbnail) error {
+			n++
+			if n%forEachBatchSize == 0 {
+				heap.sample()
+			}
+			return nil
+		})
+		if err != nil || n != 20000 {
+			b.Fatalf("visited %d thumbnails: %v", n, err)
+		}
+	}
+	heap.report(b)
+}
+
+func TestSetGridSize(t *testing.T) {
+	db := newTestDB(t)
+	addThumbnail(t, db, "short.mp4", models.StatusSuccess)
+
+	if err := db.SetGridSize("short.mp4", 3, 2); err != nil {
+		t.Fatal(err)
+	}
+	var cols, rows int
+	if err := db.db.QueryRow("SELECT grid_cols, grid_rows FROM thumbnails WHERE movie_path = ?", "short.mp4").Scan(&cols, &rows); err != nil {
+		t.Fatal(err)
+	}
+	if cols != 3 || rows != 2 {
+		t.Errorf("grid size = %dx%d, want 3x2", cols, rows)
+	}
+}
+
+func TestSetVideoStream(t *testing.T) {
+	db := newTestDB(t)
+	addThumbnail(t, db, "angles.mkv", models.StatusSuccess)
+
+	if err := db.SetVideoStream("angles.mkv", 1); err != nil {
+		t.Fatal(err)
+	}
+	var stream int
+	if err := db.db.QueryRow("SELECT video_stream FROM thumbnails WHERE movie_path = ?", "angles.mkv").Scan(&stream); err != nil {
+		t.Fatal(err)
+	}
+	if stream != 1 {
+		t.Errorf("video stream = %d, want 1", stream)
+	}
+}
+
+func TestArtifactPathsPersisted(t *testing.T) {
+	db := newTestDB(t)
+	thumbnail := &models.Thumbnail{
+		MoviePath:     "movie.mp4",
+		MovieFilename: "movie.mp4",
+		ThumbnailPath: "movie.jpg",
+		PosterPath:    "movie.poster.jpg",
+		PreviewPath:   "movie.webp",
+		Status:        models.StatusSuccess,
+	}
+	if err := db.UpsertThumbnail(thumbnail); err != nil {
+		t.Fatal(err)
+	}
+
+	// Saving the grid alone keeps the artifacts generated before
+	thumbnail.PosterPath, thumbnail.PreviewPath = "", ""
+	if err := db.UpsertThumbnail(thumbnail); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := db.GetByMoviePath("movie.mp4")
+	if err != nil || got == nil {
+		t.Fatalf("GetByMoviePath() = %v, %v", got, err)
+	}
+	if got.PosterPath != "movie.poster.jpg" || got.PreviewPath != "movie.webp" {
+		t.Errorf("artifact paths = %q, %q, want the saved ones", got.PosterPath, got.PreviewPath)
+	}
+}
+
+func TestUpsertKeepsSeparatelyRecordedColumns(t *testing.T) {
+	db := newTestDB(t)
+	thumbnail := &models.Thumbnail{
+		MoviePath:     "angles.mkv",
+		MovieFilename: "angles.mkv",
+		ThumbnailPath: "angles.jpg",
+		Status:        models.StatusPending,
+	}
+
+	// The scanner's save path: the pending record, the grid size and video stream
+	// stored during generation, the final status, then the values recorded after it
+	if err := db.UpsertThumbnail(thumbnail); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetGridSize("angles.mkv", 3, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetVideoStream("angles.mkv", 1); err != nil {
+		t.Fatal(err)
+	}
+	thumbnail.Status = models.StatusSuccess
+	if err := db.UpsertThumbnail(thumbnail); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetContentHash("angles.mkv", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetMovieMtime("angles.mkv", 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSidecarMtime("angles.mkv", 200); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetRootUsage("angles.mkv", "/movies", 4096); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.db.Exec("UPDATE thumbnails SET deletion_approved = 1 WHERE movie_path = ?", "angles.mkv"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A later regeneration saves the pending and final status again
+	for _, status := range []string{models.StatusPending, models.StatusError} {
+		thumbnail.Status = status
+		if err := db.UpsertThumbnail(thumbnail); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		cols, rows, stream, approved int
+		hash, root                   string
+		movieMtime, sidecarMtime     int64
+		size                         int64
+	)
+	err := db.db.QueryRow(`
+		SELECT grid_cols, grid_rows, video_stream, content_hash, movie_mtime, sidecar_mtime,
+			root_dir, thumbnail_size, deletion_approved
+		FROM thumbnails WHERE movie_path = ?`, "angles.mkv",
+	).Scan(&cols, &rows, &stream, &hash, &movieMtime, &sidecarMtime, &root, &size, &approved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cols != 3 || rows != 2 || stream != 1 || hash != "abc" || movieMtime != 100 || sidecarMtime != 200 ||
+		root != "/movies" || size != 4096 || approved != 1 {
+		t.Errorf("after upserts: grid %dx%d, stream %d, hash %q, mtimes %d/%d, root %q, size %d, approved %d",
+			cols, rows, stream, hash, movieMtime, sidecarMtime, root, size, approved)
+	}
+}
+
+func TestRecordedAt(t *testing.T) {
+	db := newTestDB(t)
+	day := func(d int) *time.Time {
+		recorded := time.Date(2020, 1, d, 12, 0, 0, 0, time.UTC)
+		return &recorded
+	}
+	for _, thumbnail := range []*models.Thumbnail{
+		{MoviePath: "third.mp4", RecordedAt: day(3)},
+		{MoviePath: "undated.mp4"},
+		{MoviePath: "first.mp4", RecordedAt: day(1)},
+		{MoviePath: "second.mp4", RecordedAt: day(2)},
+	} {
+		thumbnail.MovieFilename = thumbnail.MoviePath
+		thumbnail.Status = models.StatusSuccess
+		if err := db.UpsertThumbnail(thumbnail); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An upsert without a recording date keeps the stored one
+	if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: "first.mp4", MovieFilename: "first.mp4", Status: models.StatusSuccess}); err != nil {
+		t.Fatal(err)
+	}
+	first, err := db.GetByMoviePath("first.mp4")
+	if err != nil || first.RecordedAt == nil || !first.RecordedAt.Equal(*day(1)) {
+		t.Fatalf("first.mp4 recorded_at = %v, %v; want %v", first.RecordedAt, err, day(1))
+	}
+
+	names := func(thumbnails []*models.Thumbnail) []string {
+		var names []string
+		for _, thumbnail := range thumbnails {
+			names = append(names, thumbnail.MoviePath)
+		}
+		return names
+	}
+	thumbnails, _, err := db.GetThumbnailsFiltered(ThumbnailFilter{Sort: SortRecordedAt}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(names(thumbnails)), "[first.mp4 second.mp4 third.mp4 undated.mp4]"; got != want {
+		t.Errorf("sorted by recorded_at = %s, want %s", got, want)
+	}
+
+	thumbnails, total, err := db.GetThumbnailsFiltered(ThumbnailFilter{Sort: SortRecordedAt, RecordedAfter: *day(2), RecordedBefore: *day(3)}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(names(thumbnails)); total != 1 || got != "[second.mp4]" {
+		t.Errorf("recorded on day 2 = %s (%d)", got, total)
+	}
+
+	earliest, err := db.GetEarliestRecordedThumbnail(false, time.Time{}, first.ID)
+	if err != nil || earliest == nil || earliest.MoviePath != "second.mp4" {
+		t.Errorf("GetEarliestRecordedThumbnail = %v, %v; want second.mp4", earliest, err)
+	}
+}
+
+func TestNewFallbackRandSeedsFromStart(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	a, b := newFallbackRand(start), newFallbackRand(start)
+	other := newFallbackRand(start.Add(time.Nanosecond))
+
+	same, differs := true, false
+	for i := 0; i < 10; i++ {
+		x, y, z := a.Int63(), b.Int63(), other.Int63()
+		same = same && x == y
+		differs = differs || x != z
+	}
+	if !same {
+		t.Error("sources seeded from the same start time produced different sequences")
+	}
+	if !differs {
+		t.Error("sources seeded from different start times produced the same sequence")
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("no entropy") }
+
+func TestRandomPickCountsFallbacks(t *testing.T) {
+	db := newTestDB(t)
+	// With a single candidate the offset is always 0 and no randomness is read
+	for _, name := range []string{"a", "b"} {
+		if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: "/movies/" + name + ".mp4", MovieFilename: name + ".mp4", ThumbnailPath: name + ".jpg", Status: models.StatusSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	before := RandomFallbacks()
+	if _, err := db.GetRandomUnviewedThumbnail(); err != nil {
+		t.Fatal(err)
+	}
+	if got := RandomFallbacks(); got != before {
+		t.Errorf("RandomFallbacks = %d after a crypto/rand pick, want %d", got, before)
+	}
+
+	randReader = failingReader{}
+	t.Cleanup(func() { randReader = rand.Reader })
+
+	thumbnail, err := db.GetRandomUnviewedThumbnail()
+	if err != nil || thumbnail == nil {
+		t.Fatalf("GetRandomUnviewedThumbnail with failing crypto/rand = %v, %v; want a thumbnail", thumbnail, err)
+	}
+	if got := RandomFallbacks(); got != before+1 {
+		t.Errorf("RandomFallbacks = %d, want %d", got, before+1)
+	}
+}
+
+func TestGeneratedQueries(t *testing.T) {
+	db := newTestDB(t)
+	old := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	generate := func(name string, at time.Time, version string) {
+		t.Helper()
+		if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: name, MovieFilename: name, Status: models.StatusSuccess, GeneratedAt: &at, GeneratorVersion: version}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	generate("old.mp4", old, "1.0.0+aaaaaaaa")
+	generate("recent.mp4", recent, "1.1.0+bbbbbbbb")
+	addThumbnail(t, db, "imported.mp4", models.StatusSuccess)
+
+	paths := func(thumbnails []*models.Thumbnail, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, th := range thumbnails {
+			names = append(names, th.MoviePath)
+		}
+		return fmt.Sprint(names)
+	}
+
+	if got := paths(db.GetThumbnailsGeneratedBefore(recent)); got != "[old.mp4]" {
+		t.Errorf("GetThumbnailsGeneratedBefore(recent) = %s, want [old.mp4]", got)
+	}
+	if got := paths(db.GetThumbnailsGeneratedBefore(recent.Add(time.Second))); got != "[old.mp4 recent.mp4]" {
+		t.Errorf("GetThumbnailsGeneratedBefore(after recent) = %s, want [old.mp4 recent.mp4]", got)
+	}
+	if got := paths(db.GetThumbnailsGeneratedWithVersion("1.0.0+aaaaaaaa")); got != "[old.mp4]" {
+		t.Errorf("GetThumbnailsGeneratedWithVersion = %s, want [old.mp4]", got)
+	}
+	if got := paths(db.GetThumbnailsGeneratedWithVersion("")); got != "[imported.mp4]" {
+		t.Errorf("GetThumbnailsGeneratedWithVersion(\"\") = %s, want [imported.mp4]", got)
+	}
+
+	// Updates without a generation keep the last one, regenerating replaces it
+	if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: "old.mp4", MovieFilename: "old.mp4", Status: models.StatusPending}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := db.GetByMoviePath("old.mp4")
+	if err != nil || stored.GeneratedAt == nil || !stored.GeneratedAt.Equal(old) || stored.GeneratorVersion != "1.0.0+aaaaaaaa" {
+		t.Fatalf("after a pending update old.mp4 = %+v, %v; want the generation kept", stored, err)
+	}
+
+	generate("old.mp4", recent, "1.1.0+bbbbbbbb")
+	stored, err = db.GetByMoviePath("old.mp4")
+	if err != nil || stored.GeneratedAt == nil || !stored.GeneratedAt.Equal(recent) || stored.GeneratorVersion != "1.1.0+bbbbbbbb" {
+		t.Fatalf("after regenerating old.mp4 = %+v, %v; want the new generation", stored, err)
+	}
+	if got := paths(db.GetThumbnailsGeneratedBefore(recent)); got != "[]" {
+		t.Errorf("GetThumbnailsGeneratedBefore(recent) after regenerating = %s, want []", got)
+	}
+}
+
+func TestPruneStaleErrors(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	old, recent := now.Add(-48*time.Hour), now.Add(-time.Hour)
+
+	rows := []struct {
+		name    string
+		status  string
+		updated time.Time
+	}{
+		{"old-absent.mp4", models.StatusError, old},
+		{"old-present.mp4", models.StatusError, old},
+		{"new-absent.mp4", models.StatusError, recent},
+		{"new-present.mp4", models.StatusError, recent},
+		{"old-absent-success.mp4", models.StatusSuccess, old},
+	}
+	for _, r := range rows {
+		// Inserted directly, as updates reset updated_at
+		if _, err := db.db.Exec(`INSERT INTO thumbnails (movie_path, movie_filename, thumbnail_path, status, updated_at) VALUES (?, ?, '', ?, ?)`,
+			r.name, r.name, r.status, timestampArg(&r.updated)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exists := func(moviePath string) bool {
+		return strings.Contains(moviePath, "-present")
+	}
+	pruned, err := db.PruneStaleErrors(now.Add(-24*time.Hour), exists)
+	if err != nil || pruned != 1 {
+		t.Fatalf("PruneStaleErrors() = %d, %v; want 1", pruned, err)
+	}
+
+	for _, r := range rows {
+		stored, err := db.GetByMoviePath(r.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := r.name != "old-absent.mp4"; (stored != nil) != want {
+			t.Errorf("%s kept = %v, want %v", r.name, stored != nil, want)
+		}
+	}
+}
+
+func TestFileSizeRoundTrip(t *testing.T) {
+	db := newTestDB(t)
+
+	added := &models.Thumbnail{MoviePath: "added.mp4", MovieFilename: "added.mp4", ThumbnailPath: "added.jpg", Status: models.StatusSuccess, FileSize: 7 << 30}
+	if err := db.Add(added); err != nil {
+		t.Fatal(err)
+	}
+	upserted := &models.Thumbnail{MoviePath: "upserted.mp4", MovieFilename: "upserted.mp4", ThumbnailPath: "upserted.jpg", Status: models.StatusSuccess, FileSize: 123456789}
+	if err := db.UpsertThumbnail(upserted); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []*models.Thumbnail{added, upserted} {
+		byPath, err := db.GetByMoviePath(want.MoviePath)
+		if err != nil || byPath == nil {
+			t.Fatalf("GetByMoviePath(%q) = %v, %v", want.MoviePath, byPath, err)
+		}
+		byID, err := db.GetByID(byPath.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all, err := db.GetAllThumbnails()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var listed int64
+		for _, th := range all {
+			if th.ID == byPath.ID {
+				listed = th.FileSize
+			}
+		}
+		if byPath.FileSize != want.FileSize || byID.FileSize != want.FileSize || listed != want.FileSize {
+			t.Errorf("%s: file size by path %d, by ID %d, listed %d; want %d", want.MoviePath, byPath.FileSize, byID.FileSize, listed, want.FileSize)
+		}
+	}
+}
+
+func TestListQueriesScanEveryColumn(t *testing.T) {
+	db := newTestDB(t)
+
+	rows := []struct {
+		name   string
+		status string
+		viewed int
+	}{
+		{"unviewed.mp4", models.StatusSuccess, 0},
+		{"viewed.mp4", models.StatusSuccess, 1},
+		{"pending.mp4", models.StatusPending, 0},
+		{"error.mp4", models.StatusError, 0},
+		{"deleted.mp4", models.StatusDeleted, 0},
+		{"archived.mp4", models.StatusArchived, 0},
+	}
+	for _, r := range rows {
+		thumbnail := &models.Thumbnail{
+			MoviePath:     r.name,
+			MovieFilename: r.name,
+			ThumbnailPath: r.name + ".jpg",
+			Status:        r.status,
+			Viewed:        r.viewed,
+			FileSize:      4096,
+			Source:        models.SourceImported,
+		}
+		if err := db.UpsertThumbnail(thumbnail); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	queries := []struct {
+		name  string
+		query func() ([]*models.Thumbnail, error)
+		want  string
+	}{
+		{"GetUnviewedThumbnails", db.GetUnviewedThumbnails, "unviewed.mp4"},
+		{"GetViewedThumbnails", db.GetViewedThumbnails, "viewed.mp4"},
+		{"GetPendingThumbnails", db.GetPendingThumbnails, "pending.mp4"},
+		{"GetErrorThumbnails", db.GetErrorThumbnails, "error.mp4"},
+		{"GetDeletedThumbnails", func() ([]*models.Thumbnail, error) { return db.GetDeletedThumbnails(10) }, "deleted.mp4"},
+		{"GetArchivedThumbnails", func() ([]*models.Thumbnail, error) { return db.GetArchivedThumbnails(10) }, "archived.mp4"},
+	}
+	for _, q := range queries {
+		thumbnails, err := q.query()
+		if err != nil {
+			t.Errorf("%s() error = %v", q.name, err)
+			continue
+		}
+		if len(thumbnails) != 1 || thumbnails[0].MoviePath != q.want {
+			t.Errorf("%s() = %d rows, want only %s", q.name, len(thumbnails), q.want)
+			continue
+		}
+		if got := thumbnails[0]; got.Source != models.SourceImported || got.FileSize != 4096 {
+			t.Errorf("%s(): source %q, file size %d; want %q and 4096", q.name, got.Source, got.FileSize, models.SourceImported)
+		}
+	}
+
+	all, err := db.GetAllThumbnails()
+	if err != nil || len(all) != len(rows) {
+		t.Fatalf("GetAllThumbnails() = %d rows, %v; want %d", len(all), err, len(rows))
+	}
+	for _, got := range all {
+		if got.Source != models.SourceImported || got.FileSize != 4096 {
+			t.Errorf("GetAllThumbnails() %s: source %q, file size %d; want %q and 4096", got.MoviePath, got.Source, got.FileSize, models.SourceImported)
+		}
+	}
+}
+
+func TestGetByMovieFilenameAmbiguous(t *testing.T) {
+	db := newTestDB(t)
+	for _, moviePath := range []string{"Action/film.mkv", "Drama/film.mkv", "other.mkv"} {
+		if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: moviePath, MovieFilename: filepath.Base(moviePath)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, err := db.GetByMovieFilename("other.mkv"); err != nil || got == nil || got.MoviePath != "other.mkv" {
+		t.Errorf("GetByMovieFilename(other.mkv) = %+v, %v, want its row", got, err)
+	}
+	if _, err := db.GetByMovieFilename("film.mkv"); !errors.Is(err, ErrAmbiguous) {
+		t.Errorf("GetByMovieFilename(film.mkv) error = %v, want ErrAmbiguous", err)
+	}
+	if got, err := db.GetByMovieFilename("missing.mkv"); err != nil || got != nil {
+		t.Errorf("GetByMovieFilename(missing.mkv) = %+v, %v, want nil", got, err)
+	}
+}
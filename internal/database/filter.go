@@ -0,0 +1,62 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+// ParseThumbnailFilter parses a comma-separated list of key=value terms, such as
+// "source=imported,width_below=1280", into a ThumbnailFilter. The keys are status,
+// source, viewed, width_below and height_below.
+func ParseThumbnailFilter(expr string) (ThumbnailFilter, error) {
+	var filter ThumbnailFilter
+	for _, term := range strings.Split(expr, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(term, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return ThumbnailFilter{}, fmt.Errorf("invalid filter term %q, expected key=value", term)
+		}
+
+		switch key {
+		case "status":
+			switch value {
+			case models.StatusPending, models.StatusSuccess, models.StatusError,
+				models.StatusDeleted, models.StatusArchived, models.StatusDeleteFailed, models.StatusIncomplete:
+			default:
+				return ThumbnailFilter{}, fmt.Errorf("unknown status %q", value)
+			}
+			filter.Status = value
+		case "source":
+			if value != models.SourceGenerated && value != models.SourceImported {
+				return ThumbnailFilter{}, fmt.Errorf("source must be %q or %q, got %q", models.SourceGenerated, models.SourceImported, value)
+			}
+			filter.Source = value
+		case "viewed":
+			viewed, err := strconv.ParseBool(value)
+			if err != nil {
+				return ThumbnailFilter{}, fmt.Errorf("viewed must be true or false, got %q", value)
+			}
+			filter.Viewed = &viewed
+		case "width_below", "height_below":
+			pixels, err := strconv.Atoi(value)
+			if err != nil || pixels <= 0 {
+				return ThumbnailFilter{}, fmt.Errorf("%s must be a positive number of pixels, got %q", key, value)
+			}
+			if key == "width_below" {
+				filter.WidthBelow = pixels
+			} else {
+				filter.HeightBelow = pixels
+			}
+		default:
+			return ThumbnailFilter{}, fmt.Errorf("unknown filter key %q", key)
+		}
+	}
+	return filter, nil
+}
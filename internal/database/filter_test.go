@@ -0,0 +1,58 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+func TestParseThumbnailFilter(t *testing.T) {
+	filter, err := ParseThumbnailFilter("source=imported, width_below=1280,height_below=720,viewed=false,status=success")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.Source != models.SourceImported || filter.WidthBelow != 1280 || filter.HeightBelow != 720 ||
+		filter.Status != models.StatusSuccess || filter.Viewed == nil || *filter.Viewed {
+		t.Errorf("unexpected filter: %+v", filter)
+	}
+
+	for _, expr := range []string{"source", "source=copied", "width_below=-1", "viewed=maybe", "status=gone", "rating=5"} {
+		if _, err := ParseThumbnailFilter(expr); err == nil {
+			t.Errorf("ParseThumbnailFilter(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestGetThumbnailsFilteredBySourceAndResolution(t *testing.T) {
+	db := newTestDB(t)
+	for _, thumbnail := range []*models.Thumbnail{
+		{MoviePath: "imported-sd.mp4", Source: models.SourceImported, Width: 640, Height: 480},
+		{MoviePath: "imported-hd.mp4", Source: models.SourceImported, Width: 1920, Height: 1080},
+		{MoviePath: "generated-sd.mp4", Source: models.SourceGenerated, Width: 640, Height: 480},
+	} {
+		thumbnail.MovieFilename = thumbnail.MoviePath
+		thumbnail.Status = models.StatusSuccess
+		if err := db.UpsertThumbnail(thumbnail); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		expr string
+		want int
+	}{
+		{"source=imported", 2},
+		{"width_below=1280", 2},
+		{"source=imported,height_below=720", 1},
+	}
+	for _, tt := range tests {
+		filter, err := ParseThumbnailFilter(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, total, err := db.GetThumbnailsFiltered(filter, 0, 0)
+		if err != nil || total != tt.want {
+			t.Errorf("%s: %d matches (%v), want %d", tt.expr, total, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// columnMigration adds a column that older databases may be missing, then fills it
+// in for existing rows with the optional backfill statement
+type columnMigration struct {
+	name     string
+	ddl      string
+	backfill string
+}
+
+// columnMigrations lists columns added after the initial schema, in order
+var columnMigrations = []columnMigration{
+	{name: "file_size", ddl: "ALTER TABLE thumbnails ADD COLUMN file_size INTEGER DEFAULT 0"},
+	{name: "source", ddl: "ALTER TABLE thumbnails ADD COLUMN source TEXT DEFAULT 'generated'"},
+	{name: "delete_attempts", ddl: "ALTER TABLE thumbnails ADD COLUMN delete_attempts INTEGER DEFAULT 0"},
+	{name: "last_delete_attempt", ddl: "ALTER TABLE thumbnails ADD COLUMN last_delete_attempt INTEGER DEFAULT 0"},
+	{name: "view_count", ddl: "ALTER TABLE thumbnails ADD COLUMN view_count INTEGER DEFAULT 0"},
+	{name: "last_viewed_at", ddl: "ALTER TABLE thumbnails ADD COLUMN last_viewed_at TIMESTAMP"},
+	{name: "content_hash", ddl: "ALTER TABLE thumbnails ADD COLUMN content_hash TEXT"},
+	{name: "root_dir", ddl: "ALTER TABLE thumbnails ADD COLUMN root_dir TEXT NOT NULL DEFAULT ''"},
+	{name: "thumbnail_size", ddl: "ALTER TABLE thumbnails ADD COLUMN thumbnail_size INTEGER DEFAULT 0"},
+	{name: "movie_mtime", ddl: "ALTER TABLE thumbnails ADD COLUMN movie_mtime INTEGER DEFAULT 0"},
+	{name: "grid_cols", ddl: "ALTER TABLE thumbnails ADD COLUMN grid_cols INTEGER DEFAULT 0"},
+	{name: "grid_rows", ddl: "ALTER TABLE thumbnails ADD COLUMN grid_rows INTEGER DEFAULT 0"},
+	{name: "recorded_at", ddl: "ALTER TABLE thumbnails ADD COLUMN recorded_at TIMESTAMP"},
+	{name: "video_stream", ddl: "ALTER TABLE thumbnails ADD COLUMN video_stream INTEGER DEFAULT 0"},
+	{name: "sidecar_mtime", ddl: "ALTER TABLE thumbnails ADD COLUMN sidecar_mtime INTEGER DEFAULT 0"},
+	{name: "deletion_approved", ddl: "ALTER TABLE thumbnails ADD COLUMN deletion_approved INTEGER DEFAULT 0"},
+	{name: "generated_at", ddl: "ALTER TABLE thumbnails ADD COLUMN generated_at TIMESTAMP"},
+	{name: "generator_version", ddl: "ALTER TABLE thumbnails ADD COLUMN generator_version TEXT NOT NULL DEFAULT ''"},
+	{name: "poster_path", ddl: "ALTER TABLE thumbnails ADD COLUMN poster_path TEXT NOT NULL DEFAULT ''"},
+	{name: "preview_path", ddl: "ALTER TABLE thumbnails ADD COLUMN preview_path TEXT NOT NULL DEFAULT ''"},
+	{name: "queued_at", ddl: "ALTER TABLE thumbnails ADD COLUMN queued_at TIMESTAMP",
+		backfill: "UPDATE thumbnails SET queued_at = updated_at WHERE status IN ('deleted', 'archived', 'delete_failed')"},
+}
+
+// BackfillResult summarizes a file size backfill run
+type BackfillResult struct {
+	Total   int
+	Updated int
+	Missing int
+	Errors  int
+}
+
+// Migrate brings an existing database up to the current schema
+func (d *DB) Migrate() error {
+	return d.migrateColumns()
+}
+
+// migrateColumns adds any columns missing from older databases. It is idempotent
+// and also runs whenever the database is opened.
+func (d *DB) migrateColumns() error {
+	existing, err := d.columns()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range columnMigrations {
+		if existing[m.name] {
+			continue
+		}
+		if _, err := d.db.Exec(m.ddl); err != nil {
+			return fmt.Errorf("failed to add %s column: %w", m.name, err)
+		}
+		if m.backfill == "" {
+			continue
+		}
+		if _, err := d.db.Exec(m.backfill); err != nil {
+			return fmt.Errorf("failed to backfill %s column: %w", m.name, err)
+		}
+	}
+
+	// Indexes on migrated columns can only be created once the columns exist
+	if _, err := d.db.Exec("CREATE INDEX IF NOT EXISTS idx_thumbnails_content_hash ON thumbnails(content_hash)"); err != nil {
+		return fmt.Errorf("failed to create content hash index: %w", err)
+	}
+	if _, err := d.db.Exec("CREATE INDEX IF NOT EXISTS idx_thumbnails_root_dir ON thumbnails(root_dir)"); err != nil {
+		return fmt.Errorf("failed to create root dir index: %w", err)
+	}
+
+	return nil
+}
+
+// columns returns the set of column names on the thumbnails table
+func (d *DB) columns() (map[string]bool, error) {
+	rows, err := d.db.Query("SELECT name FROM pragma_table_info('thumbnails')")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table info: %w", err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan column info: %w", err)
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// BackfillFileSizes populates file_size for rows that predate the column.
+// Rows whose movie can no longer be found in any of movieDirs are marked as deleted.
+func (d *DB) BackfillFileSizes(movieDirs []string) (BackfillResult, error) {
+	var result BackfillResult
+
+	rows, err := d.db.Query(`SELECT id, movie_path, file_size FROM thumbnails ORDER BY id`)
+	if err != nil {
+		return result, fmt.Errorf("failed to query thumbnails: %w", err)
+	}
+
+	type update struct {
+		id       int64
+		fileSize int64
+		missing  bool
+	}
+	var updates []update
+
+	for rows.Next() {
+		result.Total++
+		var id, fileSize int64
+		var moviePath string
+		if err := rows.Scan(&id, &moviePath, &fileSize); err != nil {
+			result.Errors++
+			continue
+		}
+
+		// Skip if file_size is already set
+		if fileSize > 0 {
+			continue
+		}
+
+		info, err := os.Stat(MapMoviePath(moviePath, movieDirs))
+		if err != nil {
+			if os.IsNotExist(err) {
+				updates = append(updates, update{id: id, missing: true})
+				continue
+			}
+			result.Errors++
+			continue
+		}
+		updates = append(updates, update{id: id, fileSize: info.Size()})
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return result, fmt.Errorf("error iterating rows: %w", err)
+	}
+	rows.Close()
+
+	// Apply all updates in one transaction
+	tx, err := d.db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, u := range updates {
+		if u.missing {
+			if _, err := tx.Exec("UPDATE thumbnails SET status = 'deleted', queued_at = CURRENT_TIMESTAMP WHERE id = ?", u.id); err != nil {
+				result.Errors++
+				continue
+			}
+			result.Missing++
+		} else {
+			if _, err := tx.Exec("UPDATE thumbnails SET file_size = ? WHERE id = ?", u.fileSize, u.id); err != nil {
+				result.Errors++
+				continue
+			}
+			result.Updated++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	d.stats.invalidate()
+
+	return result, nil
+}
+
+// MapMoviePath attempts to map a database path to any of the provided movie directories.
+// It applies exact / basename / last-2 / last-3 heuristics per dir and returns the first hit.
+func MapMoviePath(dbPath string, movieDirs []string) string {
+	// If the path already exists as-is, use it
+	if fileExists(dbPath) {
+		return dbPath
+	}
+
+	filename := filepath.Base(dbPath)
+	pathParts := strings.Split(filepath.Clean(dbPath), string(filepath.Separator))
+
+	for _, movieDir := range movieDirs {
+		// Try the filename directly in the movie directory
+		if p := filepath.Join(movieDir, filename); fileExists(p) {
+			return p
+		}
+
+		// Try with the last 2 parts (directory + filename)
+		if len(pathParts) >= 2 {
+			p := filepath.Join(movieDir, pathParts[len(pathParts)-2], pathParts[len(pathParts)-1])
+			if fileExists(p) {
+				return p
+			}
+		}
+
+		// Try with the last 3 parts
+		if len(pathParts) >= 3 {
+			p := filepath.Join(movieDir, pathParts[len(pathParts)-3], pathParts[len(pathParts)-2], pathParts[len(pathParts)-1])
+			if fileExists(p) {
+				return p
+			}
+		}
+	}
+
+	return dbPath
+}
+
+func fileExists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
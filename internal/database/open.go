@@ -0,0 +1,56 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// OpenOptions controls how NewWithRetry opens the database
+type OpenOptions struct {
+	// Retries is the number of additional attempts after the first failure
+	Retries int
+	// Delay is the wait before the first retry; it doubles after each attempt
+	Delay time.Duration
+	// CreateDir creates the database's parent directory if it is missing
+	CreateDir bool
+	// BusyTimeout is how long SQLite waits for a lock held by another connection
+	// before failing with SQLITE_BUSY; zero uses DefaultBusyTimeout
+	BusyTimeout time.Duration
+	// OnRetry, if set, is called after each failed attempt that will be retried
+	OnRetry func(attempt int, err error, wait time.Duration)
+}
+
+// NewWithRetry opens the database like New, retrying with exponential backoff so
+// that a database on a slow or not-yet-mounted filesystem doesn't fail startup
+func NewWithRetry(dbPath string, opts OpenOptions) (*DB, error) {
+	if opts.CreateDir && !isMemoryPath(dbPath) {
+		if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	wait := opts.Delay
+	for attempt := 1; ; attempt++ {
+		db, err := open(dbPath, opts.BusyTimeout)
+		if err == nil {
+			return db, nil
+		}
+		if attempt > opts.Retries {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		if opts.OnRetry != nil {
+			opts.OnRetry(attempt, err, wait)
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// isMemoryPath reports whether a SQLite path refers to an in-memory or URI database
+func isMemoryPath(dbPath string) bool {
+	return dbPath == ":memory:" || strings.HasPrefix(dbPath, "file:")
+}
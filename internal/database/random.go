@@ -0,0 +1,47 @@
+package database
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+	mathrand "math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// randReader is the source of random offsets; tests swap it to force the fallback
+var randReader io.Reader = rand.Reader
+
+var (
+	fallbackMu   sync.Mutex
+	fallbackRand = newFallbackRand(time.Now())
+
+	randomFallbacks atomic.Int64
+)
+
+// newFallbackRand returns the math/rand source used when crypto/rand fails, seeded
+// from the start time so the pick order differs between restarts
+func newFallbackRand(start time.Time) *mathrand.Rand {
+	return mathrand.New(mathrand.NewSource(start.UnixNano()))
+}
+
+// randomOffset returns a random offset in [0, count), from crypto/rand or, when it
+// fails, from the seeded fallback source
+func randomOffset(count int) int64 {
+	n, err := rand.Int(randReader, big.NewInt(int64(count)))
+	if err == nil {
+		return n.Int64()
+	}
+
+	randomFallbacks.Add(1)
+	fallbackMu.Lock()
+	defer fallbackMu.Unlock()
+	return fallbackRand.Int63n(int64(count))
+}
+
+// RandomFallbacks returns how many random picks fell back to math/rand because
+// crypto/rand failed
+func RandomFallbacks() int64 {
+	return randomFallbacks.Load()
+}
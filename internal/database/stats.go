@@ -0,0 +1,68 @@
+package database
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+// statsCache keeps the last GetStats result for a short TTL, so frequent stats polling
+// does not rerun the full-table aggregate. Writes through the DB invalidate it.
+type statsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	stats   *models.Stats
+	expires time.Time
+	gen     uint64 // Bumped on invalidation so a read racing a write is not cached
+}
+
+// SetStatsCacheTTL sets how long GetStats results are reused; 0 disables caching
+func (d *DB) SetStatsCacheTTL(ttl time.Duration) {
+	d.stats.mu.Lock()
+	defer d.stats.mu.Unlock()
+	d.stats.ttl = ttl
+	d.stats.stats = nil
+}
+
+// get returns a copy of the cached stats when fresh, otherwise loads and caches them
+func (c *statsCache) get(load func() (*models.Stats, error)) (*models.Stats, error) {
+	c.mu.Lock()
+	if c.stats != nil && time.Now().Before(c.expires) {
+		cached := *c.stats
+		c.mu.Unlock()
+		return &cached, nil
+	}
+	ttl, gen := c.ttl, c.gen
+	c.mu.Unlock()
+
+	stats, err := load()
+	if err != nil || ttl <= 0 {
+		return stats, err
+	}
+
+	c.mu.Lock()
+	if c.gen == gen {
+		cached := *stats
+		c.stats = &cached
+		c.expires = time.Now().Add(ttl)
+	}
+	c.mu.Unlock()
+
+	return stats, nil
+}
+
+// invalidate drops the cached stats
+func (c *statsCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats = nil
+	c.gen++
+}
+
+// generation returns the number of invalidations so far
+func (c *statsCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
@@ -0,0 +1,74 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/config"
+)
+
+// dedupFilter returns the ffmpeg filter dropping near-duplicate frames selected by
+// DEDUP_FRAMES, or "" when frames aren't filtered
+func (t *Thumbnailer) dedupFilter() string {
+	switch t.cfg.DedupFrames {
+	case config.DedupMpdecimate:
+		return "mpdecimate"
+	case config.DedupScene:
+		return fmt.Sprintf("select='gt(scene,%s)'", strconv.FormatFloat(t.cfg.SceneThreshold, 'f', -1, 64))
+	default:
+		return ""
+	}
+}
+
+// countDistinctFrames decodes the keyframes of the sampling window and counts those
+// passing the dedup filter, so the grid can be spread over them
+func (t *Thumbnailer) countDistinctFrames(ctx context.Context, moviePath string, stream int, duration float64, dedup string) (int, error) {
+	start, length := t.sampleWindow(duration)
+
+	args := []string{
+		"-v", "error",
+		"-threads", "2",
+	}
+	args = append(args, t.inputArgs(moviePath, start, length)...)
+	args = append(args,
+		"-map", videoStreamMap(stream),
+		"-vf", "select='eq(pict_type,I)',"+dedup,
+		"-an",
+		"-progress", "pipe:1", "-nostats",
+		"-f", "null", "-",
+	)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("ffmpeg error: %v - %s", err, parseFFmpegError(stderr.String()))
+	}
+	return parseFrameCount(stdout.String())
+}
+
+// parseFrameCount returns the final frame count reported in ffmpeg -progress output
+func parseFrameCount(progress string) (int, error) {
+	frames := -1
+	for _, line := range strings.Split(progress, "\n") {
+		value, found := strings.CutPrefix(strings.TrimSpace(line), "frame=")
+		if !found {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid frame count %q", value)
+		}
+		frames = n
+	}
+	if frames < 0 {
+		return 0, fmt.Errorf("no frame count in ffmpeg progress output")
+	}
+	return frames, nil
+}
@@ -0,0 +1,42 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/config"
+)
+
+func TestGridFilterDedup(t *testing.T) {
+	layout := GridLayout{Cols: 4, Rows: 4, TileWidth: 320, TileHeight: 180}
+	tests := []struct {
+		mode      string
+		threshold float64
+		want      string
+	}{
+		{config.DedupOff, 0.3, "select='eq(pict_type,I)',select='not(mod(n,5))',"},
+		{config.DedupMpdecimate, 0.3, "select='eq(pict_type,I)',mpdecimate,select='not(mod(n,5))',"},
+		{config.DedupScene, 0.25, "select='eq(pict_type,I)',select='gt(scene,0.25)',select='not(mod(n,5))',"},
+	}
+
+	for _, tt := range tests {
+		th := &Thumbnailer{cfg: &config.Config{DedupFrames: tt.mode, SceneThreshold: tt.threshold}}
+		got := gridFilter(5, th.dedupFilter(), layout, false)
+		if !strings.HasPrefix(got, tt.want) || !strings.HasSuffix(got, "tile=4x4:padding=4:margin=4") {
+			t.Errorf("%s: filter = %q, want it to start with %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestParseFrameCount(t *testing.T) {
+	progress := "frame=12\nfps=0.0\nprogress=continue\nframe=37\nfps=0.0\nprogress=end\n"
+	if got, err := parseFrameCount(progress); err != nil || got != 37 {
+		t.Errorf("parseFrameCount = %d, %v; want 37", got, err)
+	}
+
+	for _, progress := range []string{"", "progress=end\n", "frame=N/A\n"} {
+		if _, err := parseFrameCount(progress); err == nil {
+			t.Errorf("parseFrameCount(%q) succeeded, want an error", progress)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package ffmpeg
+
+import (
+	"math"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/config"
+)
+
+// Tile geometry of the thumbnail grid; the tile size is set by THUMB_CELL_WIDTH and
+// THUMB_CELL_HEIGHT
+const (
+	gridPadding  = 4
+	gridMargin   = 4
+	minTileWidth = 32
+)
+
+// GridLayout describes the geometry of a generated thumbnail grid
+type GridLayout struct {
+	Cols       int
+	Rows       int
+	TileWidth  int
+	TileHeight int
+}
+
+// Width returns the total pixel width of the grid, including padding and margins
+func (g GridLayout) Width() int {
+	return g.Cols*g.TileWidth + (g.Cols-1)*gridPadding + 2*gridMargin
+}
+
+// Height returns the total pixel height of the grid, including padding and margins
+func (g GridLayout) Height() int {
+	return g.Rows*g.TileHeight + (g.Rows-1)*gridPadding + 2*gridMargin
+}
+
+// Pixels returns the total pixel count of the grid
+func (g GridLayout) Pixels() int {
+	return g.Width() * g.Height()
+}
+
+// Cells returns the number of tiles in the grid
+func (g GridLayout) Cells() int {
+	return g.Cols * g.Rows
+}
+
+// fitGrid shrinks a layout until it is at most maxPixels in total, first by scaling the
+// tiles down (keeping their aspect ratio) and, once tiles reach their minimum size, by
+// dropping rows and then columns. A maxPixels of 0 or less disables the limit. The
+// boolean reports whether the layout was adjusted.
+func fitGrid(layout GridLayout, maxPixels int) (GridLayout, bool) {
+	if maxPixels <= 0 || layout.Pixels() <= maxPixels {
+		return layout, false
+	}
+
+	fitted := layout
+	aspect := float64(layout.TileHeight) / float64(layout.TileWidth)
+
+	// Scale the tiles by the area ratio, then step down until it fits
+	scale := math.Sqrt(float64(maxPixels) / float64(layout.Pixels()))
+	width := int(float64(layout.TileWidth) * scale)
+	for width >= minTileWidth {
+		fitted.TileWidth = width &^ 1 // Keep tile sizes even for the encoder
+		fitted.TileHeight = int(float64(fitted.TileWidth)*aspect) &^ 1
+		if fitted.TileHeight < 2 {
+			fitted.TileHeight = 2
+		}
+		if fitted.Pixels() <= maxPixels {
+			return fitted, true
+		}
+		width -= 2
+	}
+
+	// Tiles are as small as allowed; reduce the grid itself
+	fitted.TileWidth = minTileWidth
+	fitted.TileHeight = int(float64(minTileWidth)*aspect) &^ 1
+	if fitted.TileHeight < 2 {
+		fitted.TileHeight = 2
+	}
+	for fitted.Pixels() > maxPixels && (fitted.Rows > 1 || fitted.Cols > 1) {
+		if fitted.Rows >= fitted.Cols && fitted.Rows > 1 {
+			fitted.Rows--
+		} else {
+			fitted.Cols--
+		}
+	}
+
+	return fitted, true
+}
+
+// fitFrames drops rows and columns, the larger dimension first, until the grid has no
+// more tiles than the movie has frames to fill them. A frames count of 0 or less means
+// it is unknown and leaves the layout alone. The boolean reports whether it was adjusted.
+func fitFrames(layout GridLayout, frames int) (GridLayout, bool) {
+	if frames <= 0 || layout.Cells() <= frames {
+		return layout, false
+	}
+
+	fitted := layout
+	for fitted.Cells() > frames && (fitted.Rows > 1 || fitted.Cols > 1) {
+		if fitted.Rows >= fitted.Cols && fitted.Rows > 1 {
+			fitted.Rows--
+		} else {
+			fitted.Cols--
+		}
+	}
+	return fitted, true
+}
+
+// gridLayout returns the layout to generate for the current configuration, bounded
+// by MAX_GRID_PIXELS
+func (t *Thumbnailer) gridLayout() (GridLayout, bool) {
+	return fitGrid(t.configuredLayout(), t.cfg.MaxGridPixels)
+}
+
+// configuredLayout returns the layout of GRID_COLS, GRID_ROWS and the tile size
+func (t *Thumbnailer) configuredLayout() GridLayout {
+	width, height := t.cfg.ThumbCellSize()
+	return GridLayout{
+		Cols:       t.cfg.GridCols,
+		Rows:       t.cfg.GridRows,
+		TileWidth:  width,
+		TileHeight: height,
+	}
+}
+
+// ConfiguredLayout returns the grid layout generated with cfg, bounded by
+// MAX_GRID_PIXELS. Short movies and sidecars can still make a movie's grid smaller.
+func ConfiguredLayout(cfg *config.Config) GridLayout {
+	layout, _ := (&Thumbnailer{cfg: cfg}).gridLayout()
+	return layout
+}
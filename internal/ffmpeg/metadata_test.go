@@ -0,0 +1,85 @@
+package ffmpeg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseVideoMetadataCreationTime(t *testing.T) {
+	const withTag = `{
+		"streams": [{"width": 1920, "height": 1080}],
+		"format": {"duration": "12.5", "tags": {"creation_time": "2021-07-04T18:30:05.000000Z", "encoder": "Lavf58"}}
+	}`
+	metadata, err := parseVideoMetadata(withTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2021, 7, 4, 18, 30, 5, 0, time.UTC)
+	if metadata.RecordedAt == nil || !metadata.RecordedAt.Equal(want) {
+		t.Errorf("RecordedAt = %v, want %v", metadata.RecordedAt, want)
+	}
+	if metadata.Width != 1920 || metadata.Height != 1080 || metadata.Duration != 12.5 {
+		t.Errorf("metadata = %+v", metadata)
+	}
+
+	for _, output := range []string{
+		`{"streams": [{"width": 640, "height": 480}], "format": {"duration": "3"}}`,
+		`{"streams": [{"width": 640, "height": 480}], "format": {"duration": "3", "tags": {"creation_time": "1970-01-01T00:00:00.000000Z"}}}`,
+		`{"streams": [{"width": 640, "height": 480}], "format": {"duration": "3", "tags": {"creation_time": "yesterday"}}}`,
+	} {
+		metadata, err := parseVideoMetadata(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if metadata.RecordedAt != nil {
+			t.Errorf("%s: RecordedAt = %v, want none", output, metadata.RecordedAt)
+		}
+	}
+}
+
+func TestParseVideoMetadataMainStream(t *testing.T) {
+	tests := []struct {
+		name          string
+		streams       string
+		wantStream    int
+		width, height int
+	}{
+		{
+			name:       "cover art flagged as attached picture",
+			streams:    `{"width": 600, "height": 600, "disposition": {"attached_pic": 1}}, {"width": 1920, "height": 1080}`,
+			wantStream: 1, width: 1920, height: 1080,
+		},
+		{
+			name:       "unflagged cover art stream",
+			streams:    `{"width": 320, "height": 240, "duration": "0.04"}, {"width": 1280, "height": 720, "duration": "3600"}`,
+			wantStream: 1, width: 1280, height: 720,
+		},
+		{
+			name:       "same size angles prefer the longest",
+			streams:    `{"width": 1920, "height": 1080, "duration": "60"}, {"width": 1920, "height": 1080, "duration": "5400"}`,
+			wantStream: 1, width: 1920, height: 1080,
+		},
+		{
+			name:       "single stream",
+			streams:    `{"width": 640, "height": 480}`,
+			wantStream: 0, width: 640, height: 480,
+		},
+		{
+			name:       "only an attached picture",
+			streams:    `{"width": 500, "height": 500, "disposition": {"attached_pic": 1}}`,
+			wantStream: 0, width: 500, height: 500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata, err := parseVideoMetadata(`{"streams": [` + tt.streams + `], "format": {"duration": "3600"}}`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if metadata.VideoStream != tt.wantStream || metadata.Width != tt.width || metadata.Height != tt.height {
+				t.Errorf("stream %d at %dx%d, want %d at %dx%d", metadata.VideoStream, metadata.Width, metadata.Height, tt.wantStream, tt.width, tt.height)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/database"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+// Size of the waveform drawn for audio files without cover art
+const (
+	waveformWidth  = 1280
+	waveformHeight = 360
+)
+
+// mediaKind is the kind of content a file holds, decided from its streams
+type mediaKind int
+
+const (
+	mediaVideo mediaKind = iota
+	mediaAudio
+	mediaImage
+)
+
+// imageCodecs are codecs ffprobe reports for still images
+var imageCodecs = map[string]bool{
+	"mjpeg": true, "png": true, "webp": true, "bmp": true, "tiff": true, "gif": true,
+}
+
+// streamProbe is the subset of ffprobe's output used to classify a file
+type streamProbe struct {
+	Streams []struct {
+		Index       int    `json:"index"`
+		CodecType   string `json:"codec_type"`
+		CodecName   string `json:"codec_name"`
+		Width       int    `json:"width"`
+		Height      int    `json:"height"`
+		Disposition struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+	} `json:"format"`
+}
+
+// classify decides whether a probed file is a video, an audio file or a still image.
+// For audio files, cover is the stream index of the attached picture, or -1.
+func (p *streamProbe) classify() (kind mediaKind, cover int, ok bool) {
+	cover = -1
+	hasAudio := false
+	var images, videos int
+
+	for _, s := range p.Streams {
+		switch s.CodecType {
+		case "audio":
+			hasAudio = true
+		case "video":
+			if s.Disposition.AttachedPic == 1 {
+				if cover < 0 {
+					cover = s.Index
+				}
+				continue
+			}
+			if imageCodecs[s.CodecName] && isImageFormat(p.Format.FormatName) {
+				images++
+			} else {
+				videos++
+			}
+		}
+	}
+
+	switch {
+	case videos > 0:
+		return mediaVideo, -1, true
+	case images > 0 && !hasAudio:
+		return mediaImage, -1, true
+	case hasAudio:
+		return mediaAudio, cover, true
+	default:
+		return mediaVideo, -1, false
+	}
+}
+
+// isImageFormat reports whether ffprobe read the file with a still image demuxer, as
+// opposed to a container that merely uses an image codec such as MJPEG in AVI
+func isImageFormat(name string) bool {
+	return name == "image2" || name == "gif" || strings.HasSuffix(name, "_pipe")
+}
+
+// probeStreams runs ffprobe over every stream of a file
+func (t *Thumbnailer) probeStreams(ctx context.Context, path string) (*streamProbe, error) {
+	start := time.Now()
+
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path,
+	)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if t.metrics != nil {
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		t.metrics.RecordFFmpegExecution(result, time.Since(start))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe error: %v - %s", err, stderr.String())
+	}
+
+	var probe streamProbe
+	if err := json.Unmarshal(stdout.Bytes(), &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe JSON output: %v", err)
+	}
+	return &probe, nil
+}
+
+// nonVideoArgs builds the ffmpeg arguments that render a still thumbnail for an audio
+// or image file at the given JPEG quality
+func nonVideoArgs(p *streamProbe, kind mediaKind, cover int, inputPath, outputPath string, maxWidth, quality int) []string {
+	args := []string{"-v", "error", "-i", inputPath}
+	q := strconv.Itoa(quality)
+
+	switch {
+	case kind == mediaImage:
+		args = append(args, "-vf", fmt.Sprintf("scale='min(iw,%d)':-2", maxWidth), "-frames:v", "1", "-q:v", q)
+	case cover >= 0:
+		args = append(args, "-map", "0:"+strconv.Itoa(cover), "-an")
+		if codec := p.streamCodec(cover); codec == "mjpeg" {
+			args = append(args, "-vcodec", "copy")
+		} else {
+			args = append(args, "-frames:v", "1", "-q:v", q)
+		}
+	default:
+		args = append(args, "-filter_complex",
+			fmt.Sprintf("showwavespic=s=%dx%d:colors=white", waveformWidth, waveformHeight),
+			"-frames:v", "1", "-q:v", q)
+	}
+
+	return append(args, "-update", "1", "-y", outputPath)
+}
+
+// streamCodec returns the codec name of the stream with the given index
+func (p *streamProbe) streamCodec(index int) string {
+	for _, s := range p.Streams {
+		if s.Index == index {
+			return s.CodecName
+		}
+	}
+	return ""
+}
+
+// createNonVideoThumbnail renders a cover-art, waveform or resized still thumbnail for
+// audio and image files. handled is false for files with a real video stream, which
+// get the regular grid.
+func (t *Thumbnailer) createNonVideoThumbnail(ctx context.Context, moviePath string, thumbnail *models.Thumbnail, db *database.DB) (handled bool, result *models.Thumbnail, err error) {
+	probe, err := t.probeStreams(ctx, moviePath)
+	if err != nil {
+		return false, nil, nil // Let the regular path report the failure
+	}
+
+	kind, cover, ok := probe.classify()
+	if !ok || kind == mediaVideo {
+		return false, nil, nil
+	}
+
+	layout, _ := t.gridLayout()
+	name := "image"
+	if kind == mediaAudio {
+		name = "audio"
+		thumbnail.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	} else {
+		thumbnail.Width, thumbnail.Height = probe.Streams[0].Width, probe.Streams[0].Height
+	}
+	t.log.WithField("movie", moviePath).Infof("Creating %s thumbnail", name)
+
+	workFile, err := os.CreateTemp("", "thumbnail-*"+filepath.Ext(thumbnail.ThumbnailPath))
+	if err != nil {
+		err = fmt.Errorf("failed to create work file: %w", err)
+		if errors.Is(err, fs.ErrPermission) {
+			return true, thumbnail, err // Not the file's fault; the scanner aborts
+		}
+		result, err := t.saveError(thumbnail, db, moviePath, fmt.Sprintf("Failed to generate %s thumbnail: %v", name, err), err)
+		return true, result, err
+	}
+	workPath := workFile.Name()
+	workFile.Close()
+	defer os.Remove(workPath)
+
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, "ffmpeg", nonVideoArgs(probe, kind, cover, moviePath, workPath, layout.Width(), t.cfg.PosterJPEGQuality())...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if t.metrics != nil {
+		status := "success"
+		if err != nil {
+			status = "error"
+		}
+		t.metrics.RecordFFmpegExecution(status, time.Since(start))
+	}
+	if err != nil {
+		err = fmt.Errorf("ffmpeg error: %v - %s", err, parseFFmpegError(stderr.String()))
+		result, err := t.saveError(thumbnail, db, moviePath, fmt.Sprintf("Failed to generate %s thumbnail: %v", name, err), err)
+		return true, result, err
+	}
+
+	if info, err := os.Stat(workPath); err != nil || info.Size() == 0 {
+		result, err := t.saveError(thumbnail, db, moviePath, "Thumbnail file was not created",
+			fmt.Errorf("thumbnail file was not created: %s", thumbnail.ThumbnailPath))
+		return true, result, err
+	}
+
+	if err := t.store(ctx, thumbnail.ThumbnailPath, workPath); err != nil {
+		t.log.WithError(err).WithField("thumbnail", thumbnail.ThumbnailPath).Error("Failed to store thumbnail")
+		if errors.Is(err, fs.ErrPermission) {
+			return true, thumbnail, err // Not the file's fault; the scanner aborts
+		}
+		result, err := t.saveError(thumbnail, db, moviePath, fmt.Sprintf("Failed to store thumbnail: %v", err), err)
+		return true, result, err
+	}
+
+	thumbnail.Status = models.StatusSuccess
+	t.markGenerated(thumbnail)
+	if db != nil {
+		if err := db.UpsertThumbnail(thumbnail); err != nil {
+			t.log.WithError(err).WithField("movie", moviePath).Error("Failed to save success status")
+		}
+	}
+	return true, thumbnail, nil
+}
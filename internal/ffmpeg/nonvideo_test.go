@@ -0,0 +1,91 @@
+package ffmpeg
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestStreamProbeClassify(t *testing.T) {
+	tests := []struct {
+		name      string
+		json      string
+		wantKind  mediaKind
+		wantCover int
+		wantOK    bool
+	}{
+		{
+			name:     "video with audio",
+			json:     `{"streams":[{"index":0,"codec_type":"video","codec_name":"h264"},{"index":1,"codec_type":"audio","codec_name":"aac"}],"format":{"format_name":"mov,mp4,m4a,3gp,3g2,mj2"}}`,
+			wantKind: mediaVideo, wantCover: -1, wantOK: true,
+		},
+		{
+			name:     "mjpeg avi is still a video",
+			json:     `{"streams":[{"index":0,"codec_type":"video","codec_name":"mjpeg"}],"format":{"format_name":"avi"}}`,
+			wantKind: mediaVideo, wantCover: -1, wantOK: true,
+		},
+		{
+			name:     "mp3 with cover art",
+			json:     `{"streams":[{"index":0,"codec_type":"audio","codec_name":"mp3"},{"index":1,"codec_type":"video","codec_name":"mjpeg","disposition":{"attached_pic":1}}],"format":{"format_name":"mp3","duration":"200.5"}}`,
+			wantKind: mediaAudio, wantCover: 1, wantOK: true,
+		},
+		{
+			name:     "audio without cover",
+			json:     `{"streams":[{"index":0,"codec_type":"audio","codec_name":"flac"}],"format":{"format_name":"flac"}}`,
+			wantKind: mediaAudio, wantCover: -1, wantOK: true,
+		},
+		{
+			name:     "jpeg image",
+			json:     `{"streams":[{"index":0,"codec_type":"video","codec_name":"mjpeg","width":4000,"height":3000}],"format":{"format_name":"image2"}}`,
+			wantKind: mediaImage, wantCover: -1, wantOK: true,
+		},
+		{
+			name:     "png image",
+			json:     `{"streams":[{"index":0,"codec_type":"video","codec_name":"png"}],"format":{"format_name":"png_pipe"}}`,
+			wantKind: mediaImage, wantCover: -1, wantOK: true,
+		},
+		{
+			name:   "subtitles only",
+			json:   `{"streams":[{"index":0,"codec_type":"subtitle","codec_name":"subrip"}],"format":{"format_name":"srt"}}`,
+			wantOK: false, wantCover: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var probe streamProbe
+			if err := json.Unmarshal([]byte(tt.json), &probe); err != nil {
+				t.Fatal(err)
+			}
+			kind, cover, ok := probe.classify()
+			if kind != tt.wantKind || cover != tt.wantCover || ok != tt.wantOK {
+				t.Errorf("classify() = (%v, %d, %v), want (%v, %d, %v)", kind, cover, ok, tt.wantKind, tt.wantCover, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNonVideoArgs(t *testing.T) {
+	var probe streamProbe
+	json.Unmarshal([]byte(`{"streams":[{"index":0,"codec_type":"audio"},{"index":1,"codec_type":"video","codec_name":"mjpeg","disposition":{"attached_pic":1}},{"index":2,"codec_type":"video","codec_name":"png","disposition":{"attached_pic":1}}]}`), &probe)
+
+	tests := []struct {
+		name  string
+		kind  mediaKind
+		cover int
+		want  string
+	}{
+		{"jpeg cover is copied", mediaAudio, 1, "-map 0:1 -an -vcodec copy"},
+		{"png cover is re-encoded", mediaAudio, 2, "-map 0:2 -an -frames:v 1 -q:v 5"},
+		{"waveform", mediaAudio, -1, "showwavespic=s=1280x360:colors=white -frames:v 1 -q:v 5"},
+		{"image", mediaImage, -1, "scale='min(iw,960)':-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := strings.Join(nonVideoArgs(&probe, tt.kind, tt.cover, "in", "out.jpg", 960, 5), " ")
+			if !strings.Contains(args, tt.want) || !strings.HasSuffix(args, "-y out.jpg") {
+				t.Errorf("expected args to contain %q, got %q", tt.want, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// JPEG markers used to tell baseline and progressive images apart
+const (
+	jpegMarkerSOF0 = 0xC0 // Baseline DCT
+	jpegMarkerSOF1 = 0xC1 // Extended sequential DCT
+	jpegMarkerSOF2 = 0xC2 // Progressive DCT
+	jpegMarkerSOS  = 0xDA // Start of scan
+)
+
+// isJPEGPath reports whether a path has a JPEG file extension
+func isJPEGPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".jpg" || ext == ".jpeg"
+}
+
+// makeProgressive rewrites a JPEG file as a progressive JPEG in place.
+// ffmpeg's mjpeg encoder only produces baseline images, so this uses jpegtran's
+// lossless transcoding rather than re-encoding the grid.
+func makeProgressive(ctx context.Context, path string) error {
+	tmpPath := path + ".progressive.tmp"
+
+	cmd := exec.CommandContext(ctx, "jpegtran", "-progressive", "-optimize", "-copy", "all", "-outfile", tmpPath, path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("jpegtran error: %v - %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace thumbnail: %w", err)
+	}
+
+	return nil
+}
+
+// isProgressiveJPEG walks the JPEG marker segments up to the first scan and
+// reports whether the frame header is progressive (SOF2)
+func isProgressiveJPEG(data []byte) bool {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return false
+	}
+
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return false
+		}
+		marker := data[i+1]
+		if marker == 0xFF { // Fill byte
+			i++
+			continue
+		}
+
+		switch marker {
+		case jpegMarkerSOF2:
+			return true
+		case jpegMarkerSOF0, jpegMarkerSOF1, jpegMarkerSOS:
+			return false
+		}
+
+		length := int(data[i+2])<<8 | int(data[i+3])
+		if length < 2 {
+			return false
+		}
+		i += 2 + length
+	}
+
+	return false
+}
@@ -0,0 +1,73 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func encodeTestJPEG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 32; y++ {
+			img.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 8), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("failed to encode test JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestIsProgressiveJPEG(t *testing.T) {
+	// Minimal marker sequences: SOI, APP0 (length 4), then a frame header
+	progressive := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00, 0xFF, 0xC2, 0x00, 0x02}
+	baseline := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00, 0xFF, 0xC0, 0x00, 0x02}
+
+	if !isProgressiveJPEG(progressive) {
+		t.Error("expected SOF2 stream to be progressive")
+	}
+	if isProgressiveJPEG(baseline) {
+		t.Error("expected SOF0 stream to be baseline")
+	}
+	if isProgressiveJPEG([]byte("not a jpeg")) {
+		t.Error("expected non-JPEG data to be rejected")
+	}
+	if isProgressiveJPEG(encodeTestJPEG(t)) {
+		t.Error("expected image/jpeg output to be baseline")
+	}
+}
+
+func TestMakeProgressive(t *testing.T) {
+	if _, err := exec.LookPath("jpegtran"); err != nil {
+		t.Skip("jpegtran not installed")
+	}
+
+	path := filepath.Join(t.TempDir(), "grid.jpg")
+	if err := os.WriteFile(path, encodeTestJPEG(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := makeProgressive(context.Background(), path); err != nil {
+		t.Fatalf("makeProgressive failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isProgressiveJPEG(data) {
+		t.Error("expected rewritten thumbnail to be progressive")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("rewritten thumbnail is not a valid JPEG: %v", err)
+	}
+}
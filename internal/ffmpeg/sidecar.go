@@ -0,0 +1,99 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SidecarSuffix is appended to a movie's name, without its extension, to find the
+// sidecar overriding the generation settings for that movie
+const SidecarSuffix = ".thumbnailer.json"
+
+// Overrides are per-movie generation settings read from a sidecar. Unset fields keep
+// the global configuration.
+type Overrides struct {
+	GridCols           int      `json:"grid_cols"`
+	GridRows           int      `json:"grid_rows"`
+	IntroSkip          *float64 `json:"intro_skip"` // Percentage, like INTRO_SKIP_PERCENT
+	SampleStartPercent *int     `json:"sample_start_percent"`
+	SampleEndPercent   *int     `json:"sample_end_percent"`
+}
+
+// SidecarPath returns the path of the sidecar next to a movie, "name.thumbnailer.json"
+func SidecarPath(moviePath string) string {
+	return strings.TrimSuffix(moviePath, filepath.Ext(moviePath)) + SidecarSuffix
+}
+
+// ReadOverrides parses and validates the sidecar at path
+func ReadOverrides(path string) (*Overrides, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var o Overrides
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&o); err != nil {
+		return nil, fmt.Errorf("invalid sidecar %s: %w", path, err)
+	}
+	if err := o.validate(); err != nil {
+		return nil, fmt.Errorf("invalid sidecar %s: %w", path, err)
+	}
+	return &o, nil
+}
+
+// validate applies the same bounds as the matching environment variables
+func (o *Overrides) validate() error {
+	if o.GridCols < 0 || o.GridRows < 0 {
+		return fmt.Errorf("grid_cols and grid_rows must be positive, got %dx%d", o.GridCols, o.GridRows)
+	}
+	if o.IntroSkip != nil && (*o.IntroSkip < 0 || *o.IntroSkip >= 50) {
+		return fmt.Errorf("intro_skip must be at least 0 and below 50, got %g", *o.IntroSkip)
+	}
+	start, end := 0, 100
+	if o.SampleStartPercent != nil {
+		start = *o.SampleStartPercent
+	}
+	if o.SampleEndPercent != nil {
+		end = *o.SampleEndPercent
+	}
+	if start < 0 || end > 100 || start >= end {
+		return fmt.Errorf("sample_start_percent (%d) and sample_end_percent (%d) must be between 0 and 100, start first", start, end)
+	}
+	return nil
+}
+
+// WithOverrides returns a Thumbnailer sharing t's logger, metrics and storage whose
+// configuration has the sidecar's settings applied. nil overrides return t itself.
+func (t *Thumbnailer) WithOverrides(o *Overrides) *Thumbnailer {
+	if o == nil {
+		return t
+	}
+
+	cfg := *t.cfg
+	if o.GridCols > 0 {
+		cfg.GridCols = o.GridCols
+	}
+	if o.GridRows > 0 {
+		cfg.GridRows = o.GridRows
+	}
+	if o.IntroSkip != nil {
+		cfg.IntroSkipPercent = *o.IntroSkip
+		cfg.IntroSkipSeconds = 0 // The sidecar's percentage replaces a fixed skip too
+	}
+	if o.SampleStartPercent != nil {
+		cfg.SampleStartPercent = *o.SampleStartPercent
+	}
+	if o.SampleEndPercent != nil {
+		cfg.SampleEndPercent = *o.SampleEndPercent
+	}
+
+	overridden := *t
+	overridden.cfg = &cfg
+	return &overridden
+}
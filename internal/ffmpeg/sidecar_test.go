@@ -0,0 +1,63 @@
+package ffmpeg
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/config"
+)
+
+func TestSidecarOverrides(t *testing.T) {
+	dir := t.TempDir()
+	moviePath := filepath.Join(dir, "odd.movie.mkv")
+	sidecar := SidecarPath(moviePath)
+	if sidecar != filepath.Join(dir, "odd.movie.thumbnailer.json") {
+		t.Fatalf("SidecarPath = %q", sidecar)
+	}
+	if err := os.WriteFile(sidecar, []byte(`{"grid_cols": 2, "grid_rows": 3, "sample_start_percent": 10, "sample_end_percent": 60}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	overrides, err := ReadOverrides(sidecar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{GridCols: 8, GridRows: 4, SampleEndPercent: 100, IntroSkipPercent: 2}
+	th := &Thumbnailer{cfg: cfg}
+	overridden := th.WithOverrides(overrides)
+
+	layout, _ := overridden.gridLayout()
+	args := overridden.gridArgs(moviePath, "out.jpg", 0, 5, "", 1000, layout, false)
+	if filter := args[slices.Index(args, "-vf")+1]; !strings.Contains(filter, "tile=2x3:") {
+		t.Errorf("grid filter = %q, want a 2x3 tile", filter)
+	}
+	if got := strings.Join(args[slices.Index(args, "-ss"):slices.Index(args, "-t")+2], " "); got != "-ss 100.00 -t 500.00" {
+		t.Errorf("sampling window args = %q, want -ss 100.00 -t 500.00", got)
+	}
+
+	// The global configuration is untouched
+	if layout, _ := th.gridLayout(); layout.Cols != 8 || layout.Rows != 4 || cfg.SampleStartPercent != 0 {
+		t.Errorf("global layout changed to %dx%d", layout.Cols, layout.Rows)
+	}
+	if th.WithOverrides(nil) != th {
+		t.Error("nil overrides should return the thumbnailer itself")
+	}
+
+	for _, body := range []string{
+		`{"grid_columns": 2}`,
+		`{"grid_cols": -1}`,
+		`{"intro_skip": 75}`,
+		`{"sample_start_percent": 80, "sample_end_percent": 20}`,
+		`not json`,
+	} {
+		if err := os.WriteFile(sidecar, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadOverrides(sidecar); err == nil {
+			t.Errorf("%s: ReadOverrides succeeded, want an error", body)
+		}
+	}
+}
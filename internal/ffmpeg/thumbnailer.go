@@ -1,10 +1,13 @@
 package ffmpeg
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -17,35 +20,44 @@ import (
 	"github.com/pandino/movie-thumbnailer-go/internal/database"
 	"github.com/pandino/movie-thumbnailer-go/internal/metrics"
 	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
 	"github.com/sirupsen/logrus"
 )
 
+// ErrProbe is returned when ffprobe cannot read a movie, as happens with truncated files
+var ErrProbe = errors.New("ffprobe error")
+
 // Thumbnailer creates thumbnail grids from movie files using ffmpeg
 type Thumbnailer struct {
 	cfg     *config.Config
 	log     *logrus.Logger
 	metrics *metrics.Metrics
+	storage storage.Storage
 }
 
-// New creates a new Thumbnailer
-func New(cfg *config.Config, log *logrus.Logger, metrics *metrics.Metrics) *Thumbnailer {
+// New creates a new Thumbnailer that saves grids to store
+func New(cfg *config.Config, log *logrus.Logger, metrics *metrics.Metrics, store storage.Storage) *Thumbnailer {
 	return &Thumbnailer{
 		cfg:     cfg,
 		log:     log,
 		metrics: metrics,
+		storage: store,
 	}
 }
 
-// CreateThumbnail generates a thumbnail grid for a movie file
-func (t *Thumbnailer) CreateThumbnail(ctx context.Context, moviePath string, db *database.DB) (*models.Thumbnail, error) {
+// ProgressFunc receives the completion percentage (0-100) of a grid generation
+type ProgressFunc func(percent float64)
+
+// CreateThumbnail generates a thumbnail grid for a movie file.
+// If onProgress is non-nil, ffmpeg progress is streamed and reported through it.
+func (t *Thumbnailer) CreateThumbnail(ctx context.Context, moviePath string, db *database.DB, onProgress ProgressFunc) (*models.Thumbnail, error) {
 	// Generate thumbnail filename
 	movieFilename := filepath.Base(moviePath)
-	thumbnailFilename := strings.TrimSuffix(movieFilename, filepath.Ext(movieFilename)) + ".jpg"
-	thumbnailPath := filepath.Join(t.cfg.ThumbnailsDir, thumbnailFilename)
+	thumbnailFilename := t.ThumbnailRelPath(moviePath)
 
 	// Initialize thumbnail record
 	thumbnail := &models.Thumbnail{
-		MoviePath:     movieFilename,
+		MoviePath:     t.MovieKey(moviePath),
 		MovieFilename: movieFilename,
 		ThumbnailPath: thumbnailFilename,
 		Status:        "pending",
@@ -61,6 +73,13 @@ func (t *Thumbnailer) CreateThumbnail(ctx context.Context, moviePath string, db
 		}
 	}
 
+	// Audio files and images get a cover-art, waveform or still thumbnail instead of a grid
+	if t.cfg.HandleNonVideo {
+		if handled, result, err := t.createNonVideoThumbnail(ctx, moviePath, thumbnail, db); handled {
+			return result, err
+		}
+	}
+
 	// Get video metadata
 	metadata, err := t.GetVideoMetadata(ctx, moviePath)
 	if err != nil {
@@ -82,74 +101,221 @@ func (t *Thumbnailer) CreateThumbnail(ctx context.Context, moviePath string, db
 	thumbnail.Duration = metadata.Duration
 	thumbnail.Width = metadata.Width
 	thumbnail.Height = metadata.Height
+	thumbnail.RecordedAt = metadata.RecordedAt
+	if metadata.VideoStream > 0 {
+		t.log.WithFields(logrus.Fields{
+			"movie":  moviePath,
+			"stream": metadata.VideoStream,
+		}).Info("Using the largest video stream instead of the first")
+	}
+
+	// Bound the grid to MAX_GRID_PIXELS
+	layout, adjusted := t.gridLayout()
+	if adjusted {
+		configured := t.configuredLayout()
+		t.log.WithFields(logrus.Fields{
+			"movie":  moviePath,
+			"grid":   fmt.Sprintf("%dx%d", configured.Cols, configured.Rows),
+			"tile":   fmt.Sprintf("%dx%d", configured.TileWidth, configured.TileHeight),
+			"limit":  t.cfg.MaxGridPixels,
+			"result": fmt.Sprintf("%dx%d tiles of %dx%d (%dx%d px)", layout.Cols, layout.Rows, layout.TileWidth, layout.TileHeight, layout.Width(), layout.Height()),
+		}).Info("Reduced thumbnail grid to fit MAX_GRID_PIXELS")
+	}
 
 	// Calculate keyframe interval for better thumbnail distribution
-	interval, err := t.calculateKeyframeInterval(ctx, moviePath, metadata.Duration)
+	interval, keyframes, err := t.calculateKeyframeInterval(ctx, moviePath, metadata.VideoStream, metadata.Duration, layout.Cells())
 	if err != nil {
 		t.log.WithError(err).WithField("movie", moviePath).Warn("Failed to calculate keyframe interval, using default")
 		interval = 10 // Default interval if calculation fails
 	}
 
-	// Generate thumbnail grid
-	err = t.generateThumbnailGrid(ctx, moviePath, thumbnailPath, interval)
-	if err != nil {
-		t.log.WithError(err).WithField("movie", moviePath).Error("Failed to generate thumbnail grid")
-		thumbnail.Status = "error"
-		thumbnail.ErrorMessage = fmt.Sprintf("Failed to generate thumbnail: %v", err)
+	// Short clips get a smaller grid instead of blank tiles
+	if fitted, adjusted := fitFrames(layout, keyframes); adjusted {
+		t.log.WithFields(logrus.Fields{
+			"movie":     moviePath,
+			"keyframes": keyframes,
+			"grid":      fmt.Sprintf("%dx%d", layout.Cols, layout.Rows),
+			"result":    fmt.Sprintf("%dx%d", fitted.Cols, fitted.Rows),
+		}).Info("Reduced thumbnail grid to the available keyframes")
+		layout = fitted
+	}
+	thumbnail.GridWidth = layout.Width()
+	thumbnail.GridHeight = layout.Height()
+	thumbnail.GridCols = layout.Cols
+	thumbnail.GridRows = layout.Rows
+
+	// With DEDUP_FRAMES, spread the grid over the frames left after dropping near-duplicates
+	dedup := t.dedupFilter()
+	if dedup != "" {
+		frames, err := t.countDistinctFrames(ctx, moviePath, metadata.VideoStream, metadata.Duration, dedup)
+		switch {
+		case err != nil:
+			t.log.WithError(err).WithField("movie", moviePath).Warn("Failed to count distinct frames, using the keyframe interval")
+			dedup = ""
+		case frames < layout.Cells():
+			t.log.WithFields(logrus.Fields{
+				"movie":  moviePath,
+				"frames": frames,
+				"tiles":  layout.Cells(),
+			}).Debug("Too few distinct frames for the grid, using the keyframe interval")
+			dedup = ""
+		default:
+			interval = frames / layout.Cells()
+		}
+	}
 
-		// Save the error status
-		if db != nil {
-			if err := db.UpsertThumbnail(thumbnail); err != nil {
-				t.log.WithError(err).WithField("movie", moviePath).Error("Failed to save error status")
-			}
+	// Render into a local work file; ffmpeg picks the encoder from its extension
+	workFile, err := os.CreateTemp("", "thumbnail-*"+filepath.Ext(thumbnailFilename))
+	if err != nil {
+		err = fmt.Errorf("failed to create work file: %w", err)
+		if errors.Is(err, fs.ErrPermission) {
+			return thumbnail, err // Not the movie's fault; the scanner aborts
 		}
+		return t.saveError(thumbnail, db, moviePath, fmt.Sprintf("Failed to generate thumbnail: %v", err), err)
+	}
+	workPath := workFile.Name()
+	workFile.Close()
+	defer os.Remove(workPath)
 
-		return thumbnail, err
+	// Generate thumbnail grid
+	err = t.generateThumbnailGrid(ctx, moviePath, workPath, metadata.VideoStream, interval, dedup, metadata.Duration, layout, onProgress)
+	if err != nil {
+		t.log.WithError(err).WithField("movie", moviePath).Error("Failed to generate thumbnail grid")
+		return t.saveError(thumbnail, db, moviePath, fmt.Sprintf("Failed to generate thumbnail: %v", err), err)
 	}
 
 	// Verify thumbnail was created
-	if _, err := os.Stat(thumbnailPath); os.IsNotExist(err) {
-		thumbnail.Status = "error"
-		thumbnail.ErrorMessage = "Thumbnail file was not created"
+	if info, err := os.Stat(workPath); err != nil || info.Size() == 0 {
+		return t.saveError(thumbnail, db, moviePath, "Thumbnail file was not created",
+			fmt.Errorf("thumbnail file was not created: %s", thumbnailFilename))
+	}
 
-		// Save the error status
-		if db != nil {
-			if err := db.UpsertThumbnail(thumbnail); err != nil {
-				t.log.WithError(err).WithField("movie", moviePath).Error("Failed to save error status")
-			}
+	// Optionally rewrite the grid as a progressive JPEG
+	if t.cfg.ProgressiveJPEG && isJPEGPath(workPath) {
+		if err := makeProgressive(ctx, workPath); err != nil {
+			t.log.WithError(err).WithField("thumbnail", thumbnailFilename).Warn("Failed to make thumbnail progressive, keeping baseline JPEG")
 		}
+	}
 
-		return thumbnail, fmt.Errorf("thumbnail file was not created: %s", thumbnailPath)
+	// Save the grid to thumbnail storage
+	if err := t.store(ctx, thumbnailFilename, workPath); err != nil {
+		t.log.WithError(err).WithField("thumbnail", thumbnailFilename).Error("Failed to store thumbnail")
+		if errors.Is(err, fs.ErrPermission) {
+			return thumbnail, err // Not the movie's fault; the scanner aborts
+		}
+		return t.saveError(thumbnail, db, moviePath, fmt.Sprintf("Failed to store thumbnail: %v", err), err)
 	}
 
 	// Update status to success
 	thumbnail.Status = "success"
+	t.markGenerated(thumbnail)
 
 	// Save the final success status
 	if db != nil {
 		if err := db.UpsertThumbnail(thumbnail); err != nil {
 			t.log.WithError(err).WithField("movie", moviePath).Error("Failed to save success status")
+		} else if err := db.SetGridSize(thumbnail.MoviePath, layout.Cols, layout.Rows); err != nil {
+			t.log.WithError(err).WithField("movie", moviePath).Warn("Failed to save grid size")
+		} else if err := db.SetVideoStream(thumbnail.MoviePath, metadata.VideoStream); err != nil {
+			t.log.WithError(err).WithField("movie", moviePath).Warn("Failed to save video stream")
 		}
 	}
 
 	return thumbnail, nil
 }
 
+// saveError records a failed generation on the thumbnail and in the database
+func (t *Thumbnailer) saveError(thumbnail *models.Thumbnail, db *database.DB, moviePath, message string, err error) (*models.Thumbnail, error) {
+	thumbnail.Status = "error"
+	thumbnail.ErrorMessage = message
+
+	// Save the error status
+	if db != nil {
+		if err := db.UpsertThumbnail(thumbnail); err != nil {
+			t.log.WithError(err).WithField("movie", moviePath).Error("Failed to save error status")
+		}
+	}
+
+	return thumbnail, err
+}
+
+// store uploads a rendered grid to thumbnail storage under key
+func (t *Thumbnailer) store(ctx context.Context, key, workPath string) error {
+	f, err := os.Open(workPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return t.storage.Put(ctx, key, f)
+}
+
+// MovieKey returns the movie_path a movie file is recorded under: its name or, with
+// RECURSIVE_SCAN, its slash-separated path relative to its movies root, so movies with
+// the same name in different subdirectories get their own rows
+func (t *Thumbnailer) MovieKey(moviePath string) string {
+	if !t.cfg.RecursiveScan {
+		return filepath.Base(moviePath)
+	}
+
+	for _, root := range t.cfg.MoviesDirs {
+		rel, err := filepath.Rel(root, moviePath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return filepath.ToSlash(rel)
+	}
+
+	return filepath.Base(moviePath)
+}
+
+// ThumbnailRelPath returns the thumbnail path, relative to ThumbnailsDir, for a movie file.
+// In the default flat layout this is just the movie name with a .jpg extension; with
+// MIRROR_STRUCTURE the movie's directory relative to its movies root is preserved.
+func (t *Thumbnailer) ThumbnailRelPath(moviePath string) string {
+	movieFilename := filepath.Base(moviePath)
+	thumbnailFilename := strings.TrimSuffix(movieFilename, filepath.Ext(movieFilename)) + ".jpg"
+
+	if !t.cfg.MirrorStructure {
+		return thumbnailFilename
+	}
+
+	for _, root := range t.cfg.MoviesDirs {
+		rel, err := filepath.Rel(root, filepath.Dir(moviePath))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return filepath.Join(rel, thumbnailFilename)
+	}
+
+	return thumbnailFilename
+}
+
 // VideoMetadata stores information about a video file
 type VideoMetadata struct {
-	Duration float64
-	Width    int
-	Height   int
+	Duration   float64
+	Width      int
+	Height     int
+	RecordedAt *time.Time // From the container's creation_time tag, nil when absent
+
+	// Position of the main video stream among the file's video streams, for "-map 0:v:N"
+	VideoStream int
 }
 
 // FFProbeResponse represents the JSON structure returned by ffprobe
 type FFProbeResponse struct {
 	Streams []struct {
-		Width  int `json:"width"`
-		Height int `json:"height"`
+		Width       int    `json:"width"`
+		Height      int    `json:"height"`
+		Duration    string `json:"duration"`
+		Disposition struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
 	} `json:"streams"`
 	Format struct {
 		Duration string `json:"duration"`
+		Tags     struct {
+			CreationTime string `json:"creation_time"`
+		} `json:"tags"`
 	} `json:"format"`
 }
 
@@ -165,7 +331,7 @@ func (t *Thumbnailer) GetVideoMetadata(ctx context.Context, moviePath string) (*
 		"-print_format", "json",
 		"-show_format",
 		"-show_streams",
-		"-select_streams", "v:0",
+		"-select_streams", "v",
 		moviePath,
 	)
 
@@ -180,7 +346,7 @@ func (t *Thumbnailer) GetVideoMetadata(ctx context.Context, moviePath string) (*
 		if t.metrics != nil {
 			t.metrics.RecordFFmpegExecution("error", execDuration)
 		}
-		return nil, fmt.Errorf("ffprobe error: %v - %s", err, stderr.String())
+		return nil, fmt.Errorf("%w: %v - %s", ErrProbe, err, stderr.String())
 	}
 
 	if t.metrics != nil {
@@ -191,6 +357,11 @@ func (t *Thumbnailer) GetVideoMetadata(ctx context.Context, moviePath string) (*
 	output := stdout.String()
 	t.log.WithField("ffprobe_output", output).Debug("FFprobe raw output")
 
+	return parseVideoMetadata(output)
+}
+
+// parseVideoMetadata extracts the metadata from ffprobe's JSON output
+func parseVideoMetadata(output string) (*VideoMetadata, error) {
 	var ffprobeData FFProbeResponse
 	if err := json.Unmarshal([]byte(output), &ffprobeData); err != nil {
 		return nil, fmt.Errorf("failed to parse ffprobe JSON output: %v", err)
@@ -201,9 +372,10 @@ func (t *Thumbnailer) GetVideoMetadata(ctx context.Context, moviePath string) (*
 		return nil, fmt.Errorf("no video streams found in file")
 	}
 
-	// Extract width and height from the first video stream
-	width := ffprobeData.Streams[0].Width
-	height := ffprobeData.Streams[0].Height
+	// Extract width and height from the main video stream
+	main := ffprobeData.mainVideoStream()
+	width := ffprobeData.Streams[main].Width
+	height := ffprobeData.Streams[main].Height
 
 	// Extract duration from format data
 	duration, err := strconv.ParseFloat(ffprobeData.Format.Duration, 64)
@@ -217,23 +389,64 @@ func (t *Thumbnailer) GetVideoMetadata(ctx context.Context, moviePath string) (*
 	}
 
 	return &VideoMetadata{
-		Duration: duration,
-		Width:    width,
-		Height:   height,
+		Duration:    duration,
+		Width:       width,
+		Height:      height,
+		RecordedAt:  parseCreationTime(ffprobeData.Format.Tags.CreationTime),
+		VideoStream: main,
 	}, nil
 }
 
-// calculateKeyframeInterval estimates an appropriate interval for thumbnail extraction
-func (t *Thumbnailer) calculateKeyframeInterval(ctx context.Context, moviePath string, duration float64) (int, error) {
-	// Skip first 30 seconds to avoid intros
-	skipSeconds := 30.0
-	if duration <= skipSeconds {
-		skipSeconds = 0 // Don't skip for very short videos
+// mainVideoStream returns the position of the movie's main video stream: the largest
+// one, then the longest, skipping cover art unless there is nothing else. Files with
+// alternate angles or attached pictures would otherwise be tiled from whichever stream
+// comes first.
+func (p *FFProbeResponse) mainVideoStream() int {
+	main := -1
+	var mainArea int
+	var mainDuration float64
+	for pass := 0; pass < 2 && main < 0; pass++ {
+		for i, s := range p.Streams {
+			if pass == 0 && s.Disposition.AttachedPic == 1 {
+				continue
+			}
+			area := s.Width * s.Height
+			duration, _ := strconv.ParseFloat(s.Duration, 64)
+			if main < 0 || area > mainArea || (area == mainArea && duration > mainDuration) {
+				main, mainArea, mainDuration = i, area, duration
+			}
+		}
 	}
+	if main < 0 {
+		return 0
+	}
+	return main
+}
 
-	adjustedDuration := duration - skipSeconds
+// parseCreationTime parses a creation_time tag, returning nil when it is missing,
+// malformed or the zero date some cameras write
+func parseCreationTime(tag string) *time.Time {
+	if tag == "" {
+		return nil
+	}
+	recorded, err := time.Parse(time.RFC3339Nano, tag)
+	if err != nil {
+		// Some muxers leave out the time zone
+		recorded, err = time.Parse("2006-01-02 15:04:05", tag)
+	}
+	if err != nil || recorded.Year() <= 1970 {
+		return nil
+	}
+	return &recorded
+}
+
+// calculateKeyframeInterval estimates an appropriate interval for thumbnail extraction,
+// and how many keyframes the sampling window holds (0 when unknown)
+func (t *Thumbnailer) calculateKeyframeInterval(ctx context.Context, moviePath string, stream int, duration float64, totalCells int) (int, int, error) {
+	// Restrict sampling to the configured window
+	skipSeconds, adjustedDuration := t.sampleWindow(duration)
 	if adjustedDuration <= 0 {
-		return 10, nil // Default for very short videos
+		return 10, 0, nil // Default for very short videos
 	}
 
 	// Sample a portion of the video to count keyframes
@@ -250,7 +463,7 @@ func (t *Thumbnailer) calculateKeyframeInterval(ctx context.Context, moviePath s
 		ctx,
 		"ffprobe",
 		"-v", "error",
-		"-select_streams", "v:0",
+		"-select_streams", "v:"+strconv.Itoa(stream),
 		"-skip_frame", "nokey",
 		"-show_entries", "frame=pict_type",
 		"-of", "csv=p=0",
@@ -263,7 +476,7 @@ func (t *Thumbnailer) calculateKeyframeInterval(ctx context.Context, moviePath s
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return 10, fmt.Errorf("ffprobe error: %v - %s", err, stderr.String())
+		return 10, 0, fmt.Errorf("ffprobe error: %v - %s", err, stderr.String())
 	}
 
 	// Count keyframes in output
@@ -271,46 +484,133 @@ func (t *Thumbnailer) calculateKeyframeInterval(ctx context.Context, moviePath s
 	keyframeCount := strings.Count(output, "I") // I frames are keyframes
 
 	if keyframeCount == 0 {
-		return 10, nil // Default if no keyframes found
+		return 10, 0, nil // Default if no keyframes found
 	}
 
+	interval, totalKeyframes := keyframeInterval(keyframeCount, sampleDuration, adjustedDuration, totalCells)
+	return interval, totalKeyframes, nil
+}
+
+// keyframeInterval extrapolates the keyframes counted in a sample of sampleDuration
+// seconds to the whole window, and returns the interval spreading them over the grid
+// along with the estimated total
+func keyframeInterval(sampleKeyframes int, sampleDuration, windowDuration float64, totalCells int) (interval, totalKeyframes int) {
 	// Estimate total keyframes in the adjusted duration
-	totalKeyframes := int((float64(keyframeCount) * adjustedDuration) / sampleDuration)
+	totalKeyframes = int((float64(sampleKeyframes) * windowDuration) / sampleDuration)
 
 	// Calculate interval to distribute frames across the grid
-	totalCells := t.cfg.GridCols * t.cfg.GridRows
-	interval := (totalKeyframes * 8 / 10) / totalCells // Use 80% of keyframes
+	interval = (totalKeyframes * 8 / 10) / totalCells // Use 80% of keyframes
 	if interval < 1 {
 		interval = 1
 	}
 
-	return interval, nil
+	return interval, totalKeyframes
+}
+
+// sampleWindow returns the start offset and length, in seconds, of the part of the movie
+// to sample. With the default 0-100% window the intro is skipped, see introSkip.
+func (t *Thumbnailer) sampleWindow(duration float64) (start, length float64) {
+	startPct, endPct := t.cfg.SampleStartPercent, t.cfg.SampleEndPercent
+	if startPct <= 0 && (endPct >= 100 || endPct <= startPct) {
+		start = t.introSkip(duration)
+		return start, duration - start
+	}
+
+	start = duration * float64(startPct) / 100
+	end := duration * float64(endPct) / 100
+	return start, end - start
 }
 
+// inputArgs returns the ffmpeg arguments opening moviePath restricted to the sampling
+// window. By default the window is seeked before the input, jumping straight to the
+// keyframe preceding it, and only keyframes are decoded. ACCURATE_SEEK seeks after
+// the input and decodes every frame instead, so the window starts at its exact
+// timestamp at the cost of speed.
+func (t *Thumbnailer) inputArgs(moviePath string, start, length float64) []string {
+	window := []string{"-ss", strconv.FormatFloat(start, 'f', 2, 64)}
+	if length > 0 {
+		window = append(window, "-t", strconv.FormatFloat(length, 'f', 2, 64))
+	}
+
+	if t.cfg.AccurateSeek {
+		return append([]string{"-i", moviePath}, window...)
+	}
+	return append(window, "-skip_frame", "nokey", "-i", moviePath)
+}
+
+// videoStreamMap returns the -map specifier of the stream-th video stream of the input
+func videoStreamMap(stream int) string {
+	return "0:v:" + strconv.Itoa(stream)
+}
+
+// introSkip returns the seconds skipped at the start of a movie: INTRO_SKIP_SECONDS
+// when set, or INTRO_SKIP_PERCENT of its duration, at most INTRO_SKIP_MAX, so short
+// clips keep nearly all their frames
+func (t *Thumbnailer) introSkip(duration float64) float64 {
+	if fixed := t.cfg.IntroSkipSeconds; fixed > 0 {
+		// A clip no longer than the skip is sampled whole
+		if duration <= fixed {
+			return 0
+		}
+		return fixed
+	}
+	if t.cfg.IntroSkipPercent <= 0 || duration <= 0 {
+		return 0
+	}
+	skip := duration * t.cfg.IntroSkipPercent / 100
+	if limit := t.cfg.IntroSkipMax.Seconds(); limit > 0 && skip > limit {
+		skip = limit
+	}
+	return skip
+}
+
+// gridFilter returns the ffmpeg filter graph tiling every interval-th keyframe, after
+// dropping near-duplicates with a non-empty dedup filter. With progress, the timestamp
+// of every frame entering the tile is printed to stdout, see runWithProgress.
+func gridFilter(interval int, dedup string, layout GridLayout, progress bool) string {
+	selectFrames := "select='eq(pict_type,I)',"
+	if dedup != "" {
+		selectFrames += dedup + ","
+	}
+	selectFrames += fmt.Sprintf("select='not(mod(n,%d))',", interval)
+	if progress {
+		selectFrames += progressFilter + ","
+	}
+	return fmt.Sprintf("%sscale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,tile=%dx%d:padding=%d:margin=%d",
+		selectFrames, layout.TileWidth, layout.TileHeight, layout.TileWidth, layout.TileHeight, layout.Cols, layout.Rows, gridPadding, gridMargin)
+}
+
+// progressFilter tags every frame and prints the tagged ones unbuffered to stdout as
+// "frame:N pts:P pts_time:T" lines. The metadata filter only prints frames carrying
+// metadata, and decoded frames usually carry none, hence the tag.
+const progressFilter = "metadata=mode=add:key=thumbnailer.progress:value=1," +
+	"metadata=mode=print:key=thumbnailer.progress:file=-:direct=1"
+
 // generateThumbnailGrid creates a grid of thumbnails from a movie file
-func (t *Thumbnailer) generateThumbnailGrid(ctx context.Context, moviePath, outputPath string, interval int) error {
+func (t *Thumbnailer) generateThumbnailGrid(ctx context.Context, moviePath, outputPath string, stream, interval int, dedup string, duration float64, layout GridLayout, onProgress ProgressFunc) error {
+	start, length := t.sampleWindow(duration)
+	args := t.gridArgs(moviePath, outputPath, stream, interval, dedup, duration, layout, onProgress != nil)
+
 	// Build ffmpeg command
-	cmd := exec.CommandContext(
-		ctx,
-		"ffmpeg",
-		"-v", "error",
-		"-threads", "2",
-		"-ss", "30", // Skip first 30 seconds
-		"-skip_frame", "nokey",
-		"-i", moviePath,
-		"-vf", fmt.Sprintf("select='eq(pict_type,I)',select='not(mod(n,%d))',scale=320:180:force_original_aspect_ratio=decrease,pad=320:180:(ow-iw)/2:(oh-ih)/2,tile=%dx%d:padding=4:margin=4",
-			interval, t.cfg.GridCols, t.cfg.GridRows),
-		"-frames:v", "1",
-		"-q:v", "3",
-		"-update", "1",
-		"-y",
-		outputPath,
-	)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
+	var err error
+	if onProgress != nil {
+		// Seeking before the input restarts timestamps at the window, ACCURATE_SEEK keeps
+		// those of the movie
+		var offset float64
+		if t.cfg.AccurateSeek {
+			offset = start
+		}
+		err = t.runWithProgress(cmd, offset, length, onProgress)
+	} else {
+		err = cmd.Run()
+	}
+
+	if err != nil {
 		// Extract error information from stderr
 		errorMsg := parseFFmpegError(stderr.String())
 		return fmt.Errorf("ffmpeg error: %v - %s", err, errorMsg)
@@ -319,6 +619,88 @@ func (t *Thumbnailer) generateThumbnailGrid(ctx context.Context, moviePath, outp
 	return nil
 }
 
+// gridArgs builds the ffmpeg arguments rendering the thumbnail grid of a movie
+func (t *Thumbnailer) gridArgs(moviePath, outputPath string, stream, interval int, dedup string, duration float64, layout GridLayout, progress bool) []string {
+	start, length := t.sampleWindow(duration)
+
+	args := []string{
+		"-v", "error",
+		"-threads", "2",
+	}
+	args = append(args, t.inputArgs(moviePath, start, length)...)
+	args = append(args,
+		"-map", videoStreamMap(stream),
+		"-vf", gridFilter(interval, dedup, layout, progress),
+		"-frames:v", "1",
+		"-q:v", strconv.Itoa(t.cfg.GridJPEGQuality()),
+		"-update", "1",
+	)
+	return append(args, "-y", outputPath)
+}
+
+// runWithProgress runs an ffmpeg grid command built with progress, streaming its stdout
+// and reporting how far into the sampled window the frames fed to the tile have got.
+// The output timestamps reported by -progress would not do: the tile emits its only
+// frame once the grid is full, so they stay at 0 until ffmpeg is done. offset is the
+// timestamp at which the window starts and sampleDuration its length.
+func (t *Thumbnailer) runWithProgress(cmd *exec.Cmd, offset, sampleDuration float64, onProgress ProgressFunc) error {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	onProgress(0)
+	lines := bufio.NewScanner(stdout)
+	for lines.Scan() {
+		seconds, ok := parseProgressLine(lines.Text())
+		if !ok || sampleDuration <= 0 {
+			continue
+		}
+		percent := (seconds - offset) / sampleDuration * 100
+		if percent < 0 {
+			percent = 0
+		}
+		if percent > 100 {
+			percent = 100
+		}
+		onProgress(percent)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return err
+	}
+
+	onProgress(100)
+	return nil
+}
+
+// parseProgressLine extracts the frame timestamp in seconds from a line printed by
+// progressFilter, such as "frame:3    pts:90090   pts_time:3.003". The key=value
+// lines listing the frame's metadata are ignored.
+func parseProgressLine(line string) (float64, bool) {
+	if !strings.HasPrefix(line, "frame:") {
+		return 0, false
+	}
+
+	for _, field := range strings.Fields(line) {
+		value, found := strings.CutPrefix(field, "pts_time:")
+		if !found {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+		return seconds, true
+	}
+
+	return 0, false
+}
+
 // maxFFmpegErrorMatches caps how many matched error lines get joined into the
 // returned message. Corrupt/truncated input can make ffmpeg emit one decode
 // error per frame (thousands of lines), which previously produced multi-hundred-KB
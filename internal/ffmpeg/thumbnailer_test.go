@@ -0,0 +1,340 @@
+package ffmpeg
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/config"
+)
+
+func TestParseProgressLine(t *testing.T) {
+	tests := []struct {
+		line   string
+		want   float64
+		wantOK bool
+	}{
+		{"frame:0    pts:0       pts_time:0", 0, true},
+		{"frame:12   pts:90090   pts_time:3.003", 3.003, true},
+		{"frame:3    pts:NOPTS   pts_time:NOPTS", 0, false},
+		{"thumbnailer.progress=1", 0, false},
+		{"out_time_us=1500000", 0, false},
+		{"garbage", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseProgressLine(tt.line)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("parseProgressLine(%q) = %v, %v; want %v, %v", tt.line, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestGenerateThumbnailGridReportsProgress(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+
+	// The fake ffmpeg prints what progressFilter would for three frames of the window
+	bin := t.TempDir()
+	script := `#!/bin/sh
+case "$*" in
+*metadata=mode=print*) ;;
+*) echo "progress filter missing" >&2; exit 1 ;;
+esac
+printf 'frame:0    pts:1       pts_time:%s\nthumbnailer.progress=1\n' "$FAKE_PTS_1"
+printf 'frame:1    pts:NOPTS   pts_time:NOPTS\nthumbnailer.progress=1\n'
+printf 'frame:2    pts:2       pts_time:%s\nthumbnailer.progress=1\n' "$FAKE_PTS_2"
+`
+	if err := os.WriteFile(filepath.Join(bin, "ffmpeg"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	tests := []struct {
+		name         string
+		cfg          *config.Config
+		pts1, pts2   string
+		wantReported []float64
+	}{
+		// Seeking before the input, timestamps start at the window
+		{"input seek", &config.Config{SampleEndPercent: 100}, "25", "60", []float64{0, 25, 60, 100}},
+		// ACCURATE_SEEK keeps the movie's timestamps, here a 20-60 s window
+		{"accurate seek", &config.Config{SampleStartPercent: 20, SampleEndPercent: 60, AccurateSeek: true}, "30", "50", []float64{0, 25, 75, 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FAKE_PTS_1", tt.pts1)
+			t.Setenv("FAKE_PTS_2", tt.pts2)
+			tt.cfg.GridCols, tt.cfg.GridRows = 2, 2
+			th := &Thumbnailer{cfg: tt.cfg}
+			layout, _ := th.gridLayout()
+
+			var reported []float64
+			err := th.generateThumbnailGrid(context.Background(), "movie.mp4", filepath.Join(t.TempDir(), "out.jpg"),
+				0, 1, "", 100, layout, func(percent float64) { reported = append(reported, percent) })
+			if err != nil {
+				t.Fatalf("generateThumbnailGrid failed: %v", err)
+			}
+			if !slices.Equal(reported, tt.wantReported) {
+				t.Errorf("reported progress = %v, want %v", reported, tt.wantReported)
+			}
+		})
+	}
+}
+
+func TestThumbnailRelPath(t *testing.T) {
+	roots := []string{"/movies", "/more"}
+
+	tests := []struct {
+		name      string
+		mirror    bool
+		moviePath string
+		want      string
+	}{
+		{"flat top level", false, "/movies/film.mp4", "film.jpg"},
+		{"flat nested", false, "/movies/a/b/film.mkv", "film.jpg"},
+		{"mirror top level", true, "/movies/film.mp4", "film.jpg"},
+		{"mirror nested", true, "/movies/a/b/film.mkv", filepath.Join("a", "b", "film.jpg")},
+		{"mirror second root", true, "/more/c/film.mp4", filepath.Join("c", "film.jpg")},
+		{"mirror outside roots", true, "/elsewhere/film.mp4", "film.jpg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := New(&config.Config{MoviesDirs: roots, MirrorStructure: tt.mirror}, nil, nil, nil)
+			if got := th.ThumbnailRelPath(tt.moviePath); got != tt.want {
+				t.Errorf("ThumbnailRelPath(%q) = %q, want %q", tt.moviePath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSampleWindow(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end int
+		duration   float64
+		wantStart  float64
+		wantLength float64
+	}{
+		{"default skips intro", 0, 100, 600, 12, 588},
+		{"unset window", 0, 0, 600, 12, 588},
+		{"middle sixty percent", 20, 80, 1000, 200, 600},
+		{"only end bounded", 0, 50, 600, 0, 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := New(&config.Config{SampleStartPercent: tt.start, SampleEndPercent: tt.end, IntroSkipPercent: 2, IntroSkipMax: 2 * time.Minute}, nil, nil, nil)
+			start, length := th.sampleWindow(tt.duration)
+			if start != tt.wantStart || length != tt.wantLength {
+				t.Errorf("sampleWindow(%v) = (%v, %v), want (%v, %v)",
+					tt.duration, start, length, tt.wantStart, tt.wantLength)
+			}
+		})
+	}
+}
+
+func TestInputArgsSeekOrder(t *testing.T) {
+	tests := []struct {
+		accurate bool
+		want     string
+	}{
+		{false, "-ss 30.00 -t 60.00 -skip_frame nokey -i movie.mp4"},
+		{true, "-i movie.mp4 -ss 30.00 -t 60.00"},
+	}
+
+	for _, tt := range tests {
+		th := &Thumbnailer{cfg: &config.Config{AccurateSeek: tt.accurate}}
+		if got := strings.Join(th.inputArgs("movie.mp4", 30, 60), " "); got != tt.want {
+			t.Errorf("AccurateSeek=%v: args = %q, want %q", tt.accurate, got, tt.want)
+		}
+	}
+
+	// Without a window length, ffmpeg reads to the end of the movie
+	th := &Thumbnailer{cfg: &config.Config{AccurateSeek: true}}
+	if got := strings.Join(th.inputArgs("movie.mp4", 0, 0), " "); got != "-i movie.mp4 -ss 0.00" {
+		t.Errorf("args without length = %q", got)
+	}
+}
+
+func TestIntroSkip(t *testing.T) {
+	tests := []struct {
+		name     string
+		percent  float64
+		max      time.Duration
+		duration float64
+		want     float64
+	}{
+		{"short clip", 2, 2 * time.Minute, 20, 0.4},
+		{"music video", 2, 2 * time.Minute, 180, 3.6},
+		{"episode", 2, 2 * time.Minute, 2700, 54},
+		{"movie is capped", 2, 2 * time.Minute, 7200, 120},
+		{"no cap", 2, 0, 7200, 144},
+		{"disabled", 0, 2 * time.Minute, 7200, 0},
+		{"unknown duration", 2, 2 * time.Minute, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := New(&config.Config{SampleEndPercent: 100, IntroSkipPercent: tt.percent, IntroSkipMax: tt.max}, nil, nil, nil)
+			if got := th.introSkip(tt.duration); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("introSkip(%v) = %v, want %v", tt.duration, got, tt.want)
+			}
+			start, length := th.sampleWindow(tt.duration)
+			if math.Abs(start-tt.want) > 1e-9 || math.Abs(start+length-tt.duration) > 1e-9 {
+				t.Errorf("sampleWindow(%v) = (%v, %v), want to start at %v and run to the end", tt.duration, start, length, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntroSkipSeconds(t *testing.T) {
+	th := New(&config.Config{SampleEndPercent: 100, IntroSkipPercent: 2, IntroSkipMax: 2 * time.Minute, IntroSkipSeconds: 30}, nil, nil, nil)
+	for _, tt := range []struct {
+		duration, want float64
+	}{
+		{7200, 30}, // Replaces the percentage and its cap
+		{180, 30},
+		{30, 0}, // Clips no longer than the skip are sampled whole
+		{12, 0},
+		{0, 0},
+	} {
+		if got := th.introSkip(tt.duration); got != tt.want {
+			t.Errorf("introSkip(%v) = %v, want %v", tt.duration, got, tt.want)
+		}
+	}
+}
+
+func TestFitGrid(t *testing.T) {
+	base := GridLayout{Cols: 8, Rows: 4, TileWidth: 320, TileHeight: 180}
+
+	tests := []struct {
+		name      string
+		layout    GridLayout
+		maxPixels int
+		adjusted  bool
+	}{
+		{"within limit", base, 16777216, false},
+		{"disabled", GridLayout{Cols: 40, Rows: 40, TileWidth: 320, TileHeight: 180}, 0, false},
+		{"scale tiles", base, 1000000, true},
+		{"reduce grid", GridLayout{Cols: 100, Rows: 100, TileWidth: 320, TileHeight: 180}, 500000, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, adjusted := fitGrid(tt.layout, tt.maxPixels)
+			if adjusted != tt.adjusted {
+				t.Fatalf("adjusted = %v, want %v", adjusted, tt.adjusted)
+			}
+			if !adjusted {
+				if got != tt.layout {
+					t.Errorf("layout changed to %+v without adjustment", got)
+				}
+				return
+			}
+			if got.Pixels() > tt.maxPixels {
+				t.Errorf("grid %dx%d (%d px) exceeds limit %d", got.Width(), got.Height(), got.Pixels(), tt.maxPixels)
+			}
+			if got.TileWidth%2 != 0 || got.TileHeight%2 != 0 {
+				t.Errorf("tile size %dx%d is not even", got.TileWidth, got.TileHeight)
+			}
+			if got.TileWidth < minTileWidth {
+				t.Errorf("tile width %d below minimum %d", got.TileWidth, minTileWidth)
+			}
+		})
+	}
+
+	// Scaling tiles alone should keep the full grid
+	got, _ := fitGrid(base, 1000000)
+	if got.Cols != base.Cols || got.Rows != base.Rows {
+		t.Errorf("expected %dx%d grid, got %dx%d", base.Cols, base.Rows, got.Cols, got.Rows)
+	}
+}
+
+func TestFitFrames(t *testing.T) {
+	base := GridLayout{Cols: 8, Rows: 4, TileWidth: 320, TileHeight: 180}
+
+	tests := []struct {
+		name     string
+		frames   int
+		cols     int
+		rows     int
+		adjusted bool
+	}{
+		{"enough frames", 100, 8, 4, false},
+		{"exactly enough", 32, 8, 4, false},
+		{"unknown", 0, 8, 4, false},
+		{"a few frames short", 30, 7, 4, true},
+		{"tiny clip", 5, 2, 2, true},
+		{"single frame", 1, 1, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, adjusted := fitFrames(base, tt.frames)
+			if adjusted != tt.adjusted || got.Cols != tt.cols || got.Rows != tt.rows {
+				t.Errorf("fitFrames(%d) = %dx%d, %v; want %dx%d, %v", tt.frames, got.Cols, got.Rows, adjusted, tt.cols, tt.rows, tt.adjusted)
+			}
+			if got.TileWidth != base.TileWidth || got.TileHeight != base.TileHeight {
+				t.Errorf("tile size changed to %dx%d", got.TileWidth, got.TileHeight)
+			}
+		})
+	}
+}
+
+func TestKeyframeIntervalLowFrameClip(t *testing.T) {
+	// A 6 second clip with a keyframe every second, sampled in full
+	interval, total := keyframeInterval(6, 6, 6, 32)
+	if interval != 1 || total != 6 {
+		t.Fatalf("keyframeInterval = %d, %d; want 1, 6", interval, total)
+	}
+	layout, _ := fitFrames(GridLayout{Cols: 8, Rows: 4}, total)
+	if layout.Cells() > total || layout.Cols != 3 || layout.Rows != 2 {
+		t.Errorf("grid for %d keyframes = %dx%d, want 3x2", total, layout.Cols, layout.Rows)
+	}
+
+	// A long movie extrapolates its sample and keeps the full grid
+	interval, total = keyframeInterval(90, 180, 5400, 32)
+	if total != 2700 || interval != 67 {
+		t.Errorf("keyframeInterval = %d, %d; want 67, 2700", interval, total)
+	}
+}
+
+func TestGeneratorVersion(t *testing.T) {
+	cfg := &config.Config{GridCols: 4, GridRows: 4, SampleEndPercent: 100}
+	th := &Thumbnailer{cfg: cfg}
+
+	version := th.GeneratorVersion()
+	if !strings.HasPrefix(version, AppVersion+"+") {
+		t.Errorf("GeneratorVersion = %q, want the %q prefix", version, AppVersion+"+")
+	}
+	if again := (&Thumbnailer{cfg: &config.Config{GridCols: 4, GridRows: 4, SampleEndPercent: 100}}).GeneratorVersion(); again != version {
+		t.Errorf("GeneratorVersion for equal settings = %q, want %q", again, version)
+	}
+
+	if changed := th.WithOverrides(&Overrides{GridCols: 6}).GeneratorVersion(); changed == version {
+		t.Errorf("GeneratorVersion with a sidecar's grid = %q, want it to differ from %q", changed, version)
+	}
+}
+
+func TestGridFilterTileSize(t *testing.T) {
+	th := &Thumbnailer{cfg: &config.Config{GridCols: 2, GridRows: 3, ThumbCellWidth: 640, ThumbCellHeight: 360}}
+	layout, _ := th.gridLayout()
+	if layout.Width() != 2*640+4+8 || layout.Height() != 3*360+2*4+8 {
+		t.Errorf("grid = %dx%d px, want it built from 640x360 tiles", layout.Width(), layout.Height())
+	}
+
+	want := "scale=640:360:force_original_aspect_ratio=decrease,pad=640:360:(ow-iw)/2:(oh-ih)/2,tile=2x3"
+	if got := gridFilter(5, "", layout, false); !strings.Contains(got, want) {
+		t.Errorf("filter = %q, want it to contain %q", got, want)
+	}
+}
@@ -0,0 +1,37 @@
+package ffmpeg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+// AppVersion is the application version recorded with every generated thumbnail; main
+// sets it from the build information
+var AppVersion = "dev"
+
+// GeneratorVersion identifies the application version and the generation settings a
+// thumbnail is made with, as "<version>+<settings hash>". Changing any setting that
+// affects the output, including through a sidecar, changes the hash.
+func (t *Thumbnailer) GeneratorVersion() string {
+	c := t.cfg
+	tileWidth, tileHeight := c.ThumbCellSize()
+	settings := fmt.Sprintf("grid=%dx%d tile=%dx%d quality=%d progressive=%t max_pixels=%d poster_quality=%d "+
+		"sample=%d-%d intro_skip=%g/%s/%gs accurate_seek=%t dedup=%s/%g",
+		c.GridCols, c.GridRows, tileWidth, tileHeight, c.GridJPEGQuality(), c.ProgressiveJPEG, c.MaxGridPixels, c.PosterJPEGQuality(),
+		c.SampleStartPercent, c.SampleEndPercent, c.IntroSkipPercent, c.IntroSkipMax, c.IntroSkipSeconds, c.AccurateSeek,
+		c.DedupFrames, c.SceneThreshold)
+	sum := sha256.Sum256([]byte(settings))
+	return AppVersion + "+" + hex.EncodeToString(sum[:4])
+}
+
+// markGenerated stamps a successfully generated thumbnail with the time and generator
+// version, so thumbnails made with older settings can be found and regenerated
+func (t *Thumbnailer) markGenerated(thumbnail *models.Thumbnail) {
+	now := time.Now()
+	thumbnail.GeneratedAt = &now
+	thumbnail.GeneratorVersion = t.GeneratorVersion()
+}
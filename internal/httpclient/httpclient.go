@@ -0,0 +1,43 @@
+// Package httpclient builds the HTTP client used for outbound requests such as
+// webhooks, so none of them can hang without a timeout.
+package httpclient
+
+import (
+	"net/http"
+	"time"
+)
+
+// UserAgent returns the User-Agent sent with outbound requests
+func UserAgent(version string) string {
+	if version == "" {
+		version = "dev"
+	}
+	return "movie-thumbnailer/" + version
+}
+
+// New returns a client whose requests time out after timeout (0 means no timeout)
+// and carry userAgent unless they set their own
+func New(timeout time.Duration, userAgent string) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &userAgentTransport{
+			base:      http.DefaultTransport,
+			userAgent: userAgent,
+		},
+	}
+}
+
+// userAgentTransport sets the User-Agent header on requests that have none
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" && t.userAgent != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,61 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := New(50*time.Millisecond, UserAgent("1.0.0"))
+	start := time.Now()
+	resp, err := client.Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected a slow server to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("request took %v, expected it to give up after the timeout", elapsed)
+	}
+}
+
+func TestClientUserAgent(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("User-Agent")
+	}))
+	defer srv.Close()
+
+	client := New(time.Second, UserAgent("1.2.3"))
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got != "movie-thumbnailer/1.2.3" {
+		t.Errorf("User-Agent = %q, want movie-thumbnailer/1.2.3", got)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("User-Agent", "custom")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got != "custom" {
+		t.Errorf("User-Agent = %q, want the request's own value", got)
+	}
+
+	if ua := UserAgent(""); ua != "movie-thumbnailer/dev" {
+		t.Errorf("UserAgent(\"\") = %q", ua)
+	}
+}
@@ -3,6 +3,7 @@ package metrics
 import (
 	"time"
 
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -18,19 +19,24 @@ type Metrics struct {
 	ThumbnailsTotal             *prometheus.GaugeVec
 	ThumbnailGenerationTotal    *prometheus.CounterVec
 	ThumbnailGenerationDuration prometheus.Histogram
+	UnviewedTotal               prometheus.Gauge
 
 	// Scanning metrics
 	ScanOperationsTotal *prometheus.CounterVec
 	ScanDuration        prometheus.Histogram
 	LastScanTimestamp   prometheus.Gauge
+	ScannerWorkers      prometheus.Gauge
+	ScanMoviesTotal     *prometheus.CounterVec
 
 	// Slideshow metrics
 	SlideshowSessionsTotal   *prometheus.CounterVec
 	SlideshowSessionDuration prometheus.Histogram
 	SlideshowViewsTotal      prometheus.Counter
+	SlideshowActionsTotal    *prometheus.CounterVec
 
 	// Storage metrics
-	TotalFileSize *prometheus.GaugeVec
+	TotalFileSize           *prometheus.GaugeVec
+	ThumbnailServesInFlight prometheus.Gauge
 
 	// Worker metrics
 	BackgroundTasksTotal *prometheus.CounterVec
@@ -43,6 +49,9 @@ type Metrics struct {
 	// Cleanup metrics
 	CleanupDeletedMoviesTotal *prometheus.CounterVec
 	CleanupDeletedMoviesSize  *prometheus.CounterVec
+
+	// Database metrics, see RegisterRandomFallbacks
+	RandomFallbacksTotal prometheus.CounterFunc
 }
 
 // New creates and registers all Prometheus metrics
@@ -75,7 +84,7 @@ func New() *Metrics {
 		ThumbnailsTotal: promauto.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "movie_thumbnailer_thumbnails_total",
-				Help: "Total number of thumbnails by status",
+				Help: "Total number of thumbnails by status, viewed state and source",
 			},
 			[]string{"status"},
 		),
@@ -94,13 +103,20 @@ func New() *Metrics {
 			},
 		),
 
+		UnviewedTotal: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "movie_thumbnailer_unviewed_total",
+				Help: "Number of successful thumbnails not yet viewed",
+			},
+		),
+
 		// Scanning metrics
 		ScanOperationsTotal: promauto.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "movie_thumbnailer_scan_operations_total",
-				Help: "Total number of scanning operations",
+				Help: "Total number of scanning operations, by scan type and result",
 			},
-			[]string{"result"},
+			[]string{"scan_type", "result"},
 		),
 		ScanDuration: promauto.NewHistogram(
 			prometheus.HistogramOpts{
@@ -115,6 +131,19 @@ func New() *Metrics {
 				Help: "Timestamp of the last successful scan",
 			},
 		),
+		ScannerWorkers: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "movie_thumbnailer_scanner_workers",
+				Help: "Number of thumbnails the scanner may generate concurrently",
+			},
+		),
+		ScanMoviesTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "movie_thumbnailer_scan_movies_total",
+				Help: "Total number of movies handled by scans, by scan type and result",
+			},
+			[]string{"scan_type", "result"},
+		),
 
 		// Slideshow metrics
 		SlideshowSessionsTotal: promauto.NewCounterVec(
@@ -137,6 +166,13 @@ func New() *Metrics {
 				Help: "Total number of images viewed in slideshow",
 			},
 		),
+		SlideshowActionsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "movie_thumbnailer_slideshow_actions_total",
+				Help: "Total number of slideshow actions",
+			},
+			[]string{"action"},
+		),
 
 		// Storage metrics
 		TotalFileSize: promauto.NewGaugeVec(
@@ -146,6 +182,12 @@ func New() *Metrics {
 			},
 			[]string{"category"},
 		),
+		ThumbnailServesInFlight: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "movie_thumbnailer_thumbnail_serves_in_flight",
+				Help: "Number of thumbnail files being read and served",
+			},
+		),
 
 		// Worker metrics
 		BackgroundTasksTotal: promauto.NewCounterVec(
@@ -197,6 +239,18 @@ func New() *Metrics {
 	}
 }
 
+// RegisterRandomFallbacks exports count, the number of random thumbnail picks that
+// fell back to math/rand, as a counter
+func (m *Metrics) RegisterRandomFallbacks(count func() int64) {
+	m.RandomFallbacksTotal = promauto.NewCounterFunc(
+		prometheus.CounterOpts{
+			Name: "movie_thumbnailer_random_fallbacks_total",
+			Help: "Total number of random thumbnail picks that fell back to math/rand because crypto/rand failed",
+		},
+		func() float64 { return float64(count()) },
+	)
+}
+
 // RecordHTTPRequest records metrics for an HTTP request
 func (m *Metrics) RecordHTTPRequest(method, endpoint, statusCode string, duration time.Duration) {
 	m.HTTPRequestsTotal.WithLabelValues(method, endpoint, statusCode).Inc()
@@ -210,14 +264,38 @@ func (m *Metrics) RecordThumbnailGeneration(result string, duration time.Duratio
 }
 
 // RecordScanOperation records metrics for scan operations
-func (m *Metrics) RecordScanOperation(result string, duration time.Duration) {
-	m.ScanOperationsTotal.WithLabelValues(result).Inc()
+func (m *Metrics) RecordScanOperation(scanType, result string, duration time.Duration) {
+	m.ScanOperationsTotal.WithLabelValues(scanType, result).Inc()
 	m.ScanDuration.Observe(duration.Seconds())
 	if result == "success" {
 		m.LastScanTimestamp.SetToCurrentTime()
 	}
 }
 
+// RecordScanResult records the movie counts of a finished scan
+func (m *Metrics) RecordScanResult(scanType string, generated, imported, errors, skipped, missingRemoved int) {
+	m.ScanMoviesTotal.WithLabelValues(scanType, "generated").Add(float64(generated))
+	m.ScanMoviesTotal.WithLabelValues(scanType, "imported").Add(float64(imported))
+	m.ScanMoviesTotal.WithLabelValues(scanType, "error").Add(float64(errors))
+	m.ScanMoviesTotal.WithLabelValues(scanType, "skipped").Add(float64(skipped))
+	m.ScanMoviesTotal.WithLabelValues(scanType, "missing_removed").Add(float64(missingRemoved))
+}
+
+// SetScannerWorkers records the scanner's effective concurrency
+func (m *Metrics) SetScannerWorkers(n int) {
+	m.ScannerWorkers.Set(float64(n))
+}
+
+// IncThumbnailServesInFlight records the start of serving a thumbnail file
+func (m *Metrics) IncThumbnailServesInFlight() {
+	m.ThumbnailServesInFlight.Inc()
+}
+
+// DecThumbnailServesInFlight records the end of serving a thumbnail file
+func (m *Metrics) DecThumbnailServesInFlight() {
+	m.ThumbnailServesInFlight.Dec()
+}
+
 // RecordSlideshowSession records metrics for slideshow sessions
 func (m *Metrics) RecordSlideshowSession(result string, duration time.Duration) {
 	m.SlideshowSessionsTotal.WithLabelValues(result).Inc()
@@ -229,6 +307,11 @@ func (m *Metrics) RecordSlideshowView() {
 	m.SlideshowViewsTotal.Inc()
 }
 
+// RecordSlideshowAction records a view, skip, delete or undo in the slideshow
+func (m *Metrics) RecordSlideshowAction(action string) {
+	m.SlideshowActionsTotal.WithLabelValues(action).Inc()
+}
+
 // RecordBackgroundTask records metrics for background tasks
 func (m *Metrics) RecordBackgroundTask(taskType, result string) {
 	m.BackgroundTasksTotal.WithLabelValues(taskType, result).Inc()
@@ -260,6 +343,19 @@ func (m *Metrics) UpdateThumbnailCounts(success, error, pending, deleted, archiv
 	m.ThumbnailsTotal.WithLabelValues("archived").Set(float64(archived))
 }
 
+// UpdateThumbnailStatusCounts sets the thumbnail count gauges for every status,
+// plus the viewed/unviewed and generated/imported breakdowns, from a stats snapshot
+func (m *Metrics) UpdateThumbnailStatusCounts(stats *models.Stats) {
+	m.UpdateThumbnailCounts(stats.Success, stats.Error, stats.Pending, stats.Deleted, stats.Archived)
+	m.ThumbnailsTotal.WithLabelValues("delete_failed").Set(float64(stats.DeleteFailed))
+	m.ThumbnailsTotal.WithLabelValues("incomplete").Set(float64(stats.Incomplete))
+	m.ThumbnailsTotal.WithLabelValues("viewed").Set(float64(stats.Viewed))
+	m.ThumbnailsTotal.WithLabelValues("unviewed").Set(float64(stats.Unviewed))
+	m.ThumbnailsTotal.WithLabelValues("generated").Set(float64(stats.Generated))
+	m.ThumbnailsTotal.WithLabelValues("imported").Set(float64(stats.Imported))
+	m.UnviewedTotal.Set(float64(stats.Unviewed))
+}
+
 // UpdateFileSizes updates the file size metrics
 func (m *Metrics) UpdateFileSizes(viewedSize, unviewedSize int64) {
 	m.TotalFileSize.WithLabelValues("viewed").Set(float64(viewedSize))
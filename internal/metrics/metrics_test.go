@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestUpdateThumbnailStatusCounts(t *testing.T) {
+	m := New()
+	m.UpdateThumbnailStatusCounts(&models.Stats{
+		Success:      5,
+		Error:        1,
+		Pending:      2,
+		Deleted:      3,
+		Archived:     4,
+		DeleteFailed: 6,
+		Viewed:       7,
+		Unviewed:     8,
+		Generated:    9,
+		Imported:     10,
+	})
+
+	want := map[string]float64{
+		"success":       5,
+		"error":         1,
+		"pending":       2,
+		"deleted":       3,
+		"archived":      4,
+		"delete_failed": 6,
+		"viewed":        7,
+		"unviewed":      8,
+		"generated":     9,
+		"imported":      10,
+	}
+	for label, value := range want {
+		if got := testutil.ToFloat64(m.ThumbnailsTotal.WithLabelValues(label)); got != value {
+			t.Errorf("thumbnails_total{status=%q} = %v, want %v", label, got, value)
+		}
+	}
+}
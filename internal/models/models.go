@@ -7,20 +7,44 @@ import (
 
 // Thumbnail represents a thumbnail generated from a movie file
 type Thumbnail struct {
-	ID            int64     `json:"id"`
-	MoviePath     string    `json:"movie_path"`
-	MovieFilename string    `json:"movie_filename"`
-	ThumbnailPath string    `json:"thumbnail_path"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
-	Status        string    `json:"status"`
-	Viewed        int       `json:"viewed"`
-	Width         int       `json:"width"`
-	Height        int       `json:"height"`
-	Duration      float64   `json:"duration"`
-	FileSize      int64     `json:"file_size"`
-	ErrorMessage  string    `json:"error_message,omitempty"`
-	Source        string    `json:"source"`
+	ID            int64      `json:"id"`
+	MoviePath     string     `json:"movie_path"`
+	MovieFilename string     `json:"movie_filename"`
+	ThumbnailPath string     `json:"thumbnail_path"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Status        string     `json:"status"`
+	Viewed        int        `json:"viewed"`
+	Width         int        `json:"width"`
+	Height        int        `json:"height"`
+	Duration      float64    `json:"duration"`
+	FileSize      int64      `json:"file_size"`
+	ErrorMessage  string     `json:"error_message,omitempty"`
+	Source        string     `json:"source"`
+	ViewCount     int        `json:"view_count"`
+	LastViewedAt  *time.Time `json:"last_viewed_at,omitempty"`
+	// When the movie was recorded, from its creation_time tag or else its mtime
+	RecordedAt *time.Time `json:"recorded_at,omitempty"`
+	// When the thumbnail was last generated, and by which app version and settings
+	GeneratedAt      *time.Time `json:"generated_at,omitempty"`
+	GeneratorVersion string     `json:"generator_version,omitempty"`
+	// When the movie was queued for deletion or archival
+	QueuedAt *time.Time `json:"queued_at,omitempty"`
+
+	// Effective grid dimensions of a freshly generated thumbnail (not persisted), and
+	// its columns and rows, which are stored with SetGridSize
+	GridWidth  int `json:"grid_width,omitempty"`
+	GridHeight int `json:"grid_height,omitempty"`
+	GridCols   int `json:"grid_cols,omitempty"`
+	GridRows   int `json:"grid_rows,omitempty"`
+
+	// Storage keys of the poster and animated preview, when they were generated
+	PosterPath  string `json:"poster_path,omitempty"`
+	PreviewPath string `json:"preview_path,omitempty"`
+
+	// URL of the image to show for the thumbnail, which is a placeholder while it is
+	// pending or failed (set by the API, not persisted)
+	DisplayPath string `json:"display_path,omitempty"`
 }
 
 // Stats represents statistics about the thumbnails
@@ -33,10 +57,26 @@ type Stats struct {
 	Unviewed     int   `json:"unviewed"`
 	Deleted      int   `json:"deleted"`
 	Archived     int   `json:"archived"`
+	DeleteFailed int   `json:"delete_failed"`
+	Incomplete   int   `json:"incomplete"`
 	Generated    int   `json:"generated"`
 	Imported     int   `json:"imported"`
 	ViewedSize   int64 `json:"viewed_size"`   // Total file size of viewed movies in bytes
 	UnviewedSize int64 `json:"unviewed_size"` // Total file size of unviewed movies in bytes
+	CaughtUp     bool  `json:"caughtUp"`      // Every successful thumbnail has been viewed
+}
+
+// RootStats represents thumbnail statistics for one movie directory
+type RootStats struct {
+	RootDir       string `json:"root_dir"`
+	Total         int    `json:"total"`
+	Success       int    `json:"success"`
+	Error         int    `json:"error"`
+	Pending       int    `json:"pending"`
+	Viewed        int    `json:"viewed"`
+	Unviewed      int    `json:"unviewed"`
+	MovieSize     int64  `json:"movie_size"`     // Total file size of movies with a thumbnail in bytes
+	ThumbnailSize int64  `json:"thumbnail_size"` // Total size of their stored thumbnails in bytes
 }
 
 // Constants for thumbnail status values
@@ -46,6 +86,13 @@ const (
 	StatusError    = "error"
 	StatusDeleted  = "deleted"
 	StatusArchived = "archived"
+
+	// StatusDeleteFailed marks a queued deletion that exhausted its retries
+	StatusDeleteFailed = "delete_failed"
+
+	// StatusIncomplete marks a movie that is empty or still being written, which the
+	// next scans check again
+	StatusIncomplete = "incomplete"
 )
 
 // Constants for thumbnail source values
@@ -54,10 +101,17 @@ const (
 	SourceImported  = "imported"
 )
 
+// Constants for the thumbnail artifacts a slideshow can show
+const (
+	ArtifactGrid    = "grid"
+	ArtifactPoster  = "poster"
+	ArtifactPreview = "preview"
+)
+
 // ValidStatus checks if a status value is valid
 func ValidStatus(status string) bool {
 	switch status {
-	case StatusPending, StatusSuccess, StatusError, StatusDeleted, StatusArchived:
+	case StatusPending, StatusSuccess, StatusError, StatusDeleted, StatusArchived, StatusDeleteFailed, StatusIncomplete:
 		return true
 	default:
 		return false
@@ -74,6 +128,29 @@ func ValidSource(source string) bool {
 	}
 }
 
+// ValidArtifact checks if an artifact value is valid
+func ValidArtifact(artifact string) bool {
+	switch artifact {
+	case ArtifactGrid, ArtifactPoster, ArtifactPreview:
+		return true
+	default:
+		return false
+	}
+}
+
+// ArtifactPath returns the storage key of the given artifact, falling back to the
+// grid when that artifact was not generated for the movie
+func (t *Thumbnail) ArtifactPath(artifact string) string {
+	switch {
+	case artifact == ArtifactPoster && t.PosterPath != "":
+		return t.PosterPath
+	case artifact == ArtifactPreview && t.PreviewPath != "":
+		return t.PreviewPath
+	default:
+		return t.ThumbnailPath
+	}
+}
+
 // IsViewed returns true if the thumbnail has been viewed
 func (t *Thumbnail) IsViewed() bool {
 	return t.Viewed == 1
@@ -109,6 +186,11 @@ func (t *Thumbnail) IsDeleted() bool {
 	return t.Status == StatusDeleted
 }
 
+// IsDeleteFailed returns true if deleting the movie failed too many times
+func (t *Thumbnail) IsDeleteFailed() bool {
+	return t.Status == StatusDeleteFailed
+}
+
 // IsArchived returns true if the thumbnail is marked for archival
 func (t *Thumbnail) IsArchived() bool {
 	return t.Status == StatusArchived
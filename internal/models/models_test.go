@@ -121,3 +121,27 @@ func TestValidSource(t *testing.T) {
 		}
 	}
 }
+
+func TestArtifactPath(t *testing.T) {
+	thumbnail := &Thumbnail{ThumbnailPath: "movie.jpg", PosterPath: "movie.poster.jpg"}
+
+	tests := []struct {
+		artifact string
+		want     string
+	}{
+		{"", "movie.jpg"},
+		{ArtifactGrid, "movie.jpg"},
+		{ArtifactPoster, "movie.poster.jpg"},
+		{ArtifactPreview, "movie.jpg"}, // Not generated, falls back to the grid
+		{"unknown", "movie.jpg"},
+	}
+	for _, tt := range tests {
+		if got := thumbnail.ArtifactPath(tt.artifact); got != tt.want {
+			t.Errorf("ArtifactPath(%q) = %q, want %q", tt.artifact, got, tt.want)
+		}
+	}
+
+	if !ValidArtifact(ArtifactPreview) || ValidArtifact("") || ValidArtifact("mosaic") {
+		t.Error("ValidArtifact accepted or rejected the wrong values")
+	}
+}
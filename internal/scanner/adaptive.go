@@ -0,0 +1,222 @@
+package scanner
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// Thresholds of the adaptive worker controller
+const (
+	adaptiveSlowdown       = 2.0   // Remove a worker when a round takes this much longer than the baseline
+	adaptiveSpeedup        = 1.25  // Add a worker while rounds stay within this factor of the baseline
+	adaptiveMaxGoroutines  = 10000 // Goroutine count treated as overload
+	adaptiveMemoryHeadroom = 0.9   // Fraction of GOMEMLIMIT treated as overload
+)
+
+// workerLimiter is a counting semaphore whose limit can change while it is in use
+type workerLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	active int
+	wake   chan struct{} // Closed whenever a slot may have opened up
+}
+
+// newWorkerLimiter creates a limiter allowing limit concurrent holders
+func newWorkerLimiter(limit int) *workerLimiter {
+	return &workerLimiter{limit: limit, wake: make(chan struct{})}
+}
+
+// Acquire blocks until a slot is free or ctx is done
+func (l *workerLimiter) Acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.active < l.limit {
+			l.active++
+			l.mu.Unlock()
+			return nil
+		}
+		wake := l.wake
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wake:
+		}
+	}
+}
+
+// Release frees a slot taken by Acquire
+func (l *workerLimiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.broadcast()
+}
+
+// SetLimit changes the number of slots. Holders above a lowered limit keep their
+// slots until they release them.
+func (l *workerLimiter) SetLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.broadcast()
+}
+
+// Limit returns the current number of slots
+func (l *workerLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// broadcast wakes every waiting Acquire; l.mu must be held
+func (l *workerLimiter) broadcast() {
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+// loadSample is a snapshot of the process load
+type loadSample struct {
+	heapInuse  uint64
+	memLimit   int64
+	goroutines int
+}
+
+// sampleLoad reads the current heap use, memory limit and goroutine count
+func sampleLoad() loadSample {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return loadSample{
+		heapInuse:  m.HeapInuse,
+		memLimit:   debug.SetMemoryLimit(-1),
+		goroutines: runtime.NumGoroutine(),
+	}
+}
+
+// overloaded reports whether the process has too many goroutines or is close to its
+// memory limit. Without GOMEMLIMIT only the goroutine count is checked.
+func (l loadSample) overloaded() bool {
+	if l.goroutines > adaptiveMaxGoroutines {
+		return true
+	}
+	return l.memLimit > 0 && l.memLimit < math.MaxInt64 &&
+		float64(l.heapInuse) > adaptiveMemoryHeadroom*float64(l.memLimit)
+}
+
+// adaptiveController resizes a workerLimiter between min and max workers from the
+// observed generation durations and the process load
+type adaptiveController struct {
+	mu       sync.Mutex
+	min, max int
+	limiter  *workerLimiter
+	baseline time.Duration   // Typical generation time while not overloaded
+	window   []time.Duration // Durations of the current round
+	sample   func() loadSample
+	log      *logrus.Logger
+	metrics  *metrics.Metrics
+}
+
+// newAdaptiveController creates a controller starting at min workers
+func newAdaptiveController(min, max int, log *logrus.Logger, metrics *metrics.Metrics) *adaptiveController {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	return &adaptiveController{
+		min:     min,
+		max:     max,
+		limiter: newWorkerLimiter(min),
+		sample:  sampleLoad,
+		log:     log,
+		metrics: metrics,
+	}
+}
+
+// Acquire blocks until a worker slot is free or ctx is done
+func (c *adaptiveController) Acquire(ctx context.Context) error {
+	return c.limiter.Acquire(ctx)
+}
+
+// Release frees a worker slot
+func (c *adaptiveController) Release() {
+	c.limiter.Release()
+}
+
+// Limit returns the current number of workers
+func (c *adaptiveController) Limit() int {
+	return c.limiter.Limit()
+}
+
+// Observe records the duration of one generation. After a round of one generation
+// per worker, the number of workers is adjusted.
+func (c *adaptiveController) Observe(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	limit := c.limiter.Limit()
+	c.window = append(c.window, d)
+	if len(c.window) < limit {
+		return
+	}
+
+	var total time.Duration
+	for _, d := range c.window {
+		total += d
+	}
+	mean := total / time.Duration(len(c.window))
+	c.window = c.window[:0]
+
+	next, reason := c.decide(limit, mean, c.sample())
+	if next == limit {
+		return
+	}
+
+	c.limiter.SetLimit(next)
+	if c.metrics != nil {
+		c.metrics.SetScannerWorkers(next)
+	}
+	c.log.WithFields(logrus.Fields{
+		"workers":  next,
+		"previous": limit,
+		"mean":     mean.Round(time.Millisecond),
+		"baseline": c.baseline.Round(time.Millisecond),
+	}).Infof("Adjusted scanner workers: %s", reason)
+}
+
+// decide returns the number of workers for the next round and why it changed
+func (c *adaptiveController) decide(limit int, mean time.Duration, load loadSample) (int, string) {
+	if c.baseline == 0 {
+		c.baseline = mean
+	}
+	ratio := float64(mean) / float64(c.baseline)
+
+	switch {
+	case load.overloaded():
+		if limit > c.min {
+			return limit - 1, "process is overloaded"
+		}
+	case ratio > adaptiveSlowdown:
+		if limit > c.min {
+			return limit - 1, "generations slowed down"
+		}
+		// Already at the floor, so these files are simply slower to process
+		c.baseline = mean
+	case ratio <= adaptiveSpeedup:
+		// Follow gradual changes, such as a directory of longer movies
+		c.baseline = (4*c.baseline + mean) / 5
+		if limit < c.max {
+			return limit + 1, "generations are fast"
+		}
+	}
+	return limit, ""
+}
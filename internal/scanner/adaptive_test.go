@@ -0,0 +1,117 @@
+package scanner
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWorkerLimiter(t *testing.T) {
+	l := newWorkerLimiter(1)
+	ctx := context.Background()
+
+	if err := l.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second holder waits until the limit is raised
+	acquired := make(chan error, 1)
+	go func() { acquired <- l.Acquire(ctx) }()
+	select {
+	case <-acquired:
+		t.Fatal("Acquire succeeded above the limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	l.SetLimit(2)
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Acquire did not wake up after SetLimit")
+	}
+
+	// Lowering the limit blocks new holders until enough slots are released
+	l.SetLimit(1)
+	l.Release()
+	cancelled, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(cancelled); err == nil {
+		t.Fatal("Acquire succeeded while at the lowered limit")
+	}
+
+	l.Release()
+	if err := l.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAdaptiveControllerDecide(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	c := newAdaptiveController(1, 3, log, nil)
+	load := loadSample{}
+
+	steps := []struct {
+		mean time.Duration
+		load loadSample
+		want int
+	}{
+		{10 * time.Second, load, 2},                          // First round sets the baseline and ramps up
+		{11 * time.Second, load, 3},                          // Still fast
+		{12 * time.Second, load, 3},                          // Capped at max
+		{30 * time.Second, load, 2},                          // Slowed down
+		{12 * time.Second, loadSample{goroutines: 20000}, 1}, // Overloaded
+		{40 * time.Second, load, 1},                          // Floor reached; rebaselines
+		{41 * time.Second, load, 2},                          // Fast relative to the new baseline
+	}
+
+	for i, step := range steps {
+		limit := c.Limit()
+		got, _ := c.decide(limit, step.mean, step.load)
+		if got != step.want {
+			t.Fatalf("step %d: decide(%d, %v) = %d, want %d", i, limit, step.mean, got, step.want)
+		}
+		c.limiter.SetLimit(got)
+	}
+}
+
+func TestAdaptiveControllerObserveRounds(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	c := newAdaptiveController(2, 4, log, nil)
+	c.sample = func() loadSample { return loadSample{} }
+
+	// Adjustments happen once per round of one generation per worker
+	c.Observe(time.Second)
+	if got := c.Limit(); got != 2 {
+		t.Fatalf("limit after half a round = %d, want 2", got)
+	}
+	c.Observe(time.Second)
+	if got := c.Limit(); got != 3 {
+		t.Fatalf("limit after a fast round = %d, want 3", got)
+	}
+}
+
+func TestLoadSampleOverloaded(t *testing.T) {
+	tests := []struct {
+		sample loadSample
+		want   bool
+	}{
+		{loadSample{goroutines: 50}, false},
+		{loadSample{goroutines: adaptiveMaxGoroutines + 1}, true},
+		{loadSample{heapInuse: 95, memLimit: 100}, true},
+		{loadSample{heapInuse: 50, memLimit: 100}, false},
+		{loadSample{heapInuse: 1 << 40}, false}, // No GOMEMLIMIT
+	}
+	for _, tt := range tests {
+		if got := tt.sample.overloaded(); got != tt.want {
+			t.Errorf("%+v.overloaded() = %v, want %v", tt.sample, got, tt.want)
+		}
+	}
+}
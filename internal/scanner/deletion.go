@@ -0,0 +1,111 @@
+package scanner
+
+import (
+	"sync"
+	"time"
+)
+
+// repeatedFailureThreshold is the number of failed attempts after which a queued
+// deletion is reported as repeatedly failing
+const repeatedFailureThreshold = 3
+
+// DeletionError describes a movie that could not be deleted
+type DeletionError struct {
+	Movie    string `json:"movie"`
+	Error    string `json:"error"`
+	Attempts int    `json:"attempts"`
+}
+
+// DeletionSummary reports the state or result of a deletion queue run
+type DeletionSummary struct {
+	Running          bool            `json:"running"`
+	StartedAt        *time.Time      `json:"started_at,omitempty"`  // Nil before the first run
+	FinishedAt       *time.Time      `json:"finished_at,omitempty"` // Nil while running
+	Total            int             `json:"total"`
+	Processed        int             `json:"processed"`
+	Deleted          int             `json:"deleted"`
+	Failed           int             `json:"failed"`
+	ReclaimedBytes   int64           `json:"reclaimed_bytes"`
+	Errors           []DeletionError `json:"errors"`
+	RepeatedFailures []DeletionError `json:"repeated_failures"`
+}
+
+// DeletionProgress tracks the current (or last) deletion queue run
+type DeletionProgress struct {
+	mu      sync.Mutex
+	summary DeletionSummary
+}
+
+// NewDeletionProgress creates an idle DeletionProgress tracker
+func NewDeletionProgress() *DeletionProgress {
+	return &DeletionProgress{}
+}
+
+// Start begins a new run of total items, returning false if a run is already in progress
+func (p *DeletionProgress) Start(total int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.summary.Running {
+		return false
+	}
+	now := time.Now()
+	p.summary = DeletionSummary{
+		Running:   true,
+		StartedAt: &now,
+		Total:     total,
+	}
+	return true
+}
+
+// Deleted records a successfully deleted movie
+func (p *DeletionProgress) Deleted(movie string, size int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.summary.Processed++
+	p.summary.Deleted++
+	p.summary.ReclaimedBytes += size
+}
+
+// Failed records a movie that could not be deleted after the given number of attempts
+func (p *DeletionProgress) Failed(movie string, err error, attempts int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.summary.Processed++
+	p.summary.Failed++
+	p.summary.Errors = append(p.summary.Errors, DeletionError{
+		Movie:    movie,
+		Error:    err.Error(),
+		Attempts: attempts,
+	})
+}
+
+// Finish marks the current run as complete and returns its summary
+func (p *DeletionProgress) Finish() DeletionSummary {
+	p.mu.Lock()
+	now := time.Now()
+	p.summary.Running = false
+	p.summary.FinishedAt = &now
+	p.mu.Unlock()
+
+	return p.Snapshot()
+}
+
+// Snapshot returns a copy of the current summary, including items whose
+// deletion has failed repeatedly
+func (p *DeletionProgress) Snapshot() DeletionSummary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	summary := p.summary
+	summary.Errors = append([]DeletionError{}, p.summary.Errors...)
+	summary.RepeatedFailures = []DeletionError{}
+	for _, e := range summary.Errors {
+		if e.Attempts >= repeatedFailureThreshold {
+			summary.RepeatedFailures = append(summary.RepeatedFailures, e)
+		}
+	}
+	return summary
+}
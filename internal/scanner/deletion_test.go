@@ -0,0 +1,55 @@
+package scanner
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDeletionProgress(t *testing.T) {
+	p := NewDeletionProgress()
+
+	// An idle tracker reports no run times
+	data, err := json.Marshal(p.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "started_at") || strings.Contains(string(data), "finished_at") {
+		t.Errorf("idle summary = %s, want no run times", data)
+	}
+
+	if !p.Start(2) {
+		t.Fatal("expected first run to start")
+	}
+	if p.Start(2) {
+		t.Fatal("expected a second concurrent run to be rejected")
+	}
+
+	p.Deleted("a.mp4", 100)
+	p.Failed("b.mp4", errors.New("permission denied"), 1)
+
+	running := p.Snapshot()
+	if !running.Running || running.Processed != 2 || running.StartedAt == nil || running.FinishedAt != nil {
+		t.Errorf("unexpected in-progress snapshot: %+v", running)
+	}
+
+	summary := p.Finish()
+	if summary.Running || summary.FinishedAt == nil || summary.Deleted != 1 || summary.Failed != 1 || summary.ReclaimedBytes != 100 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	if len(summary.Errors) != 1 || summary.Errors[0].Movie != "b.mp4" || summary.Errors[0].Attempts != 1 {
+		t.Errorf("unexpected errors: %+v", summary.Errors)
+	}
+	if len(summary.RepeatedFailures) != 0 {
+		t.Errorf("expected no repeated failures after one attempt, got %+v", summary.RepeatedFailures)
+	}
+
+	// A movie reaching the threshold is reported as repeatedly failing
+	p.Start(1)
+	p.Failed("b.mp4", errors.New("permission denied"), repeatedFailureThreshold)
+	summary = p.Finish()
+	if len(summary.RepeatedFailures) != 1 || summary.RepeatedFailures[0].Attempts != repeatedFailureThreshold {
+		t.Errorf("expected b.mp4 to be a repeated failure, got %+v", summary.RepeatedFailures)
+	}
+}
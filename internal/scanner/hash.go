@@ -0,0 +1,150 @@
+package scanner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// hashChunkSize is the size of each sample read by partialHash
+const hashChunkSize = 64 * 1024
+
+// partialHash identifies a movie's content without reading the whole file: it hashes
+// the file size and 64 KiB from the start, middle and end of the file. Empty files
+// have no hash.
+func partialHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	size := info.Size()
+	if size == 0 {
+		return "", nil // Empty files all look alike
+	}
+
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, size)
+
+	buf := make([]byte, hashChunkSize)
+	for _, offset := range []int64{0, size/2 - hashChunkSize/2, size - hashChunkSize} {
+		if offset < 0 {
+			offset = 0
+		}
+		n, err := f.ReadAt(buf, offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		h.Write(buf[:n])
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// adoptRenamedThumbnail looks for the thumbnail of a movie with the same content whose
+// file no longer exists, and moves it to the new name instead of regenerating it.
+// It reports whether a thumbnail was adopted.
+func (s *Scanner) adoptRenamedThumbnail(ctx context.Context, moviePath, hash string, thumbnail *models.Thumbnail) (bool, error) {
+	candidates, err := s.db.GetByContentHash(hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up content hash: %w", err)
+	}
+
+	for _, candidate := range candidates {
+		// A copy whose original is still present keeps its own thumbnail
+		if len(s.resolveMoviePaths(candidate.MoviePath)) > 0 {
+			continue
+		}
+
+		if candidate.ThumbnailPath != thumbnail.ThumbnailPath {
+			if err := s.moveThumbnailFile(ctx, candidate.ThumbnailPath, thumbnail.ThumbnailPath); err != nil {
+				if errors.Is(err, storage.ErrNotExist) {
+					continue
+				}
+				return false, fmt.Errorf("failed to move thumbnail: %w", err)
+			}
+		}
+
+		if err := s.db.RenameMovie(candidate.ID, thumbnail.MoviePath, thumbnail.MovieFilename, thumbnail.ThumbnailPath); err != nil {
+			// Put the file back so the stored path stays valid
+			if candidate.ThumbnailPath != thumbnail.ThumbnailPath {
+				if rbErr := s.moveThumbnailFile(ctx, thumbnail.ThumbnailPath, candidate.ThumbnailPath); rbErr != nil {
+					s.log.WithError(rbErr).WithField("thumbnail", thumbnail.ThumbnailPath).Error("Failed to restore moved thumbnail")
+				}
+			}
+			return false, fmt.Errorf("failed to rename thumbnail record: %w", err)
+		}
+
+		s.log.WithFields(logrus.Fields{
+			"movie": moviePath,
+			"from":  candidate.MoviePath,
+		}).Info("Movie was renamed, reusing its thumbnail")
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// backfillContentHashes records the content hash of successful rows that predate it,
+// so their movies are recognized when renamed
+func (s *Scanner) backfillContentHashes(ctx context.Context) error {
+	thumbnails, err := s.db.GetRowsNeedingContentHash()
+	if err != nil {
+		return fmt.Errorf("failed to get rows needing content hash: %w", err)
+	}
+	if len(thumbnails) == 0 {
+		return nil
+	}
+
+	var updated int
+	for i, thumbnail := range thumbnails {
+		// Check for context cancellation periodically
+		if i%100 == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+				// Continue processing
+			}
+		}
+
+		if s.unresolved.has(thumbnail.ID) {
+			continue
+		}
+		paths := s.resolveMoviePaths(thumbnail.MoviePath)
+		if len(paths) == 0 {
+			s.unresolved.add(thumbnail.ID)
+			continue
+		}
+		hash, err := partialHash(paths[0])
+		if err != nil {
+			s.log.WithError(err).WithField("movie", thumbnail.MoviePath).Warn("Failed to hash movie content")
+			continue
+		}
+		if hash == "" {
+			continue
+		}
+		if err := s.db.SetContentHash(thumbnail.MoviePath, hash); err != nil {
+			s.log.WithError(err).WithField("movie", thumbnail.MoviePath).Error("Failed to update content hash")
+		} else {
+			updated++
+		}
+	}
+
+	s.log.Infof("Content hash backfill completed: updated %d of %d rows", updated, len(thumbnails))
+	return nil
+}
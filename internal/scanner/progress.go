@@ -0,0 +1,50 @@
+package scanner
+
+import (
+	"sort"
+	"sync"
+)
+
+// FileProgress is the generation progress of a single movie file
+type FileProgress struct {
+	Movie   string  `json:"movie"`
+	Percent float64 `json:"percent"`
+}
+
+// Progress tracks per-file generation progress for the movies currently being processed
+type Progress struct {
+	mu    sync.Mutex
+	files map[string]float64
+}
+
+// NewProgress creates an empty Progress tracker
+func NewProgress() *Progress {
+	return &Progress{files: make(map[string]float64)}
+}
+
+// Set records the completion percentage for a movie
+func (p *Progress) Set(movie string, percent float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.files[movie] = percent
+}
+
+// Done removes a movie from the in-progress set
+func (p *Progress) Done(movie string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.files, movie)
+}
+
+// Snapshot returns the current progress of all in-flight movies, sorted by name
+func (p *Progress) Snapshot() []FileProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	snapshot := make([]FileProgress, 0, len(p.files))
+	for movie, percent := range p.files {
+		snapshot = append(snapshot, FileProgress{Movie: movie, Percent: percent})
+	}
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].Movie < snapshot[j].Movie })
+	return snapshot
+}
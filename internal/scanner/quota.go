@@ -0,0 +1,140 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// movieRoot returns the configured movie directory that contains moviePath, or ""
+func (s *Scanner) movieRoot(moviePath string) string {
+	for _, dir := range s.cfg.MoviesDirs {
+		rel, err := filepath.Rel(dir, moviePath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return dir
+	}
+	return ""
+}
+
+// storedSize returns the size of the thumbnail stored under key, or 0 if it is missing
+func (s *Scanner) storedSize(ctx context.Context, key string) int64 {
+	info, err := s.storage.Stat(ctx, key)
+	if err != nil {
+		return 0
+	}
+	return info.Size
+}
+
+// recordRootUsage stores the movie directory of a movie and, for successful
+// thumbnails, the size of the stored thumbnail
+func (s *Scanner) recordRootUsage(ctx context.Context, movieFilename, root, key string, success bool) {
+	if root == "" {
+		return
+	}
+	var size int64
+	if success {
+		size = s.storedSize(ctx, key)
+	}
+	if err := s.db.SetRootUsage(movieFilename, root, size); err != nil {
+		s.log.WithError(err).WithField("movie", movieFilename).Warn("Failed to save movie directory usage")
+	}
+}
+
+// quotaReservations holds the estimated thumbnail size of the generations running
+// for each movie directory, so concurrent workers can't together exceed its quota
+type quotaReservations struct {
+	mu       sync.Mutex
+	reserved map[string]int64
+}
+
+// reserveQuota reserves room for a movie's thumbnail under THUMBNAIL_QUOTA_PER_ROOT.
+// It reports false, logging the skipped movie, when the stored and reserved thumbnails
+// of the movie directory already use up its quota; otherwise the caller must call the
+// returned release once the thumbnail's usage is recorded. The reservation is the
+// average size of the directory's thumbnails.
+func (s *Scanner) reserveQuota(moviePath, root string) (func(), bool) {
+	if s.cfg.ThumbnailQuotaPerRoot <= 0 || root == "" {
+		return func() {}, true
+	}
+
+	s.quota.mu.Lock()
+	defer s.quota.mu.Unlock()
+	size, count, err := s.db.GetRootThumbnailUsage(root)
+	if err != nil {
+		s.log.WithError(err).WithField("root", root).Warn("Failed to read thumbnail usage of movie directory")
+		return func() {}, true
+	}
+	used := size + s.quota.reserved[root]
+	if used >= s.cfg.ThumbnailQuotaPerRoot {
+		s.log.WithFields(logrus.Fields{
+			"movie": moviePath,
+			"root":  root,
+			"used":  used,
+			"quota": s.cfg.ThumbnailQuotaPerRoot,
+		}).Warn("Movie directory is over its thumbnail quota, skipping generation")
+		return nil, false
+	}
+
+	var estimate int64
+	if count > 0 {
+		estimate = size / int64(count)
+	}
+	if s.quota.reserved == nil {
+		s.quota.reserved = make(map[string]int64)
+	}
+	s.quota.reserved[root] += estimate
+	return func() {
+		s.quota.mu.Lock()
+		defer s.quota.mu.Unlock()
+		s.quota.reserved[root] -= estimate
+	}, true
+}
+
+// backfillRootUsage records the movie directory and thumbnail size of successful rows
+// that predate the columns, so quotas account for existing thumbnails
+func (s *Scanner) backfillRootUsage(ctx context.Context) error {
+	thumbnails, err := s.db.GetRowsNeedingRootDir()
+	if err != nil {
+		return fmt.Errorf("failed to get rows needing movie directory: %w", err)
+	}
+	if len(thumbnails) == 0 {
+		return nil
+	}
+
+	var updated int
+	for i, thumbnail := range thumbnails {
+		// Check for context cancellation periodically
+		if i%100 == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+				// Continue processing
+			}
+		}
+
+		if s.unresolved.has(thumbnail.ID) {
+			continue
+		}
+		paths := s.resolveMoviePaths(thumbnail.MoviePath)
+		if len(paths) == 0 {
+			s.unresolved.add(thumbnail.ID)
+			continue
+		}
+		root := s.movieRoot(paths[0])
+		if err := s.db.SetRootUsage(thumbnail.MoviePath, root, s.storedSize(ctx, thumbnail.ThumbnailPath)); err != nil {
+			s.log.WithError(err).WithField("movie", thumbnail.MoviePath).Error("Failed to update movie directory")
+		} else {
+			updated++
+		}
+	}
+
+	s.log.Infof("Movie directory backfill completed: updated %d of %d rows", updated, len(thumbnails))
+	return nil
+}
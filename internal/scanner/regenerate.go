@@ -0,0 +1,53 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/database"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+// RegenerationCandidates returns the thumbnails matching filter that can be
+// regenerated. Rows queued for deletion or archived are never included.
+func (s *Scanner) RegenerationCandidates(filter database.ThumbnailFilter) ([]*models.Thumbnail, error) {
+	thumbnails, _, err := s.db.GetThumbnailsFiltered(filter, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query thumbnails: %w", err)
+	}
+
+	candidates := thumbnails[:0]
+	for _, thumbnail := range thumbnails {
+		if thumbnail.Status == models.StatusSuccess || thumbnail.Status == models.StatusError {
+			candidates = append(candidates, thumbnail)
+		}
+	}
+	return candidates, nil
+}
+
+// ResetForRegeneration removes the stored thumbnails of the given rows and resets them
+// to pending, so the next scan generates them again. It returns how many rows were reset.
+func (s *Scanner) ResetForRegeneration(ctx context.Context, thumbnails []*models.Thumbnail) (int, error) {
+	reset := 0
+	for _, thumbnail := range thumbnails {
+		select {
+		case <-ctx.Done():
+			return reset, ctx.Err()
+		default:
+		}
+
+		// A thumbnail that cannot be removed is overwritten by the scan anyway
+		if thumbnail.ThumbnailPath != "" {
+			if _, err := s.deleteThumbnailFile(ctx, thumbnail.ThumbnailPath); err != nil {
+				s.log.WithError(err).WithField("thumbnail", thumbnail.ThumbnailPath).Warn("Failed to remove thumbnail before regeneration")
+			}
+		}
+		if err := s.db.UpdateStatus(thumbnail.MoviePath, models.StatusPending, ""); err != nil {
+			return reset, fmt.Errorf("failed to reset %s: %w", thumbnail.MoviePath, err)
+		}
+		reset++
+	}
+
+	s.log.Infof("Reset %d thumbnails for regeneration", reset)
+	return reset, nil
+}
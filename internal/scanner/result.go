@@ -0,0 +1,61 @@
+package scanner
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ScanResult summarizes a scan run
+type ScanResult struct {
+	Type           string        `json:"type"`            // The scan_type of the run
+	Processed      int           `json:"processed"`       // Movies that were not skipped up front
+	Generated      int           `json:"generated"`       // Thumbnails generated successfully
+	Imported       int           `json:"imported"`        // Existing thumbnails imported, reused or adopted from a renamed movie
+	Errors         int           `json:"errors"`          // Movies that failed
+	Skipped        int           `json:"skipped"`         // Movies already handled, or left for a later scan
+	MissingRemoved int           `json:"missing_removed"` // Records removed for movies no longer on disk
+	Duration       time.Duration `json:"duration"`        // In nanoseconds
+}
+
+// scanTally accumulates the counts of a ScanResult from concurrent workers
+type scanTally struct {
+	processed, generated, imported, errors, skipped, missingRemoved atomic.Int64
+}
+
+// result returns the counts as a ScanResult
+func (t *scanTally) result(start time.Time) ScanResult {
+	return ScanResult{
+		Processed:      int(t.processed.Load()),
+		Generated:      int(t.generated.Load()),
+		Imported:       int(t.imported.Load()),
+		Errors:         int(t.errors.Load()),
+		Skipped:        int(t.skipped.Load()),
+		MissingRemoved: int(t.missingRemoved.Load()),
+		Duration:       time.Since(start),
+	}
+}
+
+// processOutcome is how processMovie left a movie
+type processOutcome int
+
+const (
+	outcomeNone processOutcome = iota // Interrupted before anything was decided
+	outcomeSkipped
+	outcomeGenerated
+	outcomeImported
+	outcomeError
+)
+
+// record counts an outcome
+func (t *scanTally) record(outcome processOutcome) {
+	switch outcome {
+	case outcomeSkipped:
+		t.skipped.Add(1)
+	case outcomeGenerated:
+		t.generated.Add(1)
+	case outcomeImported:
+		t.imported.Add(1)
+	case outcomeError:
+		t.errors.Add(1)
+	}
+}
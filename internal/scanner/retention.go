@@ -0,0 +1,35 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PruneStaleErrors removes the error rows older than ERROR_RETENTION whose movie is no
+// longer in any movie directory, returning the number removed. Their thumbnail files,
+// if any, are left to the orphan cleanup.
+func (s *Scanner) PruneStaleErrors(ctx context.Context) (int64, error) {
+	if s.cfg.ErrorRetention <= 0 {
+		return 0, nil
+	}
+	if !s.TryAcquire(OpCleanup) {
+		return 0, s.busyError(OpCleanup)
+	}
+	defer s.Release()
+
+	cutoff := time.Now().Add(-s.cfg.ErrorRetention)
+	pruned, err := s.db.PruneStaleErrors(cutoff, func(moviePath string) bool {
+		// Keep the remaining rows once cancelled
+		return ctx.Err() != nil || len(s.resolveMoviePaths(moviePath)) > 0
+	})
+	if err != nil {
+		return pruned, fmt.Errorf("failed to prune error rows: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return pruned, err
+	}
+
+	s.log.WithField("retention", s.cfg.ErrorRetention).Infof("Pruned %d error rows of missing movies", pruned)
+	return pruned, nil
+}
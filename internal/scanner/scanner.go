@@ -2,12 +2,16 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pandino/movie-thumbnailer-go/internal/config"
@@ -15,108 +19,319 @@ import (
 	"github.com/pandino/movie-thumbnailer-go/internal/ffmpeg"
 	"github.com/pandino/movie-thumbnailer-go/internal/metrics"
 	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 )
 
+// ErrPermissionDenied aborts a scan when thumbnails cannot be written
+var ErrPermissionDenied = errors.New("permission denied writing thumbnails")
+
+// ErrScanInProgress is returned when a scan is started while another one runs
+var ErrScanInProgress = errors.New("scan already in progress")
+
+// ErrBusy is returned when an operation is started while a different one runs
+var ErrBusy = errors.New("another operation is in progress")
+
+// Long-running operations that touch the same files and rows, so at most one runs at a time
+const (
+	OpScan      = "scan"
+	OpCleanup   = "cleanup"
+	OpDeletions = "deletions"
+)
+
 // Scanner handles scanning for movie files and managing thumbnails
 type Scanner struct {
 	cfg         *config.Config
 	db          *database.DB
 	thumbnailer *ffmpeg.Thumbnailer
+	storage     storage.Storage
 	log         *logrus.Logger
 	metrics     *metrics.Metrics
 	lock        sync.Mutex
-	isScanning  bool
+	busyOp      string        // Running operation, "" when idle
+	busyDone    chan struct{} // Closed when the running operation returns
+	progress    *Progress
+	deletions   *DeletionProgress
+
+	// Tunes generation concurrency when ADAPTIVE_WORKERS is set
+	workers *adaptiveController
+
+	// Rows the backfills couldn't find a movie for
+	unresolved unresolvedRows
+
+	// Thumbnail usage reserved by running generations, for THUMBNAIL_QUOTA_PER_ROOT
+	quota quotaReservations
 }
 
 // New creates a new Scanner
-func New(cfg *config.Config, db *database.DB, log *logrus.Logger, metrics *metrics.Metrics) *Scanner {
+func New(cfg *config.Config, db *database.DB, store storage.Storage, log *logrus.Logger, metrics *metrics.Metrics) *Scanner {
+	var workers *adaptiveController
+	if cfg.AdaptiveWorkers {
+		workers = newAdaptiveController(cfg.MinWorkers, cfg.MaxWorkers, log, metrics)
+	}
+
 	return &Scanner{
 		cfg:         cfg,
 		db:          db,
-		thumbnailer: ffmpeg.New(cfg, log, metrics),
+		thumbnailer: ffmpeg.New(cfg, log, metrics, store),
+		storage:     store,
 		log:         log,
 		metrics:     metrics,
-		isScanning:  false,
+		progress:    NewProgress(),
+		deletions:   NewDeletionProgress(),
+		workers:     workers,
 	}
 }
 
-// IsScanning returns whether a scan is currently in progress
-func (s *Scanner) IsScanning() bool {
+// TryAcquire marks op as the running operation. It reports false, leaving the state
+// alone, while another operation runs; otherwise the caller must call Release.
+func (s *Scanner) TryAcquire(op string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.busyOp != "" {
+		return false
+	}
+	s.busyOp = op
+	s.busyDone = make(chan struct{})
+	return true
+}
+
+// Release ends the operation started with TryAcquire
+func (s *Scanner) Release() {
 	s.lock.Lock()
 	defer s.lock.Unlock()
-	return s.isScanning
+	if s.busyOp == "" {
+		return
+	}
+	s.busyOp = ""
+	close(s.busyDone)
 }
 
-// ScanMovies scans for movie files and generates thumbnails for new files
-func (s *Scanner) ScanMovies(ctx context.Context) error {
+// BusyOperation returns the running operation, or "" when none runs
+func (s *Scanner) BusyOperation() string {
 	s.lock.Lock()
-	if s.isScanning {
-		s.lock.Unlock()
-		return fmt.Errorf("scan already in progress")
+	defer s.lock.Unlock()
+	return s.busyOp
+}
+
+// IsBusy returns whether a scan, cleanup or deletion run is in progress
+func (s *Scanner) IsBusy() bool {
+	return s.BusyOperation() != ""
+}
+
+// IsScanning returns whether a scan is currently in progress
+func (s *Scanner) IsScanning() bool {
+	return s.BusyOperation() == OpScan
+}
+
+// busyError returns the error for starting op while another operation runs
+func (s *Scanner) busyError(op string) error {
+	running := s.BusyOperation()
+	switch {
+	case running == op && op == OpScan:
+		return ErrScanInProgress
+	case running == op && op == OpDeletions:
+		return ErrDeletionInProgress
 	}
-	s.isScanning = true
+	return fmt.Errorf("%w: %s running", ErrBusy, running)
+}
+
+// Wait blocks until the running operation, if any, has returned
+func (s *Scanner) Wait() {
+	s.lock.Lock()
+	done := s.busyDone
 	s.lock.Unlock()
+	if done != nil {
+		<-done
+	}
+}
 
-	defer func() {
-		s.lock.Lock()
-		s.isScanning = false
-		s.lock.Unlock()
-	}()
+// Progress returns the generation progress of the movies currently being processed.
+// Per-file percentages are only reported when FFMPEG_PROGRESS is enabled.
+func (s *Scanner) Progress() []FileProgress {
+	return s.progress.Snapshot()
+}
+
+// Scan types, the scan_type label attributing a scan to what triggered it
+const (
+	ScanTypeInitial    = "initial"    // The worker's scan at startup
+	ScanTypeScheduled  = "scheduled"  // The worker's periodic scan
+	ScanTypeManual     = "manual"     // Requested through the UI or API
+	ScanTypeImport     = "import"     // Requested through the API with ?import=true
+	ScanTypeRegenerate = "regenerate" // Run by the regenerate command
+)
+
+// ScanOptions adjusts the behavior of a single scan run
+type ScanOptions struct {
+	// ImportExisting imports existing thumbnail files instead of regenerating them
+	ImportExisting bool
+	// Type is the scan_type the run is logged and counted under, ScanTypeManual by default
+	Type string
+	// SkipCleanup skips the cleanup phases, which move and delete queued movies, so
+	// the run only generates thumbnails
+	SkipCleanup bool
+}
+
+// ScanMovies scans for movie files and generates thumbnails for new files,
+// using the configured defaults
+func (s *Scanner) ScanMovies(ctx context.Context, scanType string) (ScanResult, error) {
+	return s.ScanMoviesWithOptions(ctx, ScanOptions{ImportExisting: s.cfg.ImportExisting, Type: scanType})
+}
+
+// ScanMoviesWithOptions scans for movie files like ScanMovies, with options that
+// apply to this run only. The result counts what was done, also when the scan fails.
+func (s *Scanner) ScanMoviesWithOptions(ctx context.Context, opts ScanOptions) (ScanResult, error) {
+	if opts.Type == "" {
+		opts.Type = ScanTypeManual
+	}
 
-	s.log.Info("Starting movie scan")
+	if !s.TryAcquire(OpScan) {
+		return ScanResult{Type: opts.Type}, s.busyError(OpScan)
+	}
+	defer s.Release()
+
+	result, err := s.scanMovies(ctx, opts)
+	result.Type = opts.Type
+	if s.metrics != nil {
+		status := "success"
+		if err != nil {
+			status = "error"
+		}
+		s.metrics.RecordScanOperation(opts.Type, status, result.Duration)
+		s.metrics.RecordScanResult(opts.Type, result.Generated, result.Imported, result.Errors, result.Skipped, result.MissingRemoved)
+	}
+	return result, err
+}
+
+// scanMovies runs a scan holding the OpScan operation
+func (s *Scanner) scanMovies(ctx context.Context, opts ScanOptions) (ScanResult, error) {
+	start := time.Now()
+	var tally scanTally
+
+	s.log.WithFields(logrus.Fields{
+		"scan_type":       opts.Type,
+		"import_existing": opts.ImportExisting,
+	}).Info("Starting movie scan")
 
 	// Check if context is already done before starting
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return tally.result(start), ctx.Err()
 	default:
 		// Continue with scan
 	}
 
-	// Find all movie files
-	movieFiles, err := s.findMovieFiles(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to find movie files: %w", err)
+	// Fill in file sizes for rows that predate the column
+	if err := s.backfillFileSizes(ctx); err != nil {
+		if ctx.Err() != nil {
+			return tally.result(start), ctx.Err()
+		}
+		s.log.WithError(err).Warn("Failed to backfill file sizes")
 	}
 
-	totalfiles := len(movieFiles)
+	// Attribute existing thumbnails to their movie directory for the per-root stats
+	if err := s.backfillRootUsage(ctx); err != nil {
+		if ctx.Err() != nil {
+			return tally.result(start), ctx.Err()
+		}
+		s.log.WithError(err).Warn("Failed to backfill movie directories")
+	}
 
-	s.log.Infof("Found %d movie files", totalfiles)
+	// Hash the content of existing movies so renames of them are recognized
+	if err := s.backfillContentHashes(ctx); err != nil {
+		if ctx.Err() != nil {
+			return tally.result(start), ctx.Err()
+		}
+		s.log.WithError(err).Warn("Failed to backfill content hashes")
+	}
 
 	// Process movies in parallel
 	g, gctx := errgroup.WithContext(ctx)
 	g.SetLimit(s.cfg.MaxWorkers)
+	if s.metrics != nil {
+		if s.workers != nil {
+			s.metrics.SetScannerWorkers(s.workers.Limit())
+		} else {
+			s.metrics.SetScannerWorkers(s.cfg.MaxWorkers)
+		}
+	}
+
+	// Find movie files in the background, processing them as they are found
+	discovery := s.discoverMovies(gctx)
 
-	for current, moviePath := range movieFiles {
-		moviePath := moviePath // Capture variable for goroutine
-		current := current     // Capture variable for logging
+	current := -1
+	for moviePath := range discovery.files {
+		current++
+		current := current // Capture variable for logging
+		totalfiles := discovery.Total()
 
-		// Check if context is cancelled
+		// Check if context is cancelled, or the scan was aborted by a worker
 		select {
 		case <-gctx.Done():
-			return gctx.Err()
+			if err := g.Wait(); err != nil {
+				return tally.result(start), err
+			}
+			return tally.result(start), gctx.Err()
 		default:
 			// Continue processing
 		}
 
 		// Check if thumbnail already exists and is successful
-		movieFilename := filepath.Base(moviePath)
-		thumbnail, err := s.db.GetByMoviePath(movieFilename)
+		thumbnail, err := s.db.GetByMoviePath(s.thumbnailer.MovieKey(moviePath))
 		if err != nil {
 			s.log.WithError(err).WithField("movie", moviePath).Error("Failed to check database")
+			tally.errors.Add(1)
+			if s.cfg.StrictScan {
+				g.Wait()
+				return tally.result(start), fmt.Errorf("failed to check database for movie %s: %w", moviePath, err)
+			}
 			continue
 		}
 
-		// Skip if thumbnail already exists and is successful, previously failed, or is marked for deletion or archival
-		if thumbnail != nil && (thumbnail.Status == models.StatusSuccess || thumbnail.Status == models.StatusError || thumbnail.Status == models.StatusDeleted || thumbnail.Status == models.StatusArchived) {
-			continue
+		// Skip if thumbnail already exists and is successful and the movie unchanged,
+		// previously failed, or is marked for deletion or archival
+		if thumbnail != nil {
+			switch thumbnail.Status {
+			case models.StatusSuccess:
+				if s.successUnchanged(moviePath, thumbnail) {
+					tally.skipped.Add(1)
+					continue
+				}
+			case models.StatusError, models.StatusDeleted, models.StatusArchived, models.StatusDeleteFailed:
+				tally.skipped.Add(1)
+				continue
+			}
 		}
 
-		// Process the movie in parallel; errors are per-movie and must not cancel the group
+		// Wait for the adaptive controller to allow another worker
+		if s.workers != nil {
+			if err := s.workers.Acquire(gctx); err != nil {
+				if err := g.Wait(); err != nil {
+					return tally.result(start), err
+				}
+				return tally.result(start), gctx.Err()
+			}
+		}
+
+		// Process the movie in parallel; errors are per-movie and only cancel the group
+		// with STRICT_SCAN
 		g.Go(func() error {
-			if err := s.processMovie(gctx, moviePath, current, totalfiles); err != nil {
+			if s.workers != nil {
+				defer s.workers.Release()
+			}
+			tally.processed.Add(1)
+			outcome, err := s.processMovie(gctx, moviePath, current, totalfiles, opts)
+			tally.record(outcome)
+			if err != nil {
+				if errors.Is(err, ErrPermissionDenied) {
+					s.log.WithError(err).Error("Aborting scan: thumbnails cannot be written")
+					return err
+				}
+				if s.cfg.StrictScan && gctx.Err() == nil {
+					s.log.WithError(err).WithField("movie", moviePath).Error("Aborting scan: failed to process movie with STRICT_SCAN set")
+					return err
+				}
 				s.log.WithError(err).WithField("movie", moviePath).Error("Failed to process movie, skipping")
 			}
 			return nil
@@ -126,38 +341,126 @@ func (s *Scanner) ScanMovies(ctx context.Context) error {
 	// Wait for all thumbnails to be processed
 	if err := g.Wait(); err != nil {
 		s.log.WithError(err).Error("Error during movie processing")
-		return err
+		return tally.result(start), err
 	}
+	<-discovery.done
 
 	// Check context before continuing with cleanup
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return tally.result(start), ctx.Err()
 	default:
 		// Continue with cleanup
 	}
 
+	if discovery.err != nil {
+		return tally.result(start), fmt.Errorf("failed to find movie files: %w", discovery.err)
+	}
+
 	// Clean up orphaned entries and thumbnails
-	if err := s.CleanupOrphans(ctx); err != nil {
-		s.log.WithError(err).Error("Error during orphan cleanup")
-		return err
+	if !opts.SkipCleanup {
+		missing, err := s.cleanupOrphans(ctx)
+		tally.missingRemoved.Add(int64(missing))
+		if err != nil {
+			s.log.WithError(err).Error("Error during orphan cleanup")
+			return tally.result(start), err
+		}
 	}
 
 	s.log.Info("Movie scan completed successfully")
-	return nil
+	return tally.result(start), nil
+}
+
+// readDirBatch is the number of directory entries read at a time, so that movies in
+// huge directories are processed while the directory is still being listed
+const readDirBatch = 256
+
+// movieDiscovery streams the movie files of a scan while they are being found
+type movieDiscovery struct {
+	files chan string
+	found atomic.Int64
+	done  chan struct{}
+	err   error // Set before done is closed
+}
+
+// Total returns the number of movie files found so far
+func (d *movieDiscovery) Total() int {
+	return int(d.found.Load())
+}
+
+// discoverMovies finds movie files in the background and sends them on the returned
+// discovery's files channel. Newest-first ordering needs the complete list, so with
+// PROCESS_NEWEST_FIRST nothing is sent until every directory has been read.
+func (s *Scanner) discoverMovies(ctx context.Context) *movieDiscovery {
+	d := &movieDiscovery{
+		files: make(chan string, readDirBatch),
+		done:  make(chan struct{}),
+	}
+
+	send := func(moviePath string) {
+		select {
+		case d.files <- moviePath:
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		defer close(d.done)
+		defer close(d.files)
+
+		if s.cfg.ProcessNewestFirst {
+			movieFiles, err := s.findMovieFiles(ctx)
+			if err != nil {
+				d.err = err
+				return
+			}
+			d.found.Store(int64(len(movieFiles)))
+			s.log.Infof("Found %d movie files", len(movieFiles))
+
+			// Generate thumbnails for recently added movies first
+			s.sortNewestFirst(movieFiles)
+			for _, moviePath := range movieFiles {
+				send(moviePath)
+			}
+			return
+		}
+
+		d.err = s.streamMovieFiles(ctx, func(moviePath string) {
+			d.found.Add(1)
+			send(moviePath)
+		})
+		if d.err == nil {
+			s.log.Infof("Found %d movie files", d.Total())
+		}
+	}()
+
+	return d
 }
 
 // findMovieFiles returns a deduplicated list of movie file paths across all configured volumes.
-// Each basename appears at most once (first volume wins on collision).
+// Each movie_path key, the basename or with RECURSIVE_SCAN the path relative to the
+// volume, appears at most once (first volume wins on collision).
 // Volumes that don't exist on disk are logged as warnings and skipped.
 func (s *Scanner) findMovieFiles(ctx context.Context) ([]string, error) {
-	seen := make(map[string]struct{})
 	var movieFiles []string
+	err := s.streamMovieFiles(ctx, func(moviePath string) {
+		movieFiles = append(movieFiles, moviePath)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movieFiles, nil
+}
+
+// streamMovieFiles calls fn for each movie file across all configured volumes as the
+// directories are read, with the same deduplication as findMovieFiles
+func (s *Scanner) streamMovieFiles(ctx context.Context, fn func(moviePath string)) error {
+	seen := make(map[string]struct{})
 
 	for _, dir := range s.cfg.MoviesDirs {
 		select {
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			return ctx.Err()
 		default:
 		}
 
@@ -166,51 +469,126 @@ func (s *Scanner) findMovieFiles(ctx context.Context) ([]string, error) {
 			continue
 		}
 
-		entries, err := os.ReadDir(dir)
+		err := s.walkMovieDir(ctx, dir, s.cfg.RecursiveScan, func(moviePath string) {
+			key := s.thumbnailer.MovieKey(moviePath)
+			if _, alreadySeen := seen[key]; !alreadySeen {
+				seen[key] = struct{}{}
+				fn(moviePath)
+			}
+		})
 		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			s.log.WithError(err).WithField("dir", dir).Warn("Failed to read movies directory, skipping")
 			continue
 		}
+	}
 
-		for _, entry := range entries {
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			default:
-			}
+	return nil
+}
 
-			if entry.IsDir() {
-				continue
-			}
+// walkMovieDir calls fn for every movie file in dir, in directory order, reading
+// readDirBatch entries at a time. Subdirectories are descended into only when
+// recursive is set, and those matching SCAN_EXCLUDE_DIRS are pruned entirely.
+func (s *Scanner) walkMovieDir(ctx context.Context, dir string, recursive bool, fn func(moviePath string)) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-			ext := strings.ToLower(filepath.Ext(entry.Name()))
-			if ext == "" {
-				continue
+	for {
+		entries, readErr := f.ReadDir(readDirBatch)
+		for _, d := range entries {
+			if err := ctx.Err(); err != nil {
+				return err
 			}
-			ext = ext[1:]
 
-			for _, allowedExt := range s.cfg.FileExtensions {
-				if ext == strings.ToLower(allowedExt) {
-					basename := entry.Name()
-					if _, alreadySeen := seen[basename]; !alreadySeen {
-						seen[basename] = struct{}{}
-						movieFiles = append(movieFiles, filepath.Join(dir, basename))
+			p := filepath.Join(dir, d.Name())
+			if d.IsDir() {
+				if !recursive || s.excludedDir(d.Name()) {
+					continue
+				}
+				if err := s.walkMovieDir(ctx, p, true, fn); err != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
 					}
-					break
+					s.log.WithError(err).WithField("dir", p).Warn("Failed to read directory, skipping")
 				}
+				continue
+			}
+
+			if s.isMovieFile(d.Name()) {
+				fn(p)
 			}
 		}
+
+		if errors.Is(readErr, io.EOF) {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
 	}
+}
 
-	return movieFiles, nil
+// isMovieFile reports whether a file name has one of the configured movie extensions
+func (s *Scanner) isMovieFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == "" {
+		return false
+	}
+	ext = ext[1:]
+
+	for _, allowedExt := range s.cfg.FileExtensions {
+		if ext == strings.ToLower(allowedExt) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedDir reports whether a directory name matches one of the SCAN_EXCLUDE_DIRS
+// names or glob patterns
+func (s *Scanner) excludedDir(name string) bool {
+	for _, pattern := range s.cfg.ScanExcludeDirs {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if matched, err := filepath.Match(pattern, name); (err == nil && matched) || pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sortNewestFirst orders movie paths by modification time, newest first. Files that
+// cannot be stat-ed keep their relative order at the end.
+func (s *Scanner) sortNewestFirst(movieFiles []string) {
+	modTimes := make(map[string]time.Time, len(movieFiles))
+	for _, moviePath := range movieFiles {
+		info, err := os.Stat(moviePath)
+		if err != nil {
+			s.log.WithError(err).WithField("movie", moviePath).Debug("Failed to stat movie for ordering")
+			continue
+		}
+		modTimes[moviePath] = info.ModTime()
+	}
+
+	sort.SliceStable(movieFiles, func(i, j int) bool {
+		return modTimes[movieFiles[i]].After(modTimes[movieFiles[j]])
+	})
 }
 
 // resolveMoviePaths returns every absolute path across all configured volumes where a
-// file with the given basename currently exists on disk.
-func (s *Scanner) resolveMoviePaths(basename string) []string {
+// file with the given movie_path, a basename or a path relative to the volume,
+// currently exists on disk.
+func (s *Scanner) resolveMoviePaths(moviePath string) []string {
 	var paths []string
 	for _, dir := range s.cfg.MoviesDirs {
-		p := filepath.Join(dir, basename)
+		p := filepath.Join(dir, filepath.FromSlash(moviePath))
 		if _, err := os.Stat(p); err == nil {
 			paths = append(paths, p)
 		}
@@ -218,56 +596,266 @@ func (s *Scanner) resolveMoviePaths(basename string) []string {
 	return paths
 }
 
-// processMovie generates a thumbnail for a movie file
-func (s *Scanner) processMovie(ctx context.Context, moviePath string, current int, totalFiles int) error {
+// unresolvedRows remembers the rows whose movie no configured volume has, so the
+// backfills don't look for them again on every scan until the next restart
+type unresolvedRows struct {
+	mu  sync.Mutex
+	ids map[int64]bool
+}
+
+func (u *unresolvedRows) add(id int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.ids == nil {
+		u.ids = make(map[int64]bool)
+	}
+	u.ids[id] = true
+}
+
+func (u *unresolvedRows) has(id int64) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.ids[id]
+}
+
+// backfillFileSizes records the movie file size for successful rows that have none,
+// without regenerating their thumbnails
+func (s *Scanner) backfillFileSizes(ctx context.Context) error {
+	thumbnails, err := s.db.GetRowsNeedingFileSize()
+	if err != nil {
+		return fmt.Errorf("failed to get rows needing file size: %w", err)
+	}
+	if len(thumbnails) == 0 {
+		return nil
+	}
+
+	var updated int
+	for i, thumbnail := range thumbnails {
+		// Check for context cancellation periodically
+		if i%100 == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+				// Continue processing
+			}
+		}
+
+		if s.unresolved.has(thumbnail.ID) {
+			continue
+		}
+		paths := s.resolveMoviePaths(thumbnail.MoviePath)
+		if len(paths) == 0 {
+			s.unresolved.add(thumbnail.ID)
+			continue
+		}
+		for _, moviePath := range paths {
+			info, err := os.Stat(moviePath)
+			if err != nil || info.Size() == 0 {
+				continue
+			}
+			if err := s.db.UpdateFileSize(thumbnail.ID, info.Size()); err != nil {
+				s.log.WithError(err).WithField("movie", thumbnail.MoviePath).Error("Failed to update file size")
+			} else {
+				updated++
+			}
+			break
+		}
+	}
+
+	s.log.Infof("File size backfill completed: updated %d of %d rows", updated, len(thumbnails))
+	return nil
+}
+
+// RelocateThumbnails moves existing thumbnail files to the layout expected by the
+// current configuration (e.g. flat thumbnails into mirrored subdirectories) and
+// updates their stored paths
+func (s *Scanner) RelocateThumbnails(ctx context.Context) (int, error) {
+	thumbnails, err := s.db.GetAllThumbnails()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get thumbnails: %w", err)
+	}
+
+	var moved int
+	for i, thumbnail := range thumbnails {
+		// Check for context cancellation periodically
+		if i%100 == 0 {
+			select {
+			case <-ctx.Done():
+				return moved, ctx.Err()
+			default:
+				// Continue processing
+			}
+		}
+
+		if thumbnail.ThumbnailPath == "" {
+			continue
+		}
+
+		// Find the movie on disk to derive its expected thumbnail location
+		var moviePath string
+		for _, candidate := range s.resolveMoviePaths(thumbnail.MoviePath) {
+			if _, err := os.Stat(candidate); err == nil {
+				moviePath = candidate
+				break
+			}
+		}
+		if moviePath == "" {
+			continue
+		}
+
+		expected := s.thumbnailer.ThumbnailRelPath(moviePath)
+		if expected == thumbnail.ThumbnailPath {
+			continue
+		}
+
+		if err := s.moveThumbnailFile(ctx, thumbnail.ThumbnailPath, expected); err != nil {
+			if !errors.Is(err, storage.ErrNotExist) {
+				s.log.WithError(err).WithField("thumbnail", thumbnail.ThumbnailPath).Error("Failed to move thumbnail")
+			}
+			continue
+		}
+		if err := s.db.UpdateThumbnailPath(thumbnail.ID, expected); err != nil {
+			// Put the file back so the stored path stays valid
+			if rbErr := s.moveThumbnailFile(ctx, expected, thumbnail.ThumbnailPath); rbErr != nil {
+				s.log.WithError(rbErr).WithField("thumbnail", expected).Error("Failed to restore moved thumbnail")
+			}
+			s.log.WithError(err).WithField("thumbnail", expected).Error("Failed to update thumbnail path")
+			continue
+		}
+
+		s.log.WithFields(logrus.Fields{
+			"from": thumbnail.ThumbnailPath,
+			"to":   expected,
+		}).Debug("Relocated thumbnail")
+		moved++
+	}
+
+	s.log.Infof("Thumbnail relocation completed: moved %d thumbnail files", moved)
+	return moved, nil
+}
+
+// moveThumbnailFile copies a stored thumbnail to a new key and removes the original
+func (s *Scanner) moveThumbnailFile(ctx context.Context, from, to string) error {
+	r, _, err := s.storage.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+	err = s.storage.Put(ctx, to, r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+	return s.storage.Delete(ctx, from)
+}
+
+// deleteThumbnailFile removes a stored thumbnail, reporting whether it existed
+func (s *Scanner) deleteThumbnailFile(ctx context.Context, key string) (bool, error) {
+	exists, err := s.storage.Exists(ctx, key)
+	if err != nil || !exists {
+		return false, err
+	}
+	return true, s.storage.Delete(ctx, key)
+}
+
+// processMovie generates a thumbnail for a movie file and reports how it was handled
+func (s *Scanner) processMovie(ctx context.Context, moviePath string, current int, totalFiles int, opts ScanOptions) (processOutcome, error) {
 	s.log.WithField("movie", moviePath).Infof("[%d/%d] Processing movie", current+1, totalFiles)
 
 	// Check for context cancellation
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return outcomeNone, ctx.Err()
 	default:
 		// Continue processing
 	}
 
 	// Generate expected thumbnail filename
-	movieFilename := filepath.Base(moviePath)
-	thumbnailFilename := strings.TrimSuffix(movieFilename, filepath.Ext(movieFilename)) + ".jpg"
-	thumbnailPath := filepath.Join(s.cfg.ThumbnailsDir, thumbnailFilename)
-
-	// Get file size
-	var fileSize int64
-	if fileInfo, err := os.Stat(moviePath); err == nil {
+	movieKey := s.thumbnailer.MovieKey(moviePath)
+	thumbnailFilename := s.thumbnailer.ThumbnailRelPath(moviePath)
+	root := s.movieRoot(moviePath)
+
+	// Get file size and modification time
+	var fileSize, mtime int64
+	var modTime *time.Time
+	fileInfo, statErr := os.Stat(moviePath)
+	if statErr == nil {
 		fileSize = fileInfo.Size()
+		mtime = fileInfo.ModTime().Unix()
+		modified := fileInfo.ModTime()
+		modTime = &modified
+	}
+
+	// A sidecar next to the movie overrides how its grid is generated
+	sidecarPath := ffmpeg.SidecarPath(moviePath)
+	var sidecarMtime int64
+	if info, err := os.Stat(sidecarPath); err == nil {
+		sidecarMtime = info.ModTime().Unix()
 	}
 
 	// Initialize a thumbnail record - will be either inserted or updated
 	thumbnail := &models.Thumbnail{
-		MoviePath:     movieFilename,
-		MovieFilename: movieFilename,
+		MoviePath:     movieKey,
+		MovieFilename: filepath.Base(moviePath),
 		ThumbnailPath: thumbnailFilename,
 		Status:        models.StatusPending,
 		Source:        models.SourceGenerated, // Default source
 		FileSize:      fileSize,
+		RecordedAt:    modTime, // Until the movie's creation_time tag is read
 	}
 
-	// Check if thumbnail file already exists on disk
-	fileExists := false
-	if _, err := os.Stat(thumbnailPath); err == nil {
-		fileExists = true
+	// Check if thumbnail file already exists in storage
+	fileExists, err := s.storage.Exists(ctx, thumbnailFilename)
+	if err != nil {
+		s.log.WithError(err).WithField("thumbnail", thumbnailFilename).Warn("Failed to check thumbnail storage")
 	}
 
 	// Get existing record if any
-	existingThumbnail, err := s.db.GetByMoviePath(movieFilename)
+	existingThumbnail, err := s.db.GetByMoviePath(movieKey)
 	if err != nil {
 		s.log.WithError(err).WithField("movie", moviePath).Error("Failed to check database")
-		return fmt.Errorf("failed to check database for movie %s: %w", moviePath, err)
+		return outcomeError, fmt.Errorf("failed to check database for movie %s: %w", moviePath, err)
+	}
+
+	// Partial downloads start out empty: wait for the file to grow rather than
+	// recording an error
+	if statErr == nil && fileSize == 0 {
+		return s.recordIncomplete(moviePath, thumbnail, existingThumbnail, "Movie file is empty")
 	}
 
 	// If thumbnail exists in DB and is successful, and the file exists, nothing to do
+	// unless the movie was replaced since
 	if existingThumbnail != nil && existingThumbnail.Status == models.StatusSuccess && fileExists {
-		s.log.WithField("movie", moviePath).Debug("Thumbnail already exists and is successful, skipping")
-		return nil
+		switch {
+		case s.movieChanged(existingThumbnail, fileSize, mtime):
+			s.log.WithField("movie", moviePath).Info("Movie changed since its thumbnail was generated, regenerating")
+		case s.sidecarChanged(existingThumbnail, sidecarMtime):
+			s.log.WithField("movie", moviePath).Info("Thumbnail sidecar changed since the thumbnail was generated, regenerating")
+		default:
+			s.log.WithField("movie", moviePath).Debug("Thumbnail already exists and is successful, skipping")
+			return outcomeSkipped, nil
+		}
+	}
+
+	// Identify the content, so a renamed movie can keep its thumbnail
+	hash, err := partialHash(moviePath)
+	if err != nil {
+		s.log.WithError(err).WithField("movie", moviePath).Debug("Failed to hash movie content")
+	}
+	if existingThumbnail == nil && hash != "" {
+		adopted, err := s.adoptRenamedThumbnail(ctx, moviePath, hash, thumbnail)
+		if err != nil {
+			s.log.WithError(err).WithField("movie", moviePath).Warn("Failed to reuse thumbnail of renamed movie")
+		}
+		if adopted {
+			s.recordRootUsage(ctx, movieKey, root, thumbnailFilename, true)
+			return outcomeImported, nil
+		}
+	}
+
+	// Brand-new files start unviewed unless NEW_FILES_VIEWED is set
+	if existingThumbnail == nil && s.cfg.NewFilesViewed {
+		thumbnail.Viewed = 1
 	}
 
 	// If we have an existing record, preserve some values
@@ -285,16 +873,39 @@ func (s *Scanner) processMovie(ctx context.Context, moviePath string, current in
 		}
 	}
 
+	// Rebuilding the database over an existing thumbnail set: record the file as is,
+	// without the metadata probe of a full import
+	if fileExists && existingThumbnail == nil && s.cfg.ReuseExistingThumbnails && s.storedNonEmpty(ctx, thumbnailFilename) {
+		thumbnail.Status = models.StatusSuccess
+		thumbnail.Source = models.SourceImported
+
+		if err := s.db.UpsertThumbnail(thumbnail); err != nil {
+			s.log.WithError(err).WithField("movie", moviePath).Error("Failed to save reused thumbnail")
+			return outcomeError, fmt.Errorf("failed to save reused thumbnail for movie %s: %w", moviePath, err)
+		}
+		s.saveContentHash(movieKey, hash)
+		s.saveMovieMtime(movieKey, mtime)
+		s.saveSidecarMtime(movieKey, sidecarMtime)
+		s.recordRootUsage(ctx, movieKey, root, thumbnailFilename, true)
+
+		s.log.WithFields(logrus.Fields{
+			"movie":     moviePath,
+			"thumbnail": thumbnailFilename,
+		}).Info("Reused existing thumbnail")
+		return outcomeImported, nil
+	}
+
 	// Check if thumbnail exists but no DB entry (or entry not success)
-	if fileExists && s.cfg.ImportExisting &&
+	if fileExists && opts.ImportExisting &&
 		(existingThumbnail == nil || existingThumbnail.Status != models.StatusSuccess) {
 
 		// Don't import if already archived or deleted - respect those statuses
 		if existingThumbnail != nil &&
 			(existingThumbnail.Status == models.StatusDeleted ||
-				existingThumbnail.Status == models.StatusArchived) {
+				existingThumbnail.Status == models.StatusArchived ||
+				existingThumbnail.Status == models.StatusDeleteFailed) {
 			s.log.WithField("movie", moviePath).Debug("Thumbnail already marked as deleted/archived, skipping import")
-			return nil
+			return outcomeSkipped, nil
 		}
 
 		s.log.WithField("movie", moviePath).Info("Existing thumbnail found, importing")
@@ -310,6 +921,9 @@ func (s *Scanner) processMovie(ctx context.Context, moviePath string, current in
 			thumbnail.Duration = metadata.Duration
 			thumbnail.Width = metadata.Width
 			thumbnail.Height = metadata.Height
+			if metadata.RecordedAt != nil {
+				thumbnail.RecordedAt = metadata.RecordedAt
+			}
 			thumbnail.Status = models.StatusSuccess
 			thumbnail.Source = models.SourceImported
 			thumbnail.ErrorMessage = ""
@@ -318,8 +932,12 @@ func (s *Scanner) processMovie(ctx context.Context, moviePath string, current in
 		// Save the thumbnail record
 		if err := s.db.UpsertThumbnail(thumbnail); err != nil {
 			s.log.WithError(err).WithField("movie", moviePath).Error("Failed to save imported thumbnail")
-			return fmt.Errorf("failed to save imported thumbnail for movie %s: %w", moviePath, err)
+			return outcomeError, fmt.Errorf("failed to save imported thumbnail for movie %s: %w", moviePath, err)
 		}
+		s.saveContentHash(movieKey, hash)
+		s.saveMovieMtime(movieKey, mtime)
+		s.saveSidecarMtime(movieKey, sidecarMtime)
+		s.recordRootUsage(ctx, movieKey, root, thumbnailFilename, thumbnail.Status == models.StatusSuccess)
 
 		s.log.WithFields(logrus.Fields{
 			"movie":      moviePath,
@@ -329,29 +947,72 @@ func (s *Scanner) processMovie(ctx context.Context, moviePath string, current in
 			"resolution": fmt.Sprintf("%dx%d", thumbnail.Width, thumbnail.Height),
 		}).Info("Imported existing thumbnail")
 
-		return nil
+		if thumbnail.Status != models.StatusSuccess {
+			return outcomeError, nil
+		}
+		return outcomeImported, nil
 	}
 
+	// Leave movies of a directory that has used up its quota for a later scan
+	release, ok := s.reserveQuota(moviePath, root)
+	if !ok {
+		return outcomeSkipped, nil
+	}
+	defer release()
+
 	// Save the pending status - this ensures other processes know this movie is being processed
 	// and establishes the record in the database
 	if err := s.db.UpsertThumbnail(thumbnail); err != nil {
 		s.log.WithError(err).WithField("movie", moviePath).Error("Failed to save pending status")
-		return fmt.Errorf("failed to save pending status for movie %s: %w", moviePath, err)
+		return outcomeError, fmt.Errorf("failed to save pending status for movie %s: %w", moviePath, err)
 	}
 
 	// Check for context cancellation before creating thumbnail
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return outcomeNone, ctx.Err()
 	default:
 		// Continue processing
 	}
 
+	// Report per-file progress when enabled
+	var onProgress ffmpeg.ProgressFunc
+	if s.cfg.FFmpegProgress {
+		onProgress = func(percent float64) {
+			s.progress.Set(movieKey, percent)
+		}
+		defer s.progress.Done(movieKey)
+	}
+
 	// Generate the thumbnail - this will now set source as 'generated'
 	start := time.Now()
-	generatedThumbnail, err := s.thumbnailer.CreateThumbnail(ctx, moviePath, s.db)
+	thumbnailer := s.thumbnailer
+	if sidecarMtime != 0 {
+		thumbnailer = s.sidecarThumbnailer(moviePath, sidecarPath)
+	}
+	generatedThumbnail, err := thumbnailer.CreateThumbnail(ctx, moviePath, s.db, onProgress)
 	thumbnailDuration := time.Since(start)
 
+	// A permission problem affects every movie, so stop the scan instead of
+	// marking each one as failed; the record stays pending for the next scan
+	if errors.Is(err, fs.ErrPermission) {
+		if s.metrics != nil {
+			s.metrics.RecordWorkerError("scanner", "permission_denied")
+		}
+		return outcomeNone, fmt.Errorf("%w: %w", ErrPermissionDenied, err)
+	}
+
+	// Let the adaptive controller tune concurrency from the generation time
+	if s.workers != nil {
+		s.workers.Observe(thumbnailDuration)
+	}
+
+	// A movie still being downloaded can't be probed yet; look at it again next scan
+	if err != nil && s.stillDownloading(err, modTime) {
+		s.log.WithError(err).WithField("movie", moviePath).Info("Movie modified recently can't be read yet, checking it again next scan")
+		return s.recordIncomplete(moviePath, thumbnail, existingThumbnail, fmt.Sprintf("Movie modified recently and not readable yet: %v", err))
+	}
+
 	if err != nil {
 		s.log.WithError(err).WithField("movie", moviePath).Error("Failed to create thumbnail")
 
@@ -364,12 +1025,19 @@ func (s *Scanner) processMovie(ctx context.Context, moviePath string, current in
 		thumbnail.Status = models.StatusError
 		thumbnail.ErrorMessage = fmt.Sprintf("Failed to create thumbnail: %v", err)
 
+		// The last good thumbnail no longer matches a movie that now fails
+		if s.cfg.ClearThumbnailOnError && existingThumbnail != nil && existingThumbnail.Status == models.StatusSuccess {
+			s.clearStaleThumbnail(ctx, existingThumbnail.ThumbnailPath)
+			thumbnail.ThumbnailPath = ""
+		}
+
 		// Save the error status
 		if upsertErr := s.db.UpsertThumbnail(thumbnail); upsertErr != nil {
 			s.log.WithError(upsertErr).WithField("movie", moviePath).Error("Failed to save error status")
 		}
+		s.recordRootUsage(ctx, movieKey, root, thumbnailFilename, false)
 
-		return fmt.Errorf("failed to create thumbnail for movie %s: %w", moviePath, err)
+		return outcomeError, fmt.Errorf("failed to create thumbnail for movie %s: %w", moviePath, err)
 	}
 
 	// Record metrics for successful generation
@@ -382,14 +1050,23 @@ func (s *Scanner) processMovie(ctx context.Context, moviePath string, current in
 	thumbnail.Width = generatedThumbnail.Width
 	thumbnail.Height = generatedThumbnail.Height
 	thumbnail.Duration = generatedThumbnail.Duration
+	if generatedThumbnail.RecordedAt != nil {
+		thumbnail.RecordedAt = generatedThumbnail.RecordedAt
+	}
 	thumbnail.ErrorMessage = generatedThumbnail.ErrorMessage
 	thumbnail.Source = generatedThumbnail.Source
+	thumbnail.GeneratedAt = generatedThumbnail.GeneratedAt
+	thumbnail.GeneratorVersion = generatedThumbnail.GeneratorVersion
 
 	// Save the final status
 	if err := s.db.UpsertThumbnail(thumbnail); err != nil {
 		s.log.WithError(err).WithField("movie", moviePath).Error("Failed to save final status")
-		return fmt.Errorf("failed to save final status for movie %s: %w", moviePath, err)
+		return outcomeError, fmt.Errorf("failed to save final status for movie %s: %w", moviePath, err)
 	}
+	s.saveContentHash(movieKey, hash)
+	s.saveMovieMtime(movieKey, mtime)
+	s.saveSidecarMtime(movieKey, sidecarMtime)
+	s.recordRootUsage(ctx, movieKey, root, thumbnailFilename, thumbnail.Status == models.StatusSuccess)
 
 	s.log.WithFields(logrus.Fields{
 		"movie":      moviePath,
@@ -399,53 +1076,254 @@ func (s *Scanner) processMovie(ctx context.Context, moviePath string, current in
 		"resolution": fmt.Sprintf("%dx%d", thumbnail.Width, thumbnail.Height),
 	}).Info("Processed movie")
 
-	return nil
+	if thumbnail.Status != models.StatusSuccess {
+		return outcomeError, nil
+	}
+	return outcomeGenerated, nil
+}
+
+// clearStaleThumbnail removes the thumbnail file of a movie whose generation now fails
+func (s *Scanner) clearStaleThumbnail(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+	deleted, err := s.deleteThumbnailFile(ctx, key)
+	if err != nil {
+		s.log.WithError(err).WithField("thumbnail", key).Warn("Failed to remove stale thumbnail")
+		return
+	}
+	if deleted {
+		s.log.WithField("thumbnail", key).Info("Removed stale thumbnail of failing movie")
+	}
 }
 
-// CleanupOrphans removes database entries for missing movies, orphaned thumbnails,
-// and processes items marked for deletion and archival
+// storedNonEmpty reports whether a non-empty thumbnail is stored under key
+func (s *Scanner) storedNonEmpty(ctx context.Context, key string) bool {
+	return s.storedSize(ctx, key) > 0
+}
+
+// movieChanged reports whether a movie's size or modification time differs from the
+// values recorded with its thumbnail. Values that were never recorded don't count, but
+// a missing modification time is recorded for the next scan.
+func (s *Scanner) movieChanged(existing *models.Thumbnail, fileSize, mtime int64) bool {
+	if fileSize > 0 && existing.FileSize > 0 && fileSize != existing.FileSize {
+		return true
+	}
+
+	stored, err := s.db.GetMovieMtime(existing.MoviePath)
+	if err != nil {
+		s.log.WithError(err).WithField("movie", existing.MoviePath).Warn("Failed to read movie modification time")
+		return false
+	}
+	if stored == 0 {
+		s.saveMovieMtime(existing.MoviePath, mtime)
+		return false
+	}
+	return mtime != 0 && mtime != stored
+}
+
+// successUnchanged reports whether a movie with a successful thumbnail and its sidecar
+// are unchanged since it was generated, so a scan can skip it without processing
+func (s *Scanner) successUnchanged(moviePath string, existing *models.Thumbnail) bool {
+	info, err := os.Stat(moviePath)
+	if err != nil {
+		return true
+	}
+	if s.movieChanged(existing, info.Size(), info.ModTime().Unix()) {
+		return false
+	}
+	var sidecarMtime int64
+	if info, err := os.Stat(ffmpeg.SidecarPath(moviePath)); err == nil {
+		sidecarMtime = info.ModTime().Unix()
+	}
+	return !s.sidecarChanged(existing, sidecarMtime)
+}
+
+// stillDownloading reports whether a movie that ffprobe failed on was modified within
+// INCOMPLETE_FILE_AGE, so it is likely still being written
+func (s *Scanner) stillDownloading(err error, modTime *time.Time) bool {
+	return s.cfg.IncompleteFileAge > 0 && modTime != nil &&
+		time.Since(*modTime) < s.cfg.IncompleteFileAge && errors.Is(err, ffmpeg.ErrProbe)
+}
+
+// recordIncomplete records a movie that can't be processed yet with the incomplete
+// status, which scans don't skip. Movies queued for deletion or archival keep their
+// status, and an empty movie already recorded as incomplete is left alone.
+func (s *Scanner) recordIncomplete(moviePath string, thumbnail, existing *models.Thumbnail, reason string) (processOutcome, error) {
+	if existing != nil {
+		switch existing.Status {
+		case models.StatusDeleted, models.StatusArchived, models.StatusDeleteFailed:
+			return outcomeSkipped, nil
+		case models.StatusIncomplete:
+			if existing.ErrorMessage == reason {
+				s.log.WithField("movie", moviePath).Debug("Movie still incomplete, skipping")
+				return outcomeSkipped, nil
+			}
+		}
+		thumbnail.ID = existing.ID
+		thumbnail.CreatedAt = existing.CreatedAt
+		thumbnail.Viewed = existing.Viewed
+	} else if s.cfg.NewFilesViewed {
+		thumbnail.Viewed = 1
+	}
+
+	thumbnail.Status = models.StatusIncomplete
+	thumbnail.ErrorMessage = reason
+	if err := s.db.UpsertThumbnail(thumbnail); err != nil {
+		s.log.WithError(err).WithField("movie", moviePath).Error("Failed to save incomplete status")
+		return outcomeError, fmt.Errorf("failed to save incomplete status for movie %s: %w", moviePath, err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"movie":  moviePath,
+		"reason": reason,
+	}).Info("Movie incomplete, checking it again next scan")
+	return outcomeSkipped, nil
+}
+
+// sidecarChanged reports whether a movie's override sidecar was added, edited or
+// removed since its thumbnail was generated
+func (s *Scanner) sidecarChanged(existing *models.Thumbnail, sidecarMtime int64) bool {
+	stored, err := s.db.GetSidecarMtime(existing.MoviePath)
+	if err != nil {
+		s.log.WithError(err).WithField("movie", existing.MoviePath).Warn("Failed to read sidecar modification time")
+		return false
+	}
+	return stored != sidecarMtime
+}
+
+// sidecarThumbnailer returns the thumbnailer applying a movie's sidecar overrides,
+// falling back to the global settings when the sidecar can't be read
+func (s *Scanner) sidecarThumbnailer(moviePath, sidecarPath string) *ffmpeg.Thumbnailer {
+	overrides, err := ffmpeg.ReadOverrides(sidecarPath)
+	if err != nil {
+		s.log.WithError(err).WithField("movie", moviePath).Warn("Ignoring thumbnail sidecar")
+		return s.thumbnailer
+	}
+	s.log.WithFields(logrus.Fields{
+		"movie":   moviePath,
+		"sidecar": sidecarPath,
+	}).Info("Applying thumbnail sidecar overrides")
+	return s.thumbnailer.WithOverrides(overrides)
+}
+
+// saveSidecarMtime records the modification time of a movie's override sidecar, or 0
+// when it has none, so a removed sidecar stops counting as a change once handled
+func (s *Scanner) saveSidecarMtime(movieFilename string, mtime int64) {
+	if err := s.db.SetSidecarMtime(movieFilename, mtime); err != nil {
+		s.log.WithError(err).WithField("movie", movieFilename).Warn("Failed to save sidecar modification time")
+	}
+}
+
+// saveMovieMtime records a movie's modification time, if it is known
+func (s *Scanner) saveMovieMtime(movieFilename string, mtime int64) {
+	if mtime == 0 {
+		return
+	}
+	if err := s.db.SetMovieMtime(movieFilename, mtime); err != nil {
+		s.log.WithError(err).WithField("movie", movieFilename).Warn("Failed to save movie modification time")
+	}
+}
+
+// saveContentHash records a movie's content hash, if it could be computed
+func (s *Scanner) saveContentHash(movieFilename, hash string) {
+	if hash == "" {
+		return
+	}
+	if err := s.db.SetContentHash(movieFilename, hash); err != nil {
+		s.log.WithError(err).WithField("movie", movieFilename).Warn("Failed to save content hash")
+	}
+}
+
+// CleanupOrphans runs the cleanup phases of CLEANUP_PHASES: by default it processes
+// the archival and deletion queues, then removes database entries for missing movies
+// and orphaned thumbnails
 func (s *Scanner) CleanupOrphans(ctx context.Context) error {
-	s.log.Info("Cleaning up orphaned entries, thumbnails, and processing deletion and archival queues")
+	return s.CleanupPhases(ctx, s.cleanupPhases()...)
+}
+
+// CleanupPhases runs the given cleanup phases, in order
+func (s *Scanner) CleanupPhases(ctx context.Context, phases ...string) error {
+	if err := config.ValidateCleanupPhases(phases); err != nil {
+		return err
+	}
+	if !s.TryAcquire(OpCleanup) {
+		return s.busyError(OpCleanup)
+	}
+	defer s.Release()
+
+	_, err := s.runCleanupPhases(ctx, phases)
+	return err
+}
 
-	// First, process items marked for archival (move to archive directory)
-	if err := s.processArchivedItems(ctx); err != nil {
-		s.log.WithError(err).Warn("Warning during archived items processing")
-		// Check if the context is done before continuing
+// cleanupPhases returns the phases of CLEANUP_PHASES, or the default ones
+func (s *Scanner) cleanupPhases() []string {
+	if len(s.cfg.CleanupPhases) == 0 {
+		return config.DefaultCleanupPhases
+	}
+	return s.cfg.CleanupPhases
+}
+
+// cleanupOrphans is CleanupOrphans, also returning the number of database entries
+// removed for missing movies
+func (s *Scanner) cleanupOrphans(ctx context.Context) (int, error) {
+	return s.runCleanupPhases(ctx, s.cleanupPhases())
+}
+
+// runCleanupPhases runs cleanup phases in order, returning the number of database
+// entries removed for missing movies. Failures processing the archival and deletion
+// queues are logged and the remaining phases still run.
+func (s *Scanner) runCleanupPhases(ctx context.Context, phases []string) (int, error) {
+	s.log.WithField("phases", strings.Join(phases, ",")).Info("Running cleanup")
+
+	var missing int
+	for _, phase := range phases {
+		// Check if the context is done before each phase
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return missing, ctx.Err()
 		default:
-			// Continue with other cleanup steps
 		}
-	}
 
-	// Second, process items marked for deletion (skip if deletion is disabled)
-	if !s.cfg.DisableDeletion {
-		if err := s.processDeletedItems(ctx); err != nil {
-			s.log.WithError(err).Error("Error processing deleted items")
-			// Check if the context is done before continuing
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			default:
-				// Continue with other cleanup steps
+		s.log.WithField("phase", phase).Debug("Running cleanup phase")
+		switch phase {
+		case config.CleanupArchive:
+			// Move items marked for archival to the archive directory
+			if err := s.processArchivedItems(ctx); err != nil {
+				s.log.WithError(err).Warn("Warning during archived items processing")
+			}
+		case config.CleanupQueue:
+			// Delete items marked for deletion (skip if deletion is disabled)
+			if s.cfg.DisableDeletion {
+				s.log.Debug("Skipping deletion processing because deletion is disabled")
+				continue
+			}
+			if _, err := s.processDeletedItems(ctx); err != nil {
+				s.log.WithError(err).Error("Error processing deleted items")
+			}
+		case config.CleanupMissing:
+			removed, err := s.removeMissingMovies(ctx)
+			missing += removed
+			if err != nil {
+				return missing, err
+			}
+		case config.CleanupOrphans:
+			// Find orphaned thumbnails (thumbnails without database entries)
+			if err := s.cleanupOrphanedThumbnails(ctx); err != nil {
+				return missing, err
 			}
 		}
-	} else {
-		s.log.Debug("Skipping deletion processing because deletion is disabled")
-	}
-
-	// Get all thumbnails from database (except deleted ones that were just processed)
-	thumbnails, err := s.db.GetAllThumbnails()
-	if err != nil {
-		return fmt.Errorf("failed to get thumbnails: %w", err)
 	}
+	return missing, nil
+}
 
-	var orphanedCount, missingCount int
+// removeMissingMovies removes the database entries and thumbnails of movies that are
+// no longer in any movie directory, returning the number of entries removed
+func (s *Scanner) removeMissingMovies(ctx context.Context) (int, error) {
+	var orphanedCount, missingCount, i int
 	var missingMoviesSize int64
-
-	// Check each thumbnail
-	for i, thumbnail := range thumbnails {
+	// Check each thumbnail, walking the table instead of loading it at once
+	err := s.db.ForEachThumbnail(ctx, func(thumbnail *models.Thumbnail) error {
 		// Periodically check for context cancellation
 		if i%100 == 0 {
 			select {
@@ -455,10 +1333,11 @@ func (s *Scanner) CleanupOrphans(ctx context.Context) error {
 				// Continue processing
 			}
 		}
+		i++
 
 		// Skip already deleted thumbnails
 		if thumbnail.Status == models.StatusDeleted {
-			continue
+			return nil
 		}
 
 		// Check if movie file exists in any volume
@@ -467,18 +1346,17 @@ func (s *Scanner) CleanupOrphans(ctx context.Context) error {
 
 			// Track metrics for missing movie
 			missingMoviesSize += thumbnail.FileSize
-			s.metrics.RecordCleanupDeletedMovie("missing_files", thumbnail.FileSize)
+			if s.metrics != nil {
+				s.metrics.RecordCleanupDeletedMovie("missing_files", thumbnail.FileSize)
+			}
 
 			// Delete the thumbnail if it exists
 			if thumbnail.ThumbnailPath != "" {
-				thumbnailPath := filepath.Join(s.cfg.ThumbnailsDir, thumbnail.ThumbnailPath)
-				if _, err := os.Stat(thumbnailPath); err == nil {
-					if err := os.Remove(thumbnailPath); err != nil {
-						s.log.WithError(err).WithField("thumbnail", thumbnailPath).Error("Failed to delete orphaned thumbnail")
-					} else {
-						s.log.WithField("thumbnail", thumbnailPath).Info("Deleted orphaned thumbnail")
-						orphanedCount++
-					}
+				if deleted, err := s.deleteThumbnailFile(ctx, thumbnail.ThumbnailPath); err != nil {
+					s.log.WithError(err).WithField("thumbnail", thumbnail.ThumbnailPath).Error("Failed to delete orphaned thumbnail")
+				} else if deleted {
+					s.log.WithField("thumbnail", thumbnail.ThumbnailPath).Info("Deleted orphaned thumbnail")
+					orphanedCount++
 				}
 			}
 
@@ -489,47 +1367,47 @@ func (s *Scanner) CleanupOrphans(ctx context.Context) error {
 				missingCount++
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return missingCount, ctx.Err()
+		}
+		return missingCount, fmt.Errorf("failed to get thumbnails: %w", err)
 	}
 
-	s.log.Infof("Cleanup completed: removed %d database entries for missing movies (total size: %d bytes) and deleted %d orphaned thumbnails", missingCount, missingMoviesSize, orphanedCount)
-
-	// Check context before continuing
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	default:
-		// Continue processing
-	}
-
-	// Find orphaned thumbnails (thumbnails without database entries)
-	return s.cleanupOrphanedThumbnails(ctx)
+	s.log.Infof("Removed %d database entries for missing movies (total size: %d bytes) and deleted %d of their thumbnails", missingCount, missingMoviesSize, orphanedCount)
+	return missingCount, nil
 }
 
 // cleanupOrphanedThumbnails removes thumbnail files that don't have database entries
 func (s *Scanner) cleanupOrphanedThumbnails(ctx context.Context) error {
-	// Get all thumbnails from the database
-	thumbnails, err := s.db.GetAllThumbnails()
-	if err != nil {
-		return fmt.Errorf("failed to get thumbnails: %w", err)
-	}
-
 	// Build a map of thumbnail filenames for quick lookup
 	thumbnailMap := make(map[string]bool)
-	for _, thumbnail := range thumbnails {
+	err := s.db.ForEachThumbnail(ctx, func(thumbnail *models.Thumbnail) error {
 		if thumbnail.ThumbnailPath != "" {
 			thumbnailMap[thumbnail.ThumbnailPath] = true
 		}
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to get thumbnails: %w", err)
 	}
 
-	// Check all files in the thumbnails directory
-	files, err := os.ReadDir(s.cfg.ThumbnailsDir)
+	// List stored thumbnails, including any in mirrored subdirectories
+	objects, err := s.storage.List(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to read thumbnails directory: %w", err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to list thumbnails: %w", err)
 	}
 
 	var orphanedCount int
-
-	for i, file := range files {
+	for i, object := range objects {
 		// Check for context cancellation periodically
 		if i%100 == 0 {
 			select {
@@ -540,23 +1418,18 @@ func (s *Scanner) cleanupOrphanedThumbnails(ctx context.Context) error {
 			}
 		}
 
-		if file.IsDir() {
-			continue
-		}
-
 		// Skip non-jpg files
-		if !strings.HasSuffix(strings.ToLower(file.Name()), ".jpg") {
+		if !strings.HasSuffix(strings.ToLower(object.Key), ".jpg") {
 			continue
 		}
 
 		// Check if file is in the database
-		if !thumbnailMap[file.Name()] {
-			s.log.WithField("thumbnail", file.Name()).Info("Orphaned thumbnail found, deleting")
+		if !thumbnailMap[filepath.FromSlash(object.Key)] {
+			s.log.WithField("thumbnail", object.Key).Info("Orphaned thumbnail found, deleting")
 
 			// Delete the file
-			thumbnailPath := filepath.Join(s.cfg.ThumbnailsDir, file.Name())
-			if err := os.Remove(thumbnailPath); err != nil {
-				s.log.WithError(err).WithField("thumbnail", thumbnailPath).Error("Failed to delete orphaned thumbnail")
+			if err := s.storage.Delete(ctx, object.Key); err != nil {
+				s.log.WithError(err).WithField("thumbnail", object.Key).Error("Failed to delete orphaned thumbnail")
 			} else {
 				orphanedCount++
 			}
@@ -567,25 +1440,54 @@ func (s *Scanner) cleanupOrphanedThumbnails(ctx context.Context) error {
 	return nil
 }
 
+// ErrDeletionInProgress is returned when the deletion queue is already being processed
+var ErrDeletionInProgress = errors.New("deletion processing already in progress")
+
+// ProcessDeletions processes only the deletion queue, without the rest of the cleanup,
+// and returns a summary of the run
+func (s *Scanner) ProcessDeletions(ctx context.Context) (DeletionSummary, error) {
+	if s.cfg.DisableDeletion {
+		return DeletionSummary{}, fmt.Errorf("deletion processing is disabled")
+	}
+	if !s.TryAcquire(OpDeletions) {
+		return s.deletions.Snapshot(), s.busyError(OpDeletions)
+	}
+	defer s.Release()
+
+	return s.processDeletedItems(ctx)
+}
+
+// DeletionStatus returns the progress of the current deletion run, or the result of the last one
+func (s *Scanner) DeletionStatus() DeletionSummary {
+	return s.deletions.Snapshot()
+}
+
 // processDeletedItems processes all items marked for deletion
-func (s *Scanner) processDeletedItems(ctx context.Context) error {
-	// Get all thumbnails marked for deletion
-	thumbnails, err := s.db.GetDeletedThumbnails(0)
+func (s *Scanner) processDeletedItems(ctx context.Context) (DeletionSummary, error) {
+	// Get thumbnails marked for deletion whose retry backoff has elapsed, and that
+	// have been approved when DELETION_REQUIRE_APPROVAL is set
+	approvedOnly := s.cfg.DeletionRequireApproval
+	thumbnails, err := s.db.GetDeletionCandidates(time.Now(), s.cfg.DeleteRetryBackoff, approvedOnly)
 	if err != nil {
-		return fmt.Errorf("failed to get deleted thumbnails: %w", err)
+		return DeletionSummary{}, fmt.Errorf("failed to get deleted thumbnails: %w", err)
+	}
+	if approvedOnly && len(thumbnails) == 0 {
+		s.log.Debug("Skipping deletion processing, no deletions approved")
+		return DeletionSummary{}, nil
 	}
 
-	s.log.Infof("Processing %d items marked for deletion", len(thumbnails))
+	if !s.deletions.Start(len(thumbnails)) {
+		return s.deletions.Snapshot(), ErrDeletionInProgress
+	}
 
-	var deletedCount int
-	var deletedSize int64
+	s.log.Infof("Processing %d items marked for deletion", len(thumbnails))
 
 	for i, thumbnail := range thumbnails {
 		// Check for context cancellation periodically
 		if i%10 == 0 {
 			select {
 			case <-ctx.Done():
-				return ctx.Err()
+				return s.deletions.Finish(), ctx.Err()
 			default:
 				// Continue processing
 			}
@@ -593,36 +1495,50 @@ func (s *Scanner) processDeletedItems(ctx context.Context) error {
 
 		// Delete the thumbnail file if it exists
 		if thumbnail.ThumbnailPath != "" {
-			thumbnailPath := filepath.Join(s.cfg.ThumbnailsDir, thumbnail.ThumbnailPath)
-			if _, err := os.Stat(thumbnailPath); err == nil {
-				if err := os.Remove(thumbnailPath); err != nil {
-					s.log.WithError(err).WithField("thumbnail", thumbnailPath).Error("Failed to delete thumbnail file")
-				} else {
-					s.log.WithField("thumbnail", thumbnailPath).Info("Deleted thumbnail file")
-				}
+			if deleted, err := s.deleteThumbnailFile(ctx, thumbnail.ThumbnailPath); err != nil {
+				s.log.WithError(err).WithField("thumbnail", thumbnail.ThumbnailPath).Error("Failed to delete thumbnail file")
+			} else if deleted {
+				s.log.WithField("thumbnail", thumbnail.ThumbnailPath).Info("Deleted thumbnail file")
 			}
 		}
 
 		// Delete the movie file from every volume where it exists
 		moviePaths := s.resolveMoviePaths(thumbnail.MoviePath)
-		deleteErr := false
+		var deleteErrs []error
 		for _, fullMoviePath := range moviePaths {
 			if err := os.Remove(fullMoviePath); err != nil {
 				s.log.WithError(err).WithField("movie", fullMoviePath).Error("Failed to delete movie file")
-				deleteErr = true
+				deleteErrs = append(deleteErrs, err)
 			} else {
 				s.log.WithField("movie", fullMoviePath).Info("Deleted movie file")
 			}
 		}
-		if deleteErr {
-			// Don't remove from database on error so we can retry later
+		if len(deleteErrs) > 0 {
+			// Don't remove from database on error so we can retry later, after a backoff
+			attempts, err := s.db.RecordDeleteFailure(thumbnail.ID, time.Now(), s.cfg.DeleteMaxAttempts)
+			if err != nil {
+				s.log.WithError(err).WithField("movie", thumbnail.MoviePath).Error("Failed to record deletion failure")
+			}
+			s.deletions.Failed(thumbnail.MoviePath, errors.Join(deleteErrs...), attempts)
+			if s.cfg.DeleteMaxAttempts > 0 && attempts >= s.cfg.DeleteMaxAttempts {
+				s.log.WithFields(logrus.Fields{
+					"movie":    thumbnail.MoviePath,
+					"attempts": attempts,
+				}).Warn("Movie failed deletion too many times, marked as delete_failed for manual intervention")
+			} else if attempts >= repeatedFailureThreshold {
+				s.log.WithFields(logrus.Fields{
+					"movie":    thumbnail.MoviePath,
+					"attempts": attempts,
+				}).Warn("Movie has repeatedly failed deletion and remains queued")
+			}
 			continue
 		}
 
 		// Track metrics for successfully deleted movie
-		deletedCount++
-		deletedSize += thumbnail.FileSize
-		s.metrics.RecordCleanupDeletedMovie("deletion_queue", thumbnail.FileSize)
+		s.deletions.Deleted(thumbnail.MoviePath, thumbnail.FileSize)
+		if s.metrics != nil {
+			s.metrics.RecordCleanupDeletedMovie("deletion_queue", thumbnail.FileSize)
+		}
 
 		// Remove from database
 		if err := s.db.DeleteThumbnail(thumbnail.MoviePath); err != nil {
@@ -630,8 +1546,10 @@ func (s *Scanner) processDeletedItems(ctx context.Context) error {
 		}
 	}
 
-	s.log.Infof("Deleted %d movies with total size of %d bytes from deletion queue", deletedCount, deletedSize)
-	return nil
+	summary := s.deletions.Finish()
+	s.log.Infof("Deleted %d movies with total size of %d bytes from deletion queue (%d failed)",
+		summary.Deleted, summary.ReclaimedBytes, summary.Failed)
+	return summary, nil
 }
 
 // processArchivedItems processes all items marked for archival
@@ -666,7 +1584,7 @@ func (s *Scanner) processArchivedItems(ctx context.Context) error {
 
 		// Resolve all physical copies of this movie across volumes
 		allPaths := s.resolveMoviePaths(thumbnail.MoviePath)
-		archivePath := filepath.Join(s.cfg.ArchiveDir, thumbnail.MoviePath)
+		archivePath := filepath.Join(s.cfg.ArchiveDir, filepath.FromSlash(thumbnail.MoviePath))
 
 		if len(allPaths) == 0 {
 			s.log.WithField("movie", thumbnail.MoviePath).Warn("Movie file does not exist in any volume, removing from database")
@@ -723,20 +1641,19 @@ func (s *Scanner) processArchivedItems(ctx context.Context) error {
 
 		// Delete the thumbnail file if it exists
 		if thumbnail.ThumbnailPath != "" {
-			thumbnailPath := filepath.Join(s.cfg.ThumbnailsDir, thumbnail.ThumbnailPath)
-			if _, err := os.Stat(thumbnailPath); err == nil {
-				if err := os.Remove(thumbnailPath); err != nil {
-					s.log.WithError(err).WithField("thumbnail", thumbnailPath).Error("Failed to delete thumbnail file")
-				} else {
-					s.log.WithField("thumbnail", thumbnailPath).Info("Deleted thumbnail file")
-				}
+			if deleted, err := s.deleteThumbnailFile(ctx, thumbnail.ThumbnailPath); err != nil {
+				s.log.WithError(err).WithField("thumbnail", thumbnail.ThumbnailPath).Error("Failed to delete thumbnail file")
+			} else if deleted {
+				s.log.WithField("thumbnail", thumbnail.ThumbnailPath).Info("Deleted thumbnail file")
 			}
 		}
 
 		// Track metrics for successfully archived movie
 		archivedCount++
 		archivedSize += thumbnail.FileSize
-		s.metrics.RecordCleanupDeletedMovie("archival_queue", thumbnail.FileSize)
+		if s.metrics != nil {
+			s.metrics.RecordCleanupDeletedMovie("archival_queue", thumbnail.FileSize)
+		}
 
 		// Remove from database
 		if err := s.db.DeleteThumbnail(thumbnail.MoviePath); err != nil {
@@ -782,10 +1699,18 @@ func (s *Scanner) copyFile(src, dst string) error {
 	return nil
 }
 
-// ResetViewedStatus resets the viewed status of all thumbnails
-func (s *Scanner) ResetViewedStatus() (int64, error) {
-	s.log.Info("Resetting viewed status for all thumbnails")
-	count, err := s.db.ResetViewedStatus()
+// ResetViewedStatus resets the viewed status of the thumbnails matching filter;
+// an empty filter resets all thumbnails
+func (s *Scanner) ResetViewedStatus(filter database.ResetFilter) (int64, error) {
+	var count int64
+	var err error
+	if filter.IsEmpty() && !filter.ClearHistory {
+		s.log.Info("Resetting viewed status for all thumbnails")
+		count, err = s.db.ResetViewedStatus()
+	} else {
+		s.log.WithField("filter", fmt.Sprintf("%+v", filter)).Info("Resetting viewed status for filtered thumbnails")
+		count, err = s.db.ResetViewedStatusFiltered(filter)
+	}
 	if err != nil {
 		return 0, fmt.Errorf("failed to reset viewed status: %w", err)
 	}
@@ -803,7 +1728,7 @@ func (s *Scanner) DeleteMovie(ctx context.Context, moviePath string) error {
 	s.log.WithField("movie", moviePath).Info("Deleting movie and thumbnail")
 
 	// Get the thumbnail record
-	thumbnail, err := s.db.GetByMoviePath(filepath.Base(moviePath))
+	thumbnail, err := s.db.GetByMoviePath(s.thumbnailer.MovieKey(moviePath))
 	if err != nil {
 		return fmt.Errorf("failed to get thumbnail: %w", err)
 	}
@@ -822,13 +1747,10 @@ func (s *Scanner) DeleteMovie(ctx context.Context, moviePath string) error {
 
 	// Delete the thumbnail file if it exists
 	if thumbnail.ThumbnailPath != "" {
-		thumbnailPath := filepath.Join(s.cfg.ThumbnailsDir, thumbnail.ThumbnailPath)
-		if _, err := os.Stat(thumbnailPath); err == nil {
-			if err := os.Remove(thumbnailPath); err != nil {
-				s.log.WithError(err).WithField("thumbnail", thumbnailPath).Error("Failed to delete thumbnail file")
-			} else {
-				s.log.WithField("thumbnail", thumbnailPath).Info("Deleted thumbnail file")
-			}
+		if deleted, err := s.deleteThumbnailFile(ctx, thumbnail.ThumbnailPath); err != nil {
+			s.log.WithError(err).WithField("thumbnail", thumbnail.ThumbnailPath).Error("Failed to delete thumbnail file")
+		} else if deleted {
+			s.log.WithField("thumbnail", thumbnail.ThumbnailPath).Info("Deleted thumbnail file")
 		}
 	}
 
@@ -2,12 +2,23 @@ package scanner
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pandino/movie-thumbnailer-go/internal/config"
+	"github.com/pandino/movie-thumbnailer-go/internal/database"
+	"github.com/pandino/movie-thumbnailer-go/internal/ffmpeg"
+	"github.com/pandino/movie-thumbnailer-go/internal/metrics"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 )
 
@@ -18,7 +29,7 @@ func newTestScanner(dirs []string) *Scanner {
 	}
 	log := logrus.New()
 	log.SetOutput(os.Stderr)
-	return &Scanner{cfg: cfg, log: log}
+	return &Scanner{cfg: cfg, log: log, thumbnailer: ffmpeg.New(cfg, log, nil, nil)}
 }
 
 func TestResolveMoviePaths(t *testing.T) {
@@ -53,6 +64,45 @@ func TestResolveMoviePaths(t *testing.T) {
 	})
 }
 
+func TestBackfillFileSizesSkipsUnresolvedMovies(t *testing.T) {
+	movieDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(movieDir, "present.mp4"), []byte("movie"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, name := range []string{"present.mp4", "missing.mp4"} {
+		if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: name, MovieFilename: name, Status: models.StatusSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := newTestScanner([]string{movieDir})
+	s.db = db
+	s.log.SetOutput(io.Discard)
+	if err := s.backfillFileSizes(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.GetByMoviePath("present.mp4"); got.FileSize != int64(len("movie")) {
+		t.Errorf("present movie size = %d, want %d", got.FileSize, len("movie"))
+	}
+
+	// A movie missing from every volume is not looked for again until a restart
+	if err := os.WriteFile(filepath.Join(movieDir, "missing.mp4"), []byte("movie"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.backfillFileSizes(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.GetByMoviePath("missing.mp4"); got.FileSize != 0 {
+		t.Errorf("unresolved movie size = %d, want it skipped", got.FileSize)
+	}
+}
+
 func TestFindMovieFiles_Deduplication(t *testing.T) {
 	dir1 := t.TempDir()
 	dir2 := t.TempDir()
@@ -108,11 +158,1473 @@ func TestFindMovieFiles_MissingDirSkipped(t *testing.T) {
 	}
 }
 
-func touch(t *testing.T, path string) {
-	t.Helper()
-	f, err := os.Create(path)
+func TestDiscoverMovies(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < readDirBatch+10; i++ {
+		touch(t, filepath.Join(dir, fmt.Sprintf("movie-%03d.mp4", i)))
+	}
+	touch(t, filepath.Join(dir, "notes.txt"))
+
+	s := newTestScanner([]string{dir})
+	discovery := s.discoverMovies(context.Background())
+
+	var got int
+	for range discovery.files {
+		got++
+	}
+	<-discovery.done
+
+	if discovery.err != nil {
+		t.Fatal(discovery.err)
+	}
+	if want := readDirBatch + 10; got != want || discovery.Total() != want {
+		t.Errorf("received %d files, total %d, want %d", got, discovery.Total(), want)
+	}
+}
+
+func TestWalkMovieDirExcludesDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"Action/2020", "@eaDir/movie.mp4", "Action/@eaDir", "sample", ".Trash-1000"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{
+		"top.mp4",
+		"Action/film.mkv",
+		"Action/2020/new.mp4",
+		"Action/notes.txt",
+		"@eaDir/movie.mp4/SYNOVIDEO.mp4",
+		"Action/@eaDir/film.mkv",
+		"sample/sample.mp4",
+		".Trash-1000/deleted.mp4",
+	} {
+		touch(t, filepath.Join(root, file))
+	}
+
+	s := newTestScanner([]string{root})
+	s.cfg.ScanExcludeDirs = []string{"@eaDir", "sample", ".Trash-*"}
+
+	walk := func(recursive bool) []string {
+		var found []string
+		err := s.walkMovieDir(context.Background(), root, recursive, func(moviePath string) {
+			rel, _ := filepath.Rel(root, moviePath)
+			found = append(found, filepath.ToSlash(rel))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(found)
+		return found
+	}
+
+	if got, want := walk(true), []string{"Action/2020/new.mp4", "Action/film.mkv", "top.mp4"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("recursive walk: expected %v, got %v", want, got)
+	}
+	if got, want := walk(false), []string{"top.mp4"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("flat walk: expected %v, got %v", want, got)
+	}
+}
+
+func TestSortNewestFirst(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	ages := map[string]time.Duration{"old.mp4": 48 * time.Hour, "new.mp4": time.Minute, "mid.mp4": time.Hour}
+	for name, age := range ages {
+		p := filepath.Join(dir, name)
+		touch(t, p)
+		if err := os.Chtimes(p, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := []string{
+		filepath.Join(dir, "old.mp4"),
+		filepath.Join(dir, "missing.mp4"),
+		filepath.Join(dir, "new.mp4"),
+		filepath.Join(dir, "mid.mp4"),
+	}
+	newTestScanner([]string{dir}).sortNewestFirst(files)
+
+	var got []string
+	for _, f := range files {
+		got = append(got, filepath.Base(f))
+	}
+	want := []string{"new.mp4", "mid.mp4", "old.mp4", "missing.mp4"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestProcessMovieNewFilesViewed(t *testing.T) {
+	for _, newFilesViewed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("NEW_FILES_VIEWED=%v", newFilesViewed), func(t *testing.T) {
+			movieDir := t.TempDir()
+			thumbDir := t.TempDir()
+			moviePath := filepath.Join(movieDir, "fresh.mp4")
+			writeMovie(t, moviePath)
+
+			db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			cfg := &config.Config{
+				MoviesDirs:     []string{movieDir},
+				ThumbnailsDir:  thumbDir,
+				FileExtensions: []string{"mp4"},
+				NewFilesViewed: newFilesViewed,
+			}
+			log := logrus.New()
+			log.SetOutput(io.Discard)
+			s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+
+			// The file can't be thumbnailed, but the record is still saved
+			s.processMovie(context.Background(), moviePath, 0, 1, ScanOptions{})
+
+			thumb, err := db.GetByMoviePath("fresh.mp4")
+			if err != nil || thumb == nil {
+				t.Fatalf("expected a record for the new file: %v", err)
+			}
+			want := 0
+			if newFilesViewed {
+				want = 1
+			}
+			if thumb.Viewed != want {
+				t.Errorf("viewed = %d, want %d", thumb.Viewed, want)
+			}
+		})
+	}
+}
+
+func TestScanRetriesInterruptedThumbnails(t *testing.T) {
+	movieDir := t.TempDir()
+	thumbDir := t.TempDir()
+	writeMovie(t, filepath.Join(movieDir, "stuck.mp4"))
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
 	if err != nil {
 		t.Fatal(err)
 	}
-	f.Close()
+	defer db.Close()
+
+	// A previous run crashed while generating this thumbnail
+	if err := db.UpsertThumbnail(&models.Thumbnail{
+		MoviePath:     "stuck.mp4",
+		MovieFilename: "stuck.mp4",
+		ThumbnailPath: "stuck.jpg",
+		Status:        models.StatusPending,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ResetPendingToRetry(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		MoviesDirs:     []string{movieDir},
+		ThumbnailsDir:  thumbDir,
+		FileExtensions: []string{"mp4"},
+		MaxWorkers:     1,
+		GridCols:       2,
+		GridRows:       2,
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+
+	if _, err := s.ScanMovies(context.Background(), ScanTypeManual); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file can't be thumbnailed, but the scan must have retried it
+	thumb, err := db.GetByMoviePath("stuck.mp4")
+	if err != nil || thumb == nil {
+		t.Fatalf("record missing after scan: %v", err)
+	}
+	if thumb.Status == models.StatusPending {
+		t.Errorf("interrupted row was not retried: %s %q", thumb.Status, thumb.ErrorMessage)
+	}
+}
+
+func TestScanResultTallies(t *testing.T) {
+	movieDir := t.TempDir()
+	thumbDir := t.TempDir()
+	for _, name := range []string{"broken.mp4", "known.mp4", "reused.mp4"} {
+		if err := os.WriteFile(filepath.Join(movieDir, name), []byte("not a movie: "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(thumbDir, "reused.jpg"), []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, name := range []string{"known.mp4", "gone.mp4"} {
+		if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: name, MovieFilename: name, Status: models.StatusSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		MoviesDirs:              []string{movieDir},
+		ThumbnailsDir:           thumbDir,
+		FileExtensions:          []string{"mp4"},
+		MaxWorkers:              2,
+		GridCols:                2,
+		GridRows:                2,
+		ReuseExistingThumbnails: true,
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+
+	result, err := s.ScanMovies(context.Background(), ScanTypeManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// known.mp4 is skipped, reused.mp4 is recorded from its existing thumbnail, broken.mp4
+	// fails to generate and gone.mp4 is no longer on disk
+	want := ScanResult{Type: ScanTypeManual, Processed: 2, Imported: 1, Errors: 1, Skipped: 1, MissingRemoved: 1}
+	result.Duration = 0
+	if result != want {
+		t.Errorf("ScanMovies() = %+v, want %+v", result, want)
+	}
+}
+
+func TestScanTypeLabel(t *testing.T) {
+	movieDir, thumbDir := t.TempDir(), t.TempDir()
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{
+		MoviesDirs:     []string{movieDir},
+		ThumbnailsDir:  thumbDir,
+		FileExtensions: []string{"mp4"},
+		MaxWorkers:     1,
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	m := metrics.New()
+	s := New(cfg, db, storage.NewLocal(thumbDir), log, m)
+
+	result, err := s.ScanMoviesWithOptions(context.Background(), ScanOptions{ImportExisting: true, Type: ScanTypeImport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Type != ScanTypeImport {
+		t.Errorf("result type = %q, want %q", result.Type, ScanTypeImport)
+	}
+	if got := testutil.ToFloat64(m.ScanOperationsTotal.WithLabelValues(ScanTypeImport, "success")); got != 1 {
+		t.Errorf("import scans = %v, want 1", got)
+	}
+
+	// Scans without a type count as manual
+	if result, err := s.ScanMoviesWithOptions(context.Background(), ScanOptions{}); err != nil || result.Type != ScanTypeManual {
+		t.Errorf("ScanMoviesWithOptions() = %+v, %v; want a manual scan", result, err)
+	}
+	if got := testutil.ToFloat64(m.ScanOperationsTotal.WithLabelValues(ScanTypeManual, "success")); got != 1 {
+		t.Errorf("manual scans = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.ScanMoviesTotal.WithLabelValues(ScanTypeImport, "generated")); got != 0 {
+		t.Errorf("movies generated by import scans = %v, want 0", got)
+	}
+}
+
+func TestRecursiveScanKeepsSameNamedMoviesApart(t *testing.T) {
+	movieDir, thumbDir := t.TempDir(), t.TempDir()
+	for _, dir := range []string{"Action", "Drama/2020"} {
+		if err := os.MkdirAll(filepath.Join(movieDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"top.mp4", "Action/film.mp4", "Drama/2020/film.mp4"} {
+		writeMovie(t, filepath.Join(movieDir, name))
+	}
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{
+		MoviesDirs:      []string{movieDir},
+		ThumbnailsDir:   thumbDir,
+		FileExtensions:  []string{"mp4"},
+		MaxWorkers:      1,
+		GridCols:        2,
+		GridRows:        2,
+		MirrorStructure: true,
+		RecursiveScan:   true,
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+
+	// Generation fails without ffmpeg, recording one error row per movie
+	result, err := s.ScanMovies(context.Background(), ScanTypeManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Processed != 3 {
+		t.Errorf("processed %d movies, want 3", result.Processed)
+	}
+
+	for _, moviePath := range []string{"top.mp4", "Action/film.mp4", "Drama/2020/film.mp4"} {
+		thumbnail, err := db.GetByMoviePath(moviePath)
+		if err != nil || thumbnail == nil {
+			t.Fatalf("GetByMoviePath(%q) = %v, %v; want a row", moviePath, thumbnail, err)
+		}
+		if thumbnail.MovieFilename != filepath.Base(moviePath) {
+			t.Errorf("%s: movie filename = %q, want %q", moviePath, thumbnail.MovieFilename, filepath.Base(moviePath))
+		}
+		if len(s.resolveMoviePaths(thumbnail.MoviePath)) != 1 {
+			t.Errorf("%s: not resolved on disk", moviePath)
+		}
+	}
+	if thumbnail, err := db.GetByMoviePath("film.mp4"); err != nil || thumbnail != nil {
+		t.Errorf("GetByMoviePath(film.mp4) = %v, %v; want no row keyed by the bare name", thumbnail, err)
+	}
+}
+
+func TestScanContinuesPastFailingMovies(t *testing.T) {
+	movieDir := t.TempDir()
+	thumbDir := t.TempDir()
+	var good []string
+	for i := 0; i < 10; i++ {
+		// The broken movies sort, and so are processed, before the good ones
+		if err := os.WriteFile(filepath.Join(movieDir, fmt.Sprintf("a-broken%d.mp4", i)), []byte("not a movie"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		name := fmt.Sprintf("b-good%d", i)
+		if err := os.WriteFile(filepath.Join(movieDir, name+".mp4"), []byte("movie"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(thumbDir, name+".jpg"), []byte("jpeg"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		good = append(good, name+".mp4")
+	}
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{
+		MoviesDirs:              []string{movieDir},
+		ThumbnailsDir:           thumbDir,
+		FileExtensions:          []string{"mp4"},
+		MaxWorkers:              1,
+		GridCols:                2,
+		GridRows:                2,
+		ReuseExistingThumbnails: true,
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+
+	result, err := s.ScanMovies(context.Background(), ScanTypeManual)
+	if err != nil {
+		t.Fatalf("ScanMovies() error = %v", err)
+	}
+	if result.Errors != 10 || result.Imported != 10 {
+		t.Errorf("ScanMovies() = %+v, want 10 errors and 10 imported", result)
+	}
+	for _, name := range good {
+		thumbnail, err := db.GetByMoviePath(name)
+		if err != nil || thumbnail == nil || thumbnail.Status != models.StatusSuccess {
+			t.Errorf("%s was not processed after the failures: %+v, %v", name, thumbnail, err)
+		}
+	}
+}
+
+func TestScanStrictMode(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strict=%v", strict), func(t *testing.T) {
+			movieDir := t.TempDir()
+			thumbDir := t.TempDir()
+			for _, name := range []string{"broken1.mp4", "broken2.mp4", "broken3.mp4"} {
+				if err := os.WriteFile(filepath.Join(movieDir, name), []byte("not a movie"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			cfg := &config.Config{
+				MoviesDirs:     []string{movieDir},
+				ThumbnailsDir:  thumbDir,
+				FileExtensions: []string{"mp4"},
+				MaxWorkers:     1,
+				GridCols:       2,
+				GridRows:       2,
+				StrictScan:     strict,
+			}
+			log := logrus.New()
+			log.SetOutput(io.Discard)
+			s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+
+			result, err := s.ScanMovies(context.Background(), ScanTypeManual)
+			if strict {
+				// The first failure aborts the scan
+				if err == nil {
+					t.Fatalf("ScanMovies() = %+v, want an error", result)
+				}
+				if result.Errors < 1 || result.Errors > 2 {
+					t.Errorf("errors = %d, want the scan to stop after the first failure", result.Errors)
+				}
+				return
+			}
+
+			// Every movie is attempted and recorded as failed
+			if err != nil {
+				t.Fatalf("ScanMovies() error = %v", err)
+			}
+			if result.Errors != 3 {
+				t.Errorf("errors = %d, want 3", result.Errors)
+			}
+			thumbnails, err := db.GetAllThumbnails()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, thumbnail := range thumbnails {
+				if thumbnail.Status != models.StatusError {
+					t.Errorf("%s: status %s, want %s", thumbnail.MovieFilename, thumbnail.Status, models.StatusError)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessMovieAdoptsRenamedThumbnail(t *testing.T) {
+	movieDir := t.TempDir()
+	thumbDir := t.TempDir()
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{
+		MoviesDirs:     []string{movieDir},
+		ThumbnailsDir:  thumbDir,
+		FileExtensions: []string{"mp4"},
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+
+	// The movie was thumbnailed under its old name, then renamed on disk
+	content := make([]byte, 3*hashChunkSize)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	newPath := filepath.Join(movieDir, "new.mp4")
+	if err := os.WriteFile(newPath, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := partialHash(newPath)
+	if err != nil || hash == "" {
+		t.Fatalf("partialHash = %q, %v", hash, err)
+	}
+	if err := os.WriteFile(filepath.Join(thumbDir, "old.jpg"), []byte("grid"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := &models.Thumbnail{
+		MoviePath:     "old.mp4",
+		MovieFilename: "old.mp4",
+		ThumbnailPath: "old.jpg",
+		Status:        models.StatusSuccess,
+		Viewed:        1,
+	}
+	if err := db.UpsertThumbnail(old); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetContentHash("old.mp4", hash); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.processMovie(context.Background(), newPath, 0, 1, ScanOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	thumb, err := db.GetByMoviePath("new.mp4")
+	if err != nil || thumb == nil {
+		t.Fatalf("expected the record to follow the rename: %v", err)
+	}
+	if thumb.ID != old.ID || thumb.ThumbnailPath != "new.jpg" || thumb.Status != models.StatusSuccess || thumb.Viewed != 1 {
+		t.Errorf("unexpected record after rename: %+v", thumb)
+	}
+	if got, _ := db.GetByMoviePath("old.mp4"); got != nil {
+		t.Error("old record still present")
+	}
+	if data, err := os.ReadFile(filepath.Join(thumbDir, "new.jpg")); err != nil || string(data) != "grid" {
+		t.Errorf("thumbnail not moved: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(thumbDir, "old.jpg")); !os.IsNotExist(err) {
+		t.Errorf("old thumbnail file still present: %v", err)
+	}
+}
+
+func TestScanBackfillsContentHashes(t *testing.T) {
+	movieDir := t.TempDir()
+	thumbDir := t.TempDir()
+	content := make([]byte, 3*hashChunkSize)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	oldPath := filepath.Join(movieDir, "old.mp4")
+	if err := os.WriteFile(oldPath, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(thumbDir, "old.jpg"), []byte("grid"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// Thumbnailed before content hashes were recorded
+	if err := db.UpsertThumbnail(&models.Thumbnail{
+		MoviePath:     "old.mp4",
+		MovieFilename: "old.mp4",
+		ThumbnailPath: "old.jpg",
+		Status:        models.StatusSuccess,
+		FileSize:      int64(len(content)),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		MoviesDirs:     []string{movieDir},
+		ThumbnailsDir:  thumbDir,
+		FileExtensions: []string{"mp4"},
+		MaxWorkers:     1,
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+	if _, err := s.ScanMovies(context.Background(), ScanTypeManual); err != nil {
+		t.Fatal(err)
+	}
+
+	// Renamed after the scan hashed it: the thumbnail is reused
+	if err := os.Rename(oldPath, filepath.Join(movieDir, "new.mp4")); err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.ScanMovies(context.Background(), ScanTypeManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Imported != 1 || result.Errors != 0 {
+		t.Errorf("scan after rename: %+v, want the thumbnail reused", result)
+	}
+	if got, _ := db.GetByMoviePath("new.mp4"); got == nil || got.ThumbnailPath != "new.jpg" {
+		t.Errorf("renamed movie record = %+v, want the moved thumbnail", got)
+	}
+}
+
+func TestProcessMovieReusesExistingThumbnail(t *testing.T) {
+	movieDir := t.TempDir()
+	thumbDir := t.TempDir()
+	writeMovie(t, filepath.Join(movieDir, "kept.mp4"))
+	writeMovie(t, filepath.Join(movieDir, "empty.mp4"))
+	if err := os.WriteFile(filepath.Join(thumbDir, "kept.jpg"), []byte("grid"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, filepath.Join(thumbDir, "empty.jpg"))
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{
+		MoviesDirs:              []string{movieDir},
+		ThumbnailsDir:           thumbDir,
+		FileExtensions:          []string{"mp4"},
+		ReuseExistingThumbnails: true,
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+
+	for _, name := range []string{"kept.mp4", "empty.mp4"} {
+		s.processMovie(context.Background(), filepath.Join(movieDir, name), 0, 2, ScanOptions{})
+	}
+
+	kept, err := db.GetByMoviePath("kept.mp4")
+	if err != nil || kept == nil {
+		t.Fatalf("expected a record for the reused thumbnail: %v", err)
+	}
+	if kept.Status != models.StatusSuccess || kept.Source != models.SourceImported || kept.ThumbnailPath != "kept.jpg" {
+		t.Errorf("unexpected reused record: %+v", kept)
+	}
+	if data, _ := os.ReadFile(filepath.Join(thumbDir, "kept.jpg")); string(data) != "grid" {
+		t.Errorf("reused thumbnail was rewritten: %q", data)
+	}
+
+	// Without a probe there is no creation_time, so the recording date is the mtime
+	info, err := os.Stat(filepath.Join(movieDir, "kept.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept.RecordedAt == nil || !kept.RecordedAt.Equal(info.ModTime().Truncate(time.Second)) {
+		t.Errorf("recorded_at = %v, want the mtime %v", kept.RecordedAt, info.ModTime())
+	}
+
+	// An empty file is not a usable thumbnail, so generation is attempted and fails here
+	empty, err := db.GetByMoviePath("empty.mp4")
+	if err != nil || empty == nil {
+		t.Fatalf("expected a record for the empty thumbnail: %v", err)
+	}
+	if empty.Status == models.StatusSuccess {
+		t.Errorf("empty thumbnail file was reused: %+v", empty)
+	}
+}
+
+func TestPartialHash(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	a, _ := partialHash(write("a.mp4", []byte("same content")))
+	b, _ := partialHash(write("b.mp4", []byte("same content")))
+	c, _ := partialHash(write("c.mp4", []byte("other content")))
+	empty, _ := partialHash(write("empty.mp4", nil))
+
+	if a == "" || a != b {
+		t.Errorf("identical files hash differently: %q %q", a, b)
+	}
+	if a == c {
+		t.Error("different files share a hash")
+	}
+	if empty != "" {
+		t.Errorf("empty file hash = %q, want none", empty)
+	}
+}
+
+func touch(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
+
+// writeMovie creates a movie file that isn't empty but can't be probed, so its
+// generation fails
+func writeMovie(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("not a movie"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newBenchmarkDir creates a flat directory of n movie files plus as many other files
+func newBenchmarkDir(b *testing.B, n int) string {
+	b.Helper()
+	dir := b.TempDir()
+	for i := 0; i < n; i++ {
+		for _, ext := range []string{"mp4", "nfo"} {
+			f, err := os.Create(filepath.Join(dir, fmt.Sprintf("movie-%06d.%s", i, ext)))
+			if err != nil {
+				b.Fatal(err)
+			}
+			f.Close()
+		}
+	}
+	return dir
+}
+
+func BenchmarkFindMovieFiles(b *testing.B) {
+	s := newTestScanner([]string{newBenchmarkDir(b, 20000)})
+	s.log.SetOutput(io.Discard)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		files, err := s.findMovieFiles(context.Background())
+		if err != nil || len(files) != 20000 {
+			b.Fatalf("found %d files: %v", len(files), err)
+		}
+	}
+}
+
+// BenchmarkFirstMovieFile measures how long a scan waits before it can start on the
+// first movie of a huge directory
+func BenchmarkFirstMovieFile(b *testing.B) {
+	s := newTestScanner([]string{newBenchmarkDir(b, 20000)})
+	s.log.SetOutput(io.Discard)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		discovery := s.discoverMovies(ctx)
+		if _, ok := <-discovery.files; !ok {
+			b.Fatal("no movie file found")
+		}
+		cancel()
+		<-discovery.done
+	}
+}
+
+func TestProcessMovieSkipsRootOverQuota(t *testing.T) {
+	fullDir := t.TempDir()
+	otherDir := t.TempDir()
+	thumbDir := t.TempDir()
+	writeMovie(t, filepath.Join(fullDir, "kept.mp4"))
+	writeMovie(t, filepath.Join(fullDir, "new.mp4"))
+	writeMovie(t, filepath.Join(otherDir, "other.mp4"))
+	if err := os.WriteFile(filepath.Join(thumbDir, "kept.jpg"), []byte("grid"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{
+		MoviesDirs:              []string{fullDir, otherDir},
+		ThumbnailsDir:           thumbDir,
+		FileExtensions:          []string{"mp4"},
+		ReuseExistingThumbnails: true,
+		ThumbnailQuotaPerRoot:   4,
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+
+	for _, path := range []string{
+		filepath.Join(fullDir, "kept.mp4"),
+		filepath.Join(fullDir, "new.mp4"),
+		filepath.Join(otherDir, "other.mp4"),
+	} {
+		s.processMovie(context.Background(), path, 0, 3, ScanOptions{})
+	}
+
+	if used, _, _ := db.GetRootThumbnailUsage(fullDir); used != 4 {
+		t.Errorf("expected the reused thumbnail to count 4 bytes, got %d", used)
+	}
+	if skipped, _ := db.GetByMoviePath("new.mp4"); skipped != nil {
+		t.Errorf("movie of a directory over quota was processed: %+v", skipped)
+	}
+	if other, _ := db.GetByMoviePath("other.mp4"); other == nil {
+		t.Error("movie of a directory under quota was not processed")
+	}
+}
+
+func TestReserveQuota(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, name := range []string{"a.mp4", "b.mp4"} {
+		if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: name, MovieFilename: name, Status: models.StatusSuccess}); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.SetRootUsage(name, "/movies", 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := newTestScanner([]string{"/movies"})
+	s.db = db
+	s.cfg.ThumbnailQuotaPerRoot = 6
+	s.log.SetOutput(io.Discard)
+
+	// 4 bytes are stored, and a running generation reserves the 2-byte average
+	release, ok := s.reserveQuota("/movies/c.mp4", "/movies")
+	if !ok {
+		t.Fatal("first generation was refused under the quota")
+	}
+	if _, ok := s.reserveQuota("/movies/d.mp4", "/movies"); ok {
+		t.Error("concurrent generation was allowed past the quota")
+	}
+	release()
+	if _, ok := s.reserveQuota("/movies/d.mp4", "/movies"); !ok {
+		t.Error("generation was refused after the reservation was released")
+	}
+}
+
+func TestProcessMovieClearsThumbnailOnError(t *testing.T) {
+	for _, clear := range []bool{true, false} {
+		t.Run(fmt.Sprintf("clear=%v", clear), func(t *testing.T) {
+			movieDir := t.TempDir()
+			thumbDir := t.TempDir()
+			writeMovie(t, filepath.Join(movieDir, "broken.mp4")) // Not a movie, so generation fails
+			stale := filepath.Join(thumbDir, "old", "broken.jpg")
+			if err := os.MkdirAll(filepath.Dir(stale), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(stale, []byte("grid"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			if err := db.UpsertThumbnail(&models.Thumbnail{
+				MoviePath:     "broken.mp4",
+				MovieFilename: "broken.mp4",
+				ThumbnailPath: "old/broken.jpg",
+				Status:        models.StatusSuccess,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := &config.Config{
+				MoviesDirs:            []string{movieDir},
+				ThumbnailsDir:         thumbDir,
+				FileExtensions:        []string{"mp4"},
+				ClearThumbnailOnError: clear,
+			}
+			log := logrus.New()
+			log.SetOutput(io.Discard)
+			s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+
+			if _, err := s.processMovie(context.Background(), filepath.Join(movieDir, "broken.mp4"), 0, 1, ScanOptions{}); err == nil {
+				t.Fatal("expected generation of a broken movie to fail")
+			}
+
+			got, err := db.GetByMoviePath("broken.mp4")
+			if err != nil || got == nil {
+				t.Fatalf("record not found: %v", err)
+			}
+			if got.Status != models.StatusError {
+				t.Errorf("status = %q, want error", got.Status)
+			}
+			_, statErr := os.Stat(stale)
+			if clear {
+				if !os.IsNotExist(statErr) {
+					t.Errorf("stale thumbnail was kept: %v", statErr)
+				}
+				if got.ThumbnailPath != "" {
+					t.Errorf("thumbnail path = %q, want it cleared", got.ThumbnailPath)
+				}
+			} else if statErr != nil {
+				t.Errorf("last good thumbnail was removed: %v", statErr)
+			}
+		})
+	}
+}
+
+func TestScanRegeneratesChangedMovie(t *testing.T) {
+	movieDir := t.TempDir()
+	thumbDir := t.TempDir()
+	moviePath := filepath.Join(movieDir, "replaced.mp4")
+	thumbPath := filepath.Join(thumbDir, "replaced.jpg")
+	if err := os.WriteFile(moviePath, []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(thumbPath, []byte("grid"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.UpsertThumbnail(&models.Thumbnail{
+		MoviePath:     "replaced.mp4",
+		MovieFilename: "replaced.mp4",
+		ThumbnailPath: "replaced.jpg",
+		Status:        models.StatusSuccess,
+		FileSize:      int64(len("original")),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		MoviesDirs:     []string{movieDir},
+		ThumbnailsDir:  thumbDir,
+		FileExtensions: []string{"mp4"},
+		MaxWorkers:     1,
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+	scan := func() ScanResult {
+		t.Helper()
+		result, err := s.ScanMovies(context.Background(), ScanTypeManual)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	// An unchanged movie is skipped, and its modification time recorded
+	if result := scan(); result.Skipped != 1 {
+		t.Fatalf("unchanged movie: %+v, want it skipped", result)
+	}
+	if mtime, _ := db.GetMovieMtime("replaced.mp4"); mtime == 0 {
+		t.Fatal("modification time was not recorded")
+	}
+	if result := scan(); result.Skipped != 1 {
+		t.Fatalf("unchanged movie on rescan: %+v, want it skipped", result)
+	}
+
+	// Replaced in place with the same size: only the modification time differs.
+	// Generation is attempted and fails here, as the movie is not a real video.
+	if err := os.WriteFile(moviePath, []byte("replaced"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(moviePath, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if result := scan(); result.Skipped != 0 || result.Errors != 1 {
+		t.Errorf("movie with a new modification time: %+v, want it regenerated", result)
+	}
+
+	// Replaced with a different size
+	if err := db.UpsertThumbnail(&models.Thumbnail{
+		MoviePath:     "replaced.mp4",
+		MovieFilename: "replaced.mp4",
+		ThumbnailPath: "replaced.jpg",
+		Status:        models.StatusSuccess,
+		FileSize:      int64(len("replaced")),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(thumbPath, []byte("grid"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(moviePath, []byte("replaced with a longer movie"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if result := scan(); result.Skipped != 0 || result.Errors != 1 {
+		t.Errorf("movie with a new size: %+v, want it regenerated", result)
+	}
+	if got, _ := db.GetByMoviePath("replaced.mp4"); got == nil || got.Status == models.StatusSuccess {
+		t.Errorf("changed movie record = %+v, want it regenerated", got)
+	}
+}
+
+func TestScanRegeneratesOnSidecarChange(t *testing.T) {
+	movieDir := t.TempDir()
+	thumbDir := t.TempDir()
+	moviePath := filepath.Join(movieDir, "odd.mp4")
+	if err := os.WriteFile(moviePath, []byte("movie"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(thumbDir, "odd.jpg"), []byte("grid"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	success := &models.Thumbnail{
+		MoviePath:     "odd.mp4",
+		MovieFilename: "odd.mp4",
+		ThumbnailPath: "odd.jpg",
+		Status:        models.StatusSuccess,
+		FileSize:      int64(len("movie")),
+	}
+	if err := db.UpsertThumbnail(success); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		MoviesDirs:     []string{movieDir},
+		ThumbnailsDir:  thumbDir,
+		FileExtensions: []string{"mp4"},
+		MaxWorkers:     1,
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+	scan := func() ScanResult {
+		t.Helper()
+		result, err := s.ScanMovies(context.Background(), ScanTypeManual)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if result := scan(); result.Skipped != 1 {
+		t.Fatalf("movie without sidecar: %+v, want it skipped", result)
+	}
+
+	// Adding a sidecar regenerates the thumbnail, which fails here as the movie is not
+	// a real video
+	sidecar := ffmpeg.SidecarPath(moviePath)
+	if err := os.WriteFile(sidecar, []byte(`{"grid_cols": 2}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if result := scan(); result.Skipped != 0 || result.Errors != 1 {
+		t.Fatalf("movie with a new sidecar: %+v, want it regenerated", result)
+	}
+	info, err := os.Stat(sidecar)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Once generated with the sidecar, the movie is skipped until the sidecar changes
+	if err := db.UpsertThumbnail(success); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSidecarMtime("odd.mp4", info.ModTime().Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if result := scan(); result.Skipped != 1 {
+		t.Fatalf("unchanged sidecar: %+v, want it skipped", result)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(sidecar, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if result := scan(); result.Skipped != 0 || result.Errors != 1 {
+		t.Errorf("movie with an edited sidecar: %+v, want it regenerated", result)
+	}
+
+	// Removing the sidecar goes back to the global settings
+	if err := db.UpsertThumbnail(success); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSidecarMtime("odd.mp4", later.Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(sidecar); err != nil {
+		t.Fatal(err)
+	}
+	if result := scan(); result.Skipped != 0 || result.Errors != 1 {
+		t.Errorf("movie whose sidecar was removed: %+v, want it regenerated", result)
+	}
+
+	// Once regenerated without it, the movie is skipped again. Generation always fails
+	// here, so save what a successful one records.
+	if err := db.UpsertThumbnail(success); err != nil {
+		t.Fatal(err)
+	}
+	s.saveSidecarMtime("odd.mp4", 0)
+	if stored, err := db.GetSidecarMtime("odd.mp4"); err != nil || stored != 0 {
+		t.Errorf("sidecar mtime saved without a sidecar = %d, %v, want 0", stored, err)
+	}
+	if result := scan(); result.Skipped != 1 {
+		t.Errorf("movie regenerated after its sidecar was removed: %+v, want it skipped", result)
+	}
+}
+
+func TestResetForRegeneration(t *testing.T) {
+	thumbDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(thumbDir, "imported.jpg"), []byte("grid"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, thumbnail := range []*models.Thumbnail{
+		{MoviePath: "imported.mp4", ThumbnailPath: "imported.jpg", Status: models.StatusSuccess, Source: models.SourceImported},
+		{MoviePath: "generated.mp4", ThumbnailPath: "generated.jpg", Status: models.StatusSuccess, Source: models.SourceGenerated},
+		// Queued for deletion, so never regenerated
+		{MoviePath: "deleted.mp4", ThumbnailPath: "deleted.jpg", Status: models.StatusDeleted, Source: models.SourceImported},
+	} {
+		thumbnail.MovieFilename = thumbnail.MoviePath
+		if err := db.UpsertThumbnail(thumbnail); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := New(&config.Config{ThumbnailsDir: thumbDir}, db, storage.NewLocal(thumbDir), log, nil)
+
+	candidates, err := s.RegenerationCandidates(database.ThumbnailFilter{Source: models.SourceImported})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 || candidates[0].MoviePath != "imported.mp4" {
+		t.Fatalf("candidates = %v, want only imported.mp4", candidates)
+	}
+
+	if reset, err := s.ResetForRegeneration(context.Background(), candidates); err != nil || reset != 1 {
+		t.Fatalf("ResetForRegeneration = %d, %v, want 1", reset, err)
+	}
+	if _, err := os.Stat(filepath.Join(thumbDir, "imported.jpg")); !os.IsNotExist(err) {
+		t.Errorf("thumbnail was not removed: %v", err)
+	}
+	for name, want := range map[string]string{
+		"imported.mp4":  models.StatusPending,
+		"generated.mp4": models.StatusSuccess,
+		"deleted.mp4":   models.StatusDeleted,
+	} {
+		if got, _ := db.GetByMoviePath(name); got == nil || got.Status != want {
+			t.Errorf("%s = %+v, want status %s", name, got, want)
+		}
+	}
+}
+
+func TestRegenerateScanSkipsCleanup(t *testing.T) {
+	movieDir, archiveDir := t.TempDir(), t.TempDir()
+	writeMovie(t, filepath.Join(movieDir, "keep.mp4"))
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: "keep.mp4", MovieFilename: "keep.mp4", Status: models.StatusArchived}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		MoviesDirs:     []string{movieDir},
+		ThumbnailsDir:  t.TempDir(),
+		ArchiveDir:     archiveDir,
+		FileExtensions: []string{"mp4"},
+		MaxWorkers:     1,
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := New(cfg, db, storage.NewLocal(cfg.ThumbnailsDir), log, nil) // Without metrics, like the regenerate command
+
+	if _, err := s.ScanMoviesWithOptions(context.Background(), ScanOptions{Type: ScanTypeRegenerate, SkipCleanup: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(movieDir, "keep.mp4")); err != nil {
+		t.Errorf("regenerate scan moved the movie queued for archival: %v", err)
+	}
+	if got, _ := db.GetByMoviePath("keep.mp4"); got == nil || got.Status != models.StatusArchived {
+		t.Errorf("keep.mp4 = %+v after a regenerate scan, want it still queued for archival", got)
+	}
+
+	// The archive phase itself works without metrics too
+	if err := s.CleanupPhases(context.Background(), config.CleanupArchive); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(archiveDir, "keep.mp4")); err != nil {
+		t.Errorf("archive phase did not archive keep.mp4: %v", err)
+	}
+	if got, _ := db.GetByMoviePath("keep.mp4"); got != nil {
+		t.Errorf("keep.mp4 = %+v after archival, want its record removed", got)
+	}
+}
+
+func TestOperationsExcludeEachOther(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	cfg := &config.Config{
+		MoviesDirs:     []string{t.TempDir()},
+		ThumbnailsDir:  t.TempDir(),
+		FileExtensions: []string{"mp4"},
+		MaxWorkers:     1,
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := New(cfg, db, storage.NewLocal(cfg.ThumbnailsDir), log, nil)
+
+	// A cleanup in progress keeps a scan from starting, and the other way round
+	if !s.TryAcquire(OpCleanup) {
+		t.Fatal("TryAcquire failed while idle")
+	}
+	if _, err := s.ScanMovies(context.Background(), ScanTypeManual); !errors.Is(err, ErrBusy) {
+		t.Errorf("scan during cleanup: error = %v, want ErrBusy", err)
+	}
+	if _, err := s.ProcessDeletions(context.Background()); !errors.Is(err, ErrBusy) {
+		t.Errorf("deletions during cleanup: error = %v, want ErrBusy", err)
+	}
+	if !s.IsBusy() || s.IsScanning() {
+		t.Errorf("IsBusy = %v, IsScanning = %v during cleanup", s.IsBusy(), s.IsScanning())
+	}
+	s.Release()
+
+	if !s.TryAcquire(OpScan) {
+		t.Fatal("TryAcquire failed after Release")
+	}
+	if err := s.CleanupOrphans(context.Background()); !errors.Is(err, ErrBusy) {
+		t.Errorf("cleanup during scan: error = %v, want ErrBusy", err)
+	}
+	if _, err := s.ScanMovies(context.Background(), ScanTypeManual); !errors.Is(err, ErrScanInProgress) {
+		t.Errorf("second scan: error = %v, want ErrScanInProgress", err)
+	}
+	s.Release()
+
+	// Started together, scans and cleanups never overlap: each either runs alone or
+	// is turned away
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ran := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if i%2 == 0 {
+				_, err = s.ScanMovies(context.Background(), ScanTypeManual)
+			} else {
+				err = s.CleanupOrphans(context.Background())
+			}
+			switch {
+			case err == nil:
+				mu.Lock()
+				ran++
+				mu.Unlock()
+			case !errors.Is(err, ErrBusy) && !errors.Is(err, ErrScanInProgress):
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if ran == 0 || s.IsBusy() {
+		t.Errorf("%d operations ran, busy afterwards: %v", ran, s.IsBusy())
+	}
+}
+
+func TestCleanupPhases(t *testing.T) {
+	movieDir := t.TempDir()
+	thumbDir := t.TempDir()
+	for _, name := range []string{"gone.jpg", "orphan.jpg"} {
+		if err := os.WriteFile(filepath.Join(thumbDir, name), []byte("grid"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: "gone.mp4", MovieFilename: "gone.mp4", ThumbnailPath: "gone.jpg", Status: models.StatusSuccess}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		MoviesDirs:     []string{movieDir},
+		ThumbnailsDir:  thumbDir,
+		ArchiveDir:     t.TempDir(),
+		FileExtensions: []string{"mp4"},
+		CleanupPhases:  []string{config.CleanupOrphans, config.CleanupMissing, config.CleanupQueue},
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	log.SetLevel(logrus.DebugLevel)
+	var ran []string
+	log.AddHook(phaseHook{&ran})
+	s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(thumbDir, name))
+		return err == nil
+	}
+
+	// A single phase leaves the others' work alone
+	if err := s.CleanupPhases(context.Background(), config.CleanupOrphans); err != nil {
+		t.Fatal(err)
+	}
+	if exists("orphan.jpg") || !exists("gone.jpg") {
+		t.Errorf("orphans phase: orphan.jpg exists %v, gone.jpg exists %v; want only gone.jpg kept", exists("orphan.jpg"), exists("gone.jpg"))
+	}
+	if thumbnail, _ := db.GetByMoviePath("gone.mp4"); thumbnail == nil {
+		t.Error("orphans phase removed the record of a missing movie")
+	}
+
+	// CLEANUP_PHASES sets the phases and their order
+	ran = nil
+	if err := s.CleanupOrphans(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(ran); got != "[orphans missing queue]" {
+		t.Errorf("phases ran %s, want [orphans missing queue]", got)
+	}
+	if thumbnail, _ := db.GetByMoviePath("gone.mp4"); thumbnail != nil || exists("gone.jpg") {
+		t.Error("missing phase kept the record or thumbnail of a missing movie")
+	}
+
+	// Without CLEANUP_PHASES every phase runs in the default order
+	cfg.CleanupPhases = nil
+	ran = nil
+	if err := s.CleanupOrphans(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(ran); got != "[archive queue missing orphans]" {
+		t.Errorf("default phases ran %s, want [archive queue missing orphans]", got)
+	}
+
+	for _, phases := range [][]string{nil, {"vacuum"}, {config.CleanupOrphans, config.CleanupOrphans}} {
+		if err := s.CleanupPhases(context.Background(), phases...); err == nil {
+			t.Errorf("CleanupPhases(%q) succeeded, want an error", phases)
+		}
+	}
+}
+
+// phaseHook records the cleanup phases logged as they start
+type phaseHook struct{ ran *[]string }
+
+func (h phaseHook) Levels() []logrus.Level { return []logrus.Level{logrus.DebugLevel} }
+
+func (h phaseHook) Fire(entry *logrus.Entry) error {
+	if phase, ok := entry.Data["phase"].(string); ok {
+		*h.ran = append(*h.ran, phase)
+	}
+	return nil
+}
+
+func TestProcessDeletionsRequiresApproval(t *testing.T) {
+	movieDir := t.TempDir()
+	thumbDir := t.TempDir()
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ids := make(map[string]int64)
+	for _, name := range []string{"approved.mp4", "pending.mp4"} {
+		touch(t, filepath.Join(movieDir, name))
+		if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: name, MovieFilename: name, Status: models.StatusDeleted}); err != nil {
+			t.Fatal(err)
+		}
+		thumbnail, err := db.GetByMoviePath(name)
+		if err != nil || thumbnail == nil {
+			t.Fatalf("GetByMoviePath(%s) = %v, %v", name, thumbnail, err)
+		}
+		ids[name] = thumbnail.ID
+	}
+
+	cfg := &config.Config{
+		MoviesDirs:              []string{movieDir},
+		ThumbnailsDir:           thumbDir,
+		FileExtensions:          []string{"mp4"},
+		DeletionRequireApproval: true,
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(movieDir, name))
+		return err == nil
+	}
+
+	// Nothing is deleted before approval, from a manual run or a cleanup
+	if summary, err := s.ProcessDeletions(context.Background()); err != nil || summary.Deleted != 0 {
+		t.Fatalf("ProcessDeletions before approval = %+v, %v; want nothing deleted", summary, err)
+	}
+	if err := s.CleanupPhases(context.Background(), config.CleanupQueue); err != nil {
+		t.Fatal(err)
+	}
+	if !exists("approved.mp4") || !exists("pending.mp4") {
+		t.Fatal("movies were deleted before approval")
+	}
+
+	if _, err := db.ApproveDeletions(ids["approved.mp4"]); err != nil {
+		t.Fatal(err)
+	}
+	summary, err := s.ProcessDeletions(context.Background())
+	if err != nil || summary.Deleted != 1 {
+		t.Fatalf("ProcessDeletions after approval = %+v, %v; want 1 deleted", summary, err)
+	}
+	if exists("approved.mp4") || !exists("pending.mp4") {
+		t.Errorf("approved.mp4 exists %v, pending.mp4 exists %v; want only pending.mp4 kept", exists("approved.mp4"), exists("pending.mp4"))
+	}
+	if thumbnail, _ := db.GetByMoviePath("pending.mp4"); thumbnail == nil || thumbnail.Status != models.StatusDeleted {
+		t.Errorf("pending.mp4 = %+v, want it still queued for deletion", thumbnail)
+	}
+}
+
+func TestProcessMovieIncompleteFiles(t *testing.T) {
+	movieDir := t.TempDir()
+	thumbDir := t.TempDir()
+	moviePath := filepath.Join(movieDir, "download.mp4")
+	touch(t, moviePath)
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{
+		MoviesDirs:        []string{movieDir},
+		ThumbnailsDir:     thumbDir,
+		FileExtensions:    []string{"mp4"},
+		IncompleteFileAge: time.Hour,
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := New(cfg, db, storage.NewLocal(thumbDir), log, nil)
+
+	process := func() (processOutcome, *models.Thumbnail) {
+		t.Helper()
+		outcome, err := s.processMovie(context.Background(), moviePath, 0, 1, ScanOptions{})
+		if err != nil && outcome != outcomeError {
+			t.Fatalf("processMovie = %v, %v", outcome, err)
+		}
+		thumbnail, err := db.GetByMoviePath("download.mp4")
+		if err != nil || thumbnail == nil {
+			t.Fatalf("GetByMoviePath = %v, %v", thumbnail, err)
+		}
+		return outcome, thumbnail
+	}
+
+	// An empty file is skipped as incomplete, also on the next scans
+	for i := 0; i < 2; i++ {
+		if outcome, thumbnail := process(); outcome != outcomeSkipped || thumbnail.Status != models.StatusIncomplete {
+			t.Fatalf("empty movie, pass %d: outcome %v, status %q; want skipped and incomplete", i, outcome, thumbnail.Status)
+		}
+	}
+	if stats, _ := db.GetStats(); stats.Incomplete != 1 {
+		t.Errorf("stats.Incomplete = %d, want 1", stats.Incomplete)
+	}
+
+	// Once it grows, a recently modified file that can't be probed stays incomplete
+	if err := os.WriteFile(moviePath, []byte("partial download"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outcome, thumbnail := process()
+	if outcome != outcomeSkipped || thumbnail.Status != models.StatusIncomplete || thumbnail.FileSize == 0 {
+		t.Fatalf("growing movie: outcome %v, status %q, size %d; want skipped, incomplete and the new size", outcome, thumbnail.Status, thumbnail.FileSize)
+	}
+
+	// A file that stopped changing and still can't be probed is an error
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(moviePath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if outcome, thumbnail := process(); outcome != outcomeError || thumbnail.Status != models.StatusError {
+		t.Errorf("settled broken movie: outcome %v, status %q; want an error", outcome, thumbnail.Status)
+	}
 }
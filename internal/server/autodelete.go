@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// autoDeleteMatches reports whether a viewed thumbnail's movie matches every
+// AUTO_DELETE_CRITERIA criterion, so the slideshow stages it for deletion. It never
+// matches when the criteria are empty or DISABLE_DELETION is set.
+func (s *Server) autoDeleteMatches(thumbnail *models.Thumbnail) bool {
+	c := s.autoDelete
+	if c.IsEmpty() || s.cfg.DisableDeletion || thumbnail == nil || thumbnail.Status != models.StatusSuccess {
+		return false
+	}
+
+	// Unknown sizes and resolutions (0) never count as small
+	if c.SizeBelow > 0 && (thumbnail.FileSize <= 0 || thumbnail.FileSize >= c.SizeBelow) {
+		return false
+	}
+	if c.DurationBelow > 0 && (thumbnail.Duration <= 0 || thumbnail.Duration >= c.DurationBelow.Seconds()) {
+		return false
+	}
+	if c.WidthBelow > 0 && (thumbnail.Width <= 0 || thumbnail.Width >= c.WidthBelow) {
+		return false
+	}
+	if c.HeightBelow > 0 && (thumbnail.Height <= 0 || thumbnail.Height >= c.HeightBelow) {
+		return false
+	}
+	return true
+}
+
+// logAutoDeletion logs a movie staged for deletion by AUTO_DELETE_CRITERIA
+func (s *Server) logAutoDeletion(r *http.Request, thumbnail *models.Thumbnail) {
+	s.logFrom(r).WithFields(logrus.Fields{
+		"movie":        thumbnail.MoviePath,
+		"thumbnail_id": thumbnail.ID,
+		"file_size":    thumbnail.FileSize,
+	}).Info("Staged viewed movie for deletion matching AUTO_DELETE_CRITERIA")
+}
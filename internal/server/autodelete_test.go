@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/config"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+func TestAutoDeleteMatches(t *testing.T) {
+	s := &Server{cfg: &config.Config{}, autoDelete: config.AutoDeleteCriteria{SizeBelow: 100 << 20, DurationBelow: time.Minute}}
+	small := func() *models.Thumbnail {
+		return &models.Thumbnail{Status: models.StatusSuccess, FileSize: 10 << 20, Duration: 30, Width: 640, Height: 360}
+	}
+
+	if !s.autoDeleteMatches(small()) {
+		t.Error("small short movie does not match")
+	}
+
+	for name, change := range map[string]func(*models.Thumbnail){
+		"too large":        func(th *models.Thumbnail) { th.FileSize = 200 << 20 },
+		"too long":         func(th *models.Thumbnail) { th.Duration = 60 },
+		"unknown size":     func(th *models.Thumbnail) { th.FileSize = 0 },
+		"unknown duration": func(th *models.Thumbnail) { th.Duration = 0 },
+		"not successful":   func(th *models.Thumbnail) { th.Status = models.StatusError },
+	} {
+		th := small()
+		change(th)
+		if s.autoDeleteMatches(th) {
+			t.Errorf("%s: matches, want no match", name)
+		}
+	}
+
+	s.cfg.DisableDeletion = true
+	if s.autoDeleteMatches(small()) {
+		t.Error("matches with DisableDeletion set")
+	}
+
+	s.cfg.DisableDeletion = false
+	s.autoDelete = config.AutoDeleteCriteria{}
+	if s.autoDeleteMatches(small()) {
+		t.Error("matches without criteria")
+	}
+}
+
+func TestAutoDeleteStaging(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	s.autoDelete = config.AutoDeleteCriteria{SizeBelow: 100 << 20}
+
+	insert := func(name string, size int64) *models.Thumbnail {
+		thumbnail := &models.Thumbnail{MoviePath: name, MovieFilename: name, Status: models.StatusSuccess, FileSize: size}
+		if err := db.UpsertThumbnail(thumbnail); err != nil {
+			t.Fatal(err)
+		}
+		return thumbnail
+	}
+	small := insert("small.mp4", 10<<20)
+	large := insert("large.mp4", 500<<20)
+	// Gives the slideshow somewhere to move on to
+	insert("other.mp4", 500<<20)
+
+	call := func(t *testing.T, handler http.HandlerFunc, current int64) *SessionData {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/slideshow", nil)
+		req.Header.Set("X-Requested-With", "XMLHttpRequest")
+		req.AddCookie(sessionCookie(t, SessionData{TotalImages: 3, CurrentID: current, StartedAt: time.Now().Unix()}))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == "slideshow_session" {
+				session, err := s.getSessionFromCookie(&http.Request{Header: http.Header{"Cookie": {c.String()}}})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return session
+			}
+		}
+		t.Fatalf("no session cookie in response (status %d)", rec.Code)
+		return nil
+	}
+
+	for name, handler := range map[string]http.HandlerFunc{
+		"mark viewed": s.handleMarkViewed,
+		"next":        s.handleSlideshowNext,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if session := call(t, handler, small.ID); !session.PendingDelete || session.PreviousID != small.ID {
+				t.Errorf("small movie: pending delete %v for %d, want it staged", session.PendingDelete, session.PreviousID)
+			}
+			if session := call(t, handler, large.ID); session.PendingDelete {
+				t.Errorf("large movie was staged for deletion")
+			}
+
+			// Staged deletions stay undoable until the slideshow moves on
+			thumbnail, err := db.GetByID(small.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if thumbnail.Status == models.StatusDeleted {
+				t.Error("small movie was marked deleted in the database")
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+// caughtUpDetector tracks the unviewed count between metrics updates to detect the
+// moment the library-wide unviewed pool is exhausted
+type caughtUpDetector struct {
+	mu       sync.Mutex
+	observed bool
+	previous int
+}
+
+// Observe records the current unviewed count and reports whether it just dropped to
+// zero. The first observation only sets the baseline, so a library that starts out
+// caught up does not fire.
+func (d *caughtUpDetector) Observe(unviewed int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	transitioned := d.observed && d.previous > 0 && unviewed == 0
+	d.observed = true
+	d.previous = unviewed
+	return transitioned
+}
+
+// caughtUpEvent is the JSON body posted to CAUGHT_UP_WEBHOOK
+type caughtUpEvent struct {
+	Event     string `json:"event"`
+	Viewed    int    `json:"viewed"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// notifyCaughtUp logs that every thumbnail has been viewed and posts the event to
+// the configured webhook in the background
+func (s *Server) notifyCaughtUp(stats *models.Stats) {
+	s.log.WithField("viewed", stats.Viewed).Info("All thumbnails viewed, library is caught up")
+
+	if s.cfg.CaughtUpWebhook == "" {
+		return
+	}
+
+	body, err := json.Marshal(caughtUpEvent{
+		Event:     "caught_up",
+		Viewed:    stats.Viewed,
+		Timestamp: time.Now().Unix(),
+	})
+	if err != nil {
+		s.log.WithError(err).Error("Failed to encode caught up event")
+		return
+	}
+
+	go func() {
+		req, err := http.NewRequest(http.MethodPost, s.cfg.CaughtUpWebhook, bytes.NewReader(body))
+		if err != nil {
+			s.log.WithError(err).Error("Failed to create caught up webhook request")
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			s.log.WithError(err).Warn("Failed to send caught up webhook")
+			return
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			s.log.WithField("status", resp.Status).Warn("Caught up webhook returned an error")
+		}
+	}()
+}
@@ -0,0 +1,26 @@
+package server
+
+import "testing"
+
+func TestCaughtUpDetector(t *testing.T) {
+	var d caughtUpDetector
+
+	steps := []struct {
+		unviewed int
+		want     bool
+	}{
+		{0, false}, // First observation only sets the baseline
+		{3, false},
+		{1, false},
+		{0, true},  // Dropped to zero
+		{0, false}, // Still zero, fires only once
+		{2, false}, // New movies scanned
+		{0, true},
+	}
+
+	for i, step := range steps {
+		if got := d.Observe(step.unviewed); got != step.want {
+			t.Errorf("step %d: Observe(%d) = %v, want %v", i, step.unviewed, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// parseTrustedProxies parses TRUSTED_PROXIES entries, each an IP address or a CIDR
+// range. Invalid entries are reported by Config.Validate and skipped here.
+func parseTrustedProxies(entries []string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range entries {
+		if prefix, ok := parseProxyEntry(entry); ok {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// parseProxyEntry parses a single IP address or CIDR range
+func parseProxyEntry(entry string) (netip.Prefix, bool) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return netip.Prefix{}, false
+	}
+	if prefix, err := netip.ParsePrefix(entry); err == nil {
+		return prefix.Masked(), true
+	}
+	if addr, err := netip.ParseAddr(entry); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), true
+	}
+	return netip.Prefix{}, false
+}
+
+// clientIPMiddleware stores the effective client IP in the request context. Requests
+// from a trusted proxy are attributed to the right-most X-Forwarded-For entry that is
+// not itself a trusted proxy; the header is ignored from any other source.
+func clientIPMiddleware(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := forwardedClientIP(r, trusted)
+			ctx := context.WithValue(r.Context(), clientIPKey{}, ip)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ClientIPFromContext returns the effective client IP stored in ctx, if any
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// clientIP returns the effective client IP of a request, falling back to the
+// connection's remote address
+func clientIP(r *http.Request) string {
+	if ip := ClientIPFromContext(r.Context()); ip != "" {
+		return ip
+	}
+	return remoteHost(r.RemoteAddr)
+}
+
+// forwardedClientIP determines the client IP of r, honoring X-Forwarded-For only
+// when the connection comes from a trusted proxy
+func forwardedClientIP(r *http.Request, trusted []netip.Prefix) string {
+	remote := remoteHost(r.RemoteAddr)
+	addr, err := netip.ParseAddr(remote)
+	if err != nil || !isTrusted(addr, trusted) {
+		return remote
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	// Walk back from the proxy that connected to us until a hop we don't trust
+	client := addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := parseForwardedHop(hops[i])
+		if err != nil {
+			break // Anything further left can't be relied on
+		}
+		client = hop
+		if !isTrusted(hop, trusted) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// parseForwardedHop parses an X-Forwarded-For entry, which may carry a port
+func parseForwardedHop(hop string) (netip.Addr, error) {
+	hop = strings.TrimSpace(hop)
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return addrPort.Addr().Unmap(), nil
+	}
+	addr, err := netip.ParseAddr(strings.Trim(hop, "[]"))
+	return addr.Unmap(), err
+}
+
+// isTrusted reports whether addr belongs to one of the trusted proxy ranges
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteHost strips the port from a RemoteAddr
+func remoteHost(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwardedClientIP(t *testing.T) {
+	trusted := parseTrustedProxies([]string{"10.0.0.0/8", " 192.168.1.5 ", "fd00::/8", "bogus"})
+
+	tests := []struct {
+		name    string
+		trusted bool
+		remote  string
+		xff     []string
+		want    string
+	}{
+		{"no proxies configured", false, "10.0.0.2:1234", []string{"203.0.113.7"}, "10.0.0.2"},
+		{"untrusted source ignores header", true, "198.51.100.9:1234", []string{"203.0.113.7"}, "198.51.100.9"},
+		{"trusted proxy", true, "10.0.0.2:1234", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"trusted proxy without header", true, "10.0.0.2:1234", nil, "10.0.0.2"},
+		{"single trusted address", true, "192.168.1.5:80", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"right-most untrusted entry wins", true, "10.0.0.2:1234", []string{"1.2.3.4, 203.0.113.7, 10.0.0.3"}, "203.0.113.7"},
+		{"spoofed left entries ignored", true, "10.0.0.2:1234", []string{"6.6.6.6", "203.0.113.7"}, "203.0.113.7"},
+		{"all hops trusted", true, "10.0.0.2:1234", []string{"10.1.1.1, 10.0.0.3"}, "10.1.1.1"},
+		{"invalid hop stops the walk", true, "10.0.0.2:1234", []string{"203.0.113.7, garbage, 10.0.0.3"}, "10.0.0.3"},
+		{"hop with port", true, "10.0.0.2:1234", []string{"203.0.113.7:5555"}, "203.0.113.7"},
+		{"ipv6", true, "[fd00::1]:443", []string{"2001:db8::1"}, "2001:db8::1"},
+		{"ipv4-mapped proxy", true, "[::ffff:10.0.0.2]:1234", []string{"203.0.113.7"}, "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			proxies := trusted
+			if !tt.trusted {
+				proxies = nil
+			}
+			if got := forwardedClientIP(r, proxies); got != tt.want {
+				t.Errorf("forwardedClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPMiddleware(t *testing.T) {
+	var got string
+	handler := clientIPMiddleware(parseTrustedProxies([]string{"127.0.0.1"}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = clientIP(r)
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:40000"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if got != "203.0.113.7" {
+		t.Errorf("clientIP = %q, want forwarded address", got)
+	}
+
+	// Without the middleware the connection address is used
+	r = httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:40000"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	if got := clientIP(r); got != "127.0.0.1" {
+		t.Errorf("clientIP without middleware = %q, want 127.0.0.1", got)
+	}
+}
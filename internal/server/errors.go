@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrorResponse is the JSON body of an error response
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	Code      int    `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// wantsJSON reports whether an error for r should be sent as JSON: requests under
+// /api/ and clients that accept application/json
+func wantsJSON(r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		return true
+	}
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			if mediaType, _, err := mime.ParseMediaType(mediaRange); err == nil && mediaType == "application/json" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeError sends an error response, as JSON for API clients and as plain text otherwise
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, code int, msg string) {
+	if !wantsJSON(r) {
+		http.Error(w, msg, code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Error:     msg,
+		Code:      code,
+		RequestID: RequestIDFromContext(r.Context()),
+	})
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteErrorNegotiation(t *testing.T) {
+	s, _ := newSessionTestServer(t)
+
+	tests := []struct {
+		name     string
+		path     string
+		accept   string
+		wantJSON bool
+	}{
+		{"api path", "/api/thumbnails/1", "", true},
+		{"accept json", "/slideshow/next", "application/json", true},
+		{"accept json with params", "/scan", "text/html;q=0.9, application/json;q=1", true},
+		{"browser", "/slideshow/next", "text/html,application/xhtml+xml,*/*;q=0.8", false},
+		{"no accept", "/scan", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			req = req.WithContext(context.WithValue(req.Context(), requestIDKey{}, "req-123"))
+			rec := httptest.NewRecorder()
+
+			s.writeError(rec, req, http.StatusConflict, "Scan already in progress")
+
+			if rec.Code != http.StatusConflict {
+				t.Errorf("status = %d, want 409", rec.Code)
+			}
+			ct := rec.Header().Get("Content-Type")
+			if !tt.wantJSON {
+				if !strings.HasPrefix(ct, "text/plain") {
+					t.Errorf("Content-Type = %q, want text/plain", ct)
+				}
+				if got := strings.TrimSpace(rec.Body.String()); got != "Scan already in progress" {
+					t.Errorf("body = %q", got)
+				}
+				return
+			}
+
+			if ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid JSON body %q: %v", rec.Body.String(), err)
+			}
+			want := ErrorResponse{Error: "Scan already in progress", Code: http.StatusConflict, RequestID: "req-123"}
+			if resp != want {
+				t.Errorf("body = %+v, want %+v", resp, want)
+			}
+		})
+	}
+}
+
+func TestHandleNotFoundNegotiation(t *testing.T) {
+	s, _ := newSessionTestServer(t)
+
+	rec := httptest.NewRecorder()
+	s.handleNotFound(rec, httptest.NewRequest("GET", "/missing", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "<h1>404 Not Found</h1>") {
+		t.Errorf("browser 404 = %d %q, want HTML page", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleNotFound(rec, httptest.NewRequest("GET", "/api/missing", nil))
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("API 404 body %q is not JSON: %v", rec.Body.String(), err)
+	}
+	if rec.Code != http.StatusNotFound || resp.Code != http.StatusNotFound {
+		t.Errorf("API 404 = %d %+v", rec.Code, resp)
+	}
+}
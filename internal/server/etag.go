@@ -0,0 +1,68 @@
+package server
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
+)
+
+// Page sizes of /api/thumbnails
+const (
+	defaultThumbnailPageSize = 50
+	maxThumbnailPageSize     = 500
+)
+
+// pageParams reads the limit and offset query parameters. A missing or invalid limit
+// gives defaultLimit, and limits above maxLimit are capped.
+func pageParams(r *http.Request, defaultLimit, maxLimit int) (limit, offset int) {
+	limit = defaultLimit
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = min(v, maxLimit)
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && v > 0 {
+		offset = v
+	}
+	return limit, offset
+}
+
+// listETag derives the entity tag of a list response from the table version and the
+// query that selected it
+func listETag(version, rawQuery string) string {
+	h := fnv.New64a()
+	h.Write([]byte(version))
+	h.Write([]byte{0})
+	h.Write([]byte(rawQuery))
+	return fmt.Sprintf(`"%x"`, h.Sum64())
+}
+
+// imageETag derives the entity tag of a stored thumbnail image from its key, size and
+// modification time, so a regenerated grid gets a new tag
+func imageETag(info storage.ObjectInfo) string {
+	return fmt.Sprintf(`"%x-%x-%x"`, fnvHash(info.Key), info.Size, info.ModTime.UnixNano())
+}
+
+// fnvHash returns the 64-bit FNV-1a hash of s
+func fnvHash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using the weak
+// comparison RFC 9110 specifies for If-None-Match
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+func TestHandleThumbnailsNotModified(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	for _, name := range []string{"a.mp4", "b.mp4"} {
+		if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: name, MovieFilename: name, Status: models.StatusSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/thumbnails?status=success", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		s.handleThumbnails(rec, req)
+		return rec
+	}
+
+	first := get("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first request = %d with ETag %q, want 200 with an ETag", first.Code, etag)
+	}
+
+	if rec := get(etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("matching If-None-Match = %d with %d bytes, want an empty 304", rec.Code, rec.Body.Len())
+	}
+	if rec := get(`"other", W/` + etag); rec.Code != http.StatusNotModified {
+		t.Errorf("weak match in a list = %d, want 304", rec.Code)
+	}
+
+	// Any change to the table changes the ETag
+	a, _ := db.GetByMoviePath("a.mp4")
+	if err := db.MarkAsViewedByID(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	rec := get(etag)
+	if rec.Code != http.StatusOK {
+		t.Errorf("stale If-None-Match = %d, want 200", rec.Code)
+	}
+	if rec.Header().Get("ETag") == etag {
+		t.Error("ETag did not change after a write")
+	}
+}
+
+func TestHandleThumbnailsPagination(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	for i := 0; i < 7; i++ {
+		name := fmt.Sprintf("movie%d.mp4", i)
+		if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: name, MovieFilename: name, Status: models.StatusSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 7},
+		{"limit=3", 3},
+		{"limit=3&offset=6", 1},
+		{"limit=3&offset=7", 0},
+		{"limit=0", 7},   // Invalid limits fall back to the default
+		{"offset=-2", 7}, // Negative offsets start at the beginning
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleThumbnails(rec, httptest.NewRequest("GET", "/api/thumbnails?"+tt.query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: status %d", tt.query, rec.Code)
+		}
+		var thumbnails []models.Thumbnail
+		if err := json.NewDecoder(rec.Body).Decode(&thumbnails); err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		if len(thumbnails) != tt.want {
+			t.Errorf("%q: got %d thumbnails, want %d", tt.query, len(thumbnails), tt.want)
+		}
+		if total := rec.Header().Get("X-Total-Count"); total != "7" {
+			t.Errorf("%q: X-Total-Count = %q, want 7", tt.query, total)
+		}
+	}
+}
+
+func TestHandleThumbnailsEnvelope(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("movie%d.mp4", i)
+		if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: name, MovieFilename: name, Status: models.StatusSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleThumbnails(rec, httptest.NewRequest("GET", "/api/thumbnails?envelope=true&limit=2&offset=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	var page ThumbnailPage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Thumbnails) != 2 || page.Total != 3 || page.Limit != 2 || page.Offset != 1 {
+		t.Errorf("page = %d thumbnails, total %d, limit %d, offset %d; want 2, 3, 2, 1", len(page.Thumbnails), page.Total, page.Limit, page.Offset)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleThumbnails(rec, httptest.NewRequest("GET", "/api/thumbnails?envelope=maybe", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid envelope: status %d, want 400", rec.Code)
+	}
+}
+
+func TestPageParamsCapsLimit(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/thumbnails?limit=100000&offset=20", nil)
+	if limit, offset := pageParams(r, defaultThumbnailPageSize, maxThumbnailPageSize); limit != maxThumbnailPageSize || offset != 20 {
+		t.Errorf("pageParams = %d, %d; want %d, 20", limit, offset, maxThumbnailPageSize)
+	}
+}
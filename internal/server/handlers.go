@@ -4,15 +4,21 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"net/http"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/pandino/movie-thumbnailer-go/internal/config"
+	"github.com/pandino/movie-thumbnailer-go/internal/database"
 	"github.com/pandino/movie-thumbnailer-go/internal/models" // Add missing import
+	"github.com/pandino/movie-thumbnailer-go/internal/scanner"
 	"github.com/sirupsen/logrus"
 )
 
@@ -42,16 +48,34 @@ func formatBytes(bytes int64) string {
 }
 
 type SessionData struct {
-	TotalImages     int   `json:"total_images"`
-	ViewedCount     int   `json:"viewed_count"`
-	NavigationCount int   `json:"navigation_count"` // Track actual navigation through slideshow
-	CurrentID       int64 `json:"current_id"`
-	StartedAt       int64 `json:"started_at"`
-	PreviousID      int64 `json:"previous_id"`     // Store previous thumbnail ID for single undo/navigation
-	NextID          int64 `json:"next_id"`         // Store next thumbnail ID for coordination with prefetcher
-	PendingDelete   bool  `json:"pending_delete"`  // Flag indicating if PreviousID thumbnail is marked for deletion
-	PendingArchive  bool  `json:"pending_archive"` // Flag indicating if PreviousID thumbnail is marked for archival
-	DeletedSize     int64 `json:"deleted_size"`    // Total size in bytes of movies deleted in this session
+	TotalImages     int     `json:"total_images"`
+	ViewedCount     int     `json:"viewed_count"`
+	NavigationCount int     `json:"navigation_count"` // Track actual navigation through slideshow
+	CurrentID       int64   `json:"current_id"`
+	StartedAt       int64   `json:"started_at"`
+	PreviousID      int64   `json:"previous_id"`              // Store previous thumbnail ID for single undo/navigation
+	NextID          int64   `json:"next_id"`                  // Store next thumbnail ID for coordination with prefetcher
+	PendingDelete   bool    `json:"pending_delete"`           // Flag indicating if PreviousID thumbnail is marked for deletion
+	PendingArchive  bool    `json:"pending_archive"`          // Flag indicating if PreviousID thumbnail is marked for archival
+	DeletedSize     int64   `json:"deleted_size"`             // Total size in bytes of movies deleted in this session
+	Order           string  `json:"order,omitempty"`          // SLIDESHOW_ORDER the session was started with
+	IncludeViewed   bool    `json:"include_viewed,omitempty"` // Review mode: draw from viewed thumbnails too
+	LastActivity    int64   `json:"last_activity,omitempty"`  // Unix time the session was last saved
+	Artifact        string  `json:"artifact,omitempty"`       // Thumbnail artifact shown; empty means the grid
+	Playlist        []int64 `json:"playlist,omitempty"`       // Thumbnail IDs to show in order before falling back to Order
+	Source          string  `json:"source,omitempty"`         // Only generated or imported thumbnails, drawn at random
+	PlaylistPos     int     `json:"playlist_pos,omitempty"`   // Index of CurrentID in Playlist; len(Playlist) once it ran out
+}
+
+// errSessionExpired is returned for sessions idle for longer than SESSION_IDLE_EXPIRY
+var errSessionExpired = errors.New("session expired")
+
+// lastActive returns when the session was last used
+func (session *SessionData) lastActive() time.Time {
+	if session.LastActivity > 0 {
+		return time.Unix(session.LastActivity, 0)
+	}
+	return time.Unix(session.StartedAt, 0)
 }
 
 // getSessionFromCookie retrieves and validates session data from cookie
@@ -76,11 +100,18 @@ func (s *Server) getSessionFromCookie(r *http.Request) (*SessionData, error) {
 		return nil, fmt.Errorf("failed to unmarshal session data: %w", err)
 	}
 
+	// The session is still returned so the caller can record it
+	if s.cfg.SessionIdleExpiry > 0 && session.StartedAt > 0 && time.Since(session.lastActive()) > s.cfg.SessionIdleExpiry {
+		return &session, errSessionExpired
+	}
+
 	return &session, nil
 }
 
 // saveSessionToCookie saves session data to cookie
 func (s *Server) saveSessionToCookie(w http.ResponseWriter, session *SessionData) error {
+	session.LastActivity = time.Now().Unix()
+
 	sessionJSON, err := json.Marshal(session)
 	if err != nil {
 		return fmt.Errorf("failed to marshal session data: %w", err)
@@ -97,6 +128,40 @@ func (s *Server) saveSessionToCookie(w http.ResponseWriter, session *SessionData
 	return nil
 }
 
+// recordExpiredSession logs and counts a session replaced after SESSION_IDLE_EXPIRY
+func (s *Server) recordExpiredSession(r *http.Request, session *SessionData) {
+	duration := session.lastActive().Sub(time.Unix(session.StartedAt, 0))
+	s.logFrom(r).WithFields(logrus.Fields{
+		"viewed":   session.ViewedCount,
+		"idle":     time.Since(session.lastActive()).Round(time.Second),
+		"duration": duration,
+	}).Info("Slideshow session expired, starting a new one")
+	if s.metrics != nil {
+		s.metrics.RecordSlideshowSession("expired", duration)
+	}
+}
+
+// safeGetStats returns the current stats, falling back to the last known (or zero)
+// stats when the read fails. The boolean is false when the fallback was used.
+func (s *Server) safeGetStats(r *http.Request) (*models.Stats, bool) {
+	stats, err := s.scanner.GetStats()
+
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	if err != nil {
+		s.logFrom(r).WithError(err).Error("Failed to get stats")
+		if s.lastStats != nil {
+			fallback := *s.lastStats
+			return &fallback, false
+		}
+		return &models.Stats{}, false
+	}
+
+	s.lastStats = stats
+	return stats, true
+}
+
 // createNewSession creates a new session with initial data
 func (s *Server) createNewSession() (*SessionData, error) {
 	stats, err := s.scanner.GetStats()
@@ -117,11 +182,42 @@ func (s *Server) createNewSession() (*SessionData, error) {
 		PendingDelete:   false,
 		PendingArchive:  false,
 		DeletedSize:     0,
+		Order:           s.cfg.SlideshowOrder,
+		IncludeViewed:   s.cfg.IncludeViewed,
+	}
+
+	// lru and review sessions cycle through every thumbnail, not just the unviewed ones
+	if session.Order == config.SlideshowLRU || session.IncludeViewed {
+		total, err := s.db.GetSuccessfulThumbnailCount()
+		if err != nil {
+			s.log.WithError(err).Error("Failed to count thumbnails for new session")
+		} else {
+			session.TotalImages = total
+		}
 	}
 
 	return session, nil
 }
 
+// restrictSessionSource limits a new session to thumbnails of one source. Such sessions
+// draw at random whatever the SLIDESHOW_ORDER.
+func (s *Server) restrictSessionSource(session *SessionData, source string) {
+	session.Source = source
+	session.Order = config.SlideshowRandom
+
+	filter := database.ThumbnailFilter{Status: models.StatusSuccess, Source: source}
+	if !session.IncludeViewed {
+		viewed := false
+		filter.Viewed = &viewed
+	}
+	_, total, err := s.db.GetThumbnailsFiltered(filter, 1, 0)
+	if err != nil {
+		s.log.WithError(err).WithField("source", source).Error("Failed to count thumbnails for new session")
+		return
+	}
+	session.TotalImages = total
+}
+
 // redirectToSlideshow redirects to /slideshow without ID parameter (uses session state)
 func (s *Server) redirectToSlideshow(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/slideshow", http.StatusSeeOther)
@@ -131,14 +227,14 @@ func (s *Server) redirectToSlideshow(w http.ResponseWriter, r *http.Request) {
 func (s *Server) requireValidSession(w http.ResponseWriter, r *http.Request) (*SessionData, bool) {
 	session, err := s.getSessionFromCookie(r)
 	if err != nil {
-		s.log.WithError(err).Debug("No valid session found, redirecting to slideshow")
+		s.logFrom(r).WithError(err).Debug("No valid session found, redirecting to slideshow")
 		s.redirectToSlideshow(w, r)
 		return nil, false
 	}
 
 	// Additional validation: check if session has meaningful data
 	if session.StartedAt == 0 {
-		s.log.Debug("Session has no start time, redirecting to slideshow")
+		s.logFrom(r).Debug("Session has no start time, redirecting to slideshow")
 		s.redirectToSlideshow(w, r)
 		return nil, false
 	}
@@ -148,11 +244,10 @@ func (s *Server) requireValidSession(w http.ResponseWriter, r *http.Request) (*S
 
 // handleControlPage renders the control page
 func (s *Server) handleControlPage(w http.ResponseWriter, r *http.Request) {
-	stats, err := s.scanner.GetStats()
-	if err != nil {
-		s.log.WithError(err).Error("Failed to get stats")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+	stats, ok := s.safeGetStats(r)
+	var warning string
+	if !ok {
+		warning = "Statistics could not be loaded; the numbers below may be out of date."
 	}
 
 	// Check for existing session from cookie
@@ -161,27 +256,18 @@ func (s *Server) handleControlPage(w http.ResponseWriter, r *http.Request) {
 	var sessionTotalCount int
 	var sessionDeletedSize int64
 
-	sessionCookie, err := r.Cookie("slideshow_session")
-	if err == nil && sessionCookie.Value != "" {
-		// Decode the cookie value
-		jsonData, err := base64.StdEncoding.DecodeString(sessionCookie.Value)
-		if err == nil {
-			var session SessionData
-			err = json.Unmarshal(jsonData, &session)
-			if err == nil && session.TotalImages > 0 {
-				hasSession = true
-				sessionViewedCount = session.ViewedCount
-				sessionTotalCount = session.TotalImages
-				sessionDeletedSize = session.DeletedSize
-			}
-		}
+	if session, err := s.getSessionFromCookie(r); err == nil && session.TotalImages > 0 {
+		hasSession = true
+		sessionViewedCount = session.ViewedCount
+		sessionTotalCount = session.TotalImages
+		sessionDeletedSize = session.DeletedSize
 	}
 
 	// Parse template
 	tmpl, err := template.ParseFiles(filepath.Join(s.cfg.TemplatesDir, "control.html"))
 	if err != nil {
-		s.log.WithError(err).Error("Failed to parse template")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.logFrom(r).WithError(err).Error("Failed to parse template")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
@@ -197,6 +283,9 @@ func (s *Server) handleControlPage(w http.ResponseWriter, r *http.Request) {
 		ViewedSizeFormatted         string
 		UnviewedSizeFormatted       string
 		SessionDeletedSizeFormatted string
+		Warning                     string
+		Maintenance                 bool
+		MaintenanceMessage          string
 	}{
 		Stats:                       stats,
 		IsScanning:                  s.scanner.IsScanning(),
@@ -208,19 +297,37 @@ func (s *Server) handleControlPage(w http.ResponseWriter, r *http.Request) {
 		ViewedSizeFormatted:         formatBytes(stats.ViewedSize),
 		UnviewedSizeFormatted:       formatBytes(stats.UnviewedSize),
 		SessionDeletedSizeFormatted: formatBytes(sessionDeletedSize),
+		Warning:                     warning,
 	}
+	maintenance := s.maintenanceStatus()
+	data.Maintenance, data.MaintenanceMessage = maintenance.Active, maintenance.Message
 
 	if err := tmpl.Execute(w, data); err != nil {
-		s.log.WithError(err).Error("Failed to render template")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.logFrom(r).WithError(err).Error("Failed to render template")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 }
 
+// rejectBusy answers 409 while the scanner runs a scan, cleanup or deletion run, with
+// scanningMsg when it is a scan, and reports whether it did
+func (s *Server) rejectBusy(w http.ResponseWriter, r *http.Request, scanningMsg string) bool {
+	switch s.scanner.BusyOperation() {
+	case "":
+		return false
+	case scanner.OpScan:
+		s.writeError(w, r, http.StatusConflict, scanningMsg)
+	case scanner.OpCleanup:
+		s.writeError(w, r, http.StatusConflict, "A cleanup is in progress")
+	default:
+		s.writeError(w, r, http.StatusConflict, "Deletion processing is in progress")
+	}
+	return true
+}
+
 // handleScan triggers a scan for new movies
 func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
-	if s.scanner.IsScanning() {
-		http.Error(w, "Scan already in progress", http.StatusConflict)
+	if s.rejectBusy(w, r, "Scan already in progress") {
 		return
 	}
 
@@ -230,8 +337,8 @@ func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
 
 	go func() {
 		defer cancel() // Ensure context is cancelled when operation completes
-		if err := s.scanner.ScanMovies(ctx); err != nil {
-			s.log.WithError(err).Error("Scan failed")
+		if _, err := s.scanner.ScanMovies(ctx, scanner.ScanTypeManual); err != nil {
+			s.logFrom(r).WithError(err).Error("Scan failed")
 		}
 	}()
 
@@ -239,15 +346,83 @@ func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// handleAPIScan starts a scan in the background. With ?import=true, existing
+// thumbnail files are imported during this run regardless of IMPORT_EXISTING, and
+// with ?wait=true the scan runs synchronously and its result is returned.
+func (s *Server) handleAPIScan(w http.ResponseWriter, r *http.Request) {
+	if s.rejectBusy(w, r, "Scan already in progress") {
+		return
+	}
+
+	opts := scanner.ScanOptions{ImportExisting: s.cfg.ImportExisting, Type: scanner.ScanTypeManual}
+	if v := r.URL.Query().Get("import"); v != "" {
+		importExisting, err := strconv.ParseBool(v)
+		if err != nil {
+			s.writeError(w, r, http.StatusBadRequest, "Invalid import value")
+			return
+		}
+		opts.ImportExisting = importExisting
+		if importExisting {
+			opts.Type = scanner.ScanTypeImport
+		}
+	}
+
+	if r.URL.Query().Get("wait") == "true" {
+		s.scanAndWait(w, r, opts)
+		return
+	}
+
+	// 30 minutes should be enough for a manual triggered scan
+	ctx, cancel := context.WithTimeout(s.appCtx, 30*time.Minute)
+
+	go func() {
+		defer cancel()
+		if _, err := s.scanner.ScanMoviesWithOptions(ctx, opts); err != nil {
+			s.logFrom(r).WithError(err).Error("Scan failed")
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":         true,
+		"import_existing": opts.ImportExisting,
+		"scan_type":       opts.Type,
+	})
+}
+
+// scanAndWait runs a scan synchronously and writes its result as JSON
+func (s *Server) scanAndWait(w http.ResponseWriter, r *http.Request, opts scanner.ScanOptions) {
+	// Scanning may take longer than the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(30 * time.Minute)); err != nil {
+		s.logFrom(r).WithError(err).Debug("Failed to extend write deadline")
+	}
+
+	ctx, cancel := context.WithTimeout(s.appCtx, 30*time.Minute)
+	defer cancel()
+
+	result, err := s.scanner.ScanMoviesWithOptions(ctx, opts)
+	if errors.Is(err, scanner.ErrScanInProgress) || errors.Is(err, scanner.ErrBusy) {
+		s.writeError(w, r, http.StatusConflict, "Scan already in progress")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		s.logFrom(r).WithError(err).Error("Scan failed")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
 // handleCleanup triggers a cleanup of orphaned entries and thumbnails
 func (s *Server) handleCleanup(w http.ResponseWriter, r *http.Request) {
 	if s.cfg.DisableDeletion {
-		http.Error(w, "Cleanup is disabled via DISABLE_DELETION flag", http.StatusForbidden)
+		s.writeError(w, r, http.StatusForbidden, "Cleanup is disabled via DISABLE_DELETION flag")
 		return
 	}
 
-	if s.scanner.IsScanning() {
-		http.Error(w, "Cannot perform cleanup while scanning", http.StatusConflict)
+	if s.rejectBusy(w, r, "Cannot perform cleanup while scanning") {
 		return
 	}
 
@@ -257,7 +432,7 @@ func (s *Server) handleCleanup(w http.ResponseWriter, r *http.Request) {
 	go func() {
 		defer cancel() // Ensure context is cancelled when operation completes
 		if err := s.scanner.CleanupOrphans(ctx); err != nil {
-			s.log.WithError(err).Error("Cleanup failed")
+			s.logFrom(r).WithError(err).Error("Cleanup failed")
 		}
 	}()
 
@@ -265,12 +440,60 @@ func (s *Server) handleCleanup(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// handleAPICleanup starts a cleanup in the background. ?phase= runs only the given
+// comma-separated phases, in that order, instead of those of CLEANUP_PHASES.
+func (s *Server) handleAPICleanup(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.DisableDeletion {
+		s.writeError(w, r, http.StatusForbidden, "Cleanup is disabled via DISABLE_DELETION flag")
+		return
+	}
+
+	phases := s.cfg.CleanupPhases
+	if v := r.URL.Query().Get("phase"); v != "" {
+		phases = strings.Split(v, ",")
+	}
+	if len(phases) == 0 {
+		phases = config.DefaultCleanupPhases
+	}
+	if err := config.ValidateCleanupPhases(phases); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if s.rejectBusy(w, r, "Cannot perform cleanup while scanning") {
+		return
+	}
+
+	// Create a timeout context derived from the application context
+	ctx, cancel := context.WithTimeout(s.appCtx, 15*time.Minute)
+
+	go func() {
+		defer cancel() // Ensure context is cancelled when operation completes
+		if err := s.scanner.CleanupPhases(ctx, phases...); err != nil {
+			s.logFrom(r).WithError(err).Error("Cleanup failed")
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"phases":  phases,
+	})
+}
+
 // handleResetViews resets the viewed status of all thumbnails
 func (s *Server) handleResetViews(w http.ResponseWriter, r *http.Request) {
-	count, err := s.scanner.ResetViewedStatus()
+	filter, err := parseResetFilter(r)
 	if err != nil {
-		s.log.WithError(err).Error("Failed to reset views")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	count, err := s.scanner.ResetViewedStatus(filter)
+	if err != nil {
+		s.logFrom(r).WithError(err).Error("Failed to reset views")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
@@ -286,23 +509,92 @@ func (s *Server) handleResetViews(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// parseResetFilter reads the optional reset-views criteria from the query string or form body:
+// min_size, max_size (bytes), created_after, created_before (YYYY-MM-DD or RFC 3339),
+// source and path_prefix
+func parseResetFilter(r *http.Request) (database.ResetFilter, error) {
+	var filter database.ResetFilter
+	if err := r.ParseForm(); err != nil {
+		return filter, fmt.Errorf("invalid form data")
+	}
+
+	parseSize := func(key string) (int64, error) {
+		v := r.Form.Get(key)
+		if v == "" {
+			return 0, nil
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid %s", key)
+		}
+		return n, nil
+	}
+	parseTime := func(key string) (time.Time, error) {
+		v := r.Form.Get(key)
+		if v == "" {
+			return time.Time{}, nil
+		}
+		if t, err := time.Parse("2006-01-02", v); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s", key)
+		}
+		return t, nil
+	}
+
+	var err error
+	if filter.MinSize, err = parseSize("min_size"); err != nil {
+		return filter, err
+	}
+	if filter.MaxSize, err = parseSize("max_size"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedAfter, err = parseTime("created_after"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedBefore, err = parseTime("created_before"); err != nil {
+		return filter, err
+	}
+
+	filter.Source = r.Form.Get("source")
+	if filter.Source != "" && !models.ValidSource(filter.Source) {
+		return filter, fmt.Errorf("invalid source")
+	}
+	filter.PathPrefix = r.Form.Get("path_prefix")
+
+	if v := r.Form.Get("clear_history"); v != "" {
+		if filter.ClearHistory, err = strconv.ParseBool(v); err != nil {
+			return filter, fmt.Errorf("invalid clear_history")
+		}
+	}
+
+	return filter, nil
+}
+
 // handleProcessDeletions triggers immediate processing of the deletion queue
 func (s *Server) handleProcessDeletions(w http.ResponseWriter, r *http.Request) {
 	if s.cfg.DisableDeletion {
-		http.Error(w, "Deletion processing is disabled via DISABLE_DELETION flag", http.StatusForbidden)
+		s.writeError(w, r, http.StatusForbidden, "Deletion processing is disabled via DISABLE_DELETION flag")
+		return
+	}
+
+	if s.rejectBusy(w, r, "Cannot process deletions while scanning") {
 		return
 	}
 
-	if s.scanner.IsScanning() {
-		http.Error(w, "Cannot process deletions while scanning", http.StatusConflict)
+	// Synchronous mode: process the queue now and report the result
+	if r.URL.Query().Get("wait") == "true" {
+		s.processDeletionsAndWait(w, r)
 		return
 	}
 
 	// Get the count of deleted items before processing
 	stats, err := s.scanner.GetStats()
 	if err != nil {
-		s.log.WithError(err).Error("Failed to get stats")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.logFrom(r).WithError(err).Error("Failed to get stats")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
@@ -315,7 +607,7 @@ func (s *Server) handleProcessDeletions(w http.ResponseWriter, r *http.Request)
 	go func() {
 		defer cancel() // Ensure context is cancelled when operation completes
 		if err := s.scanner.CleanupOrphans(ctx); err != nil {
-			s.log.WithError(err).Error("Process deletions failed")
+			s.logFrom(r).WithError(err).Error("Process deletions failed")
 		}
 	}()
 
@@ -332,16 +624,15 @@ func (s *Server) handleProcessDeletions(w http.ResponseWriter, r *http.Request)
 
 // handleProcessArchival triggers immediate processing of the archival queue
 func (s *Server) handleProcessArchival(w http.ResponseWriter, r *http.Request) {
-	if s.scanner.IsScanning() {
-		http.Error(w, "Cannot process archival while scanning", http.StatusConflict)
+	if s.rejectBusy(w, r, "Cannot process archival while scanning") {
 		return
 	}
 
 	// Get the count of archived items before processing
 	stats, err := s.scanner.GetStats()
 	if err != nil {
-		s.log.WithError(err).Error("Failed to get stats")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.logFrom(r).WithError(err).Error("Failed to get stats")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
@@ -354,7 +645,7 @@ func (s *Server) handleProcessArchival(w http.ResponseWriter, r *http.Request) {
 	go func() {
 		defer cancel() // Ensure context is cancelled when operation completes
 		if err := s.scanner.CleanupOrphans(ctx); err != nil {
-			s.log.WithError(err).Error("Process archival failed")
+			s.logFrom(r).WithError(err).Error("Process archival failed")
 		}
 	}()
 
@@ -377,43 +668,71 @@ func (s *Server) handleSlideshow(w http.ResponseWriter, r *http.Request) {
 	var session *SessionData
 
 	if newSession {
+		// ?source= restricts the new session to generated or imported thumbnails
+		source := r.URL.Query().Get("source")
+		if source != "" && !models.ValidSource(source) {
+			s.writeError(w, r, http.StatusBadRequest, "Invalid source")
+			return
+		}
+
 		// Create a new session
 		var err error
 		session, err = s.createNewSession()
 		if err != nil {
-			s.log.WithError(err).Error("Failed to create new session")
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			s.logFrom(r).WithError(err).Error("Failed to create new session")
+			s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 			return
 		}
-		s.log.Debug("Created new session")
+		if source != "" {
+			s.restrictSessionSource(session, source)
+		}
+		s.logFrom(r).WithField("source", source).Debug("Created new session")
 
 		// Save to cookie
 		if err := s.saveSessionToCookie(w, session); err != nil {
-			s.log.WithError(err).Error("Failed to save new session to cookie")
+			s.logFrom(r).WithError(err).Error("Failed to save new session to cookie")
 			// Continue without session cookie
 		}
 	} else {
 		// Try to get existing session from cookie
 		var err error
+		var expired *SessionData
 		session, err = s.getSessionFromCookie(r)
+		if errors.Is(err, errSessionExpired) {
+			expired = session
+		}
 		if err != nil {
 			// No valid session found, create a new one
-			s.log.WithError(err).Debug("No valid session found, creating new session")
+			s.logFrom(r).WithError(err).Debug("No valid session found, creating new session")
+			if expired != nil {
+				s.recordExpiredSession(r, expired)
+			}
 			session, err = s.createNewSession()
 			if err != nil {
-				s.log.WithError(err).Error("Failed to create fallback session")
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				s.logFrom(r).WithError(err).Error("Failed to create fallback session")
+				s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 				return
 			}
 
 			// Save to cookie
 			if err := s.saveSessionToCookie(w, session); err != nil {
-				s.log.WithError(err).Error("Failed to save fallback session to cookie")
+				s.logFrom(r).WithError(err).Error("Failed to save fallback session to cookie")
 				// Continue without session cookie
 			}
 		}
 	}
 
+	// ?artifact= switches the artifact shown for the rest of the session
+	artifactChanged := false
+	if artifact := r.URL.Query().Get("artifact"); artifact != "" {
+		if !models.ValidArtifact(artifact) {
+			s.writeError(w, r, http.StatusBadRequest, "Invalid artifact")
+			return
+		}
+		artifactChanged = artifact != session.Artifact
+		session.Artifact = artifact
+	}
+
 	// Use session's current ID as target (no more ID parameter support)
 	targetID := session.CurrentID
 
@@ -426,17 +745,17 @@ func (s *Server) handleSlideshow(w http.ResponseWriter, r *http.Request) {
 		thumbnail, err = s.db.GetByID(targetID)
 		if err != nil || thumbnail == nil {
 			// If the stored thumbnail doesn't exist anymore, get a new random one
-			s.log.WithError(err).WithField("targetID", targetID).Warn("Stored thumbnail not found, getting new random thumbnail")
-			thumbnail, err = s.db.GetRandomUnviewedThumbnail()
+			s.logFrom(r).WithError(err).WithField("targetID", targetID).Warn("Stored thumbnail not found, getting new random thumbnail")
+			thumbnail, err = s.nextCandidate(session)
 		}
 	} else {
-		// No current thumbnail in session, get a random unviewed thumbnail
-		thumbnail, err = s.db.GetRandomUnviewedThumbnail()
+		// No current thumbnail in session, get the first one for the session's order
+		thumbnail, err = s.nextCandidate(session)
 	}
 
 	if err != nil {
-		s.log.WithError(err).Error("Failed to get thumbnail")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.logFrom(r).WithError(err).Error("Failed to get thumbnail")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
@@ -452,7 +771,7 @@ func (s *Server) handleSlideshow(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update session with current thumbnail
-	s.log.WithFields(map[string]interface{}{
+	s.logFrom(r).WithFields(map[string]interface{}{
 		"thumbnailID":            thumbnail.ID,
 		"sessionCurrentID":       session.CurrentID,
 		"sessionViewedCount":     session.ViewedCount,
@@ -461,17 +780,17 @@ func (s *Server) handleSlideshow(w http.ResponseWriter, r *http.Request) {
 		"newSession":             newSession,
 	}).Debug("Before session update check")
 
-	shouldUpdateSession := false
+	shouldUpdateSession := artifactChanged
 	if newSession {
 		// For new sessions, always set the first thumbnail without incrementing counters
 		if session.CurrentID == 0 {
-			s.log.Debug("New session: setting first thumbnail without incrementing counters")
+			s.logFrom(r).Debug("New session: setting first thumbnail without incrementing counters")
 			session.CurrentID = thumbnail.ID
 			shouldUpdateSession = true
 		}
 	} else if thumbnail.ID != session.CurrentID {
 		// For existing sessions, only update if we're viewing a different thumbnail
-		s.log.Debug("Existing session: viewing different thumbnail, updating with navigation logic")
+		s.logFrom(r).Debug("Existing session: viewing different thumbnail, updating with navigation logic")
 		if session.CurrentID > 0 {
 			// This is actual navigation between thumbnails
 			session.ViewedCount++
@@ -483,7 +802,7 @@ func (s *Server) handleSlideshow(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if shouldUpdateSession {
-		s.log.WithFields(map[string]interface{}{
+		s.logFrom(r).WithFields(map[string]interface{}{
 			"newCurrentID":       session.CurrentID,
 			"newViewedCount":     session.ViewedCount,
 			"newNavigationCount": session.NavigationCount,
@@ -493,10 +812,10 @@ func (s *Server) handleSlideshow(w http.ResponseWriter, r *http.Request) {
 		// Pre-determine the next thumbnail for prefetch coordination
 		// Only do this if we don't already have a NextID or if this is a new session
 		if session.NextID == 0 || newSession {
-			nextThumbnail, err := s.db.GetRandomUnviewedThumbnail()
+			nextThumbnail, err := s.nextCandidate(session, thumbnail.ID)
 			if err == nil && nextThumbnail != nil {
 				session.NextID = nextThumbnail.ID
-				s.log.WithFields(logrus.Fields{
+				s.logFrom(r).WithFields(logrus.Fields{
 					"nextID":  session.NextID,
 					"context": "slideshow_display",
 				}).Debug("Pre-determined next thumbnail for prefetch coordination")
@@ -505,10 +824,10 @@ func (s *Server) handleSlideshow(w http.ResponseWriter, r *http.Request) {
 
 		// Save the updated session
 		if err := s.saveSessionToCookie(w, session); err != nil {
-			s.log.WithError(err).Error("Failed to save updated session")
+			s.logFrom(r).WithError(err).Error("Failed to save updated session")
 		}
 	} else {
-		s.log.Debug("No session update needed")
+		s.logFrom(r).Debug("No session update needed")
 	}
 
 	// Calculate current position in this session
@@ -517,35 +836,19 @@ func (s *Server) handleSlideshow(w http.ResponseWriter, r *http.Request) {
 	// Parse template
 	tmpl, err := template.ParseFiles(filepath.Join(s.cfg.TemplatesDir, "slideshow.html"))
 	if err != nil {
-		s.log.WithError(err).Error("Failed to parse template")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.logFrom(r).WithError(err).Error("Failed to parse template")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
-	// Check if this is the last thumbnail by seeing if there are any more unviewed thumbnails
-	// excluding the current one and any pending viewed thumbnails
-	var excludeForCount []int64
-	excludeForCount = append(excludeForCount, thumbnail.ID)
-	// Also exclude the previous thumbnail that will be marked as viewed on next navigation
-	if session.PreviousID > 0 && session.PreviousID != thumbnail.ID {
-		excludeForCount = append(excludeForCount, session.PreviousID)
-	}
+	// Check if this is the last thumbnail
+	isLastThumbnail, excludeForCount := s.isLastThumbnail(thumbnail.ID, session)
 
-	remainingThumbnail, err := s.db.GetRandomUnviewedThumbnailExcluding(excludeForCount...)
-	isLastThumbnail := (err != nil || remainingThumbnail == nil)
-
-	s.log.WithFields(logrus.Fields{
+	s.logFrom(r).WithFields(logrus.Fields{
 		"currentThumbnailID":  thumbnail.ID,
 		"previousThumbnailID": session.PreviousID,
 		"excludeForCount":     excludeForCount,
-		"remainingThumbnail": func() interface{} {
-			if remainingThumbnail != nil {
-				return remainingThumbnail.ID
-			}
-			return "nil"
-		}(),
-		"isLastThumbnail": isLastThumbnail,
-		"err":             err,
+		"isLastThumbnail":     isLastThumbnail,
 	}).Debug("Last thumbnail check")
 
 	// Render template with data
@@ -559,25 +862,63 @@ func (s *Server) handleSlideshow(w http.ResponseWriter, r *http.Request) {
 		IsLastThumbnail             bool
 		SessionDeletedSize          int64
 		SessionDeletedSizeFormatted string
+		LibraryPosition             int
+		LibraryTotal                int
+		Maintenance                 bool
+		MaintenanceMessage          string
+		ImagePath                   string
 	}{
 		Thumbnail:                   thumbnail,
 		Total:                       session.TotalImages,
 		Current:                     position,
-		HasPrevious:                 session.PreviousID > 0 && session.PreviousID != session.CurrentID,
+		HasPrevious:                 session.hasPrevious(),
 		PendingDelete:               session.PendingDelete,
 		PendingArchive:              session.PendingArchive,
 		IsLastThumbnail:             isLastThumbnail,
 		SessionDeletedSize:          session.DeletedSize,
 		SessionDeletedSizeFormatted: formatBytes(session.DeletedSize),
+		ImagePath:                   thumbnail.ArtifactPath(session.Artifact),
+	}
+	if s.cfg.ShowLibraryPosition {
+		data.LibraryPosition, data.LibraryTotal = s.libraryPosition(r, thumbnail)
 	}
+	maintenance := s.maintenanceStatus()
+	data.Maintenance, data.MaintenanceMessage = maintenance.Active, maintenance.Message
 
 	if err := tmpl.Execute(w, data); err != nil {
-		s.log.WithError(err).Error("Failed to render template")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.logFrom(r).WithError(err).Error("Failed to render template")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 }
 
+// libraryPosition returns where an unviewed thumbnail sits among all unviewed
+// thumbnails. Both values are 0 when the thumbnail is viewed or a lookup fails.
+func (s *Server) libraryPosition(r *http.Request, thumbnail *models.Thumbnail) (position, total int) {
+	if thumbnail.IsViewed() || thumbnail.Status != models.StatusSuccess {
+		return 0, 0
+	}
+
+	position, err := s.db.GetThumbnailPosition(thumbnail.ID)
+	if err != nil {
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", thumbnail.ID).Warn("Failed to get library position")
+		return 0, 0
+	}
+	total, err = s.db.GetUnviewedThumbnailCount()
+	if err != nil {
+		s.logFrom(r).WithError(err).Warn("Failed to count unviewed thumbnails")
+		return 0, 0
+	}
+	return position, total
+}
+
+// recordSlideshowAction counts a view, skip, delete or undo made in the slideshow
+func (s *Server) recordSlideshowAction(action string) {
+	if s.metrics != nil {
+		s.metrics.RecordSlideshowAction(action)
+	}
+}
+
 // handleSlideshowNext shows the next thumbnail in the slideshow
 func (s *Server) handleSlideshowNext(w http.ResponseWriter, r *http.Request) {
 	// Require valid session - redirect to /slideshow if none found
@@ -599,18 +940,18 @@ func (s *Server) handleSlideshowNext(w http.ResponseWriter, r *http.Request) {
 			// Get the thumbnail to obtain its file size before marking for deletion
 			deletedThumbnail, err := s.db.GetByID(session.PreviousID)
 			if err != nil {
-				s.log.WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to get thumbnail for deletion size tracking")
+				s.logFrom(r).WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to get thumbnail for deletion size tracking")
 			}
 
 			if err := s.db.MarkForDeletionByID(session.PreviousID); err != nil {
-				s.log.WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to commit pending deletion")
+				s.logFrom(r).WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to commit pending deletion")
 			} else {
-				s.log.WithField("thumbnail_id", session.PreviousID).Debug("Committed pending deletion to database")
+				s.logFrom(r).WithField("thumbnail_id", session.PreviousID).Debug("Committed pending deletion to database")
 
 				// Add the file size to the session's deleted size counter
 				if deletedThumbnail != nil {
 					session.DeletedSize += deletedThumbnail.FileSize
-					s.log.WithFields(logrus.Fields{
+					s.logFrom(r).WithFields(logrus.Fields{
 						"thumbnail_id":       session.PreviousID,
 						"file_size":          deletedThumbnail.FileSize,
 						"total_deleted_size": session.DeletedSize,
@@ -622,18 +963,18 @@ func (s *Server) handleSlideshowNext(w http.ResponseWriter, r *http.Request) {
 		} else if session.PendingArchive {
 			// Commit pending archival when moving to next
 			if err := s.db.MarkForArchivalByID(session.PreviousID); err != nil {
-				s.log.WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to commit pending archival")
+				s.logFrom(r).WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to commit pending archival")
 			} else {
-				s.log.WithField("thumbnail_id", session.PreviousID).Debug("Committed pending archival to database")
+				s.logFrom(r).WithField("thumbnail_id", session.PreviousID).Debug("Committed pending archival to database")
 			}
 			// Clear the pending archival
 			session.PendingArchive = false
 		} else {
 			// Mark the previous thumbnail as viewed (delayed from last navigation)
 			if err := s.db.MarkAsViewedByID(session.PreviousID); err != nil {
-				s.log.WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to mark previous thumbnail as viewed")
+				s.logFrom(r).WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to mark previous thumbnail as viewed")
 			} else {
-				s.log.WithField("thumbnail_id", session.PreviousID).Debug("Marked previous thumbnail as viewed (delayed)")
+				s.logFrom(r).WithField("thumbnail_id", session.PreviousID).Debug("Marked previous thumbnail as viewed (delayed)")
 				session.ViewedCount++
 			}
 		}
@@ -644,20 +985,33 @@ func (s *Server) handleSlideshowNext(w http.ResponseWriter, r *http.Request) {
 	var nextThumbnail *models.Thumbnail
 	var err error
 
-	if session.NextID > 0 {
+	// A playlist is walked in order; once it runs out the usual selection takes over
+	if session.inPlaylist() {
+		nextThumbnail, session.PlaylistPos, err = s.playlistThumbnail(session.Playlist, session.PlaylistPos+1, 1)
+		if err != nil {
+			s.logFrom(r).WithError(err).Error("Failed to get next playlist thumbnail")
+			s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		if nextThumbnail == nil {
+			session.NextID = 0
+		}
+	}
+
+	if nextThumbnail == nil && session.NextID > 0 {
 		// Use the pre-determined next thumbnail
 		nextThumbnail, err = s.db.GetByID(session.NextID)
 		if err != nil {
-			s.log.WithError(err).WithField("nextID", session.NextID).Error("Failed to get predetermined next thumbnail")
+			s.logFrom(r).WithError(err).WithField("nextID", session.NextID).Error("Failed to get predetermined next thumbnail")
 			// Fall back to random
 			session.NextID = 0
-		} else if nextThumbnail != nil && nextThumbnail.IsViewed() {
+		} else if nextThumbnail != nil && session.seen(nextThumbnail) {
 			// The predetermined thumbnail was already viewed, get a new one
-			s.log.WithField("nextID", session.NextID).Debug("Predetermined next thumbnail was already viewed, getting new random")
+			s.logFrom(r).WithField("nextID", session.NextID).Debug("Predetermined next thumbnail was already viewed, getting new random")
 			session.NextID = 0
 			nextThumbnail = nil
 		} else if nextThumbnail != nil {
-			s.log.WithFields(logrus.Fields{
+			s.logFrom(r).WithFields(logrus.Fields{
 				"nextID":        session.NextID,
 				"thumbnailPath": nextThumbnail.ThumbnailPath,
 				"movieFilename": nextThumbnail.MovieFilename,
@@ -667,7 +1021,7 @@ func (s *Server) handleSlideshowNext(w http.ResponseWriter, r *http.Request) {
 
 	// If we don't have a valid predetermined thumbnail, get a random one
 	if nextThumbnail == nil {
-		s.log.Debug("Getting random thumbnail (no predetermined NextID or it was invalid)")
+		s.logFrom(r).Debug("Getting random thumbnail (no predetermined NextID or it was invalid)")
 
 		// Exclude current ID to avoid getting the same thumbnail
 		var excludeIDs []int64
@@ -675,14 +1029,22 @@ func (s *Server) handleSlideshowNext(w http.ResponseWriter, r *http.Request) {
 			excludeIDs = append(excludeIDs, currentID)
 		}
 
-		nextThumbnail, err = s.db.GetRandomUnviewedThumbnailExcluding(excludeIDs...)
+		nextThumbnail, err = s.nextCandidate(session, excludeIDs...)
 		if err != nil {
-			s.log.WithError(err).Error("Failed to get next thumbnail")
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			s.logFrom(r).WithError(err).Error("Failed to get next thumbnail")
+			s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 			return
 		}
 	}
 
+	if currentID > 0 {
+		if skipViewing {
+			s.recordSlideshowAction("skip")
+		} else {
+			s.recordSlideshowAction("view")
+		}
+	}
+
 	// If no next thumbnail, redirect to control page
 	if nextThumbnail == nil {
 		http.SetCookie(w, &http.Cookie{
@@ -701,6 +1063,12 @@ func (s *Server) handleSlideshowNext(w http.ResponseWriter, r *http.Request) {
 		if err == nil && thumbnail != nil && thumbnail.Status != models.StatusDeleted {
 			// Store current ID as previous for single undo (viewing will be deferred)
 			session.PreviousID = currentID
+
+			// Movies matching AUTO_DELETE_CRITERIA are staged for deletion instead
+			if !session.PendingArchive && s.autoDeleteMatches(thumbnail) {
+				session.PendingDelete = true
+				s.logAutoDeletion(r, thumbnail)
+			}
 		}
 	} else if currentID > 0 && skipViewing {
 		// For skip operation, check if the next thumbnail is different from current
@@ -711,7 +1079,7 @@ func (s *Server) handleSlideshowNext(w http.ResponseWriter, r *http.Request) {
 		} else {
 			// If we're skipping the last thumbnail or getting the same thumbnail,
 			// don't update PreviousID to avoid same ID issue
-			s.log.WithFields(logrus.Fields{
+			s.logFrom(r).WithFields(logrus.Fields{
 				"currentID": currentID,
 				"nextID": func() int64 {
 					if nextThumbnail != nil {
@@ -738,10 +1106,16 @@ func (s *Server) handleSlideshowNext(w http.ResponseWriter, r *http.Request) {
 		excludeIDs = append(excludeIDs, session.PreviousID)
 	}
 
-	nextNextThumbnail, err := s.db.GetRandomUnviewedThumbnailExcluding(excludeIDs...)
+	var nextNextThumbnail *models.Thumbnail
+	if session.inPlaylist() {
+		nextNextThumbnail, _, err = s.playlistThumbnail(session.Playlist, session.PlaylistPos+1, 1)
+	}
+	if err == nil && nextNextThumbnail == nil {
+		nextNextThumbnail, err = s.nextCandidate(session, excludeIDs...)
+	}
 	if err == nil && nextNextThumbnail != nil {
 		session.NextID = nextNextThumbnail.ID
-		s.log.WithFields(logrus.Fields{
+		s.logFrom(r).WithFields(logrus.Fields{
 			"currentID": session.CurrentID,
 			"nextID":    session.NextID,
 		}).Debug("Pre-determined next thumbnail for prefetch coordination")
@@ -749,7 +1123,7 @@ func (s *Server) handleSlideshowNext(w http.ResponseWriter, r *http.Request) {
 
 	// Save the updated session
 	if err := s.saveSessionToCookie(w, session); err != nil {
-		s.log.WithError(err).Error("Failed to save updated session")
+		s.logFrom(r).WithError(err).Error("Failed to save updated session")
 	}
 
 	// Redirect to slideshow without ID parameter (uses session state)
@@ -773,11 +1147,11 @@ func (s *Server) handleSlideshowPrevious(w http.ResponseWriter, r *http.Request)
 		operationThumbnailID := session.PreviousID
 
 		if session.PendingDelete {
-			s.log.WithFields(logrus.Fields{
+			s.logFrom(r).WithFields(logrus.Fields{
 				"thumbnail": operationThumbnailID,
 			}).Info("Undoing pending deletion")
 		} else {
-			s.log.WithFields(logrus.Fields{
+			s.logFrom(r).WithFields(logrus.Fields{
 				"thumbnail": operationThumbnailID,
 			}).Info("Undoing pending archival")
 		}
@@ -791,9 +1165,12 @@ func (s *Server) handleSlideshowPrevious(w http.ResponseWriter, r *http.Request)
 				// Navigate back to the previously marked thumbnail
 				session.CurrentID = operationThumbnailID
 				session.NextID = currentID // Save current thumbnail as next for navigation coordination
+				session.syncPlaylistPos()
 			}
 		}
 
+		s.recordSlideshowAction("undo")
+
 		// Clear the pending operations from session
 		session.PendingDelete = false
 		session.PendingArchive = false
@@ -801,7 +1178,7 @@ func (s *Server) handleSlideshowPrevious(w http.ResponseWriter, r *http.Request)
 
 		// Save the updated session
 		if err := s.saveSessionToCookie(w, session); err != nil {
-			s.log.WithError(err).Error("Failed to save session after undo")
+			s.logFrom(r).WithError(err).Error("Failed to save session after undo")
 		}
 
 		// Redirect to slideshow (will show the restored thumbnail)
@@ -809,6 +1186,28 @@ func (s *Server) handleSlideshowPrevious(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Within a playlist, go back to the previous entry that can still be shown
+	if session.inPlaylist() && session.PlaylistPos > 0 {
+		prevThumbnail, pos, err := s.playlistThumbnail(session.Playlist, session.PlaylistPos-1, -1)
+		if err != nil {
+			s.logFrom(r).WithError(err).Error("Failed to get previous playlist thumbnail")
+			s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		if prevThumbnail != nil {
+			session.CurrentID = prevThumbnail.ID
+			session.PlaylistPos = pos
+			session.NextID = currentID
+			session.PreviousID = 0 // The current slide is shown again next, not marked as viewed
+
+			if err := s.saveSessionToCookie(w, session); err != nil {
+				s.logFrom(r).WithError(err).Error("Failed to save session after navigation")
+			}
+		}
+		s.redirectToSlideshow(w, r)
+		return
+	}
+
 	// Regular previous navigation - check if we have a previous thumbnail
 	if session.PreviousID == 0 {
 		// No previous thumbnail, redirect back to current
@@ -842,13 +1241,14 @@ func (s *Server) handleSlideshowPrevious(w http.ResponseWriter, r *http.Request)
 	session.CurrentID = prevID
 	session.NextID = currentID // Save current slide as next ID for return navigation
 	session.PreviousID = 0     // Clear previous ID after going back (single undo consumed)
+	session.syncPlaylistPos()  // Going back from past the end of a playlist returns to its last entry
 
 	// When undoing navigation, we don't want to mark the previous slide as viewed
 	// since the user is going back to it
 
 	// Save the updated session
 	if err := s.saveSessionToCookie(w, session); err != nil {
-		s.log.WithError(err).Error("Failed to save session after navigation")
+		s.logFrom(r).WithError(err).Error("Failed to save session after navigation")
 	}
 
 	// Redirect to slideshow without ID parameter (uses session state)
@@ -866,26 +1266,44 @@ func (s *Server) handleMarkViewed(w http.ResponseWriter, r *http.Request) {
 	// Use current ID from session
 	thumbnailID := session.CurrentID
 	if thumbnailID == 0 {
-		http.Error(w, "No current thumbnail in session", http.StatusBadRequest)
+		s.writeError(w, r, http.StatusBadRequest, "No current thumbnail in session")
 		return
 	}
 
 	// Mark as viewed using session's current ID
 	if err := s.db.MarkAsViewedByID(thumbnailID); err != nil {
-		s.log.WithError(err).WithField("thumbnail_id", thumbnailID).Error("Failed to mark as viewed")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		if errors.Is(err, database.ErrNotFound) {
+			s.writeError(w, r, http.StatusNotFound, "Thumbnail not found")
+			return
+		}
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", thumbnailID).Error("Failed to mark as viewed")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
 	// Record view in metrics
-	s.metrics.RecordSlideshowView()
+	if s.metrics != nil {
+		s.metrics.RecordSlideshowView()
+	}
+	s.recordSlideshowAction("view")
+
+	// Movies matching AUTO_DELETE_CRITERIA are staged for deletion, which can be undone
+	if !s.autoDelete.IsEmpty() {
+		thumbnail, err := s.db.GetByID(thumbnailID)
+		if err != nil {
+			s.logFrom(r).WithError(err).WithField("thumbnail_id", thumbnailID).Error("Failed to get thumbnail for automatic deletion")
+		} else if s.autoDeleteMatches(thumbnail) {
+			s.stageDeletion(r, session, thumbnailID)
+			s.logAutoDeletion(r, thumbnail)
+		}
+	}
 
 	// Update session viewed count
 	session.ViewedCount++
 
 	// Save the updated session
 	if err := s.saveSessionToCookie(w, session); err != nil {
-		s.log.WithError(err).Error("Failed to save session after marking viewed")
+		s.logFrom(r).WithError(err).Error("Failed to save session after marking viewed")
 	}
 
 	// If ajax request, return JSON response
@@ -910,23 +1328,50 @@ func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
 	// Use current ID from session
 	thumbnailID := session.CurrentID
 	if thumbnailID == 0 {
-		http.Error(w, "No current thumbnail in session", http.StatusBadRequest)
+		s.writeError(w, r, http.StatusBadRequest, "No current thumbnail in session")
 		return
 	}
 
 	// Get the thumbnail record
 	thumbnail, err := s.db.GetByID(thumbnailID)
 	if err != nil {
-		s.log.WithError(err).WithField("thumbnail_id", thumbnailID).Error("Failed to get thumbnail")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", thumbnailID).Error("Failed to get thumbnail")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
 	if thumbnail == nil {
-		http.Error(w, "Thumbnail not found", http.StatusNotFound)
+		s.writeError(w, r, http.StatusNotFound, "Thumbnail not found")
+		return
+	}
+
+	s.stageDeletion(r, session, thumbnail.ID)
+
+	// Save the updated session
+	if err := s.saveSessionToCookie(w, session); err != nil {
+		s.logFrom(r).WithError(err).Error("Failed to save session after marking for deletion")
+	}
+	s.recordSlideshowAction("delete")
+
+	s.logFrom(r).WithFields(logrus.Fields{
+		"movie":        thumbnail.MoviePath,
+		"thumbnail_id": thumbnail.ID,
+	}).Debug("Marked movie for deletion in session (pending)")
+
+	// If ajax request, return JSON response
+	if r.Header.Get("X-Requested-With") == "XMLHttpRequest" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"success": true})
 		return
 	}
 
+	// Otherwise redirect to next (no longer passing current ID)
+	http.Redirect(w, r, "/slideshow/next", http.StatusSeeOther)
+}
+
+// stageDeletion marks a thumbnail for deletion in the session only, so it can still be
+// undone, after committing any pending operation and deferred view of the previous one
+func (s *Server) stageDeletion(r *http.Request, session *SessionData, thumbnailID int64) {
 	// Before setting up deletion, handle any previous thumbnails that need to be marked as viewed
 	// and any existing pending deletions or archival
 	if session.PendingDelete && session.PreviousID != 0 {
@@ -934,19 +1379,19 @@ func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
 		// Get the thumbnail to obtain its file size before marking for deletion
 		deletedThumbnail, err := s.db.GetByID(session.PreviousID)
 		if err != nil {
-			s.log.WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to get thumbnail for deletion size tracking")
+			s.logFrom(r).WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to get thumbnail for deletion size tracking")
 		}
 
 		if err := s.db.MarkForDeletionByID(session.PreviousID); err != nil {
-			s.log.WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to commit pending deletion")
+			s.logFrom(r).WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to commit pending deletion")
 			// Continue anyway - don't fail the current operation
 		} else {
-			s.log.WithField("thumbnail_id", session.PreviousID).Debug("Committed pending deletion to database")
+			s.logFrom(r).WithField("thumbnail_id", session.PreviousID).Debug("Committed pending deletion to database")
 
 			// Add the file size to the session's deleted size counter
 			if deletedThumbnail != nil {
 				session.DeletedSize += deletedThumbnail.FileSize
-				s.log.WithFields(logrus.Fields{
+				s.logFrom(r).WithFields(logrus.Fields{
 					"thumbnail_id":       session.PreviousID,
 					"file_size":          deletedThumbnail.FileSize,
 					"total_deleted_size": session.DeletedSize,
@@ -960,10 +1405,10 @@ func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
 	} else if session.PendingArchive && session.PreviousID != 0 {
 		// If there's already a pending archival, commit it to the database first
 		if err := s.db.MarkForArchivalByID(session.PreviousID); err != nil {
-			s.log.WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to commit pending archival")
+			s.logFrom(r).WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to commit pending archival")
 			// Continue anyway - don't fail the current operation
 		} else {
-			s.log.WithField("thumbnail_id", session.PreviousID).Debug("Committed pending archival to database")
+			s.logFrom(r).WithField("thumbnail_id", session.PreviousID).Debug("Committed pending archival to database")
 		}
 
 		// Clear the pending archival state
@@ -975,37 +1420,17 @@ func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
 	// This handles the normal case: A -> B -> delete B (mark A as viewed)
 	if session.PreviousID != 0 && session.PreviousID != thumbnailID {
 		if err := s.db.MarkAsViewedByID(session.PreviousID); err != nil {
-			s.log.WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to mark previous thumbnail as viewed before deletion")
+			s.logFrom(r).WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to mark previous thumbnail as viewed before deletion")
 		} else {
-			s.log.WithField("thumbnail_id", session.PreviousID).Debug("Marked previous thumbnail as viewed before deletion")
+			s.logFrom(r).WithField("thumbnail_id", session.PreviousID).Debug("Marked previous thumbnail as viewed before deletion")
 			session.ViewedCount++
 		}
 	}
 
 	// Mark the current thumbnail for deletion in the session only (not in database yet)
-	session.PreviousID = thumbnail.ID // Set as previous for undo functionality
-	session.PendingDelete = true      // Flag that PreviousID is pending deletion
-	session.PendingArchive = false    // Clear any pending archival
-
-	// Save the updated session
-	if err := s.saveSessionToCookie(w, session); err != nil {
-		s.log.WithError(err).Error("Failed to save session after marking for deletion")
-	}
-
-	s.log.WithFields(logrus.Fields{
-		"movie":        thumbnail.MoviePath,
-		"thumbnail_id": thumbnail.ID,
-	}).Debug("Marked movie for deletion in session (pending)")
-
-	// If ajax request, return JSON response
-	if r.Header.Get("X-Requested-With") == "XMLHttpRequest" {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]bool{"success": true})
-		return
-	}
-
-	// Otherwise redirect to next (no longer passing current ID)
-	http.Redirect(w, r, "/slideshow/next", http.StatusSeeOther)
+	session.PreviousID = thumbnailID // Set as previous for undo functionality
+	session.PendingDelete = true     // Flag that PreviousID is pending deletion
+	session.PendingArchive = false   // Clear any pending archival
 }
 
 // handleArchive marks a movie for archival in the session (soft archive with undo capability)
@@ -1019,20 +1444,20 @@ func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
 	// Use current ID from session
 	thumbnailID := session.CurrentID
 	if thumbnailID == 0 {
-		http.Error(w, "No current thumbnail in session", http.StatusBadRequest)
+		s.writeError(w, r, http.StatusBadRequest, "No current thumbnail in session")
 		return
 	}
 
 	// Get the thumbnail record
 	thumbnail, err := s.db.GetByID(thumbnailID)
 	if err != nil {
-		s.log.WithError(err).WithField("thumbnail_id", thumbnailID).Error("Failed to get thumbnail")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", thumbnailID).Error("Failed to get thumbnail")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
 	if thumbnail == nil {
-		http.Error(w, "Thumbnail not found", http.StatusNotFound)
+		s.writeError(w, r, http.StatusNotFound, "Thumbnail not found")
 		return
 	}
 
@@ -1042,13 +1467,13 @@ func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
 		// If there's already a pending deletion, commit it to the database first
 		deletedThumbnail, err := s.db.GetByID(session.PreviousID)
 		if err != nil {
-			s.log.WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to get thumbnail for deletion size tracking")
+			s.logFrom(r).WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to get thumbnail for deletion size tracking")
 		}
 
 		if err := s.db.MarkForDeletionByID(session.PreviousID); err != nil {
-			s.log.WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to commit pending deletion")
+			s.logFrom(r).WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to commit pending deletion")
 		} else {
-			s.log.WithField("thumbnail_id", session.PreviousID).Debug("Committed pending deletion to database")
+			s.logFrom(r).WithField("thumbnail_id", session.PreviousID).Debug("Committed pending deletion to database")
 
 			// Add the file size to the session's deleted size counter
 			if deletedThumbnail != nil {
@@ -1062,9 +1487,9 @@ func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
 	} else if session.PendingArchive && session.PreviousID != 0 {
 		// If there's already a pending archival, commit it to the database first
 		if err := s.db.MarkForArchivalByID(session.PreviousID); err != nil {
-			s.log.WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to commit pending archival")
+			s.logFrom(r).WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to commit pending archival")
 		} else {
-			s.log.WithField("thumbnail_id", session.PreviousID).Debug("Committed pending archival to database")
+			s.logFrom(r).WithField("thumbnail_id", session.PreviousID).Debug("Committed pending archival to database")
 		}
 
 		// Clear the pending archival state
@@ -1075,9 +1500,9 @@ func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
 	// Now check if there's a previous thumbnail that should be marked as viewed
 	if session.PreviousID != 0 && session.PreviousID != thumbnailID {
 		if err := s.db.MarkAsViewedByID(session.PreviousID); err != nil {
-			s.log.WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to mark previous thumbnail as viewed before archival")
+			s.logFrom(r).WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to mark previous thumbnail as viewed before archival")
 		} else {
-			s.log.WithField("thumbnail_id", session.PreviousID).Debug("Marked previous thumbnail as viewed before archival")
+			s.logFrom(r).WithField("thumbnail_id", session.PreviousID).Debug("Marked previous thumbnail as viewed before archival")
 			session.ViewedCount++
 		}
 	}
@@ -1089,10 +1514,10 @@ func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
 
 	// Save the updated session
 	if err := s.saveSessionToCookie(w, session); err != nil {
-		s.log.WithError(err).Error("Failed to save session after marking for archival")
+		s.logFrom(r).WithError(err).Error("Failed to save session after marking for archival")
 	}
 
-	s.log.WithFields(logrus.Fields{
+	s.logFrom(r).WithFields(logrus.Fields{
 		"movie":        thumbnail.MoviePath,
 		"thumbnail_id": thumbnail.ID,
 	}).Debug("Marked movie for archival in session (pending)")
@@ -1113,44 +1538,49 @@ func (s *Server) handleUndoDelete(w http.ResponseWriter, r *http.Request) {
 	// Get thumbnail ID from form
 	thumbnailIDStr := r.FormValue("id")
 	if thumbnailIDStr == "" {
-		http.Error(w, "Thumbnail ID is required", http.StatusBadRequest)
+		s.writeError(w, r, http.StatusBadRequest, "Thumbnail ID is required")
 		return
 	}
 
 	thumbnailID, err := strconv.ParseInt(thumbnailIDStr, 10, 64)
 	if err != nil {
-		s.log.WithError(err).Error("Invalid thumbnail ID")
-		http.Error(w, "Invalid thumbnail ID", http.StatusBadRequest)
+		s.logFrom(r).WithError(err).Error("Invalid thumbnail ID")
+		s.writeError(w, r, http.StatusBadRequest, "Invalid thumbnail ID")
 		return
 	}
 
 	// Get the thumbnail record
 	thumbnail, err := s.db.GetByID(thumbnailID)
 	if err != nil {
-		s.log.WithError(err).WithField("thumbnail_id", thumbnailID).Error("Failed to get thumbnail")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", thumbnailID).Error("Failed to get thumbnail")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
 	if thumbnail == nil {
-		http.Error(w, "Thumbnail not found", http.StatusNotFound)
+		s.writeError(w, r, http.StatusNotFound, "Thumbnail not found")
 		return
 	}
 
 	// Make sure it's marked as deleted
 	if thumbnail.Status != models.StatusDeleted {
-		http.Error(w, "Thumbnail is not marked for deletion", http.StatusBadRequest)
+		s.writeError(w, r, http.StatusBadRequest, "Thumbnail is not marked for deletion")
 		return
 	}
 
 	// Restore the thumbnail by setting status back to success
 	if err := s.db.RestoreFromDeletionByID(thumbnailID); err != nil {
-		s.log.WithError(err).WithField("thumbnail_id", thumbnailID).Error("Failed to restore from deletion")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		if errors.Is(err, database.ErrNotFound) {
+			s.writeError(w, r, http.StatusNotFound, "Thumbnail not found")
+			return
+		}
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", thumbnailID).Error("Failed to restore from deletion")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
-	s.log.WithField("thumbnail_id", thumbnailID).WithField("movie", thumbnail.MoviePath).Info("Restored movie from deletion")
+	s.logFrom(r).WithField("thumbnail_id", thumbnailID).WithField("movie", thumbnail.MoviePath).Info("Restored movie from deletion")
+	s.recordSlideshowAction("undo")
 
 	// If ajax request, return JSON response
 	if r.Header.Get("X-Requested-With") == "XMLHttpRequest" {
@@ -1167,8 +1597,8 @@ func (s *Server) handleUndoDelete(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	stats, err := s.scanner.GetStats()
 	if err != nil {
-		s.log.WithError(err).Error("Failed to get stats")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.logFrom(r).WithError(err).Error("Failed to get stats")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
@@ -1176,53 +1606,441 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(stats)
 }
 
+// handleStatsByRoot returns thumbnail counts and sizes per movie directory as JSON
+func (s *Server) handleStatsByRoot(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.db.GetStatsByRoot()
+	if err != nil {
+		s.logFrom(r).WithError(err).Error("Failed to get stats by root")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	if stats == nil {
+		stats = []*models.RootStats{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleScanProgress returns the scan state and per-file generation progress as JSON
+func (s *Server) handleScanProgress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scanning": s.scanner.IsScanning(),
+		"files":    s.scanner.Progress(),
+	})
+}
+
+// DeletionItem is a single entry in the deletion queue
+type DeletionItem struct {
+	ID            int64     `json:"id"`
+	MoviePath     string    `json:"movie_path"`
+	MovieFilename string    `json:"movie_filename"`
+	ThumbnailPath string    `json:"thumbnail_path"`
+	FileSize      int64     `json:"file_size"`
+	QueuedAt      time.Time `json:"queued_at"`
+}
+
+// DeletionListResponse is a page of the deletion queue
+type DeletionListResponse struct {
+	Items  []DeletionItem `json:"items"`
+	Total  int            `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
+
+// handleDeletions lists the items queued for deletion as JSON
+func (s *Server) handleDeletions(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	offset := 0
+	if v, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && v >= 0 {
+		offset = v
+	}
+
+	thumbnails, total, err := s.db.GetDeletedThumbnailsPaged(limit, offset)
+	if err != nil {
+		s.logFrom(r).WithError(err).Error("Failed to get deletion queue")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	items := make([]DeletionItem, 0, len(thumbnails))
+	for _, t := range thumbnails {
+		queuedAt := t.UpdatedAt
+		if t.QueuedAt != nil {
+			queuedAt = *t.QueuedAt
+		}
+		items = append(items, DeletionItem{
+			ID:            t.ID,
+			MoviePath:     t.MoviePath,
+			MovieFilename: t.MovieFilename,
+			ThumbnailPath: t.ThumbnailPath,
+			FileSize:      t.FileSize,
+			QueuedAt:      queuedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DeletionListResponse{
+		Items:  items,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// handleDeletionCancel removes a single item from the deletion queue
+func (s *Server) handleDeletionCancel(w http.ResponseWriter, r *http.Request) {
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, "Invalid thumbnail ID")
+		return
+	}
+
+	thumbnail, err := s.db.GetByID(id)
+	if err != nil {
+		s.logFrom(r).WithError(err).WithField("id", id).Error("Failed to get thumbnail")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	if thumbnail == nil {
+		s.writeError(w, r, http.StatusNotFound, "Thumbnail not found")
+		return
+	}
+	if thumbnail.Status != models.StatusDeleted && thumbnail.Status != models.StatusDeleteFailed {
+		s.writeError(w, r, http.StatusConflict, "Thumbnail is not queued for deletion")
+		return
+	}
+
+	if err := s.db.RestoreFromDeletionByID(id); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			s.writeError(w, r, http.StatusNotFound, "Thumbnail not found")
+			return
+		}
+		s.logFrom(r).WithError(err).WithField("id", id).Error("Failed to cancel deletion")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	s.logFrom(r).WithField("movie", thumbnail.MovieFilename).Info("Deletion cancelled")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"id":      id,
+	})
+}
+
+// DeletionApproveRequest selects the queued deletions to approve; no IDs approve the
+// whole queue
+type DeletionApproveRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+// handleDeletionsApprove approves queued deletions, so DELETION_REQUIRE_APPROVAL lets
+// the next deletion run remove them
+func (s *Server) handleDeletionsApprove(w http.ResponseWriter, r *http.Request) {
+	var req DeletionApproveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		s.writeError(w, r, http.StatusBadRequest, "Invalid JSON request body")
+		return
+	}
+
+	approved, err := s.db.ApproveDeletions(req.IDs...)
+	if err != nil {
+		s.logFrom(r).WithError(err).Error("Failed to approve deletions")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	s.logFrom(r).WithFields(logrus.Fields{
+		"approved": approved,
+		"ids":      req.IDs,
+	}).Info("Deletions approved")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"approved": approved,
+	})
+}
+
+// handleDeletionsProcess starts processing the deletion queue in the background
+func (s *Server) handleDeletionsProcess(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.DisableDeletion {
+		s.writeError(w, r, http.StatusForbidden, "Deletion processing is disabled via DISABLE_DELETION flag")
+		return
+	}
+
+	if s.rejectBusy(w, r, "Cannot process deletions while scanning") {
+		return
+	}
+
+	if r.URL.Query().Get("wait") == "true" {
+		s.processDeletionsAndWait(w, r)
+		return
+	}
+
+	if s.scanner.DeletionStatus().Running {
+		s.writeError(w, r, http.StatusConflict, "Deletion processing already in progress")
+		return
+	}
+
+	_, total, err := s.db.GetDeletedThumbnailsPaged(1, 0)
+	if err != nil {
+		s.logFrom(r).WithError(err).Error("Failed to get deletion queue")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	// Create a timeout context derived from the application context
+	ctx, cancel := context.WithTimeout(s.appCtx, 15*time.Minute)
+
+	go func() {
+		defer cancel()
+		if _, err := s.scanner.ProcessDeletions(ctx); err != nil {
+			s.logFrom(r).WithError(err).Error("Process deletions failed")
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"queued":  total,
+	})
+}
+
+// processDeletionsAndWait processes the deletion queue synchronously and writes the summary as JSON
+func (s *Server) processDeletionsAndWait(w http.ResponseWriter, r *http.Request) {
+	// Processing may take longer than the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(15 * time.Minute)); err != nil {
+		s.logFrom(r).WithError(err).Debug("Failed to extend write deadline")
+	}
+
+	ctx, cancel := context.WithTimeout(s.appCtx, 15*time.Minute)
+	defer cancel()
+
+	summary, err := s.scanner.ProcessDeletions(ctx)
+	w.Header().Set("Content-Type", "application/json")
+	if errors.Is(err, scanner.ErrDeletionInProgress) || errors.Is(err, scanner.ErrBusy) {
+		w.WriteHeader(http.StatusConflict)
+	} else if err != nil {
+		s.logFrom(r).WithError(err).Error("Process deletions failed")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(summary)
+}
+
+// handleDeletionsProgress returns the progress of the current or last deletion run as JSON
+func (s *Server) handleDeletionsProgress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.scanner.DeletionStatus())
+}
+
+// isLastThumbnail checks whether any unviewed thumbnails remain besides the current one and
+// the previous one that will be marked as viewed on next navigation. It also returns the
+// IDs that were excluded from the check.
+func (s *Server) isLastThumbnail(currentID int64, session *SessionData) (bool, []int64) {
+	excludeForCount := []int64{currentID}
+	if session.PreviousID > 0 && session.PreviousID != currentID {
+		excludeForCount = append(excludeForCount, session.PreviousID)
+	}
+
+	remainingThumbnail, err := s.nextCandidate(session, excludeForCount...)
+	return err != nil || remainingThumbnail == nil, excludeForCount
+}
+
+// startedAt returns the session start time, which bounds the lru pool to thumbnails
+// not yet viewed in this session
+func (session *SessionData) startedAt() time.Time {
+	return time.Unix(session.StartedAt, 0)
+}
+
+// seen reports whether a thumbnail is no longer eligible for the session: already
+// viewed in random order, or viewed since the session started in lru order and in
+// review sessions
+func (session *SessionData) seen(thumbnail *models.Thumbnail) bool {
+	if session.Order == config.SlideshowLRU || session.IncludeViewed {
+		return thumbnail.LastViewedAt != nil && !thumbnail.LastViewedAt.Before(session.startedAt())
+	}
+	return thumbnail.IsViewed()
+}
+
+// nextCandidate picks the next slideshow thumbnail according to the session's order,
+// skipping the given IDs
+func (s *Server) nextCandidate(session *SessionData, excludeIDs ...int64) (*models.Thumbnail, error) {
+	switch {
+	case session.Source != "":
+		return s.db.GetRandomThumbnailBySource(session.Source, session.IncludeViewed, session.startedAt(), excludeIDs...)
+	case session.Order == config.SlideshowLRU:
+		return s.db.GetLeastRecentlyViewedThumbnail(session.startedAt(), excludeIDs...)
+	case session.Order == config.SlideshowRecorded:
+		return s.db.GetEarliestRecordedThumbnail(session.IncludeViewed, session.startedAt(), excludeIDs...)
+	case session.IncludeViewed:
+		return s.db.GetRandomThumbnailNotViewedSince(session.startedAt(), excludeIDs...)
+	default:
+		return s.db.GetRandomUnviewedThumbnailExcluding(excludeIDs...)
+	}
+}
+
+// SlideshowSessionResponse is the client-facing view of the slideshow session
+type SlideshowSessionResponse struct {
+	Active               bool   `json:"active"`
+	TotalImages          int    `json:"total_images"`
+	ViewedCount          int    `json:"viewed_count"`
+	Position             int    `json:"position"`
+	CurrentID            int64  `json:"current_id"`
+	StartedAt            int64  `json:"started_at"`
+	PendingDelete        bool   `json:"pending_delete"`
+	PendingArchive       bool   `json:"pending_archive"`
+	DeletedSize          int64  `json:"deleted_size"`
+	DeletedSizeFormatted string `json:"deleted_size_formatted"`
+	HasPrevious          bool   `json:"has_previous"`
+	IsLast               bool   `json:"is_last"`
+}
+
+// handleSlideshowSession returns the current slideshow session state as JSON.
+// Without a session it returns {"active": false} rather than an error.
+func (s *Server) handleSlideshowSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	session, err := s.getSessionFromCookie(r)
+	if err != nil || session.StartedAt == 0 {
+		json.NewEncoder(w).Encode(SlideshowSessionResponse{Active: false})
+		return
+	}
+
+	json.NewEncoder(w).Encode(s.sessionResponse(session))
+}
+
+// sessionResponse returns the client-facing view of an active session
+func (s *Server) sessionResponse(session *SessionData) SlideshowSessionResponse {
+	resp := SlideshowSessionResponse{
+		Active:               true,
+		TotalImages:          session.TotalImages,
+		ViewedCount:          session.ViewedCount,
+		Position:             session.NavigationCount + 1,
+		CurrentID:            session.CurrentID,
+		StartedAt:            session.StartedAt,
+		PendingDelete:        session.PendingDelete,
+		PendingArchive:       session.PendingArchive,
+		DeletedSize:          session.DeletedSize,
+		DeletedSizeFormatted: formatBytes(session.DeletedSize),
+		HasPrevious:          session.hasPrevious(),
+	}
+	if session.CurrentID > 0 {
+		resp.IsLast, _ = s.isLastThumbnail(session.CurrentID, session)
+	}
+	return resp
+}
+
 // handleThumbnails returns a list of thumbnails as JSON
 func (s *Server) handleThumbnails(w http.ResponseWriter, r *http.Request) {
 	// Get query parameters
-	status := r.URL.Query().Get("status")
-	viewed := r.URL.Query().Get("viewed")
-	limitStr := r.URL.Query().Get("limit")
-
-	// Default limit of 10 if not specified
-	limit := 10
-	if limitStr != "" {
+	query := r.URL.Query()
+	var filter database.ThumbnailFilter
+	filter.Status = query.Get("status")
+	filter.Source = query.Get("source")
+	if filter.Source != "" && !models.ValidSource(filter.Source) {
+		s.writeError(w, r, http.StatusBadRequest, "Invalid source, expected generated or imported")
+		return
+	}
+	if filter.Status == models.StatusSuccess {
+		switch query.Get("viewed") {
+		case "0":
+			viewed := false
+			filter.Viewed = &viewed
+		case "1":
+			viewed := true
+			filter.Viewed = &viewed
+		}
+	}
+	switch sort := query.Get("sort"); sort {
+	case "", database.SortRecordedAt:
+		filter.Sort = sort
+	default:
+		s.writeError(w, r, http.StatusBadRequest, "Invalid sort, expected recorded_at")
+		return
+	}
+	for param, bound := range map[string]*time.Time{"recorded_after": &filter.RecordedAfter, "recorded_before": &filter.RecordedBefore} {
+		if value := query.Get(param); value != "" {
+			t, err := parseDateParam(value)
+			if err != nil {
+				s.writeError(w, r, http.StatusBadRequest, "Invalid "+param+", expected a date or RFC 3339 time")
+				return
+			}
+			*bound = t
+		}
+	}
+	limit, offset := pageParams(r, defaultThumbnailPageSize, maxThumbnailPageSize)
+	var envelope bool
+	if value := query.Get("envelope"); value != "" {
 		var err error
-		limit, err = strconv.Atoi(limitStr)
-		if err != nil {
-			limit = 10 // Default to 10 on parse error
+		if envelope, err = strconv.ParseBool(value); err != nil {
+			s.writeError(w, r, http.StatusBadRequest, "Invalid envelope value")
+			return
 		}
 	}
 
-	var thumbnails []*models.Thumbnail
-	var err error
-
-	// Get thumbnails based on filters
-	if status == "success" && viewed == "0" {
-		thumbnails, err = s.db.GetUnviewedThumbnails()
-	} else if status == "success" && viewed == "1" {
-		thumbnails, err = s.db.GetViewedThumbnails()
-	} else if status == "pending" {
-		thumbnails, err = s.db.GetPendingThumbnails()
-	} else if status == "error" {
-		thumbnails, err = s.db.GetErrorThumbnails()
-	} else if status == "deleted" {
-		thumbnails, err = s.db.GetDeletedThumbnails(limit)
-	} else if status == "archived" {
-		thumbnails, err = s.db.GetArchivedThumbnails(limit)
-	} else {
-		thumbnails, err = s.db.GetAllThumbnails()
+	// Answer unchanged lists without querying them
+	version, err := s.db.ThumbnailsVersion()
+	if err != nil {
+		s.logFrom(r).WithError(err).Error("Failed to get thumbnails version")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	etag := listETag(version, r.URL.RawQuery)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
 
+	thumbnails, total, err := s.db.GetThumbnailsFiltered(filter, limit, offset)
 	if err != nil {
-		s.log.WithError(err).Error("Failed to get thumbnails")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.logFrom(r).WithError(err).Error("Failed to get thumbnails")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
+	if thumbnails == nil {
+		thumbnails = []*models.Thumbnail{}
+	}
+	for _, thumbnail := range thumbnails {
+		thumbnail.DisplayPath = s.displayPath(thumbnail)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if envelope {
+		json.NewEncoder(w).Encode(ThumbnailPage{Thumbnails: thumbnails, Total: total, Limit: limit, Offset: offset})
+		return
+	}
 	json.NewEncoder(w).Encode(thumbnails)
 }
 
+// ThumbnailPage is a page of /api/thumbnails with ?envelope=true, for clients that
+// cannot read the X-Total-Count header
+type ThumbnailPage struct {
+	Thumbnails []*models.Thumbnail `json:"thumbnails"`
+	Total      int                 `json:"total"` // Number of matching thumbnails across all pages
+	Limit      int                 `json:"limit"`
+	Offset     int                 `json:"offset"`
+}
+
+// parseDateParam parses a query parameter given as a YYYY-MM-DD date or an RFC 3339 time
+func parseDateParam(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
 // handleThumbnail returns a single thumbnail as JSON
 func (s *Server) handleThumbnail(w http.ResponseWriter, r *http.Request) {
 	// Get thumbnail ID from URL
@@ -1232,32 +2050,84 @@ func (s *Server) handleThumbnail(w http.ResponseWriter, r *http.Request) {
 	// Convert ID from string to int64
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		s.log.WithError(err).WithField("id", idStr).Error("Invalid thumbnail ID")
-		http.Error(w, "Invalid thumbnail ID", http.StatusBadRequest)
+		s.logFrom(r).WithError(err).WithField("id", idStr).Error("Invalid thumbnail ID")
+		s.writeError(w, r, http.StatusBadRequest, "Invalid thumbnail ID")
 		return
 	}
 
 	// Get thumbnail by ID - we need to add this method to the database package
 	thumbnail, err := s.db.GetByID(id)
 	if err != nil {
-		s.log.WithError(err).WithField("id", id).Error("Failed to get thumbnail")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.logFrom(r).WithError(err).WithField("id", id).Error("Failed to get thumbnail")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
 	// Check if thumbnail was found
 	if thumbnail == nil {
-		http.Error(w, "Thumbnail not found", http.StatusNotFound)
+		s.writeError(w, r, http.StatusNotFound, "Thumbnail not found")
 		return
 	}
 
-	// Return thumbnail as JSON
+	// Return thumbnail as JSON, encoded up front so HEAD gets the same Content-Length
+	thumbnail.DisplayPath = s.displayPath(thumbnail)
+	data, err := json.Marshal(thumbnail)
+	if err != nil {
+		s.logFrom(r).WithError(err).Error("Failed to encode thumbnail")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	data = append(data, '\n')
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(thumbnail); err != nil {
-		s.log.WithError(err).Error("Failed to encode thumbnail")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if r.Method == http.MethodHead {
 		return
 	}
+	w.Write(data)
+}
+
+// NextImageResponse is the JSON response of /api/slideshow/next-image
+type NextImageResponse struct {
+	HasNext       bool   `json:"hasNext"`
+	ThumbnailPath string `json:"thumbnailPath,omitempty"`
+	MovieFilename string `json:"movieFilename,omitempty"`
+
+	// Artifact the session shows, and the generated artifacts of the next thumbnail
+	// the slideshow may prefetch
+	Artifact  string                      `json:"artifact,omitempty"`
+	Artifacts map[string]PrefetchArtifact `json:"artifacts,omitempty"`
+}
+
+// PrefetchArtifact is the storage key and size of an artifact of the next thumbnail
+type PrefetchArtifact struct {
+	Path string `json:"path"`
+	Size int64  `json:"size,omitempty"` // Bytes, omitted when unknown
+}
+
+// prefetchArtifacts lists the generated artifacts of a thumbnail for prefetching. The
+// animated preview is much larger than the grid, so it is only listed when the
+// session is in preview mode.
+func (s *Server) prefetchArtifacts(r *http.Request, thumbnail *models.Thumbnail, mode string) map[string]PrefetchArtifact {
+	artifacts := make(map[string]PrefetchArtifact)
+	add := func(name, path string) {
+		if path == "" {
+			return
+		}
+		artifact := PrefetchArtifact{Path: path}
+		if s.storage != nil {
+			if info, err := s.storage.Stat(r.Context(), path); err == nil {
+				artifact.Size = info.Size
+			}
+		}
+		artifacts[name] = artifact
+	}
+
+	add(models.ArtifactGrid, thumbnail.ThumbnailPath)
+	add(models.ArtifactPoster, thumbnail.PosterPath)
+	if mode == models.ArtifactPreview {
+		add(models.ArtifactPreview, thumbnail.PreviewPath)
+	}
+	return artifacts
 }
 
 // handleSlideshowNextImage returns the next thumbnail image path without navigation
@@ -1267,8 +2137,8 @@ func (s *Server) handleSlideshowNextImage(w http.ResponseWriter, r *http.Request
 	// Require valid session
 	session, err := s.getSessionFromCookie(r)
 	if err != nil {
-		s.log.WithError(err).Debug("No valid session found for next image request")
-		http.Error(w, "No slideshow session found", http.StatusBadRequest)
+		s.logFrom(r).WithError(err).Debug("No valid session found for next image request")
+		s.writeError(w, r, http.StatusBadRequest, "No slideshow session found")
 		return
 	}
 
@@ -1277,47 +2147,45 @@ func (s *Server) handleSlideshowNextImage(w http.ResponseWriter, r *http.Request
 	if session.NextID > 0 {
 		nextThumbnail, err = s.db.GetByID(session.NextID)
 		if err != nil {
-			s.log.WithError(err).WithField("nextID", session.NextID).Error("Failed to get predetermined next thumbnail for prefetch")
+			s.logFrom(r).WithError(err).WithField("nextID", session.NextID).Error("Failed to get predetermined next thumbnail for prefetch")
 			// Return empty response instead of error to not break the UI
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"hasNext": false,
-			})
+			json.NewEncoder(w).Encode(NextImageResponse{HasNext: false})
 			return
 		}
 
 		// Double-check the thumbnail is still unviewed
-		if nextThumbnail != nil && nextThumbnail.IsViewed() {
-			s.log.WithField("nextID", session.NextID).Debug("Predetermined next thumbnail was already viewed")
+		if nextThumbnail != nil && session.seen(nextThumbnail) {
+			s.logFrom(r).WithField("nextID", session.NextID).Debug("Predetermined next thumbnail was already viewed")
 			nextThumbnail = nil
 		} else if nextThumbnail != nil {
-			s.log.WithFields(logrus.Fields{
+			s.logFrom(r).WithFields(logrus.Fields{
 				"nextID":        session.NextID,
 				"thumbnailPath": nextThumbnail.ThumbnailPath,
 				"movieFilename": nextThumbnail.MovieFilename,
 			}).Debug("Using predetermined next thumbnail for prefetch")
 		}
 	} else {
-		s.log.WithField("sessionNextID", session.NextID).Debug("No NextID in session, cannot prefetch")
+		s.logFrom(r).WithField("sessionNextID", session.NextID).Debug("No NextID in session, cannot prefetch")
 	}
 
 	if nextThumbnail == nil {
 		// No more thumbnails
-		s.log.Debug("No next thumbnail available for prefetch")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"hasNext": false,
-		})
+		s.logFrom(r).Debug("No next thumbnail available for prefetch")
+		json.NewEncoder(w).Encode(NextImageResponse{HasNext: false})
 		return
 	}
 
 	// Return the thumbnail path for prefetching
-	response := map[string]interface{}{
-		"hasNext":       true,
-		"thumbnailPath": nextThumbnail.ThumbnailPath,
-		"movieFilename": nextThumbnail.MovieFilename,
+	response := NextImageResponse{
+		HasNext:       true,
+		ThumbnailPath: nextThumbnail.ArtifactPath(session.Artifact),
+		MovieFilename: nextThumbnail.MovieFilename,
+		Artifact:      session.Artifact,
+		Artifacts:     s.prefetchArtifacts(r, nextThumbnail, session.Artifact),
 	}
 
-	s.log.WithFields(logrus.Fields{
-		"thumbnailPath": nextThumbnail.ThumbnailPath,
+	s.logFrom(r).WithFields(logrus.Fields{
+		"thumbnailPath": response.ThumbnailPath,
 		"movieFilename": nextThumbnail.MovieFilename,
 	}).Debug("Providing next image for prefetch")
 
@@ -1326,6 +2194,14 @@ func (s *Server) handleSlideshowNextImage(w http.ResponseWriter, r *http.Request
 
 // handleNotFound handles 404 errors
 func (s *Server) handleNotFound(w http.ResponseWriter, r *http.Request) {
+	if wantsJSON(r) {
+		s.writeError(w, r, http.StatusNotFound, "Not Found")
+		return
+	}
+	if s.cfg.Headless {
+		http.NotFound(w, r)
+		return
+	}
 	w.WriteHeader(http.StatusNotFound)
 	w.Header().Set("Content-Type", "text/html")
 	w.Write([]byte("<html><body><h1>404 Not Found</h1><p>The requested page could not be found.</p></body></html>"))
@@ -1342,7 +2218,7 @@ func (s *Server) handleSlideshowFinish(w http.ResponseWriter, r *http.Request) {
 	// Get current ID from session
 	currentID := session.CurrentID
 	if currentID == 0 {
-		http.Error(w, "No current thumbnail in session", http.StatusBadRequest)
+		s.writeError(w, r, http.StatusBadRequest, "No current thumbnail in session")
 		return
 	}
 
@@ -1350,20 +2226,24 @@ func (s *Server) handleSlideshowFinish(w http.ResponseWriter, r *http.Request) {
 	if session.PreviousID != 0 && session.PreviousID != currentID && !session.PendingDelete && !session.PendingArchive {
 		// Mark the previous thumbnail as viewed (delayed from last navigation)
 		if err := s.db.MarkAsViewedByID(session.PreviousID); err != nil {
-			s.log.WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to mark previous thumbnail as viewed during finish")
+			s.logFrom(r).WithError(err).WithField("thumbnail_id", session.PreviousID).Error("Failed to mark previous thumbnail as viewed during finish")
 		} else {
-			s.log.WithField("thumbnail_id", session.PreviousID).Debug("Marked previous thumbnail as viewed (delayed) during finish")
+			s.logFrom(r).WithField("thumbnail_id", session.PreviousID).Debug("Marked previous thumbnail as viewed (delayed) during finish")
 		}
 	}
 
 	// Mark the current thumbnail as viewed
 	if err := s.db.MarkAsViewedByID(currentID); err != nil {
-		s.log.WithError(err).WithField("thumbnail_id", currentID).Error("Failed to mark thumbnail as viewed")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		if errors.Is(err, database.ErrNotFound) {
+			s.writeError(w, r, http.StatusNotFound, "Thumbnail not found")
+			return
+		}
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", currentID).Error("Failed to mark thumbnail as viewed")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
-	s.log.WithField("thumbnail_id", currentID).Info("Marked last thumbnail as viewed and finishing slideshow")
+	s.logFrom(r).WithField("thumbnail_id", currentID).Info("Marked last thumbnail as viewed and finishing slideshow")
 
 	// Record slideshow session metrics
 	if session.StartedAt > 0 {
@@ -1401,34 +2281,36 @@ func (s *Server) handleDeleteAndFinish(w http.ResponseWriter, r *http.Request) {
 	// Get current ID from session
 	currentID := session.CurrentID
 	if currentID == 0 {
-		http.Error(w, "No current thumbnail in session", http.StatusBadRequest)
+		s.writeError(w, r, http.StatusBadRequest, "No current thumbnail in session")
 		return
 	}
 
 	// Get the thumbnail record
 	thumbnail, err := s.db.GetByID(currentID)
 	if err != nil {
-		s.log.WithError(err).WithField("thumbnail_id", currentID).Error("Failed to get thumbnail")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", currentID).Error("Failed to get thumbnail")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
 	if thumbnail == nil {
-		http.Error(w, "Thumbnail not found", http.StatusNotFound)
+		s.writeError(w, r, http.StatusNotFound, "Thumbnail not found")
 		return
 	}
 
 	// Immediately mark for deletion in database (no undo for last thumbnail)
 	if err := s.db.MarkForDeletionByID(currentID); err != nil {
-		s.log.WithError(err).WithField("thumbnail_id", currentID).Error("Failed to mark thumbnail for deletion")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", currentID).Error("Failed to mark thumbnail for deletion")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
+	s.recordSlideshowAction("delete")
+
 	// Add the file size to the session's deleted size counter
 	session.DeletedSize += thumbnail.FileSize
 
-	s.log.WithFields(logrus.Fields{
+	s.logFrom(r).WithFields(logrus.Fields{
 		"thumbnail_id":       currentID,
 		"movie_path":         thumbnail.MoviePath,
 		"file_size":          thumbnail.FileSize,
@@ -1519,8 +2401,17 @@ func (s *Server) handleAPIArchiveVideo(w http.ResponseWriter, r *http.Request) {
 
 	// Find thumbnail by filename
 	thumbnail, err := s.db.GetByMovieFilename(req.Filename)
+	if errors.Is(err, database.ErrAmbiguous) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(VideoResponse{
+			Success:  false,
+			Error:    "More than one video has this filename, use its path instead",
+			Filename: req.Filename,
+		})
+		return
+	}
 	if err != nil {
-		s.log.WithError(err).WithField("filename", req.Filename).Error("Database error when searching for video")
+		s.logFrom(r).WithError(err).WithField("filename", req.Filename).Error("Database error when searching for video")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(VideoResponse{
 			Success:  false,
@@ -1542,7 +2433,7 @@ func (s *Server) handleAPIArchiveVideo(w http.ResponseWriter, r *http.Request) {
 
 	// Mark for archival
 	if err := s.db.MarkForArchivalByID(thumbnail.ID); err != nil {
-		s.log.WithError(err).WithField("thumbnail_id", thumbnail.ID).Error("Failed to mark video for archival")
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", thumbnail.ID).Error("Failed to mark video for archival")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(VideoResponse{
 			Success:     false,
@@ -1553,7 +2444,7 @@ func (s *Server) handleAPIArchiveVideo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.log.WithFields(logrus.Fields{
+	s.logFrom(r).WithFields(logrus.Fields{
 		"filename":     req.Filename,
 		"thumbnail_id": thumbnail.ID,
 	}).Info("Video marked for archival via API")
@@ -1593,8 +2484,17 @@ func (s *Server) handleAPIDeleteVideo(w http.ResponseWriter, r *http.Request) {
 
 	// Find thumbnail by filename
 	thumbnail, err := s.db.GetByMovieFilename(req.Filename)
+	if errors.Is(err, database.ErrAmbiguous) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(VideoResponse{
+			Success:  false,
+			Error:    "More than one video has this filename, use its path instead",
+			Filename: req.Filename,
+		})
+		return
+	}
 	if err != nil {
-		s.log.WithError(err).WithField("filename", req.Filename).Error("Database error when searching for video")
+		s.logFrom(r).WithError(err).WithField("filename", req.Filename).Error("Database error when searching for video")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(VideoResponse{
 			Success:  false,
@@ -1628,7 +2528,7 @@ func (s *Server) handleAPIDeleteVideo(w http.ResponseWriter, r *http.Request) {
 
 	// Mark for deletion
 	if err := s.db.MarkForDeletionByID(thumbnail.ID); err != nil {
-		s.log.WithError(err).WithField("thumbnail_id", thumbnail.ID).Error("Failed to mark video for deletion")
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", thumbnail.ID).Error("Failed to mark video for deletion")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(VideoResponse{
 			Success:     false,
@@ -1639,7 +2539,7 @@ func (s *Server) handleAPIDeleteVideo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.log.WithFields(logrus.Fields{
+	s.logFrom(r).WithFields(logrus.Fields{
 		"filename":     req.Filename,
 		"thumbnail_id": thumbnail.ID,
 	}).Info("Video marked for deletion via API")
@@ -1672,8 +2572,17 @@ func (s *Server) handleAPIVideoStatus(w http.ResponseWriter, r *http.Request) {
 
 	// Find thumbnail by filename
 	thumbnail, err := s.db.GetByMovieFilename(filename)
+	if errors.Is(err, database.ErrAmbiguous) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(VideoStatusResponse{
+			Success:  false,
+			Error:    "More than one video has this filename, use its path instead",
+			Filename: filename,
+		})
+		return
+	}
 	if err != nil {
-		s.log.WithError(err).WithField("filename", filename).Error("Database error when searching for video")
+		s.logFrom(r).WithError(err).WithField("filename", filename).Error("Database error when searching for video")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(VideoStatusResponse{
 			Success:  false,
@@ -1703,3 +2612,47 @@ func (s *Server) handleAPIVideoStatus(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:   thumbnail.CreatedAt.Format(time.RFC3339),
 	})
 }
+
+// BackupResponse describes a database backup written by POST /api/db/backup
+type BackupResponse struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// handleDBBackup writes a timestamped copy of the database to BACKUP_DIR
+func (s *Server) handleDBBackup(w http.ResponseWriter, r *http.Request) {
+	filename := fmt.Sprintf("thumbnailer-%s.db", time.Now().UTC().Format("20060102-150405.000000000")) // Sub-second, so quick successive backups get their own file
+	destPath := filepath.Join(s.cfg.BackupDir, filename)
+
+	size, err := s.db.Backup(destPath)
+	if err != nil {
+		s.logFrom(r).WithError(err).WithField("backup", destPath).Error("Failed to back up database")
+		s.writeError(w, r, http.StatusInternalServerError, "Failed to back up database")
+		return
+	}
+
+	s.logFrom(r).WithFields(logrus.Fields{
+		"backup": destPath,
+		"size":   size,
+	}).Info("Database backup created")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BackupResponse{Path: destPath, Size: size})
+}
+
+// handleDBVacuum compacts the database, keeping the server in maintenance mode
+// while it runs
+func (s *Server) handleDBVacuum(w http.ResponseWriter, r *http.Request) {
+	end := s.BeginMaintenance("Vacuuming the database; the slideshow may be slow.")
+	defer end()
+
+	started := time.Now()
+	if err := s.db.Vacuum(); err != nil {
+		s.logFrom(r).WithError(err).Error("Failed to vacuum database")
+		s.writeError(w, r, http.StatusInternalServerError, "Failed to vacuum database")
+		return
+	}
+
+	s.logFrom(r).WithField("duration", time.Since(started)).Info("Database vacuumed")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2294,3 +2294,25 @@ func (ts *TestServer) handleDeleteAndFinish(w http.ResponseWriter, r *http.Reque
 	// Redirect to control page
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
+
+func TestDBBackupSuccessiveCalls(t *testing.T) {
+	s, _ := newSessionTestServer(t)
+	s.cfg.BackupDir = t.TempDir()
+
+	paths := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		s.handleDBBackup(rec, httptest.NewRequest("POST", "/api/db/backup", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("backup %d = %d %s, want 200", i+1, rec.Code, rec.Body.String())
+		}
+		var resp BackupResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		paths[resp.Path] = true
+	}
+	if len(paths) != 2 {
+		t.Errorf("backups in the same second wrote %v, want two files", paths)
+	}
+}
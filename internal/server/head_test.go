@@ -0,0 +1,59 @@
+package server
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
+)
+
+func TestHeadRequests(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	thumbDir := t.TempDir()
+	s.storage = storage.NewLocal(thumbDir)
+	s.resizeCache = newResizeCache(4)
+	s.router = mux.NewRouter()
+	s.routes()
+
+	if err := os.WriteFile(filepath.Join(thumbDir, "movie.jpg"), []byte("thumbnail image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	thumbnail := &models.Thumbnail{MoviePath: "movie.mp4", MovieFilename: "movie.mp4", ThumbnailPath: "movie.jpg", Status: models.StatusSuccess}
+	if err := db.UpsertThumbnail(thumbnail); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/thumbnails/movie.jpg", fmt.Sprintf("/api/thumbnails/%d", thumbnail.ID)} {
+		t.Run(path, func(t *testing.T) {
+			get := httptest.NewRecorder()
+			s.router.ServeHTTP(get, httptest.NewRequest("GET", path, nil))
+			head := httptest.NewRecorder()
+			s.router.ServeHTTP(head, httptest.NewRequest("HEAD", path, nil))
+
+			if head.Code != 200 || get.Code != 200 {
+				t.Fatalf("HEAD status %d, GET status %d, want 200", head.Code, get.Code)
+			}
+			if head.Body.Len() != 0 {
+				t.Errorf("HEAD body = %q, want none", head.Body)
+			}
+			if got, want := head.Header().Get("Content-Length"), strconv.Itoa(get.Body.Len()); got != want {
+				t.Errorf("Content-Length = %q, want %q", got, want)
+			}
+			if got, want := head.Header().Get("Content-Type"), get.Header().Get("Content-Type"); got == "" || got != want {
+				t.Errorf("Content-Type = %q, want %q", got, want)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest("HEAD", "/api/thumbnails/999", nil))
+	if rec.Code != 404 {
+		t.Errorf("HEAD of a missing thumbnail: status %d, want 404", rec.Code)
+	}
+}
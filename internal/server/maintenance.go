@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Banner messages used when maintenance mode has no message of its own
+const (
+	defaultMaintenanceMessage     = "The server is in maintenance mode; the slideshow may be slow."
+	defaultScanMaintenanceMessage = "A library scan is running; the slideshow may be slow."
+)
+
+// maintenanceState is maintenance mode set manually or around heavy operations
+type maintenanceState struct {
+	mu      sync.Mutex
+	manual  bool
+	message string
+	ops     map[int]string // Running heavy operations and their messages
+	nextOp  int
+}
+
+// MaintenanceResponse is the JSON body of the maintenance endpoints
+type MaintenanceResponse struct {
+	Active  bool   `json:"active"`
+	Manual  bool   `json:"manual"`
+	Message string `json:"message,omitempty"`
+}
+
+// MaintenanceRequest is the JSON body of POST /api/maintenance
+type MaintenanceRequest struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message"`
+}
+
+// BeginMaintenance puts the server in maintenance mode for a heavy operation such as
+// a vacuum. The returned function ends it.
+func (s *Server) BeginMaintenance(message string) (end func()) {
+	m := &s.maintenance
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ops == nil {
+		m.ops = map[int]string{}
+	}
+	id := m.nextOp
+	m.nextOp++
+	m.ops[id] = message
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			delete(m.ops, id)
+			m.mu.Unlock()
+		})
+	}
+}
+
+// maintenanceStatus reports whether the server is in maintenance mode and why. A
+// running scan counts as an operation.
+func (s *Server) maintenanceStatus() MaintenanceResponse {
+	m := &s.maintenance
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := MaintenanceResponse{Manual: m.manual}
+	switch {
+	case m.manual:
+		status.Active, status.Message = true, m.message
+	case len(m.ops) > 0:
+		// Report the longest running operation
+		first := -1
+		for id := range m.ops {
+			if first < 0 || id < first {
+				first = id
+			}
+		}
+		status.Active, status.Message = true, m.ops[first]
+	case s.scanner != nil && s.scanner.IsScanning():
+		status.Active, status.Message = true, defaultScanMaintenanceMessage
+	}
+	if status.Active && status.Message == "" {
+		status.Message = defaultMaintenanceMessage
+	}
+	return status
+}
+
+// maintenanceMiddleware answers mutation requests with 503 during maintenance when
+// MAINTENANCE_BLOCK_MUTATIONS is set. The maintenance endpoint itself stays usable.
+func (s *Server) maintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.MaintenanceBlockMutations && isMutation(r.Method) && r.URL.Path != "/api/maintenance" {
+			if status := s.maintenanceStatus(); status.Active {
+				w.Header().Set("Retry-After", "60")
+				s.writeError(w, r, http.StatusServiceUnavailable, status.Message)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isMutation reports whether requests with this method change state
+func isMutation(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
+
+// handleMaintenance returns the maintenance state as JSON
+func (s *Server) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.maintenanceStatus())
+}
+
+// handleSetMaintenance turns manual maintenance mode on or off
+func (s *Server) handleSetMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req MaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, "Invalid JSON request body")
+		return
+	}
+
+	m := &s.maintenance
+	m.mu.Lock()
+	m.manual = req.Enabled
+	m.message = ""
+	if req.Enabled {
+		m.message = req.Message
+	}
+	m.mu.Unlock()
+
+	s.logFrom(r).WithField("enabled", req.Enabled).Info("Maintenance mode changed")
+	s.handleMaintenance(w, r)
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+func TestMaintenanceBlocksMutations(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	s.cfg.MaintenanceBlockMutations = true
+	s.router = mux.NewRouter()
+	s.routes()
+
+	if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: "movie.mp4", MovieFilename: "movie.mp4", Status: models.StatusSuccess}); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+	markViewed := func() *httptest.ResponseRecorder {
+		return serve("POST", "/api/movies/movie.mp4/viewed", "")
+	}
+
+	if rec := markViewed(); rec.Code != http.StatusOK {
+		t.Fatalf("mark viewed outside maintenance = %d, want 200", rec.Code)
+	}
+
+	if rec := serve("POST", "/api/maintenance", `{"enabled": true, "message": "Vacuuming the database"}`); rec.Code != http.StatusOK {
+		t.Fatalf("enable maintenance = %d, want 200", rec.Code)
+	}
+	rec := markViewed()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("mark viewed during maintenance = %d (Retry-After %q), want 503", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	var errResp ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&errResp); err != nil || errResp.Error != "Vacuuming the database" {
+		t.Errorf("error body = %+v (%v), want the maintenance message", errResp, err)
+	}
+
+	// Reads keep working
+	rec = serve("GET", "/api/maintenance", "")
+	var status MaintenanceResponse
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil || !status.Active || !status.Manual {
+		t.Errorf("maintenance status = %+v (%v), want active and manual", status, err)
+	}
+
+	if rec := serve("POST", "/api/maintenance", `{"enabled": false}`); rec.Code != http.StatusOK {
+		t.Fatalf("disable maintenance = %d, want 200", rec.Code)
+	}
+	if rec := markViewed(); rec.Code != http.StatusOK {
+		t.Errorf("mark viewed after maintenance = %d, want 200", rec.Code)
+	}
+
+	// Heavy operations put the server in maintenance until they end
+	end := s.BeginMaintenance("Vacuuming the database")
+	if rec := markViewed(); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("mark viewed during an operation = %d, want 503", rec.Code)
+	}
+	end()
+	if rec := markViewed(); rec.Code != http.StatusOK {
+		t.Errorf("mark viewed after the operation = %d, want 200", rec.Code)
+	}
+
+	// A vacuum ends its maintenance when done
+	if rec := serve("POST", "/api/db/vacuum", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("vacuum = %d, want 204", rec.Code)
+	}
+	if got := s.maintenanceStatus(); got.Active {
+		t.Errorf("maintenance status after vacuum = %+v, want inactive", got)
+	}
+
+	// Without MAINTENANCE_BLOCK_MUTATIONS maintenance mode only shows a banner
+	s.cfg.MaintenanceBlockMutations = false
+	defer s.BeginMaintenance("")()
+	if rec := markViewed(); rec.Code != http.StatusOK {
+		t.Errorf("mark viewed with blocking disabled = %d, want 200", rec.Code)
+	}
+	if got := s.maintenanceStatus(); !got.Active || got.Message != defaultMaintenanceMessage {
+		t.Errorf("maintenance status = %+v, want active with the default message", got)
+	}
+}
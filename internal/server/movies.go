@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pandino/movie-thumbnailer-go/internal/ffmpeg"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// movieFromPath resolves the {path} route variable to a thumbnail record. A path
+// inside a movies directory, with or without its leading slash, is looked up under
+// the movie_path the scanner records it by. Without RECURSIVE_SCAN movies are recorded
+// by file name, so any other path falls back to its last element. It writes the
+// error response and returns nil when the movie cannot be resolved.
+func (s *Server) movieFromPath(w http.ResponseWriter, r *http.Request) *models.Thumbnail {
+	moviePath := mux.Vars(r)["path"]
+	if moviePath == "" {
+		s.writeError(w, r, http.StatusBadRequest, "Movie path is required")
+		return nil
+	}
+
+	key := s.movieKey(moviePath)
+	thumbnail, err := s.db.GetByMoviePath(key)
+	if err == nil && thumbnail == nil && !s.cfg.RecursiveScan {
+		if base := path.Base(key); base != key {
+			thumbnail, err = s.db.GetByMoviePath(base)
+		}
+	}
+	if err != nil {
+		s.logFrom(r).WithError(err).WithField("movie", moviePath).Error("Database error when searching for movie")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		return nil
+	}
+	if thumbnail == nil {
+		s.writeError(w, r, http.StatusNotFound, "Movie not found")
+		return nil
+	}
+	return thumbnail
+}
+
+// movieKey returns the movie_path of a movie given by its path: the key the scanner
+// records for a path inside a movies directory, or the path itself
+func (s *Server) movieKey(moviePath string) string {
+	full := filepath.FromSlash("/" + strings.TrimPrefix(moviePath, "/"))
+	for _, root := range s.cfg.MoviesDirs {
+		rel, err := filepath.Rel(root, full)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return ffmpeg.New(s.cfg, s.log, nil, s.storage).MovieKey(full)
+	}
+	return moviePath
+}
+
+// handleThumbnailByMovie redirects to the thumbnail image of a movie given by its path,
+// keeping the query so ?w= resizing still applies
+func (s *Server) handleThumbnailByMovie(w http.ResponseWriter, r *http.Request) {
+	thumbnail := s.movieFromPath(w, r)
+	if thumbnail == nil {
+		return
+	}
+	if thumbnail.Status != models.StatusSuccess || thumbnail.ThumbnailPath == "" {
+		s.writeError(w, r, http.StatusNotFound, "Movie has no generated thumbnail")
+		return
+	}
+
+	target := "/thumbnails/" + (&url.URL{Path: thumbnail.ThumbnailPath}).EscapedPath()
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// handleAPIMovieViewed marks a movie as viewed by its path
+func (s *Server) handleAPIMovieViewed(w http.ResponseWriter, r *http.Request) {
+	thumbnail := s.movieFromPath(w, r)
+	if thumbnail == nil {
+		return
+	}
+
+	if err := s.db.MarkAsViewedByID(thumbnail.ID); err != nil {
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", thumbnail.ID).Error("Failed to mark movie as viewed")
+		s.writeError(w, r, http.StatusInternalServerError, "Failed to mark movie as viewed")
+		return
+	}
+
+	s.logFrom(r).WithFields(logrus.Fields{
+		"movie":        thumbnail.MoviePath,
+		"thumbnail_id": thumbnail.ID,
+	}).Info("Movie marked as viewed via API")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(VideoResponse{
+		Success:     true,
+		Message:     "Movie marked as viewed",
+		Filename:    thumbnail.MovieFilename,
+		ThumbnailID: thumbnail.ID,
+	})
+}
+
+// handleAPIMovieDelete marks a movie for deletion by its path
+func (s *Server) handleAPIMovieDelete(w http.ResponseWriter, r *http.Request) {
+	thumbnail := s.movieFromPath(w, r)
+	if thumbnail == nil {
+		return
+	}
+
+	if thumbnail.Status == models.StatusDeleted {
+		s.writeError(w, r, http.StatusConflict, "Movie is already marked for deletion")
+		return
+	}
+
+	if err := s.db.MarkForDeletionByID(thumbnail.ID); err != nil {
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", thumbnail.ID).Error("Failed to mark movie for deletion")
+		s.writeError(w, r, http.StatusInternalServerError, "Failed to mark movie for deletion")
+		return
+	}
+
+	s.logFrom(r).WithFields(logrus.Fields{
+		"movie":        thumbnail.MoviePath,
+		"thumbnail_id": thumbnail.ID,
+	}).Info("Movie marked for deletion via API")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(VideoResponse{
+		Success:     true,
+		Message:     "Movie marked for deletion",
+		Filename:    thumbnail.MovieFilename,
+		ThumbnailID: thumbnail.ID,
+	})
+}
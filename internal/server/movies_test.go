@@ -0,0 +1,134 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+func TestMoviePathRoutes(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	s.router = mux.NewRouter()
+	s.routes()
+
+	for _, name := range []string{"two words.mp4", "episode.mkv"} {
+		if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: name, MovieFilename: name, Status: models.StatusSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	post := func(path string) int {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest("POST", path, nil))
+		return rec.Code
+	}
+
+	if code := post("/api/movies/two%20words.mp4/viewed"); code != http.StatusOK {
+		t.Errorf("mark viewed = %d, want 200", code)
+	}
+	if got, _ := db.GetByMoviePath("two words.mp4"); !got.IsViewed() || got.ViewCount != 1 {
+		t.Errorf("movie not marked as viewed: %+v", got)
+	}
+
+	// A full path resolves by its file name
+	if code := post("/api/movies/shows%2Fseason%201%2Fepisode.mkv/delete"); code != http.StatusOK {
+		t.Errorf("mark for deletion = %d, want 200", code)
+	}
+	if got, _ := db.GetByMoviePath("episode.mkv"); got.Status != models.StatusDeleted {
+		t.Errorf("status = %q, want deleted", got.Status)
+	}
+	if code := post("/api/movies/episode.mkv/delete"); code != http.StatusConflict {
+		t.Errorf("deleting twice = %d, want 409", code)
+	}
+
+	for _, path := range []string{"/api/movies/missing.mp4/viewed", "/api/movies/missing.mp4/delete"} {
+		if code := post(path); code != http.StatusNotFound {
+			t.Errorf("POST %s = %d, want 404", path, code)
+		}
+	}
+}
+
+func TestMoviePathRoutesRecursive(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	s.cfg.RecursiveScan = true
+	s.cfg.MoviesDirs = []string{"/movies"}
+	s.router = mux.NewRouter()
+	s.routes()
+
+	for _, moviePath := range []string{"Action/film.mkv", "Drama/film.mkv", "film.mkv"} {
+		if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: moviePath, MovieFilename: "film.mkv", Status: models.StatusSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	serve := func(method, path, body string) int {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec.Code
+	}
+
+	// A mistyped directory must not resolve to the root-level movie of the same name
+	if code := serve("POST", "/api/movies/Comedy%2Ffilm.mkv/delete", ""); code != http.StatusNotFound {
+		t.Errorf("delete of a mistyped path = %d, want 404", code)
+	}
+	if got, _ := db.GetByMoviePath("film.mkv"); got.Status != models.StatusSuccess {
+		t.Errorf("root-level film.mkv status = %q, want it untouched", got.Status)
+	}
+
+	// A path inside a movies directory resolves to its key
+	if code := serve("POST", "/api/movies/movies%2FAction%2Ffilm.mkv/delete", ""); code != http.StatusOK {
+		t.Errorf("delete by full path = %d, want 200", code)
+	}
+	if got, _ := db.GetByMoviePath("Action/film.mkv"); got.Status != models.StatusDeleted {
+		t.Errorf("Action/film.mkv status = %q, want deleted", got.Status)
+	}
+
+	// The file name alone no longer names one movie
+	if code := serve("POST", "/api/v1/video/delete", `{"filename": "film.mkv"}`); code != http.StatusConflict {
+		t.Errorf("video delete of a shared file name = %d, want 409", code)
+	}
+	if code := serve("GET", "/api/v1/video/status/film.mkv", ""); code != http.StatusConflict {
+		t.Errorf("video status of a shared file name = %d, want 409", code)
+	}
+	if got, _ := db.GetByMoviePath("Drama/film.mkv"); got.Status != models.StatusSuccess {
+		t.Errorf("Drama/film.mkv status = %q, want it untouched", got.Status)
+	}
+}
+
+func TestThumbnailByMovie(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	s.router = mux.NewRouter()
+	s.routes()
+
+	for _, thumbnail := range []*models.Thumbnail{
+		{MoviePath: "two words.mp4", ThumbnailPath: "two words.jpg", Status: models.StatusSuccess},
+		{MoviePath: "pending.mp4", ThumbnailPath: "pending.jpg", Status: models.StatusPending},
+	} {
+		thumbnail.MovieFilename = thumbnail.MoviePath
+		if err := db.UpsertThumbnail(thumbnail); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path     string
+		code     int
+		location string
+	}{
+		{"/thumbnail-by-movie/two%20words.mp4", http.StatusFound, "/thumbnails/two%20words.jpg"},
+		{"/thumbnail-by-movie/movies%2Ftwo%20words.mp4?w=640", http.StatusFound, "/thumbnails/two%20words.jpg?w=640"},
+		{"/thumbnail-by-movie/pending.mp4", http.StatusNotFound, ""},
+		{"/thumbnail-by-movie/missing.mp4", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if rec.Code != tt.code || rec.Header().Get("Location") != tt.location {
+			t.Errorf("GET %s = %d to %q, want %d to %q", tt.path, rec.Code, rec.Header().Get("Location"), tt.code, tt.location)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+// openAPISchemas are the request and response types described in the OpenAPI document. Their
+// schemas are generated from the struct definitions, so they follow the JSON encoding.
+var openAPISchemas = map[string]interface{}{
+	"Thumbnail":        models.Thumbnail{},
+	"ThumbnailPage":    ThumbnailPage{},
+	"Stats":            models.Stats{},
+	"RootStats":        models.RootStats{},
+	"SlideshowSession": SlideshowSessionResponse{},
+	"NextImage":        NextImageResponse{},
+	"Maintenance":      MaintenanceResponse{},
+	"ThumbnailPatch":   ThumbnailPatch{},
+	"Playlist":         SlideshowPlaylistRequest{},
+	"Error":            ErrorResponse{},
+	"Version":          VersionInfo{},
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonSchema returns the JSON schema of a Go type as encoded by encoding/json
+func jsonSchema(t reflect.Type) map[string]interface{} {
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := jsonSchema(t.Elem())
+		schema["nullable"] = true
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = jsonSchema(field.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// schemaRef references a schema in components
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// jsonResponse describes a successful JSON response
+func jsonResponse(description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+// errorResponse describes a JSON error response
+func errorResponse(description string) map[string]interface{} {
+	return jsonResponse(description, schemaRef("Error"))
+}
+
+// queryParam describes an optional query parameter
+func queryParam(name, description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      schema,
+	}
+}
+
+// openAPIDocument builds the OpenAPI 3 description of the JSON API
+func openAPIDocument(version string) map[string]interface{} {
+	schemas := map[string]interface{}{}
+	for name, v := range openAPISchemas {
+		schemas[name] = jsonSchema(reflect.TypeOf(v))
+	}
+
+	idParam := map[string]interface{}{
+		"name":     "id",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]interface{}{"type": "integer", "format": "int64"},
+	}
+
+	statuses := []string{
+		models.StatusPending, models.StatusSuccess, models.StatusError,
+		models.StatusDeleted, models.StatusArchived, models.StatusDeleteFailed, models.StatusIncomplete,
+	}
+
+	paths := map[string]interface{}{
+		"/api/stats": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary": "Thumbnail statistics",
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Current statistics", schemaRef("Stats")),
+					"500": errorResponse("Statistics could not be read"),
+				},
+			},
+		},
+		"/api/stats/by-root": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary": "Thumbnail statistics per movie directory",
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Statistics of each movie directory", map[string]interface{}{"type": "array", "items": schemaRef("RootStats")}),
+					"500": errorResponse("Statistics could not be read"),
+				},
+			},
+		},
+		"/api/thumbnails": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary": "List thumbnails",
+				"parameters": []interface{}{
+					queryParam("status", "Only thumbnails with this status", map[string]interface{}{"type": "string", "enum": statuses}),
+					queryParam("viewed", "With status=success, only viewed (1) or unviewed (0) thumbnails", map[string]interface{}{"type": "string", "enum": []string{"0", "1"}}),
+					queryParam("source", "Only generated or imported thumbnails", map[string]interface{}{"type": "string", "enum": []string{"generated", "imported"}}),
+					queryParam("sort", "Order by recording date, oldest first, instead of newest created first", map[string]interface{}{"type": "string", "enum": []string{"recorded_at"}}),
+					queryParam("recorded_after", "Only movies recorded at or after this date or RFC 3339 time", map[string]interface{}{"type": "string"}),
+					queryParam("recorded_before", "Only movies recorded before this date or RFC 3339 time", map[string]interface{}{"type": "string"}),
+					queryParam("limit", "Page size, at most 500", map[string]interface{}{"type": "integer", "default": 50}),
+					queryParam("offset", "Number of matching thumbnails to skip", map[string]interface{}{"type": "integer", "default": 0}),
+					queryParam("envelope", "Wrap the page in an object with the total, limit and offset", map[string]interface{}{"type": "boolean", "default": false}),
+				},
+				"responses": map[string]interface{}{
+					"200": jsonResponse("A page of matching thumbnails, or a ThumbnailPage with envelope=true; X-Total-Count holds the number of matches", map[string]interface{}{"oneOf": []interface{}{
+						map[string]interface{}{"type": "array", "items": schemaRef("Thumbnail")},
+						schemaRef("ThumbnailPage"),
+					}}),
+					"304": map[string]interface{}{"description": "The list has not changed since the ETag given in If-None-Match"},
+					"400": errorResponse("Invalid source, sort, recording date or envelope"),
+					"500": errorResponse("Thumbnails could not be read"),
+				},
+			},
+		},
+		"/api/thumbnails/{id}": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":    "Get a thumbnail",
+				"parameters": []interface{}{idParam},
+				"responses": map[string]interface{}{
+					"200": jsonResponse("The thumbnail", schemaRef("Thumbnail")),
+					"400": errorResponse("Invalid thumbnail ID"),
+					"404": errorResponse("Thumbnail not found"),
+					"500": errorResponse("Thumbnail could not be read"),
+				},
+			},
+			"head": map[string]interface{}{
+				"summary":    "Check that a thumbnail exists",
+				"parameters": []interface{}{idParam},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{"description": "The thumbnail exists; the headers match a GET, without a body"},
+					"404": map[string]interface{}{"description": "Thumbnail not found"},
+				},
+			},
+			"patch": map[string]interface{}{
+				"summary":    "Change a thumbnail's viewed status",
+				"parameters": []interface{}{idParam},
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": schemaRef("ThumbnailPatch")},
+					},
+				},
+				"responses": map[string]interface{}{
+					"200": jsonResponse("The updated thumbnail", schemaRef("Thumbnail")),
+					"400": errorResponse("Invalid thumbnail ID or request body"),
+					"404": errorResponse("Thumbnail not found"),
+					"500": errorResponse("Thumbnail could not be updated"),
+				},
+			},
+		},
+		"/api/thumbnails/{id}/image": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":    "Get a thumbnail's grid image",
+				"parameters": []interface{}{idParam},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "The grid JPEG, with an ETag and Last-Modified for conditional requests",
+						"content":     map[string]interface{}{"image/jpeg": map[string]interface{}{}},
+					},
+					"304": map[string]interface{}{"description": "The image has not changed since the ETag given in If-None-Match"},
+					"400": errorResponse("Invalid thumbnail ID"),
+					"404": errorResponse("Thumbnail not found, not generated, or its file is missing"),
+					"503": errorResponse("Too many thumbnail requests"),
+				},
+			},
+		},
+		"/api/version": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary": "Version, commit and build date of the running server",
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Build information", schemaRef("Version")),
+				},
+			},
+		},
+		"/api/maintenance": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary": "Maintenance mode, set manually or while a scan runs",
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Maintenance state", schemaRef("Maintenance")),
+				},
+			},
+		},
+		"/api/slideshow/session": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Current slideshow session",
+				"description": "Without a session cookie the response is {\"active\": false}.",
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Session state", schemaRef("SlideshowSession")),
+				},
+			},
+		},
+		"/api/slideshow/playlist": map[string]interface{}{
+			"post": map[string]interface{}{
+				"summary":     "Start a slideshow session that shows the given thumbnails in order",
+				"description": "Once the playlist is exhausted the slideshow falls back to its usual selection.",
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": schemaRef("Playlist")},
+					},
+				},
+				"responses": map[string]interface{}{
+					"200": jsonResponse("The new session", schemaRef("SlideshowSession")),
+					"400": errorResponse("Invalid request body, or a thumbnail that cannot be shown"),
+				},
+			},
+		},
+		"/api/slideshow/next-image": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary": "Next thumbnail of the slideshow, for prefetching",
+				"responses": map[string]interface{}{
+					"200": jsonResponse("The next thumbnail, if any", schemaRef("NextImage")),
+					"400": errorResponse("No slideshow session found"),
+				},
+			},
+		},
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Movie Thumbnailer API",
+			"version": version,
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+// openAPIHandler serves the OpenAPI document, generated once when routes are set up
+func (s *Server) openAPIHandler() http.HandlerFunc {
+	doc, err := json.Marshal(openAPIDocument(s.versionString()))
+	if err != nil {
+		s.log.WithError(err).Error("Failed to encode OpenAPI document")
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	}
+}
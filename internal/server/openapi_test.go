@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	s, _ := newSessionTestServer(t)
+
+	rec := httptest.NewRecorder()
+	s.openAPIHandler()(rec, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	var doc struct {
+		OpenAPI    string                     `json:"openapi"`
+		Paths      map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]struct {
+					Type     string `json:"type"`
+					Format   string `json:"format"`
+					Nullable bool   `json:"nullable"`
+				} `json:"properties"`
+				Required []string `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want 3.x", doc.OpenAPI)
+	}
+	for _, path := range []string{"/api/stats", "/api/stats/by-root", "/api/thumbnails", "/api/thumbnails/{id}", "/api/thumbnails/{id}/image", "/api/slideshow/session", "/api/slideshow/next-image"} {
+		if _, ok := doc.Paths[path]; !ok {
+			t.Errorf("path %s missing", path)
+		}
+	}
+
+	// Every JSON field of the model must be described
+	thumbnail := doc.Components.Schemas["Thumbnail"]
+	typ := reflect.TypeOf(models.Thumbnail{})
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if _, ok := thumbnail.Properties[name]; !ok {
+			t.Errorf("Thumbnail schema lacks %q", name)
+		}
+	}
+
+	if p := thumbnail.Properties["created_at"]; p.Type != "string" || p.Format != "date-time" {
+		t.Errorf("created_at = %+v, want date-time string", p)
+	}
+	if p := thumbnail.Properties["last_viewed_at"]; !p.Nullable {
+		t.Error("last_viewed_at should be nullable")
+	}
+	for _, name := range thumbnail.Required {
+		if name == "last_viewed_at" || name == "error_message" {
+			t.Errorf("omitempty field %q marked required", name)
+		}
+	}
+	if p := doc.Components.Schemas["Stats"].Properties["caughtUp"]; p.Type != "boolean" {
+		t.Errorf("Stats.caughtUp type = %q, want boolean", p.Type)
+	}
+
+	// Every $ref must point to a described schema
+	var raw interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &raw); err != nil {
+		t.Fatal(err)
+	}
+	var checkRefs func(v interface{})
+	checkRefs = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				name, found := strings.CutPrefix(ref, "#/components/schemas/")
+				if _, described := doc.Components.Schemas[name]; !found || !described {
+					t.Errorf("$ref %q does not resolve", ref)
+				}
+			}
+			for _, child := range v {
+				checkRefs(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				checkRefs(child)
+			}
+		}
+	}
+	checkRefs(raw)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+// placeholderFile returns the configured placeholder image for a thumbnail status, or
+// "" when the status has none
+func (s *Server) placeholderFile(status string) string {
+	switch status {
+	case models.StatusPending, models.StatusIncomplete:
+		return s.cfg.PendingPlaceholder
+	case models.StatusError:
+		return s.cfg.ErrorPlaceholder
+	default:
+		return ""
+	}
+}
+
+// displayPath returns the URL of the image to show for a thumbnail: the placeholder of
+// a pending or failed thumbnail when one is configured, otherwise the thumbnail itself
+func (s *Server) displayPath(thumbnail *models.Thumbnail) string {
+	if s.placeholderFile(thumbnail.Status) != "" {
+		return "/placeholders/" + thumbnail.Status
+	}
+	if thumbnail.ThumbnailPath == "" {
+		return ""
+	}
+	return "/thumbnails/" + (&url.URL{Path: thumbnail.ThumbnailPath}).EscapedPath()
+}
+
+// handlePlaceholder serves the placeholder image of a thumbnail status
+func (s *Server) handlePlaceholder(w http.ResponseWriter, r *http.Request) {
+	file := s.placeholderFile(mux.Vars(r)["status"])
+	if file == "" {
+		http.NotFound(w, r)
+		return
+	}
+	servePlaceholder(w, r, file)
+}
+
+// servePlaceholderFor serves the placeholder of the thumbnail stored under key when it
+// is missing because the thumbnail is pending or failed. It reports whether it did.
+func (s *Server) servePlaceholderFor(w http.ResponseWriter, r *http.Request, key string) bool {
+	if s.cfg.PendingPlaceholder == "" && s.cfg.ErrorPlaceholder == "" {
+		return false
+	}
+
+	thumbnail, err := s.db.GetByThumbnailPath(key)
+	if err != nil {
+		s.logFrom(r).WithError(err).WithField("thumbnail", key).Warn("Failed to look up missing thumbnail")
+		return false
+	}
+	if thumbnail == nil {
+		return false
+	}
+
+	file := s.placeholderFile(thumbnail.Status)
+	if file == "" {
+		return false
+	}
+	servePlaceholder(w, r, file)
+	return true
+}
+
+// servePlaceholder serves a placeholder image without letting clients cache it, so the
+// real thumbnail shows up once it has been generated
+func servePlaceholder(w http.ResponseWriter, r *http.Request, file string) {
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeFile(w, r, file)
+}
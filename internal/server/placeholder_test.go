@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
+)
+
+func TestThumbnailPlaceholders(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	thumbDir, placeholderDir := t.TempDir(), t.TempDir()
+	s.storage = storage.NewLocal(thumbDir)
+	s.resizeCache = newResizeCache(4)
+	s.router = mux.NewRouter()
+	s.routes()
+	s.cfg.PendingPlaceholder = filepath.Join(placeholderDir, "pending.png")
+	s.cfg.ErrorPlaceholder = filepath.Join(placeholderDir, "error.png")
+	for file, data := range map[string]string{
+		s.cfg.PendingPlaceholder:               "pending image",
+		s.cfg.ErrorPlaceholder:                 "error image",
+		filepath.Join(thumbDir, "success.jpg"): "thumbnail",
+	} {
+		if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	thumbnails := map[string]*models.Thumbnail{}
+	for _, status := range []string{models.StatusPending, models.StatusError, models.StatusSuccess, models.StatusDeleted} {
+		thumbnail := &models.Thumbnail{MoviePath: status + ".mp4", MovieFilename: status + ".mp4", ThumbnailPath: status + ".jpg", Status: status}
+		if err := db.UpsertThumbnail(thumbnail); err != nil {
+			t.Fatal(err)
+		}
+		thumbnails[status] = thumbnail
+	}
+
+	handler := s.thumbnailHandler()
+	tests := []struct {
+		status      string
+		wantCode    int
+		wantBody    string
+		displayPath string
+	}{
+		{models.StatusPending, 200, "pending image", "/placeholders/pending"},
+		{models.StatusError, 200, "error image", "/placeholders/error"},
+		{models.StatusSuccess, 200, "thumbnail", "/thumbnails/success.jpg"},
+		{models.StatusDeleted, 404, "", "/thumbnails/deleted.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/thumbnails/"+tt.status+".jpg", nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+
+			rec = httptest.NewRecorder()
+			req := httptest.NewRequest("GET", fmt.Sprintf("/api/thumbnails/%d", thumbnails[tt.status].ID), nil)
+			s.router.ServeHTTP(rec, req)
+			var got models.Thumbnail
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.DisplayPath != tt.displayPath {
+				t.Errorf("display_path = %q, want %q", got.DisplayPath, tt.displayPath)
+			}
+		})
+	}
+
+	// Without placeholders a missing thumbnail is simply not found
+	s.cfg.PendingPlaceholder = ""
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/thumbnails/pending.jpg", nil))
+	if rec.Code != 404 {
+		t.Errorf("pending thumbnail without a placeholder = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// maxPlaylistLength keeps a playlist small enough for the session cookie
+const maxPlaylistLength = 200
+
+// SlideshowPlaylistRequest is the body of POST /api/slideshow/playlist
+type SlideshowPlaylistRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+// inPlaylist reports whether the session is showing an entry of its playlist
+func (session *SessionData) inPlaylist() bool {
+	return session.PlaylistPos < len(session.Playlist)
+}
+
+// hasPrevious reports whether the slideshow can go back from the current thumbnail
+func (session *SessionData) hasPrevious() bool {
+	if session.inPlaylist() && session.PlaylistPos > 0 {
+		return true
+	}
+	return session.PreviousID > 0 && session.PreviousID != session.CurrentID
+}
+
+// syncPlaylistPos points PlaylistPos at the current thumbnail after navigation that
+// didn't walk the playlist, such as an undo. Thumbnails not in the playlist leave it alone.
+func (session *SessionData) syncPlaylistPos() {
+	for i, id := range session.Playlist {
+		if id == session.CurrentID {
+			session.PlaylistPos = i
+			return
+		}
+	}
+}
+
+// playlistThumbnail returns the first thumbnail of playlist that can still be shown,
+// starting at pos and moving by step, with its position. It returns nil and a
+// position past the end when the playlist runs out in that direction.
+func (s *Server) playlistThumbnail(playlist []int64, pos, step int) (*models.Thumbnail, int, error) {
+	for ; pos >= 0 && pos < len(playlist); pos += step {
+		thumbnail, err := s.db.GetByID(playlist[pos])
+		if err != nil {
+			return nil, pos, fmt.Errorf("failed to get playlist thumbnail %d: %w", playlist[pos], err)
+		}
+		// Movies deleted or archived since the playlist was set are skipped
+		if thumbnail != nil && thumbnail.Status == models.StatusSuccess {
+			return thumbnail, pos, nil
+		}
+	}
+	return nil, len(playlist), nil
+}
+
+// handleSlideshowPlaylist starts a slideshow session that shows the given thumbnails
+// in order before falling back to the session's usual selection
+func (s *Server) handleSlideshowPlaylist(w http.ResponseWriter, r *http.Request) {
+	var req SlideshowPlaylistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, "Invalid JSON request body")
+		return
+	}
+	if len(req.IDs) == 0 {
+		s.writeError(w, r, http.StatusBadRequest, "Playlist must not be empty")
+		return
+	}
+	if len(req.IDs) > maxPlaylistLength {
+		s.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Playlist must have at most %d thumbnails", maxPlaylistLength))
+		return
+	}
+
+	seen := make(map[int64]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if seen[id] {
+			s.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Thumbnail %d appears more than once", id))
+			return
+		}
+		seen[id] = true
+
+		thumbnail, err := s.db.GetByID(id)
+		if err != nil {
+			s.logFrom(r).WithError(err).WithField("thumbnail_id", id).Error("Failed to get playlist thumbnail")
+			s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		if thumbnail == nil || thumbnail.Status != models.StatusSuccess {
+			s.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Thumbnail %d cannot be shown", id))
+			return
+		}
+	}
+
+	session := &SessionData{
+		TotalImages:   len(req.IDs),
+		CurrentID:     req.IDs[0],
+		StartedAt:     time.Now().Unix(),
+		Order:         s.cfg.SlideshowOrder,
+		IncludeViewed: s.cfg.IncludeViewed,
+		Playlist:      req.IDs,
+	}
+	if len(req.IDs) > 1 {
+		session.NextID = req.IDs[1]
+	}
+	if err := s.saveSessionToCookie(w, session); err != nil {
+		s.logFrom(r).WithError(err).Error("Failed to save playlist session")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	s.logFrom(r).WithFields(logrus.Fields{
+		"thumbnails": len(req.IDs),
+	}).Info("Started slideshow playlist")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.sessionResponse(session))
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+func TestSlideshowPlaylist(t *testing.T) {
+	s, db := newSessionTestServer(t)
+
+	ids := map[string]int64{}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		thumbnail := &models.Thumbnail{MoviePath: name + ".mp4", MovieFilename: name + ".mp4", Status: models.StatusSuccess}
+		if err := db.UpsertThumbnail(thumbnail); err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = thumbnail.ID
+	}
+
+	var cookie *http.Cookie
+	do := func(t *testing.T, handler http.HandlerFunc, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == "slideshow_session" {
+				cookie = c
+			}
+		}
+		return rec
+	}
+	session := func(t *testing.T) *SessionData {
+		t.Helper()
+		session, err := s.getSessionFromCookie(&http.Request{Header: http.Header{"Cookie": {cookie.String()}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return session
+	}
+
+	for _, body := range []string{
+		`{"ids": []}`,
+		fmt.Sprintf(`{"ids": [%d, %d]}`, ids["a"], ids["a"]),
+		fmt.Sprintf(`{"ids": [%d, 999]}`, ids["a"]),
+		`not json`,
+	} {
+		if rec := do(t, s.handleSlideshowPlaylist, "POST", "/api/slideshow/playlist", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, rec.Code)
+		}
+	}
+
+	body := fmt.Sprintf(`{"ids": [%d, %d, %d, %d]}`, ids["c"], ids["a"], ids["b"], ids["d"])
+	if rec := do(t, s.handleSlideshowPlaylist, "POST", "/api/slideshow/playlist", body); rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if got := session(t); got.CurrentID != ids["c"] || got.NextID != ids["a"] || got.TotalImages != 4 {
+		t.Fatalf("new playlist session = %+v", got)
+	}
+
+	// b is archived after the playlist was set, so it is skipped both ways
+	if err := db.MarkForArchivalByID(ids["b"]); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		handler http.HandlerFunc
+		want    string
+		pos     int
+	}{
+		{s.handleSlideshowNext, "a", 1},
+		{s.handleSlideshowNext, "d", 3},
+		{s.handleSlideshowPrevious, "a", 1},
+		{s.handleSlideshowPrevious, "c", 0},
+		// Going back from the first entry stays on it
+		{s.handleSlideshowPrevious, "c", 0},
+		{s.handleSlideshowNext, "a", 1},
+		{s.handleSlideshowNext, "d", 3},
+		// Past the end the slideshow falls back to the only other unviewed thumbnail
+		{s.handleSlideshowNext, "e", 4},
+	}
+	for i, step := range steps {
+		do(t, step.handler, "GET", "/slideshow", "")
+		got := session(t)
+		if got.CurrentID != ids[step.want] || got.PlaylistPos != step.pos {
+			t.Fatalf("step %d: current %d at %d, want %s (%d) at %d", i, got.CurrentID, got.PlaylistPos, step.want, ids[step.want], step.pos)
+		}
+	}
+
+	// Playlist entries are marked viewed as the slideshow moves past them
+	for _, name := range []string{"a", "c"} {
+		thumbnail, _ := db.GetByID(ids[name])
+		if !thumbnail.IsViewed() {
+			t.Errorf("%s was not marked as viewed", name)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package server
+
+import (
+	"net/http/pprof"
+
+	"github.com/gorilla/mux"
+)
+
+// registerPprof mounts the net/http/pprof handlers under /debug/pprof/. They expose
+// goroutine stacks, heap contents and the command line, so they are only registered
+// when ENABLE_PPROF is set.
+func registerPprof(router *mux.Router) {
+	router.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	router.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	router.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	router.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	// Index also serves the named profiles, such as /debug/pprof/heap
+	router.PathPrefix("/debug/pprof/").HandlerFunc(pprof.Index)
+}
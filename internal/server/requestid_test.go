@@ -0,0 +1,60 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&logs)
+	s := &Server{log: log}
+
+	var seen string
+	handler := s.requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+		s.logFrom(r).Info("inside handler")
+	}))
+
+	t.Run("generates an ID", func(t *testing.T) {
+		logs.Reset()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+		if len(seen) != 16 {
+			t.Fatalf("expected a 16-character generated ID, got %q", seen)
+		}
+		if rec.Header().Get(requestIDHeader) != seen {
+			t.Errorf("expected response header %q, got %q", seen, rec.Header().Get(requestIDHeader))
+		}
+		if !strings.Contains(logs.String(), "request_id="+seen) {
+			t.Errorf("expected log line to carry request ID, got %q", logs.String())
+		}
+	})
+
+	t.Run("reuses client ID", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(requestIDHeader, "abc-123")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if seen != "abc-123" {
+			t.Errorf("expected client ID to be reused, got %q", seen)
+		}
+	})
+
+	t.Run("replaces invalid client ID", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(requestIDHeader, "has spaces\nand newline")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if seen == "has spaces\nand newline" || len(seen) != 16 {
+			t.Errorf("expected invalid client ID to be replaced, got %q", seen)
+		}
+	})
+}
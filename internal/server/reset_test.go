@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseResetFilter(t *testing.T) {
+	t.Run("query and form values", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/reset-views?min_size=100&created_after=2024-02-01",
+			strings.NewReader("source=imported&path_prefix=shows/"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		filter, err := parseResetFilter(req)
+		if err != nil {
+			t.Fatalf("parseResetFilter failed: %v", err)
+		}
+		if filter.MinSize != 100 || filter.Source != "imported" || filter.PathPrefix != "shows/" {
+			t.Errorf("unexpected filter: %+v", filter)
+		}
+		if !filter.CreatedAfter.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected created_after: %v", filter.CreatedAfter)
+		}
+	})
+
+	t.Run("empty filter", func(t *testing.T) {
+		filter, err := parseResetFilter(httptest.NewRequest("POST", "/reset-views", nil))
+		if err != nil || !filter.IsEmpty() {
+			t.Errorf("expected empty filter, got %+v (err %v)", filter, err)
+		}
+	})
+
+	for _, query := range []string{"min_size=abc", "max_size=-1", "created_before=yesterday", "source=bogus"} {
+		t.Run("invalid "+query, func(t *testing.T) {
+			if _, err := parseResetFilter(httptest.NewRequest("POST", "/reset-views?"+query, nil)); err == nil {
+				t.Errorf("expected error for %s", query)
+			}
+		})
+	}
+}
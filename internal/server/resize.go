@@ -0,0 +1,261 @@
+package server
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
+	"golang.org/x/image/draw"
+)
+
+// resizeCache is a fixed-size LRU cache of resized thumbnail JPEGs
+type resizeCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+type resizeCacheEntry struct {
+	key  string
+	data []byte
+}
+
+// newResizeCache creates an LRU cache holding up to capacity images
+func newResizeCache(capacity int) *resizeCache {
+	return &resizeCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns a cached image and marks it as recently used
+func (c *resizeCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*resizeCacheEntry).data, true
+	}
+	return nil, false
+}
+
+// Add stores an image, evicting the least recently used one when full
+func (c *resizeCache) Add(key string, data []byte) {
+	if c.capacity <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*resizeCacheEntry).data = data
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&resizeCacheEntry{key: key, data: data})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*resizeCacheEntry).key)
+	}
+}
+
+// Len returns the number of cached images
+func (c *resizeCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// clampWidth maps a requested width onto the smallest allowed width that is at least
+// as large, or the largest allowed width. Allowed widths must be sorted ascending.
+func clampWidth(requested int, allowed []int) int {
+	if len(allowed) == 0 {
+		return 0
+	}
+	i := sort.SearchInts(allowed, requested)
+	if i == len(allowed) {
+		return allowed[len(allowed)-1]
+	}
+	return allowed[i]
+}
+
+// resizeJPEG scales a JPEG down to the given width, keeping its aspect ratio.
+// Images that are already narrower are returned unchanged.
+func resizeJPEG(data []byte, width int) ([]byte, error) {
+	src, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode thumbnail: %w", err)
+	}
+
+	bounds := src.Bounds()
+	if width <= 0 || width >= bounds.Dx() {
+		return data, nil
+	}
+
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, fmt.Errorf("failed to encode resized thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// serveQueueTimeout is how long a thumbnail request waits for a free slot under
+// THUMBNAIL_SERVE_CONCURRENCY before it is answered with 503
+const serveQueueTimeout = 5 * time.Second
+
+// acquireServeSlot waits for one of the THUMBNAIL_SERVE_CONCURRENCY slots and returns
+// the function that releases it. It reports false when the request gave up waiting.
+func (s *Server) acquireServeSlot(r *http.Request) (release func(), ok bool) {
+	if s.serveSlots != nil {
+		timer := time.NewTimer(serveQueueTimeout)
+		defer timer.Stop()
+		select {
+		case s.serveSlots <- struct{}{}:
+		case <-timer.C:
+			return nil, false
+		case <-r.Context().Done():
+			return nil, false
+		}
+	}
+
+	if s.metrics != nil {
+		s.metrics.IncThumbnailServesInFlight()
+	}
+	return func() {
+		if s.metrics != nil {
+			s.metrics.DecThumbnailServesInFlight()
+		}
+		if s.serveSlots != nil {
+			<-s.serveSlots
+		}
+	}, true
+}
+
+// thumbnailHandler serves thumbnail files from storage, resizing them on the fly
+// when a ?w= width is given
+func (s *Server) thumbnailHandler() http.Handler {
+	return http.StripPrefix("/thumbnails/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Resolve the key inside thumbnail storage, rejecting traversal
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		// Queue briefly for a read slot rather than piling reads onto the disk
+		release, ok := s.acquireServeSlot(r)
+		if !ok {
+			w.Header().Set("Retry-After", "1")
+			s.writeError(w, r, http.StatusServiceUnavailable, "Too many thumbnail requests")
+			return
+		}
+		defer release()
+
+		width := 0
+		if widthStr := r.URL.Query().Get("w"); widthStr != "" && len(s.cfg.ThumbnailWidths) > 0 {
+			requested, err := strconv.Atoi(widthStr)
+			if err != nil || requested <= 0 {
+				s.writeError(w, r, http.StatusBadRequest, "Invalid width")
+				return
+			}
+			if strings.HasSuffix(strings.ToLower(name), ".jpg") {
+				width = clampWidth(requested, s.cfg.ThumbnailWidths)
+			}
+		}
+
+		body, info, err := s.storage.Get(r.Context(), name)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotExist) {
+				if !s.servePlaceholderFor(w, r, name) {
+					http.NotFound(w, r)
+				}
+				return
+			}
+			s.logFrom(r).WithError(err).WithField("thumbnail", name).Error("Failed to read thumbnail")
+			s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		defer body.Close()
+
+		if width == 0 {
+			serveObject(w, r, name, info, body)
+			return
+		}
+
+		key := fmt.Sprintf("%s|%d|%d", name, width, info.ModTime.UnixNano())
+		data, ok := s.resizeCache.Get(key)
+		if !ok {
+			original, err := io.ReadAll(body)
+			if err != nil {
+				s.logFrom(r).WithError(err).WithField("thumbnail", name).Error("Failed to read thumbnail")
+				s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+				return
+			}
+			data, err = resizeJPEG(original, width)
+			if err != nil {
+				s.logFrom(r).WithError(err).WithField("thumbnail", name).Warn("Failed to resize thumbnail, serving original")
+				data = original
+			}
+			s.resizeCache.Add(key, data)
+		}
+
+		http.ServeContent(w, r, name, info.ModTime, bytes.NewReader(data))
+	}))
+}
+
+// serveObject streams a stored object without buffering it. Seekable bodies, such as
+// local files, go through http.ServeContent for range and conditional requests;
+// others are copied as they are read, answering only If-Modified-Since.
+func serveObject(w http.ResponseWriter, r *http.Request, name string, info storage.ObjectInfo, body io.Reader) {
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, name, info.ModTime, seeker)
+		return
+	}
+
+	if !info.ModTime.IsZero() {
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !info.ModTime.Truncate(time.Second).After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if w.Header().Get("Content-Type") == "" {
+		if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+	}
+	if info.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, body)
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/config"
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+func TestClampWidth(t *testing.T) {
+	allowed := []int{320, 640, 1280}
+	tests := []struct{ requested, want int }{
+		{1, 320},
+		{320, 320},
+		{321, 640},
+		{1000, 1280},
+		{99999, 1280},
+	}
+	for _, tt := range tests {
+		if got := clampWidth(tt.requested, allowed); got != tt.want {
+			t.Errorf("clampWidth(%d) = %d, want %d", tt.requested, got, tt.want)
+		}
+	}
+}
+
+func TestResizeCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newResizeCache(2)
+	c.Add("a", []byte("a"))
+	c.Add("b", []byte("b"))
+	c.Get("a") // a is now more recent than b
+	c.Add("c", []byte("c"))
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("expected a to be kept")
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", c.Len())
+	}
+}
+
+func TestThumbnailHandlerResizes(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+	for x := 0; x < 1000; x++ {
+		img.Set(x, 0, color.RGBA{uint8(x), 0, 0, 255})
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "grid.jpg"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := &Server{
+		cfg:         &config.Config{ThumbnailsDir: dir, ThumbnailWidths: []int{320, 640}},
+		log:         log,
+		storage:     storage.NewLocal(dir),
+		resizeCache: newResizeCache(4),
+	}
+	handler := s.thumbnailHandler()
+
+	decodeWidth := func(t *testing.T, body []byte) int {
+		t.Helper()
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("response is not a JPEG: %v", err)
+		}
+		return cfg.Width
+	}
+
+	t.Run("resizes to whitelisted width", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/thumbnails/grid.jpg?w=500", nil))
+		if rec.Code != 200 {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		if w := decodeWidth(t, rec.Body.Bytes()); w != 640 {
+			t.Errorf("expected width 640, got %d", w)
+		}
+		if s.resizeCache.Len() != 1 {
+			t.Errorf("expected resized image to be cached")
+		}
+	})
+
+	t.Run("original without width", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/thumbnails/grid.jpg", nil))
+		if w := decodeWidth(t, rec.Body.Bytes()); w != 1000 {
+			t.Errorf("expected original width 1000, got %d", w)
+		}
+	})
+
+	t.Run("rejects traversal", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/thumbnails/../../etc/passwd.jpg?w=320", nil))
+		if rec.Code != 404 {
+			t.Errorf("expected 404, got %d", rec.Code)
+		}
+	})
+
+	t.Run("invalid width", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/thumbnails/grid.jpg?w=abc", nil))
+		if rec.Code != 400 {
+			t.Errorf("expected 400, got %d", rec.Code)
+		}
+	})
+}
+
+func TestServeObjectStreamsUnseekableBodies(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	info := storage.ObjectInfo{Key: "grid.jpg", Size: 4, ModTime: modTime}
+	serve := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/thumbnails/grid.jpg", nil)
+		req.Header = header
+		rec := httptest.NewRecorder()
+		// A MultiReader hides the Seek of the strings.Reader, like an S3 response body
+		serveObject(rec, req, "grid.jpg", info, io.MultiReader(strings.NewReader("jpeg")))
+		return rec
+	}
+
+	rec := serve(http.Header{})
+	if rec.Code != http.StatusOK || rec.Body.String() != "jpeg" {
+		t.Fatalf("serveObject() = %d %q, want 200 and the object", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Length"); got != "4" {
+		t.Errorf("Content-Length = %q, want 4", got)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "image/jpeg" {
+		t.Errorf("Content-Type = %q, want image/jpeg", got)
+	}
+
+	rec = serve(http.Header{"If-Modified-Since": {modTime.Format(http.TimeFormat)}})
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("serveObject() with If-Modified-Since = %d with %d bytes, want an empty 304", rec.Code, rec.Body.Len())
+	}
+}
+
+// countingStorage tracks concurrent reads, holding each one until release is closed
+type countingStorage struct {
+	storage.Storage
+	release chan struct{}
+	mu      sync.Mutex
+	active  int
+	maxSeen int
+	started chan struct{}
+}
+
+func (c *countingStorage) Get(ctx context.Context, key string) (io.ReadCloser, storage.ObjectInfo, error) {
+	c.mu.Lock()
+	c.active++
+	if c.active > c.maxSeen {
+		c.maxSeen = c.active
+	}
+	c.mu.Unlock()
+	c.started <- struct{}{}
+
+	<-c.release
+	c.mu.Lock()
+	c.active--
+	c.mu.Unlock()
+	return c.Storage.Get(ctx, key)
+}
+
+func TestThumbnailHandlerConcurrencyLimit(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "grid.jpg"), []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	const limit, requests = 2, 6
+	store := &countingStorage{
+		Storage: storage.NewLocal(dir),
+		release: make(chan struct{}),
+		started: make(chan struct{}, requests),
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := &Server{
+		cfg:         &config.Config{ThumbnailsDir: dir},
+		log:         log,
+		storage:     store,
+		resizeCache: newResizeCache(4),
+		serveSlots:  make(chan struct{}, limit),
+	}
+	handler := s.thumbnailHandler()
+
+	var wg sync.WaitGroup
+	codes := make(chan int, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/thumbnails/grid.jpg", nil))
+			codes <- rec.Code
+		}()
+	}
+
+	// Wait until the slots are taken, then give the queued requests a chance to
+	// overtake the limit
+	for i := 0; i < limit; i++ {
+		<-store.started
+	}
+	time.Sleep(50 * time.Millisecond)
+	store.mu.Lock()
+	active := store.active
+	store.mu.Unlock()
+	if active != limit {
+		t.Errorf("concurrent reads = %d, want %d", active, limit)
+	}
+
+	close(store.release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != 200 {
+			t.Errorf("queued request status = %d, want 200", code)
+		}
+	}
+	if store.maxSeen != limit {
+		t.Errorf("max concurrent reads = %d, want %d", store.maxSeen, limit)
+	}
+}
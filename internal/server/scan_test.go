@@ -0,0 +1,52 @@
+package server
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/config"
+	"github.com/pandino/movie-thumbnailer-go/internal/scanner"
+	"github.com/sirupsen/logrus"
+)
+
+func TestHandleAPIScanRejectsInvalidImport(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	cfg := &config.Config{}
+	s := &Server{cfg: cfg, log: log, scanner: scanner.New(cfg, nil, nil, log, nil)}
+
+	rec := httptest.NewRecorder()
+	s.handleAPIScan(rec, httptest.NewRequest("POST", "/api/scan?import=maybe", nil))
+	if rec.Code != 400 {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+	if s.scanner.IsScanning() {
+		t.Error("expected no scan to start")
+	}
+}
+
+func TestHandleAPICleanupValidatesPhases(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	cfg := &config.Config{}
+	s := &Server{cfg: cfg, log: log, scanner: scanner.New(cfg, nil, nil, log, nil)}
+
+	for _, query := range []string{"?phase=vacuum", "?phase=orphans,orphans", "?phase=orphans,"} {
+		rec := httptest.NewRecorder()
+		s.handleAPICleanup(rec, httptest.NewRequest("POST", "/api/cleanup"+query, nil))
+		if rec.Code != 400 {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+	if s.scanner.IsBusy() {
+		t.Error("expected no cleanup to start")
+	}
+
+	cfg.DisableDeletion = true
+	rec := httptest.NewRecorder()
+	s.handleAPICleanup(rec, httptest.NewRequest("POST", "/api/cleanup?phase=orphans", nil))
+	if rec.Code != 403 {
+		t.Errorf("with DISABLE_DELETION: expected 403, got %d", rec.Code)
+	}
+}
@@ -2,50 +2,96 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pandino/movie-thumbnailer-go/internal/config"
 	"github.com/pandino/movie-thumbnailer-go/internal/database"
+	"github.com/pandino/movie-thumbnailer-go/internal/httpclient"
 	"github.com/pandino/movie-thumbnailer-go/internal/metrics"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
 	"github.com/pandino/movie-thumbnailer-go/internal/scanner"
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
 
 // VersionInfo holds application version information
 type VersionInfo struct {
-	Version   string
-	Commit    string
-	BuildDate string
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
 }
 
 // Server handles HTTP requests for the application
 type Server struct {
 	cfg     *config.Config
 	db      *database.DB
+	storage storage.Storage
 	scanner *scanner.Scanner
 	log     *logrus.Logger
 	server  *http.Server
 	router  *mux.Router
+
+	// Admin listener for metrics, profiling and the control routes, if configured
+	adminServer *http.Server
+	adminRouter *mux.Router
+
 	appCtx  context.Context
 	version *VersionInfo
 	metrics *metrics.Metrics
+
+	// Last successfully read stats, used when a read fails
+	statsMu   sync.Mutex
+	lastStats *models.Stats
+
+	// Recently resized thumbnails
+	resizeCache *resizeCache
+
+	// Slots limiting concurrent thumbnail reads; nil when unlimited
+	serveSlots chan struct{}
+
+	// Detects the library-wide unviewed pool running out
+	caughtUp caughtUpDetector
+
+	// Client for outbound requests such as webhooks
+	httpClient *http.Client
+
+	// Maintenance mode shown on the HTML pages
+	maintenance maintenanceState
+
+	// Viewed movies the slideshow stages for deletion; empty when off
+	autoDelete config.AutoDeleteCriteria
 }
 
 // New creates a new Server
-func New(cfg *config.Config, db *database.DB, scanner *scanner.Scanner, log *logrus.Logger, appCtx context.Context, version *VersionInfo) *Server {
+func New(cfg *config.Config, db *database.DB, store storage.Storage, scanner *scanner.Scanner, log *logrus.Logger, appCtx context.Context, version *VersionInfo) *Server {
 	s := &Server{
 		cfg:     cfg,
 		db:      db,
+		storage: store,
 		scanner: scanner,
 		log:     log,
 		router:  mux.NewRouter(),
 		appCtx:  appCtx,
 		version: version,
 		metrics: metrics.New(),
+
+		resizeCache: newResizeCache(cfg.ThumbnailCacheSize),
+	}
+	// Invalid criteria are reported by Config.Validate
+	s.autoDelete, _ = config.ParseAutoDeleteCriteria(cfg.AutoDeleteCriteria)
+	if cfg.ThumbnailServeConcurrency > 0 {
+		s.serveSlots = make(chan struct{}, cfg.ThumbnailServeConcurrency)
+	}
+	s.httpClient = httpclient.New(cfg.HTTPClientTimeout, httpclient.UserAgent(s.versionString()))
+	if cfg.AdminPort != "" {
+		s.adminRouter = mux.NewRouter()
 	}
 
 	// Initialize routes
@@ -59,71 +105,176 @@ func New(cfg *config.Config, db *database.DB, scanner *scanner.Scanner, log *log
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	if s.adminRouter != nil {
+		s.adminServer = &http.Server{
+			Addr:         fmt.Sprintf("%s:%s", cfg.AdminHost, cfg.AdminPort),
+			Handler:      s.adminRouter,
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 15 * time.Second,
+			IdleTimeout:  60 * time.Second,
+		}
+	}
 
 	return s
 }
 
-// Start begins the HTTP server
+// versionString returns the build version, or "dev" when it is unknown
+func (s *Server) versionString() string {
+	if s.version != nil && s.version.Version != "" {
+		return s.version.Version
+	}
+	return "dev"
+}
+
+// handleVersion returns the build information as JSON
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	info := VersionInfo{}
+	if s.version != nil {
+		info = *s.version
+	}
+	info.Version = s.versionString()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// handleConfig returns the effective configuration, with secrets redacted
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.cfg.Effective())
+}
+
+// Start begins the HTTP server, and the admin server if configured. It returns the
+// first error of either.
 func (s *Server) Start() error {
+	errs := make(chan error, 2)
+	if s.adminServer != nil {
+		s.log.Infof("Starting admin server on %s:%s", s.cfg.AdminHost, s.cfg.AdminPort)
+		go func() { errs <- s.adminServer.ListenAndServe() }()
+	}
+
 	s.log.Infof("Starting server on %s:%s", s.cfg.ServerHost, s.cfg.ServerPort)
-	return s.server.ListenAndServe()
+	go func() { errs <- s.server.ListenAndServe() }()
+	return <-errs
 }
 
-// Shutdown gracefully stops the HTTP server
+// Shutdown gracefully stops the HTTP server and the admin server
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.log.Info("Shutting down server")
-	return s.server.Shutdown(ctx)
+	err := s.server.Shutdown(ctx)
+	if s.adminServer != nil {
+		err = errors.Join(err, s.adminServer.Shutdown(ctx))
+	}
+	return err
 }
 
-// routes initializes the HTTP routes
+// routes initializes the HTTP routes. With an admin listener, the main router only
+// gets the viewing routes and the admin router gets all of them.
 func (s *Server) routes() {
-	// Middleware
-	s.router.Use(s.loggingMiddleware)
-	s.router.Use(s.recoveryMiddleware)
+	if s.adminRouter == nil {
+		s.registerRoutes(s.router, true)
+		return
+	}
+	s.registerRoutes(s.router, false)
+	s.registerRoutes(s.adminRouter, true)
+}
 
-	// Static files
-	fs := http.FileServer(http.Dir(s.cfg.StaticDir))
-	s.router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", fs))
+// registerRoutes adds the viewing routes to router, and with admin also the metrics,
+// profiling, control page and mutation routes
+func (s *Server) registerRoutes(router *mux.Router, admin bool) {
+	// Middleware
+	router.Use(s.requestIDMiddleware)
+	router.Use(clientIPMiddleware(parseTrustedProxies(s.cfg.TrustedProxies)))
+	router.Use(s.loggingMiddleware)
+	router.Use(s.recoveryMiddleware)
+	router.Use(s.maintenanceMiddleware)
 
 	// Thumbnails
-	tfs := http.FileServer(http.Dir(s.cfg.ThumbnailsDir))
-	s.router.PathPrefix("/thumbnails/").Handler(http.StripPrefix("/thumbnails/", tfs))
+	router.PathPrefix("/thumbnails/").Handler(s.thumbnailHandler()).Methods("GET", "HEAD")
+	router.HandleFunc("/placeholders/{status}", s.handlePlaceholder).Methods("GET", "HEAD")
+	router.HandleFunc("/thumbnail-by-movie/{path:.+}", s.handleThumbnailByMovie).Methods("GET", "HEAD")
 
-	// Control page routes
-	s.router.HandleFunc("/", s.handleControlPage).Methods("GET")
-	s.router.HandleFunc("/scan", s.handleScan).Methods("POST")
-	s.router.HandleFunc("/cleanup", s.handleCleanup).Methods("POST")
-	s.router.HandleFunc("/reset-views", s.handleResetViews).Methods("POST")
-	s.router.HandleFunc("/process-deletions", s.handleProcessDeletions).Methods("POST")
-	s.router.HandleFunc("/process-archival", s.handleProcessArchival).Methods("POST")
-	s.router.HandleFunc("/undo-delete", s.handleUndoDelete).Methods("POST")
+	// API routes
+	router.HandleFunc("/api/openapi.json", s.openAPIHandler()).Methods("GET")
+	router.HandleFunc("/api/version", s.handleVersion).Methods("GET")
+	router.HandleFunc("/api/stats", s.handleStats).Methods("GET")
+	router.HandleFunc("/api/stats/by-root", s.handleStatsByRoot).Methods("GET")
+	router.HandleFunc("/api/thumbnails", s.handleThumbnails).Methods("GET")
+	router.HandleFunc("/api/thumbnails/{id}", s.handleThumbnail).Methods("GET", "HEAD")
+	router.HandleFunc("/api/thumbnails/{id}/image", s.handleThumbnailImage).Methods("GET", "HEAD")
+	router.HandleFunc("/api/thumbnails/{id}", s.handleAPIThumbnailPatch).Methods("PATCH")
+	router.HandleFunc("/api/slideshow/next-image", s.handleSlideshowNextImage).Methods("GET")
+	router.HandleFunc("/api/slideshow/session", s.handleSlideshowSession).Methods("GET")
+	router.HandleFunc("/api/slideshow/playlist", s.handleSlideshowPlaylist).Methods("POST")
+	router.HandleFunc("/api/v1/video/status/{filename}", s.handleAPIVideoStatus).Methods("GET")
+	router.HandleFunc("/api/movies/{path:.+}/viewed", s.handleAPIMovieViewed).Methods("POST")
+	router.HandleFunc("/api/maintenance", s.handleMaintenance).Methods("GET")
+
+	if admin {
+		router.HandleFunc("/api/scan", s.handleAPIScan).Methods("POST")
+		router.HandleFunc("/api/scan/progress", s.handleScanProgress).Methods("GET")
+		router.HandleFunc("/api/cleanup", s.handleAPICleanup).Methods("POST")
+		router.HandleFunc("/api/deletions", s.handleDeletions).Methods("GET")
+		router.HandleFunc("/api/deletions/process", s.handleDeletionsProcess).Methods("POST")
+		router.HandleFunc("/api/deletions/approve", s.handleDeletionsApprove).Methods("POST")
+		router.HandleFunc("/api/deletions/progress", s.handleDeletionsProgress).Methods("GET")
+		router.HandleFunc("/api/deletions/{id}/cancel", s.handleDeletionCancel).Methods("POST")
+		router.HandleFunc("/api/db/backup", s.handleDBBackup).Methods("POST")
+		router.HandleFunc("/api/db/vacuum", s.handleDBVacuum).Methods("POST")
+		router.HandleFunc("/api/verify", s.handleVerify).Methods("GET")
+		router.HandleFunc("/api/config", s.handleConfig).Methods("GET")
+		router.HandleFunc("/api/maintenance", s.handleSetMaintenance).Methods("POST")
+
+		// API v1 routes for video operations
+		router.HandleFunc("/api/v1/video/archive", s.handleAPIArchiveVideo).Methods("POST")
+		router.HandleFunc("/api/v1/video/delete", s.handleAPIDeleteVideo).Methods("POST")
+		router.HandleFunc("/api/movies/{path:.+}/delete", s.handleAPIMovieDelete).Methods("POST")
+		router.HandleFunc("/api/thumbnails/{id}", s.handleAPIThumbnailDelete).Methods("DELETE")
+
+		// Metrics endpoint
+		router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+
+		// Profiling endpoints
+		if s.cfg.EnablePprof {
+			registerPprof(router)
+		}
+	}
 
-	// Slideshow routes
-	s.router.HandleFunc("/slideshow", s.handleSlideshow).Methods("GET")
-	s.router.HandleFunc("/slideshow/next", s.handleSlideshowNext).Methods("GET")
-	s.router.HandleFunc("/slideshow/previous", s.handleSlideshowPrevious).Methods("GET")
-	s.router.HandleFunc("/slideshow/mark-viewed", s.handleMarkViewed).Methods("POST")
-	s.router.HandleFunc("/slideshow/delete", s.handleDelete).Methods("POST")
-	s.router.HandleFunc("/slideshow/archive", s.handleArchive).Methods("POST")
-	s.router.HandleFunc("/slideshow/finish", s.handleSlideshowFinish).Methods("GET")
-	s.router.HandleFunc("/slideshow/delete-and-finish", s.handleDeleteAndFinish).Methods("POST")
+	// 404 handler
+	router.NotFoundHandler = http.HandlerFunc(s.handleNotFound)
 
-	// API routes
-	s.router.HandleFunc("/api/stats", s.handleStats).Methods("GET")
-	s.router.HandleFunc("/api/thumbnails", s.handleThumbnails).Methods("GET")
-	s.router.HandleFunc("/api/thumbnails/{id}", s.handleThumbnail).Methods("GET")
-	s.router.HandleFunc("/api/slideshow/next-image", s.handleSlideshowNextImage).Methods("GET")
+	// Headless mode serves only the API, thumbnails and metrics
+	if s.cfg.Headless {
+		return
+	}
 
-	// API v1 routes for video operations
-	s.router.HandleFunc("/api/v1/video/archive", s.handleAPIArchiveVideo).Methods("POST")
-	s.router.HandleFunc("/api/v1/video/delete", s.handleAPIDeleteVideo).Methods("POST")
-	s.router.HandleFunc("/api/v1/video/status/{filename}", s.handleAPIVideoStatus).Methods("GET")
+	// Static files
+	fs := http.FileServer(http.Dir(s.cfg.StaticDir))
+	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", fs))
 
-	// Metrics endpoint
-	s.router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	// Control page routes
+	if admin {
+		router.HandleFunc("/", s.handleControlPage).Methods("GET")
+		router.HandleFunc("/scan", s.handleScan).Methods("POST")
+		router.HandleFunc("/cleanup", s.handleCleanup).Methods("POST")
+		router.HandleFunc("/reset-views", s.handleResetViews).Methods("POST")
+		router.HandleFunc("/process-deletions", s.handleProcessDeletions).Methods("POST")
+		router.HandleFunc("/process-archival", s.handleProcessArchival).Methods("POST")
+		router.HandleFunc("/undo-delete", s.handleUndoDelete).Methods("POST")
+	} else {
+		// The slideshow links back to the control page, which is only on the admin listener
+		router.Handle("/", http.RedirectHandler("/slideshow", http.StatusSeeOther)).Methods("GET")
+	}
 
-	// 404 handler
-	s.router.NotFoundHandler = http.HandlerFunc(s.handleNotFound)
+	// Slideshow routes
+	router.HandleFunc("/slideshow", s.handleSlideshow).Methods("GET")
+	router.HandleFunc("/slideshow/next", s.handleSlideshowNext).Methods("GET")
+	router.HandleFunc("/slideshow/previous", s.handleSlideshowPrevious).Methods("GET")
+	router.HandleFunc("/slideshow/mark-viewed", s.handleMarkViewed).Methods("POST")
+	router.HandleFunc("/slideshow/delete", s.handleDelete).Methods("POST")
+	router.HandleFunc("/slideshow/archive", s.handleArchive).Methods("POST")
+	router.HandleFunc("/slideshow/finish", s.handleSlideshowFinish).Methods("GET")
+	router.HandleFunc("/slideshow/delete-and-finish", s.handleDeleteAndFinish).Methods("POST")
 }
 
 // loggingMiddleware logs HTTP requests and records metrics
@@ -154,16 +305,18 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 		}
 
 		// Record metrics
-		s.metrics.RecordHTTPRequest(r.Method, endpoint, fmt.Sprintf("%d", ww.Status()), duration)
+		if s.metrics != nil {
+			s.metrics.RecordHTTPRequest(r.Method, endpoint, fmt.Sprintf("%d", ww.Status()), duration)
+		}
 
 		// Log the request
-		s.log.WithFields(logrus.Fields{
+		s.logFrom(r).WithFields(logrus.Fields{
 			"method":     r.Method,
 			"path":       r.URL.Path,
 			"status":     ww.Status(),
 			"duration":   duration,
 			"user-agent": r.UserAgent(),
-			"remote":     r.RemoteAddr,
+			"remote":     clientIP(r),
 		}).Info("HTTP request")
 	})
 }
@@ -173,8 +326,8 @@ func (s *Server) recoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				s.log.WithField("error", err).Error("Panic in HTTP handler")
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				s.logFrom(r).WithField("error", err).Error("Panic in HTTP handler")
+				s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -206,6 +359,12 @@ func (w *WrappedResponseWriter) Status() int {
 	return w.statusCode
 }
 
+// Unwrap returns the underlying writer, so http.ResponseController can flush and
+// extend deadlines through the wrapper
+func (w *WrappedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // GetMetrics returns the metrics instance for use by other components
 func (s *Server) GetMetrics() *metrics.Metrics {
 	return s.metrics
@@ -225,7 +384,11 @@ func (s *Server) UpdateMetricsFromStats() {
 	}
 
 	// Update thumbnail counts
-	s.metrics.UpdateThumbnailCounts(stats.Success, stats.Error, stats.Pending, stats.Deleted, stats.Archived)
+	s.metrics.UpdateThumbnailStatusCounts(stats)
+
+	if s.caughtUp.Observe(stats.Unviewed) {
+		s.notifyCaughtUp(stats)
+	}
 
 	// Update file sizes
 	s.metrics.UpdateFileSizes(stats.ViewedSize, stats.UnviewedSize)
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pandino/movie-thumbnailer-go/internal/config"
+)
+
+func TestHeadlessRoutes(t *testing.T) {
+	base, _ := newSessionTestServer(t)
+	base.cfg.Headless = true
+	s := New(base.cfg, base.db, nil, nil, base.log, context.Background(), &VersionInfo{})
+
+	for _, path := range []string{"/", "/slideshow", "/slideshow/next", "/static/style.css"} {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404 in headless mode", path, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/slideshow/session", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /api/slideshow/session = %d, want 200 in headless mode", rec.Code)
+	}
+}
+
+func TestAdminRoutesOnlyOnAdminListener(t *testing.T) {
+	s, _ := newSessionTestServer(t)
+	s.cfg.AdminPort = "9090"
+	s.cfg.EnablePprof = true
+	s.router = mux.NewRouter()
+	s.adminRouter = mux.NewRouter()
+	s.routes()
+
+	admin := []struct{ method, path string }{
+		{"GET", "/metrics"},
+		{"GET", "/debug/pprof/"},
+		{"POST", "/scan"},
+		{"POST", "/cleanup"},
+		{"POST", "/api/scan"},
+		{"GET", "/api/deletions"},
+		{"POST", "/api/deletions/approve"},
+		{"POST", "/api/db/backup"},
+		{"GET", "/api/config"},
+		{"POST", "/api/v1/video/delete"},
+	}
+	for _, route := range admin {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(route.method, route.path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("public %s %s = %d, want 404", route.method, route.path, rec.Code)
+		}
+	}
+
+	for _, path := range []string{"/metrics", "/debug/pprof/"} {
+		rec := httptest.NewRecorder()
+		s.adminRouter.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("admin GET %s = %d, want 200", path, rec.Code)
+		}
+	}
+
+	for _, router := range []*mux.Router{s.router, s.adminRouter} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/slideshow/session", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET /api/slideshow/session = %d, want 200 on both listeners", rec.Code)
+		}
+	}
+
+	// The slideshow's links to the control page lead back to the slideshow
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/slideshow" {
+		t.Errorf("public GET / = %d %q, want a redirect to /slideshow", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestHandleVersion(t *testing.T) {
+	s, _ := newSessionTestServer(t)
+	s.router = mux.NewRouter()
+	s.routes()
+
+	get := func(t *testing.T) VersionInfo {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/version", nil))
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("GET /api/version = %d (%s)", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		var info VersionInfo
+		if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+
+	if info := get(t); info != (VersionInfo{Version: "dev"}) {
+		t.Errorf("without build info = %+v, want version dev", info)
+	}
+
+	s.version = &VersionInfo{Version: "v1.4.0", Commit: "3f2c1ab", BuildDate: "2026-10-01T12:00:00Z"}
+	if info := get(t); info != *s.version {
+		t.Errorf("version = %+v, want %+v", info, *s.version)
+	}
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	t.Setenv("GRID_COLS", "6")
+	t.Setenv("INTRO_SKIP_MAX", "90s")
+	t.Setenv("FILE_EXTENSIONS", "mkv,mp4")
+	t.Setenv("S3_SECRET_ACCESS_KEY", "hunter2")
+	t.Setenv("CAUGHT_UP_WEBHOOK", "https://hooks.example.com/token")
+	t.Setenv("S3_ACCESS_KEY_ID", "")
+
+	s, _ := newSessionTestServer(t)
+	s.cfg = config.New()
+	s.router = mux.NewRouter()
+	s.routes()
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/config", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"grid_cols":            float64(6),
+		"grid_rows":            float64(4),
+		"intro_skip_max":       "1m30s",
+		"file_extensions":      []interface{}{"mkv", "mp4"},
+		"s3_secret_access_key": "***",
+		"caught_up_webhook":    "***",
+		"s3_access_key_id":     "",
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(got[key], value) {
+			t.Errorf("%s = %#v, want %#v", key, got[key], value)
+		}
+	}
+	if strings.Contains(rec.Body.String(), "hunter2") || strings.Contains(rec.Body.String(), "token") {
+		t.Errorf("response leaks a secret: %s", rec.Body)
+	}
+}
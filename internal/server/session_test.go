@@ -0,0 +1,514 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/config"
+	"github.com/pandino/movie-thumbnailer-go/internal/database"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/pandino/movie-thumbnailer-go/internal/scanner"
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+func newSessionTestServer(t *testing.T) (*Server, *database.DB) {
+	t.Helper()
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return &Server{cfg: &config.Config{}, db: db, log: log}, db
+}
+
+func sessionCookie(t *testing.T, session SessionData) *http.Cookie {
+	t.Helper()
+	data, err := json.Marshal(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Cookie{Name: "slideshow_session", Value: base64.StdEncoding.EncodeToString(data)}
+}
+
+func TestHandleSlideshowSession(t *testing.T) {
+	s, db := newSessionTestServer(t)
+
+	for _, name := range []string{"a.mp4", "b.mp4"} {
+		if err := db.UpsertThumbnail(&models.Thumbnail{
+			MoviePath:     name,
+			MovieFilename: name,
+			ThumbnailPath: name + ".jpg",
+			Status:        models.StatusSuccess,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	current, _ := db.GetByMoviePath("a.mp4")
+	other, _ := db.GetByMoviePath("b.mp4")
+
+	get := func(t *testing.T, cookie *http.Cookie) SlideshowSessionResponse {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/slideshow/session", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		s.handleSlideshowSession(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		var resp SlideshowSessionResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	t.Run("no session", func(t *testing.T) {
+		if resp := get(t, nil); resp.Active {
+			t.Errorf("expected inactive session, got %+v", resp)
+		}
+	})
+
+	t.Run("invalid cookie", func(t *testing.T) {
+		if resp := get(t, &http.Cookie{Name: "slideshow_session", Value: "!!!"}); resp.Active {
+			t.Errorf("expected inactive session, got %+v", resp)
+		}
+	})
+
+	t.Run("session with more to view", func(t *testing.T) {
+		resp := get(t, sessionCookie(t, SessionData{
+			TotalImages:     2,
+			NavigationCount: 0,
+			CurrentID:       current.ID,
+			StartedAt:       time.Now().Unix(),
+			DeletedSize:     2048,
+		}))
+		if !resp.Active || resp.Position != 1 || resp.HasPrevious || resp.IsLast {
+			t.Errorf("unexpected response: %+v", resp)
+		}
+		if resp.DeletedSizeFormatted != "2.00 KB" {
+			t.Errorf("expected formatted size 2.00 KB, got %q", resp.DeletedSizeFormatted)
+		}
+	})
+
+	t.Run("last thumbnail with previous", func(t *testing.T) {
+		resp := get(t, sessionCookie(t, SessionData{
+			TotalImages:     2,
+			NavigationCount: 1,
+			CurrentID:       current.ID,
+			PreviousID:      other.ID,
+			StartedAt:       time.Now().Unix(),
+			PendingDelete:   true,
+		}))
+		if !resp.Active || resp.Position != 2 || !resp.HasPrevious || !resp.IsLast || !resp.PendingDelete {
+			t.Errorf("unexpected response: %+v", resp)
+		}
+	})
+}
+
+func TestSlideshowOrder(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	s.scanner = scanner.New(s.cfg, db, nil, s.log, nil)
+
+	for _, name := range []string{"a.mp4", "b.mp4", "c.mp4"} {
+		if err := db.UpsertThumbnail(&models.Thumbnail{
+			MoviePath:     name,
+			MovieFilename: name,
+			ThumbnailPath: name + ".jpg",
+			Status:        models.StatusSuccess,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	viewed, _ := db.GetByMoviePath("a.mp4")
+	current, _ := db.GetByMoviePath("b.mp4")
+	unviewed, _ := db.GetByMoviePath("c.mp4")
+	if err := db.MarkAsViewedByID(viewed.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Start the session after the earlier view so it still counts as least recently viewed
+	startedAt := time.Now().Add(time.Minute).Unix()
+
+	next := func(t *testing.T, order string) SessionData {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/slideshow/next", nil)
+		req.AddCookie(sessionCookie(t, SessionData{
+			TotalImages: 3,
+			CurrentID:   current.ID,
+			StartedAt:   startedAt,
+			Order:       order,
+		}))
+		rec := httptest.NewRecorder()
+		s.handleSlideshowNext(rec, req)
+
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == "slideshow_session" {
+				session, err := s.getSessionFromCookie(&http.Request{Header: http.Header{"Cookie": {c.String()}}})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return *session
+			}
+		}
+		t.Fatalf("no session cookie set, status %d", rec.Code)
+		return SessionData{}
+	}
+
+	t.Run("random", func(t *testing.T) {
+		session := next(t, config.SlideshowRandom)
+		if session.CurrentID != unviewed.ID {
+			t.Errorf("expected the only other unviewed thumbnail %d, got %d", unviewed.ID, session.CurrentID)
+		}
+		if session.NextID != 0 {
+			t.Errorf("expected no further thumbnail in random order, got %d", session.NextID)
+		}
+	})
+
+	t.Run("lru", func(t *testing.T) {
+		session := next(t, config.SlideshowLRU)
+		if session.CurrentID != unviewed.ID {
+			t.Errorf("expected the never-viewed thumbnail %d first, got %d", unviewed.ID, session.CurrentID)
+		}
+		if session.NextID != viewed.ID {
+			t.Errorf("expected the previously viewed thumbnail %d next, got %d", viewed.ID, session.NextID)
+		}
+	})
+
+	t.Run("new session totals", func(t *testing.T) {
+		s.cfg.SlideshowOrder = config.SlideshowRandom
+		random, err := s.createNewSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.cfg.SlideshowOrder = config.SlideshowLRU
+		lru, err := s.createNewSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if random.TotalImages != 2 || lru.TotalImages != 3 {
+			t.Errorf("expected totals random=2 lru=3, got random=%d lru=%d", random.TotalImages, lru.TotalImages)
+		}
+		if lru.Order != config.SlideshowLRU {
+			t.Errorf("expected the session to record its order, got %q", lru.Order)
+		}
+	})
+}
+
+func TestIncludeViewedSession(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	s.scanner = scanner.New(s.cfg, db, nil, s.log, nil)
+
+	for _, name := range []string{"a.mp4", "b.mp4", "c.mp4"} {
+		if err := db.UpsertThumbnail(&models.Thumbnail{
+			MoviePath:     name,
+			MovieFilename: name,
+			ThumbnailPath: name + ".jpg",
+			Status:        models.StatusSuccess,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	viewed, _ := db.GetByMoviePath("a.mp4")
+	current, _ := db.GetByMoviePath("b.mp4")
+	if err := db.MarkAsViewedByID(viewed.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		includeViewed bool
+		wantTotal     int
+		wantLast      bool
+	}{
+		{includeViewed: false, wantTotal: 2, wantLast: true},
+		{includeViewed: true, wantTotal: 3, wantLast: false},
+	} {
+		s.cfg.IncludeViewed = tc.includeViewed
+		session, err := s.createNewSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Started after the earlier view, which doesn't count as seen in the session
+		session.StartedAt = time.Now().Add(time.Minute).Unix()
+		if session.TotalImages != tc.wantTotal || session.IncludeViewed != tc.includeViewed {
+			t.Errorf("include_viewed=%v: expected total %d, got %+v", tc.includeViewed, tc.wantTotal, session)
+		}
+
+		// Only the viewed thumbnail remains besides the current and the unviewed previous one
+		unviewed, _ := db.GetByMoviePath("c.mp4")
+		session.CurrentID = current.ID
+		session.PreviousID = unviewed.ID
+		if last, _ := s.isLastThumbnail(current.ID, session); last != tc.wantLast {
+			t.Errorf("include_viewed=%v: expected last=%v, got %v", tc.includeViewed, tc.wantLast, last)
+		}
+	}
+}
+
+func TestReviewSessionEnds(t *testing.T) {
+	for _, order := range []string{config.SlideshowRandom, config.SlideshowRecorded} {
+		t.Run(order, func(t *testing.T) {
+			s, db := newSessionTestServer(t)
+			for i, name := range []string{"a.mp4", "b.mp4", "c.mp4"} {
+				if err := db.UpsertThumbnail(&models.Thumbnail{
+					MoviePath:     name,
+					MovieFilename: name,
+					ThumbnailPath: name + ".jpg",
+					Status:        models.StatusSuccess,
+					Viewed:        i % 2, // Viewed before the session
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Viewing each thumbnail takes it out of the session's pool
+			session := &SessionData{
+				StartedAt:     time.Now().Add(-time.Minute).Unix(),
+				Order:         order,
+				IncludeViewed: true,
+			}
+			shown := map[int64]bool{}
+			for i := 0; i < 10; i++ {
+				next, err := s.nextCandidate(session)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if next == nil {
+					break
+				}
+				if shown[next.ID] {
+					t.Fatalf("thumbnail %d shown twice", next.ID)
+				}
+				shown[next.ID] = true
+				if err := db.MarkAsViewedByID(next.ID); err != nil {
+					t.Fatal(err)
+				}
+				if viewed, _ := db.GetByID(next.ID); !session.seen(viewed) {
+					t.Errorf("thumbnail %d viewed in the session is not seen", next.ID)
+				}
+			}
+			if len(shown) != 3 {
+				t.Errorf("session showed %d thumbnails, want all 3 once and then end", len(shown))
+			}
+		})
+	}
+}
+
+func TestLibraryPosition(t *testing.T) {
+	s, db := newSessionTestServer(t)
+
+	names := []string{"a.mp4", "b.mp4", "c.mp4", "d.mp4"}
+	for _, name := range names {
+		if err := db.UpsertThumbnail(&models.Thumbnail{
+			MoviePath:     name,
+			MovieFilename: name,
+			ThumbnailPath: name + ".jpg",
+			Status:        models.StatusSuccess,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	viewed, _ := db.GetByMoviePath("b.mp4")
+	if err := db.MarkAsViewedByID(viewed.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/slideshow", nil)
+	want := map[string][2]int{"a.mp4": {1, 3}, "b.mp4": {0, 0}, "c.mp4": {2, 3}, "d.mp4": {3, 3}}
+	for _, name := range names {
+		thumbnail, _ := db.GetByMoviePath(name)
+		position, total := s.libraryPosition(req, thumbnail)
+		if got := [2]int{position, total}; got != want[name] {
+			t.Errorf("%s: expected position %v, got %v", name, want[name], got)
+		}
+	}
+}
+
+func TestSessionIdleExpiry(t *testing.T) {
+	s, _ := newSessionTestServer(t)
+	s.cfg.SessionIdleExpiry = time.Hour
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		session SessionData
+		expired bool
+	}{
+		{"recently active", SessionData{StartedAt: now.Add(-48 * time.Hour).Unix(), LastActivity: now.Add(-time.Minute).Unix()}, false},
+		{"idle", SessionData{StartedAt: now.Add(-48 * time.Hour).Unix(), LastActivity: now.Add(-2 * time.Hour).Unix()}, true},
+		{"old session without activity", SessionData{StartedAt: now.Add(-2 * time.Hour).Unix()}, true},
+		{"new session without activity", SessionData{StartedAt: now.Add(-time.Minute).Unix()}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/slideshow/next", nil)
+			req.AddCookie(sessionCookie(t, tt.session))
+
+			session, err := s.getSessionFromCookie(req)
+			if tt.expired != errors.Is(err, errSessionExpired) {
+				t.Fatalf("getSessionFromCookie() error = %v, want expired=%v", err, tt.expired)
+			}
+			if session == nil || session.StartedAt != tt.session.StartedAt {
+				t.Fatalf("session not returned: %+v", session)
+			}
+
+			// Expired sessions send navigation back to /slideshow for a fresh start
+			rec := httptest.NewRecorder()
+			_, ok := s.requireValidSession(rec, req)
+			if ok == tt.expired {
+				t.Errorf("requireValidSession() ok = %v, want %v", ok, !tt.expired)
+			}
+		})
+	}
+
+	// Disabled expiry keeps idle sessions
+	s.cfg.SessionIdleExpiry = 0
+	req := httptest.NewRequest("GET", "/slideshow/next", nil)
+	req.AddCookie(sessionCookie(t, tests[1].session))
+	if _, err := s.getSessionFromCookie(req); err != nil {
+		t.Errorf("expected idle session to be kept without SESSION_IDLE_EXPIRY, got %v", err)
+	}
+}
+
+func TestSaveSessionTracksActivity(t *testing.T) {
+	s, _ := newSessionTestServer(t)
+
+	session := &SessionData{StartedAt: time.Now().Add(-time.Hour).Unix()}
+	rec := httptest.NewRecorder()
+	if err := s.saveSessionToCookie(rec, session); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	saved, err := s.getSessionFromCookie(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(time.Unix(saved.LastActivity, 0)) > time.Minute {
+		t.Errorf("LastActivity = %d, want about now", saved.LastActivity)
+	}
+}
+
+func TestSlideshowArtifact(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	s.cfg.TemplatesDir = filepath.Join("..", "..", "web", "templates")
+	s.scanner = scanner.New(s.cfg, db, nil, s.log, nil)
+
+	for _, name := range []string{"a.mp4", "b.mp4"} {
+		if err := db.UpsertThumbnail(&models.Thumbnail{
+			MoviePath:     name,
+			MovieFilename: name,
+			ThumbnailPath: name + ".jpg",
+			Status:        models.StatusSuccess,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleSlideshow(rec, httptest.NewRequest("GET", "/slideshow?new=true&artifact=poster", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("slideshow = %d, want 200", rec.Code)
+	}
+	var session *SessionData
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "slideshow_session" {
+			session, _ = s.getSessionFromCookie(&http.Request{Header: http.Header{"Cookie": {c.String()}}})
+		}
+	}
+	if session == nil || session.Artifact != models.ArtifactPoster {
+		t.Fatalf("expected the session to keep the poster artifact, got %+v", session)
+	}
+
+	// No poster was generated, so the page and the prefetch fall back to the grid
+	current, _ := db.GetByID(session.CurrentID)
+	if !strings.Contains(rec.Body.String(), `src="/thumbnails/`+current.ThumbnailPath+`"`) {
+		t.Errorf("expected the page to show the grid %s", current.ThumbnailPath)
+	}
+	req := httptest.NewRequest("GET", "/api/slideshow/next-image", nil)
+	req.AddCookie(sessionCookie(t, *session))
+	rec = httptest.NewRecorder()
+	s.handleSlideshowNextImage(rec, req)
+	var next NextImageResponse
+	if err := json.NewDecoder(rec.Body).Decode(&next); err != nil {
+		t.Fatal(err)
+	}
+	if nextThumbnail, _ := db.GetByID(session.NextID); !next.HasNext || next.ThumbnailPath != nextThumbnail.ThumbnailPath {
+		t.Errorf("expected the next grid %s, got %+v", nextThumbnail.ThumbnailPath, next)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleSlideshow(rec, httptest.NewRequest("GET", "/slideshow?artifact=mosaic", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid artifact = %d, want 400", rec.Code)
+	}
+}
+
+func TestPrefetchArtifacts(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	thumbDir := t.TempDir()
+	s.storage = storage.NewLocal(thumbDir)
+	if err := os.WriteFile(filepath.Join(thumbDir, "movie.jpg"), []byte("grid"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(thumbDir, "movie.webp"), []byte("animated preview"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/slideshow/next-image", nil)
+	withPreview := &models.Thumbnail{ThumbnailPath: "movie.jpg", PreviewPath: "movie.webp"}
+	artifacts := s.prefetchArtifacts(req, withPreview, models.ArtifactPreview)
+	if got := artifacts[models.ArtifactPreview]; got.Path != "movie.webp" || got.Size != int64(len("animated preview")) {
+		t.Errorf("preview mode: preview = %+v, want movie.webp with its size", got)
+	}
+	if got := artifacts[models.ArtifactGrid]; got.Path != "movie.jpg" || got.Size != 4 {
+		t.Errorf("preview mode: grid = %+v, want movie.jpg with its size", got)
+	}
+	if _, ok := artifacts[models.ArtifactPoster]; ok {
+		t.Error("listed a poster that was not generated")
+	}
+
+	// The preview is left out outside preview mode, and when it wasn't generated
+	for _, mode := range []string{"", models.ArtifactGrid, models.ArtifactPoster} {
+		if _, ok := s.prefetchArtifacts(req, withPreview, mode)[models.ArtifactPreview]; ok {
+			t.Errorf("mode %q: listed the preview", mode)
+		}
+	}
+	if _, ok := s.prefetchArtifacts(req, &models.Thumbnail{ThumbnailPath: "movie.jpg"}, models.ArtifactPreview)[models.ArtifactPreview]; ok {
+		t.Error("listed a preview that was not generated")
+	}
+
+	// The endpoint lists the artifacts of the session's next thumbnail
+	next := &models.Thumbnail{MoviePath: "movie.mp4", MovieFilename: "movie.mp4", ThumbnailPath: "movie.jpg", Status: models.StatusSuccess}
+	if err := db.UpsertThumbnail(next); err != nil {
+		t.Fatal(err)
+	}
+	req.AddCookie(sessionCookie(t, SessionData{NextID: next.ID, Artifact: models.ArtifactPreview, StartedAt: time.Now().Unix()}))
+	rec := httptest.NewRecorder()
+	s.handleSlideshowNextImage(rec, req)
+	var response NextImageResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Artifact != models.ArtifactPreview || len(response.Artifacts) != 1 || response.Artifacts[models.ArtifactGrid].Path != "movie.jpg" {
+		t.Errorf("next image = %+v, want only the grid in preview mode without a preview", response)
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/metrics"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSlideshowActionMetrics(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	s.metrics = &metrics.Metrics{
+		SlideshowViewsTotal:   prometheus.NewCounter(prometheus.CounterOpts{Name: "views"}),
+		SlideshowActionsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "actions"}, []string{"action"}),
+	}
+
+	for _, name := range []string{"a.mp4", "b.mp4", "c.mp4"} {
+		if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: name, MovieFilename: name, Status: models.StatusSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, _ := db.GetByMoviePath("a.mp4")
+	b, _ := db.GetByMoviePath("b.mp4")
+	startedAt := time.Now().Unix()
+
+	requests := []struct {
+		handler http.HandlerFunc
+		path    string
+		session SessionData
+	}{
+		{s.handleSlideshowNext, "/slideshow/next", SessionData{CurrentID: a.ID, StartedAt: startedAt}},
+		{s.handleSlideshowNext, "/slideshow/next?skip=true", SessionData{CurrentID: a.ID, StartedAt: startedAt}},
+		{s.handleSlideshowNext, "/slideshow/next?skip=true", SessionData{CurrentID: a.ID, StartedAt: startedAt}},
+		{s.handleMarkViewed, "/mark-viewed", SessionData{CurrentID: b.ID, StartedAt: startedAt}},
+		{s.handleDelete, "/delete", SessionData{CurrentID: b.ID, StartedAt: startedAt}},
+		{s.handleSlideshowPrevious, "/slideshow/previous", SessionData{CurrentID: a.ID, PreviousID: b.ID, PendingDelete: true, StartedAt: startedAt}},
+	}
+	for _, req := range requests {
+		r := httptest.NewRequest("POST", req.path, nil)
+		r.AddCookie(sessionCookie(t, req.session))
+		req.handler(httptest.NewRecorder(), r)
+	}
+
+	want := map[string]float64{"view": 2, "skip": 2, "delete": 1, "undo": 1}
+	for action, value := range want {
+		if got := testutil.ToFloat64(s.metrics.SlideshowActionsTotal.WithLabelValues(action)); got != value {
+			t.Errorf("slideshow_actions_total{action=%q} = %v, want %v", action, got, value)
+		}
+	}
+}
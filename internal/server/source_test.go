@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+)
+
+func TestThumbnailSourceFilter(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	s.router = mux.NewRouter()
+	s.routes()
+
+	for _, thumbnail := range []*models.Thumbnail{
+		{MoviePath: "gen1.mp4", Source: models.SourceGenerated, Status: models.StatusSuccess},
+		{MoviePath: "gen2.mp4", Source: models.SourceGenerated, Status: models.StatusError},
+		{MoviePath: "imp1.mp4", Source: models.SourceImported, Status: models.StatusSuccess},
+		{MoviePath: "imp2.mp4", Source: models.SourceImported, Status: models.StatusSuccess, Viewed: 1},
+		{MoviePath: "imp3.mp4", Source: models.SourceImported, Status: models.StatusDeleted},
+	} {
+		thumbnail.MovieFilename = thumbnail.MoviePath
+		if err := db.UpsertThumbnail(thumbnail); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err := db.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The API lists as many thumbnails per source as the stats count
+	for source, want := range map[string]int{models.SourceGenerated: stats.Generated, models.SourceImported: stats.Imported} {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/thumbnails?source="+source, nil))
+		var thumbnails []models.Thumbnail
+		if err := json.NewDecoder(rec.Body).Decode(&thumbnails); err != nil {
+			t.Fatal(err)
+		}
+		if total, _ := strconv.Atoi(rec.Header().Get("X-Total-Count")); total != want || len(thumbnails) != want {
+			t.Errorf("source=%s: %d thumbnails, total %d, want %d as in the stats", source, len(thumbnails), total, want)
+		}
+		for _, thumbnail := range thumbnails {
+			if thumbnail.Source != source {
+				t.Errorf("source=%s listed %s from %s", source, thumbnail.MoviePath, thumbnail.Source)
+			}
+		}
+	}
+	if stats.Generated+stats.Imported != stats.Total {
+		t.Errorf("generated %d + imported %d != total %d", stats.Generated, stats.Imported, stats.Total)
+	}
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/thumbnails?source=scanned", nil))
+	if rec.Code != 400 {
+		t.Errorf("unknown source: status %d, want 400", rec.Code)
+	}
+
+	// A source-restricted session only draws unviewed thumbnails of that source
+	session := &SessionData{}
+	s.restrictSessionSource(session, models.SourceImported)
+	if session.TotalImages != 1 {
+		t.Errorf("session total = %d, want 1", session.TotalImages)
+	}
+	for i := 0; i < 10; i++ {
+		thumbnail, err := s.nextCandidate(session)
+		if err != nil || thumbnail == nil || thumbnail.MoviePath != "imp1.mp4" {
+			t.Fatalf("nextCandidate = %v, %v; want imp1.mp4", thumbnail, err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/config"
+	"github.com/pandino/movie-thumbnailer-go/internal/database"
+	"github.com/pandino/movie-thumbnailer-go/internal/scanner"
+	"github.com/sirupsen/logrus"
+)
+
+func TestSafeGetStatsFallsBack(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	cfg := &config.Config{}
+	s := &Server{cfg: cfg, log: log, scanner: scanner.New(cfg, db, nil, log, nil)}
+	req := httptest.NewRequest("GET", "/", nil)
+
+	stats, ok := s.safeGetStats(req)
+	if !ok || stats == nil {
+		t.Fatalf("expected stats read to succeed, got ok=%v", ok)
+	}
+	stats.Total = 7 // Pretend the last good read saw some rows
+	s.lastStats = stats
+
+	// Break the database so the next read fails
+	db.Close()
+
+	stats, ok = s.safeGetStats(req)
+	if ok {
+		t.Fatal("expected stats read to fail after closing the database")
+	}
+	if stats == nil || stats.Total != 7 {
+		t.Errorf("expected last known stats, got %+v", stats)
+	}
+
+	s.lastStats = nil
+	stats, ok = s.safeGetStats(req)
+	if ok || stats == nil || stats.Total != 0 {
+		t.Errorf("expected zeroed stats without a previous read, got %+v ok=%v", stats, ok)
+	}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/pandino/movie-thumbnailer-go/internal/database"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// ThumbnailPatch is the JSON body of PATCH /api/thumbnails/{id}. Fields left out are
+// not changed.
+type ThumbnailPatch struct {
+	Viewed *bool `json:"viewed,omitempty"`
+}
+
+// thumbnailFromID resolves the {id} route variable to a thumbnail record. It writes
+// the error response and returns nil when the thumbnail cannot be resolved.
+func (s *Server) thumbnailFromID(w http.ResponseWriter, r *http.Request) *models.Thumbnail {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, "Invalid thumbnail ID")
+		return nil
+	}
+
+	thumbnail, err := s.db.GetByID(id)
+	if err != nil {
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", id).Error("Failed to get thumbnail")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		return nil
+	}
+	if thumbnail == nil {
+		s.writeError(w, r, http.StatusNotFound, "Thumbnail not found")
+		return nil
+	}
+	return thumbnail
+}
+
+// writeUpdatedThumbnail re-reads a changed thumbnail and writes it as JSON
+func (s *Server) writeUpdatedThumbnail(w http.ResponseWriter, r *http.Request, id int64) {
+	thumbnail, err := s.db.GetByID(id)
+	if err != nil || thumbnail == nil {
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", id).Error("Failed to read updated thumbnail")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	thumbnail.DisplayPath = s.displayPath(thumbnail)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(thumbnail)
+}
+
+// handleThumbnailImage serves the grid JPEG of a thumbnail by ID, resolving its storage
+// key from the database so clients don't depend on the file naming scheme. It answers
+// conditional requests against an ETag and the file's modification time.
+func (s *Server) handleThumbnailImage(w http.ResponseWriter, r *http.Request) {
+	thumbnail := s.thumbnailFromID(w, r)
+	if thumbnail == nil {
+		return
+	}
+	if thumbnail.Status != models.StatusSuccess || thumbnail.ThumbnailPath == "" {
+		s.writeError(w, r, http.StatusNotFound, "Thumbnail has no generated image")
+		return
+	}
+
+	release, ok := s.acquireServeSlot(r)
+	if !ok {
+		w.Header().Set("Retry-After", "1")
+		s.writeError(w, r, http.StatusServiceUnavailable, "Too many thumbnail requests")
+		return
+	}
+	defer release()
+
+	// Answer revalidations from the object's metadata without downloading it
+	info, err := s.storage.Stat(r.Context(), thumbnail.ThumbnailPath)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			s.writeError(w, r, http.StatusNotFound, "Thumbnail file not found")
+			return
+		}
+		s.logFrom(r).WithError(err).WithField("thumbnail", thumbnail.ThumbnailPath).Error("Failed to read thumbnail")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	// The URL stays the same when the grid is regenerated, so caches revalidate
+	w.Header().Set("Cache-Control", "no-cache")
+	if etag := imageETag(info); etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body, info, err := s.storage.Get(r.Context(), thumbnail.ThumbnailPath)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			s.writeError(w, r, http.StatusNotFound, "Thumbnail file not found")
+			return
+		}
+		s.logFrom(r).WithError(err).WithField("thumbnail", thumbnail.ThumbnailPath).Error("Failed to read thumbnail")
+		s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("ETag", imageETag(info))
+	serveObject(w, r, "", info, body)
+}
+
+// handleAPIThumbnailDelete marks a thumbnail's movie for deletion and returns the
+// updated thumbnail
+func (s *Server) handleAPIThumbnailDelete(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.DisableDeletion {
+		s.writeError(w, r, http.StatusForbidden, "Deletion is disabled via DISABLE_DELETION flag")
+		return
+	}
+
+	thumbnail := s.thumbnailFromID(w, r)
+	if thumbnail == nil {
+		return
+	}
+	if thumbnail.Status == models.StatusDeleted {
+		s.writeError(w, r, http.StatusConflict, "Thumbnail is already marked for deletion")
+		return
+	}
+
+	if err := s.db.MarkForDeletionByID(thumbnail.ID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			s.writeError(w, r, http.StatusNotFound, "Thumbnail not found")
+			return
+		}
+		s.logFrom(r).WithError(err).WithField("thumbnail_id", thumbnail.ID).Error("Failed to mark thumbnail for deletion")
+		s.writeError(w, r, http.StatusInternalServerError, "Failed to mark thumbnail for deletion")
+		return
+	}
+
+	s.logFrom(r).WithFields(logrus.Fields{
+		"movie":        thumbnail.MoviePath,
+		"thumbnail_id": thumbnail.ID,
+	}).Info("Movie marked for deletion via API")
+
+	s.writeUpdatedThumbnail(w, r, thumbnail.ID)
+}
+
+// handleAPIThumbnailPatch updates the fields given in a ThumbnailPatch and returns the
+// updated thumbnail
+func (s *Server) handleAPIThumbnailPatch(w http.ResponseWriter, r *http.Request) {
+	thumbnail := s.thumbnailFromID(w, r)
+	if thumbnail == nil {
+		return
+	}
+
+	var patch ThumbnailPatch
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, "Invalid JSON request body: only viewed can be changed")
+		return
+	}
+
+	if patch.Viewed != nil && *patch.Viewed != thumbnail.IsViewed() {
+		var err error
+		if *patch.Viewed {
+			err = s.db.MarkAsViewedByID(thumbnail.ID)
+		} else {
+			err = s.db.MarkAsUnviewedByID(thumbnail.ID)
+		}
+		if errors.Is(err, database.ErrNotFound) {
+			s.writeError(w, r, http.StatusNotFound, "Thumbnail not found")
+			return
+		}
+		if err != nil {
+			s.logFrom(r).WithError(err).WithField("thumbnail_id", thumbnail.ID).Error("Failed to update viewed status")
+			s.writeError(w, r, http.StatusInternalServerError, "Failed to update thumbnail")
+			return
+		}
+		s.logFrom(r).WithFields(logrus.Fields{
+			"movie":        thumbnail.MoviePath,
+			"thumbnail_id": thumbnail.ID,
+			"viewed":       *patch.Viewed,
+		}).Info("Viewed status changed via API")
+	}
+
+	s.writeUpdatedThumbnail(w, r, thumbnail.ID)
+}
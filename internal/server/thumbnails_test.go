@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
+)
+
+func TestAPIThumbnailPatchAndDelete(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	s.router = mux.NewRouter()
+	s.routes()
+
+	if err := db.UpsertThumbnail(&models.Thumbnail{MoviePath: "movie.mp4", MovieFilename: "movie.mp4", Status: models.StatusSuccess}); err != nil {
+		t.Fatal(err)
+	}
+	thumbnail, err := db.GetByMoviePath("movie.mp4")
+	if err != nil || thumbnail == nil {
+		t.Fatalf("GetByMoviePath = %v, %v", thumbnail, err)
+	}
+	path := fmt.Sprintf("/api/thumbnails/%d", thumbnail.ID)
+
+	serve := func(method, path, body string) (*httptest.ResponseRecorder, models.Thumbnail) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		var got models.Thumbnail
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("%s %s: decoding response: %v", method, path, err)
+			}
+		}
+		return rec, got
+	}
+
+	rec, got := serve("PATCH", path, `{"viewed": true}`)
+	if rec.Code != http.StatusOK || !got.IsViewed() {
+		t.Fatalf("PATCH viewed=true = %d (viewed %v), want 200 and viewed", rec.Code, got.Viewed)
+	}
+	rec, got = serve("PATCH", path, `{"viewed": false}`)
+	if rec.Code != http.StatusOK || got.IsViewed() {
+		t.Fatalf("PATCH viewed=false = %d (viewed %v), want 200 and unviewed", rec.Code, got.Viewed)
+	}
+	if rec, _ := serve("PATCH", path, `{"rating": 5}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("PATCH unsupported field = %d, want 400", rec.Code)
+	}
+	if rec, _ := serve("PATCH", "/api/thumbnails/999", `{"viewed": true}`); rec.Code != http.StatusNotFound {
+		t.Errorf("PATCH missing thumbnail = %d, want 404", rec.Code)
+	}
+
+	// Deletion is refused while DISABLE_DELETION is set
+	s.cfg.DisableDeletion = true
+	if rec, _ := serve("DELETE", path, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("DELETE with deletion disabled = %d, want 403", rec.Code)
+	}
+	if current, _ := db.GetByID(thumbnail.ID); current.Status != models.StatusSuccess {
+		t.Errorf("status after refused DELETE = %q, want %q", current.Status, models.StatusSuccess)
+	}
+	s.cfg.DisableDeletion = false
+
+	rec, got = serve("DELETE", path, "")
+	if rec.Code != http.StatusOK || got.Status != models.StatusDeleted {
+		t.Fatalf("DELETE = %d (status %q), want 200 and %q", rec.Code, got.Status, models.StatusDeleted)
+	}
+	if rec, _ := serve("DELETE", path, ""); rec.Code != http.StatusConflict {
+		t.Errorf("second DELETE = %d, want 409", rec.Code)
+	}
+	if rec, _ := serve("DELETE", "/api/thumbnails/999", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE missing thumbnail = %d, want 404", rec.Code)
+	}
+}
+
+// getCountingStorage counts object downloads
+type getCountingStorage struct {
+	storage.Storage
+	gets int
+}
+
+func (g *getCountingStorage) Get(ctx context.Context, key string) (io.ReadCloser, storage.ObjectInfo, error) {
+	g.gets++
+	return g.Storage.Get(ctx, key)
+}
+
+func TestThumbnailImage(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	dir := t.TempDir()
+	store := &getCountingStorage{Storage: storage.NewLocal(dir)}
+	s.storage = store
+	s.router = mux.NewRouter()
+	s.routes()
+
+	grid := []byte("\xff\xd8grid\xff\xd9")
+	if err := os.MkdirAll(filepath.Join(dir, "ab"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ab", "movie.jpg"), grid, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]int64)
+	for name, th := range map[string]models.Thumbnail{
+		"movie.mp4":   {ThumbnailPath: "ab/movie.jpg", Status: models.StatusSuccess},
+		"missing.mp4": {ThumbnailPath: "missing.jpg", Status: models.StatusSuccess},
+		"pending.mp4": {ThumbnailPath: "ab/movie.jpg", Status: models.StatusPending},
+	} {
+		th.MoviePath, th.MovieFilename = name, name
+		if err := db.UpsertThumbnail(&th); err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = th.ID
+	}
+
+	serve := func(name string, header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/thumbnails/%d/image", ids[name]), nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("movie.mp4", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != string(grid) {
+		t.Fatalf("GET image = %d %q, want 200 and the grid", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/jpeg" {
+		t.Errorf("Content-Type = %q, want image/jpeg", ct)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("response has no ETag")
+	}
+
+	gets := store.gets
+	rec = serve("movie.mp4", http.Header{"If-None-Match": {etag}})
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("GET with matching If-None-Match = %d with %d bytes, want an empty 304", rec.Code, rec.Body.Len())
+	}
+	if store.gets != gets {
+		t.Errorf("GET with matching If-None-Match downloaded the image %d times, want none", store.gets-gets)
+	}
+	if rec := serve("movie.mp4", http.Header{"If-None-Match": {`"stale"`}}); rec.Code != http.StatusOK {
+		t.Errorf("GET with stale If-None-Match = %d, want 200", rec.Code)
+	}
+
+	for _, name := range []string{"missing.mp4", "pending.mp4"} {
+		if rec := serve(name, nil); rec.Code != http.StatusNotFound {
+			t.Errorf("GET image of %s = %d, want 404", name, rec.Code)
+		}
+	}
+	ids["unknown.mp4"] = 999
+	if rec := serve("unknown.mp4", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET image of an unknown ID = %d, want 404", rec.Code)
+	}
+}
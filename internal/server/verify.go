@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/database"
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
+)
+
+// Problems reported by GET /api/verify
+const (
+	verifyMissing = "missing"
+	verifyEmpty   = "empty"
+	verifyError   = "error"
+)
+
+// verifyPageSize is how many rows GET /api/verify reads from the database at a time
+const verifyPageSize = 500
+
+// VerifyIssue is one line of the GET /api/verify report: a successful thumbnail whose
+// stored file is missing, empty or could not be checked
+type VerifyIssue struct {
+	ID            int64  `json:"id"`
+	MoviePath     string `json:"movie_path"`
+	ThumbnailPath string `json:"thumbnail_path"`
+	Problem       string `json:"problem"`
+	Error         string `json:"error,omitempty"`
+}
+
+// handleVerify streams, as NDJSON, the successful thumbnails whose stored file is
+// missing or empty. Nothing is changed.
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	// Checking a large library may take longer than the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(30 * time.Minute)); err != nil {
+		s.logFrom(r).WithError(err).Debug("Failed to extend write deadline")
+	}
+
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	filter := database.ThumbnailFilter{Status: models.StatusSuccess}
+
+	checked, issues := 0, 0
+	for offset := 0; ; offset += verifyPageSize {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		thumbnails, _, err := s.db.GetThumbnailsFiltered(filter, verifyPageSize, offset)
+		if err != nil {
+			s.logFrom(r).WithError(err).Error("Failed to list thumbnails for verification")
+			if checked == 0 {
+				s.writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			}
+			return
+		}
+
+		for _, thumbnail := range thumbnails {
+			checked++
+			issue := s.verifyThumbnail(r, thumbnail)
+			if issue == nil {
+				continue
+			}
+			issues++
+			if err := encoder.Encode(issue); err != nil {
+				return
+			}
+		}
+		rc.Flush()
+
+		if len(thumbnails) < verifyPageSize {
+			break
+		}
+	}
+
+	s.logFrom(r).WithField("checked", checked).WithField("issues", issues).Info("Thumbnail verification completed")
+}
+
+// verifyThumbnail checks the stored file of a thumbnail, returning nil when it is fine
+func (s *Server) verifyThumbnail(r *http.Request, thumbnail *models.Thumbnail) *VerifyIssue {
+	issue := &VerifyIssue{
+		ID:            thumbnail.ID,
+		MoviePath:     thumbnail.MoviePath,
+		ThumbnailPath: thumbnail.ThumbnailPath,
+	}
+	if thumbnail.ThumbnailPath == "" {
+		issue.Problem = verifyMissing
+		return issue
+	}
+
+	info, err := s.storage.Stat(r.Context(), thumbnail.ThumbnailPath)
+	switch {
+	case errors.Is(err, storage.ErrNotExist):
+		issue.Problem = verifyMissing
+	case err != nil:
+		issue.Problem, issue.Error = verifyError, err.Error()
+	case info.Size == 0:
+		issue.Problem = verifyEmpty
+	default:
+		return nil
+	}
+	return issue
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/models"
+	"github.com/pandino/movie-thumbnailer-go/internal/storage"
+)
+
+func TestHandleVerify(t *testing.T) {
+	s, db := newSessionTestServer(t)
+	dir := t.TempDir()
+	s.storage = storage.NewLocal(dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "present.jpg"), []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "empty.jpg"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, thumbnail := range []*models.Thumbnail{
+		{MoviePath: "present.mp4", MovieFilename: "present.mp4", ThumbnailPath: "present.jpg", Status: models.StatusSuccess},
+		{MoviePath: "empty.mp4", MovieFilename: "empty.mp4", ThumbnailPath: "empty.jpg", Status: models.StatusSuccess},
+		{MoviePath: "missing.mp4", MovieFilename: "missing.mp4", ThumbnailPath: "missing.jpg", Status: models.StatusSuccess},
+		// Only successful thumbnails are expected to have a file
+		{MoviePath: "failed.mp4", MovieFilename: "failed.mp4", ThumbnailPath: "failed.jpg", Status: models.StatusError},
+	} {
+		if err := db.UpsertThumbnail(thumbnail); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleVerify(rec, httptest.NewRequest("GET", "/api/verify", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", ct)
+	}
+
+	got := map[string]string{}
+	decoder := json.NewDecoder(rec.Body)
+	for decoder.More() {
+		var issue VerifyIssue
+		if err := decoder.Decode(&issue); err != nil {
+			t.Fatalf("decoding report line: %v", err)
+		}
+		got[issue.MoviePath] = issue.Problem
+	}
+	want := map[string]string{"empty.mp4": verifyEmpty, "missing.mp4": verifyMissing}
+	if len(got) != len(want) || got["empty.mp4"] != want["empty.mp4"] || got["missing.mp4"] != want["missing.mp4"] {
+		t.Errorf("report = %v, want %v", got, want)
+	}
+
+	// The check is read-only
+	if thumbnail, _ := db.GetByMoviePath("missing.mp4"); thumbnail == nil || thumbnail.Status != models.StatusSuccess {
+		t.Errorf("missing thumbnail after verify = %+v, want unchanged", thumbnail)
+	}
+}
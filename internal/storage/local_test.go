@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLocalRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewLocal(dir)
+
+	if err := store.Put(ctx, "series/s01/e01.jpg", strings.NewReader("grid")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := store.Put(ctx, "movie.jpg", strings.NewReader("other")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "series", "s01", "e01.jpg")); err != nil {
+		t.Errorf("expected nested file on disk: %v", err)
+	}
+
+	exists, err := store.Exists(ctx, "series/s01/e01.jpg")
+	if err != nil || !exists {
+		t.Errorf("Exists = %v, %v; want true", exists, err)
+	}
+
+	r, info, err := store.Get(ctx, "series/s01/e01.jpg")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "grid" || info.Size != 4 {
+		t.Errorf("Get returned %q (%d bytes)", data, info.Size)
+	}
+
+	if info, err := store.Stat(ctx, "series/s01/e01.jpg"); err != nil || info.Size != 4 {
+		t.Errorf("Stat = %+v, %v; want 4 bytes", info, err)
+	}
+	if _, err := store.Stat(ctx, "series"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat of a directory: expected ErrNotExist, got %v", err)
+	}
+
+	objects, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	var keys []string
+	for _, o := range objects {
+		keys = append(keys, o.Key)
+	}
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != "movie.jpg,series/s01/e01.jpg" {
+		t.Errorf("List returned %v", keys)
+	}
+
+	if err := store.Delete(ctx, "movie.jpg"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Delete(ctx, "movie.jpg"); err != nil {
+		t.Errorf("deleting a missing object should succeed, got %v", err)
+	}
+	if _, _, err := store.Get(ctx, "movie.jpg"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+}
+
+func TestLocalRejectsTraversal(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	dir := filepath.Join(root, "thumbnails")
+	store := NewLocal(dir)
+
+	if err := store.Put(ctx, "../escape.jpg", strings.NewReader("x")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "escape.jpg")); err == nil {
+		t.Error("key escaped the storage root")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.jpg")); err != nil {
+		t.Errorf("expected key to be confined to the root: %v", err)
+	}
+}
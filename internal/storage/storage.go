@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pandino/movie-thumbnailer-go/internal/config"
+)
+
+// ErrNotExist is returned when an object does not exist
+var ErrNotExist = fs.ErrNotExist
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage stores thumbnail files under slash-separated keys relative to the
+// thumbnail root, such as "movie.jpg" or "series/season1/episode.jpg"
+type Storage interface {
+	// Put stores the contents of r under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the object stored under key. Missing objects return ErrNotExist.
+	Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+	// Delete removes the object stored under key. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// Exists reports whether an object is stored under key
+	Exists(ctx context.Context, key string) (bool, error)
+	// Stat returns the info of the object stored under key without reading it.
+	// Missing objects return ErrNotExist.
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// List returns all stored objects
+	List(ctx context.Context) ([]ObjectInfo, error)
+}
+
+// New creates the storage backend selected by STORAGE_BACKEND. Remote backends
+// send their requests with client.
+func New(cfg *config.Config, client *http.Client) (Storage, error) {
+	switch cfg.StorageBackend {
+	case "", config.StorageLocal:
+		return NewLocal(cfg.ThumbnailsDir), nil
+	case config.StorageS3:
+		return NewS3(S3Options{
+			Bucket:          cfg.S3Bucket,
+			Region:          cfg.S3Region,
+			Endpoint:        cfg.S3Endpoint,
+			Prefix:          cfg.S3Prefix,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PathStyle:       cfg.S3PathStyle,
+			Client:          client,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.StorageBackend)
+	}
+}
+
+// cleanKey normalizes a key and rejects keys that escape the storage root
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + strings.ReplaceAll(key, "\\", "/"))
+	cleaned = strings.TrimPrefix(cleaned, "/")
+	if cleaned == "" || cleaned == "." {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+	return cleaned, nil
+}
@@ -3,6 +3,8 @@ package worker
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
+	"sync"
 	"time"
 
 	"github.com/pandino/movie-thumbnailer-go/internal/config"
@@ -17,6 +19,11 @@ type Worker struct {
 	scanner *scanner.Scanner
 	log     *logrus.Logger
 	metrics *metrics.Metrics
+
+	// Scans running in their own goroutines
+	scans sync.WaitGroup
+	// Closed when Start has returned and its scans have finished
+	done chan struct{}
 }
 
 // New creates a new Worker
@@ -26,86 +33,88 @@ func New(cfg *config.Config, scanner *scanner.Scanner, log *logrus.Logger, metri
 		scanner: scanner,
 		log:     log,
 		metrics: metrics,
+		done:    make(chan struct{}),
 	}
 }
 
 // Start begins the background task processing
 func (w *Worker) Start(ctx context.Context) {
 	w.log.Info("Starting background worker")
+	defer close(w.done)
+	defer w.scans.Wait()
 
-	// Perform an initial scan at startup
+	// Perform an initial scan at startup, after up to SCAN_JITTER so instances started
+	// together don't all scan at once
+	w.scans.Add(1)
 	go func() {
+		defer w.scans.Done()
+		if delay := startupDelay(w.cfg.ScanJitter); delay > 0 {
+			w.log.WithField("delay", delay).Info("Delaying initial scan")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+		}
 		w.log.Info("Running initial scan")
-		start := time.Now()
 
 		// Create a child context that can be cancelled either by the worker context or app shutdown
 		scanCtx, cancel := context.WithCancel(ctx)
 		defer cancel()
 
-		if err := w.scanner.ScanMovies(scanCtx); err != nil {
-			w.log.WithError(err).Error("Initial scan failed")
-			if w.metrics != nil {
-				w.metrics.RecordScanOperation("error", time.Since(start))
-				w.metrics.RecordBackgroundTask("initial_scan", "error")
-			}
-		} else {
-			if w.metrics != nil {
-				w.metrics.RecordScanOperation("success", time.Since(start))
-				w.metrics.RecordBackgroundTask("initial_scan", "success")
-			}
-		}
+		w.runScan(scanCtx, scanner.ScanTypeInitial, "Initial scan")
 	}()
 
-	// Set up ticker for periodic scans
-	scanTicker := time.NewTicker(w.cfg.ScanInterval)
-	defer scanTicker.Stop()
+	// Set up timers for periodic scans and cleanups, rearmed with a new jittered
+	// interval each time they fire
+	scanTimer := time.NewTimer(jittered(w.cfg.ScanInterval, w.cfg.ScanJitter))
+	defer scanTimer.Stop()
+	cleanupTimer := time.NewTimer(jittered(config.CleanupInterval, w.cfg.CleanupJitter))
+	defer cleanupTimer.Stop()
 
-	// Set up ticker for periodic cleanups (every 6 hours)
-	cleanupInterval := 6 * time.Hour
-	cleanupTicker := time.NewTicker(cleanupInterval)
-	defer cleanupTicker.Stop()
+	// Prune stale error rows on the cleanup interval when ERROR_RETENTION is set;
+	// receiving from the nil channel otherwise never fires
+	var pruneTimer *time.Timer
+	var pruneC <-chan time.Time
+	if w.cfg.ErrorRetention > 0 {
+		pruneTimer = time.NewTimer(jittered(config.CleanupInterval, w.cfg.CleanupJitter))
+		defer pruneTimer.Stop()
+		pruneC = pruneTimer.C
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			w.log.Info("Worker shutting down")
 			return
-		case <-scanTicker.C:
-			// Skip if a scan is already in progress
-			if w.scanner.IsScanning() {
-				w.log.Info("Skipping scheduled scan because a scan is already in progress")
+		case <-scanTimer.C:
+			scanTimer.Reset(jittered(w.cfg.ScanInterval, w.cfg.ScanJitter))
+
+			// Skip if a scan, cleanup or deletion run is already in progress
+			if op := w.scanner.BusyOperation(); op != "" {
+				w.log.WithField("operation", op).Info("Skipping scheduled scan because another operation is in progress")
 				continue
 			}
 
 			w.log.Info("Running scheduled scan")
-			start := time.Now()
 
 			// Create a child context for each scan operation
 			scanCtx, cancel := context.WithCancel(ctx)
 			defer cancel()
 
-			if err := w.scanner.ScanMovies(scanCtx); err != nil {
-				w.log.WithError(err).Error("Scheduled scan failed")
-				if w.metrics != nil {
-					w.metrics.RecordScanOperation("error", time.Since(start))
-					w.metrics.RecordBackgroundTask("scheduled_scan", "error")
-				}
-			} else {
-				if w.metrics != nil {
-					w.metrics.RecordScanOperation("success", time.Since(start))
-					w.metrics.RecordBackgroundTask("scheduled_scan", "success")
-				}
-			}
-		case <-cleanupTicker.C:
+			w.runScan(scanCtx, scanner.ScanTypeScheduled, "Scheduled scan")
+		case <-cleanupTimer.C:
+			cleanupTimer.Reset(jittered(config.CleanupInterval, w.cfg.CleanupJitter))
+
 			// Skip if deletion is disabled
 			if w.cfg.DisableDeletion {
 				w.log.Debug("Skipping scheduled cleanup because deletion is disabled")
 				continue
 			}
 
-			// Skip if a scan is already in progress
-			if w.scanner.IsScanning() {
-				w.log.Info("Skipping scheduled cleanup because a scan is in progress")
+			// Skip if a scan, cleanup or deletion run is already in progress
+			if op := w.scanner.BusyOperation(); op != "" {
+				w.log.WithField("operation", op).Info("Skipping scheduled cleanup because another operation is in progress")
 				continue
 			}
 
@@ -128,42 +137,104 @@ func (w *Worker) Start(ctx context.Context) {
 				}
 				w.log.WithField("duration", duration).Info("Scheduled cleanup completed")
 			}
+		case <-pruneC:
+			pruneTimer.Reset(jittered(config.CleanupInterval, w.cfg.CleanupJitter))
+
+			// Skip if a scan, cleanup or deletion run is already in progress
+			if op := w.scanner.BusyOperation(); op != "" {
+				w.log.WithField("operation", op).Info("Skipping error pruning because another operation is in progress")
+				continue
+			}
+
+			if _, err := w.scanner.PruneStaleErrors(ctx); err != nil {
+				w.log.WithError(err).Error("Error pruning failed")
+				if w.metrics != nil {
+					w.metrics.RecordBackgroundTask("error_pruning", "error")
+				}
+			} else if w.metrics != nil {
+				w.metrics.RecordBackgroundTask("error_pruning", "success")
+			}
 		}
 	}
 }
 
+// jittered returns interval moved by a random offset of up to jitter either way
+func jittered(interval, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+	d := interval - jitter + rand.N(2*jitter+1)
+	if d <= 0 {
+		return interval
+	}
+	return d
+}
+
+// startupDelay returns a random wait of up to jitter before the initial scan
+func startupDelay(jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return 0
+	}
+	return rand.N(jitter + 1)
+}
+
+// Wait blocks until Start has returned after its context was cancelled, including
+// any scan or cleanup still finishing. It must only be called after Start.
+func (w *Worker) Wait() {
+	<-w.done
+}
+
 // PerformScan triggers a scan on demand
 func (w *Worker) PerformScan(ctx context.Context) error {
-	if w.scanner.IsScanning() {
-		w.log.Info("Scan already in progress")
+	if op := w.scanner.BusyOperation(); op != "" {
+		w.log.WithField("operation", op).Info("Scan not started, another operation is in progress")
 		return nil
 	}
 
 	w.log.Info("Triggering manual scan")
+	w.scans.Add(1)
 	go func() {
-		start := time.Now()
-
+		defer w.scans.Done()
 		// Create a child context that will be cancelled either by the provided context or app shutdown
 		scanCtx, cancel := context.WithCancel(ctx)
 		defer cancel()
 
-		if err := w.scanner.ScanMovies(scanCtx); err != nil {
-			w.log.WithError(err).Error("Manual scan failed")
-			if w.metrics != nil {
-				w.metrics.RecordScanOperation("error", time.Since(start))
-				w.metrics.RecordBackgroundTask("manual_scan", "error")
-			}
-		} else {
-			if w.metrics != nil {
-				w.metrics.RecordScanOperation("success", time.Since(start))
-				w.metrics.RecordBackgroundTask("manual_scan", "success")
-			}
-		}
+		w.runScan(scanCtx, scanner.ScanTypeManual, "Manual scan")
 	}()
 
 	return nil
 }
 
+// runScan runs a scan of the given type, logs its summary and records it as the
+// "<type>_scan" background task. The scanner records the scan metrics.
+func (w *Worker) runScan(ctx context.Context, scanType, name string) {
+	task := scanType + "_scan"
+	result, err := w.scanner.ScanMovies(ctx, scanType)
+
+	status := "success"
+	entry := w.log.WithFields(logrus.Fields{
+		"task":            task,
+		"scan_type":       scanType,
+		"processed":       result.Processed,
+		"generated":       result.Generated,
+		"imported":        result.Imported,
+		"errors":          result.Errors,
+		"skipped":         result.Skipped,
+		"missing_removed": result.MissingRemoved,
+		"duration":        result.Duration,
+	})
+	if err != nil {
+		status = "error"
+		entry.WithError(err).Error(name + " failed")
+	} else {
+		entry.Info(name + " completed")
+	}
+
+	if w.metrics != nil {
+		w.metrics.RecordBackgroundTask(task, status)
+	}
+}
+
 // PerformCleanup performs a cleanup of orphaned entries, thumbnails, and processes items marked for deletion
 func (w *Worker) PerformCleanup(ctx context.Context) error {
 	if w.cfg.DisableDeletion {
@@ -171,8 +242,8 @@ func (w *Worker) PerformCleanup(ctx context.Context) error {
 		return fmt.Errorf("cleanup is disabled via DISABLE_DELETION flag")
 	}
 
-	if w.scanner.IsScanning() {
-		return fmt.Errorf("cannot perform cleanup while scan is in progress")
+	if op := w.scanner.BusyOperation(); op != "" {
+		return fmt.Errorf("cannot perform cleanup while %s is in progress: %w", op, scanner.ErrBusy)
 	}
 
 	w.log.Info("Triggering manual cleanup")
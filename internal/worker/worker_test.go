@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJittered(t *testing.T) {
+	interval, jitter := time.Hour, 5*time.Minute
+	var early, late bool
+	for i := 0; i < 1000; i++ {
+		d := jittered(interval, jitter)
+		if d < interval-jitter || d > interval+jitter {
+			t.Fatalf("jittered(%s, %s) = %s, outside the jittered window", interval, jitter, d)
+		}
+		early = early || d < interval
+		late = late || d > interval
+	}
+	if !early || !late {
+		t.Errorf("expected intervals on both sides of %s, got early=%v late=%v", interval, early, late)
+	}
+
+	if d := jittered(interval, 0); d != interval {
+		t.Errorf("jittered without jitter = %s, want %s", d, interval)
+	}
+}
+
+func TestStartupDelay(t *testing.T) {
+	jitter := 30 * time.Second
+	for i := 0; i < 1000; i++ {
+		if d := startupDelay(jitter); d < 0 || d > jitter {
+			t.Fatalf("startupDelay(%s) = %s, want between 0 and %s", jitter, d, jitter)
+		}
+	}
+	if d := startupDelay(0); d != 0 {
+		t.Errorf("startupDelay without jitter = %s, want 0", d)
+	}
+}
@@ -247,9 +247,14 @@ function preloadNextImage() {
     })
     .then(data => {
         if (data && data.hasNext && data.thumbnailPath) {
+            // Prefetch the artifact the session shows; the server only lists the
+            // (larger) animated preview in preview mode
+            const artifact = data.artifacts && data.artifacts[data.artifact || 'grid'];
+            const path = artifact ? artifact.path : data.thumbnailPath;
+
             // Create a new Image object to preload the thumbnail
             const img = new Image();
-            img.src = '/thumbnails/' + data.thumbnailPath;
+            img.src = '/thumbnails/' + path;
             
             // Store reference to prevent garbage collection
             window.preloadedImage = img;